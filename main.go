@@ -3,11 +3,14 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/blent/beagle/pkg/auth"
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/pkg/streaming"
 	"github.com/blent/beagle/pkg/tracking"
 	"github.com/blent/beagle/server"
 	"github.com/blent/beagle/server/http"
 	"github.com/blent/beagle/server/storage"
-	"github.com/pkg/errors"
 	"os"
 	"strings"
 	"time"
@@ -16,15 +19,6 @@ import (
 var DefaultSettings = server.NewDefaultSettings()
 var Version = "undefined"
 
-var (
-	ErrInvalidName              = errors.New("name value must be non-empty string")
-	ErrRouteCollision           = errors.New("routes collision detected")
-	ErrStaticRoute              = errors.New("static route must be non-empty string")
-	ErrInvalidTtlDuration       = errors.New("ttl value must be greater than 0")
-	ErrInvalidHeartbeatInterval = errors.New("heartbeat value must be greater than 0")
-	ErrInvalidStorageConnection = errors.New("storage connection value must be non-empty string")
-)
-
 var (
 	help = flag.Bool(
 		"help",
@@ -36,16 +30,41 @@ var (
 		false,
 		"show version",
 	)
+	configFile = flag.String(
+		"config",
+		"",
+		"path to a YAML settings file, BEAGLE_CONFIG environment variable is used by default",
+	)
+	logLevel = flag.String(
+		"log-level",
+		DefaultSettings.Log.Level,
+		"log level: debug, info, warn or error",
+	)
 	name = flag.String(
 		"name",
 		DefaultSettings.Name,
 		"application name",
 	)
+	shutdownTimeout = flag.Int(
+		"shutdown-timeout",
+		int(DefaultSettings.ShutdownTimeout/time.Second),
+		"time in seconds given to flush pending deliveries and close connections on shutdown",
+	)
 	httpEnable = flag.Bool(
 		"http",
 		DefaultSettings.Http.Enabled,
 		"enables http server",
 	)
+	httpAddress = flag.String(
+		"http-address",
+		DefaultSettings.Http.Address,
+		"http server listen address, all addresses by default",
+	)
+	httpInterface = flag.String(
+		"http-interface",
+		DefaultSettings.Http.Interface,
+		"network interface the http server listens on, overrides the address",
+	)
 	httpPort = flag.Int(
 		"http-port",
 		DefaultSettings.Http.Port,
@@ -65,6 +84,76 @@ var (
 		DefaultSettings.Http.Static.Route,
 		"http server static files route",
 	)
+	httpTls = flag.Bool(
+		"http-tls",
+		DefaultSettings.Http.Tls.Enabled,
+		"enables https",
+	)
+	httpTlsCertificate = flag.String(
+		"http-tls-certificate",
+		DefaultSettings.Http.Tls.Certificate,
+		"https certificate file",
+	)
+	httpTlsKey = flag.String(
+		"http-tls-key",
+		DefaultSettings.Http.Tls.Key,
+		"https private key file",
+	)
+	httpTlsSelfSigned = flag.Bool(
+		"http-tls-self-signed",
+		DefaultSettings.Http.Tls.SelfSigned,
+		"generates a self-signed certificate when the certificate and key files do not exist",
+	)
+	httpTlsClientCa = flag.String(
+		"http-tls-client-ca",
+		DefaultSettings.Http.Tls.ClientCa,
+		"ca file client certificates are verified against, enables mutual tls",
+	)
+	httpTlsClientAuth = flag.String(
+		"http-tls-client-auth",
+		DefaultSettings.Http.Tls.ClientAuth,
+		"client certificate verification mode: optional or required",
+	)
+	httpMetrics = flag.Bool(
+		"http-metrics",
+		DefaultSettings.Http.Metrics.Enabled,
+		"exposes metrics in prometheus format",
+	)
+	httpMetricsRoute = flag.String(
+		"http-metrics-route",
+		DefaultSettings.Http.Metrics.Route,
+		"metrics route",
+	)
+	authEnable = flag.Bool(
+		"auth",
+		DefaultSettings.Auth.Enabled,
+		"enables authentication for the http api",
+	)
+	authSessionTtl = flag.Int(
+		"auth-session-ttl",
+		int(DefaultSettings.Auth.SessionTtl/time.Second),
+		"session duration in seconds",
+	)
+	deviceKind = flag.String(
+		"device",
+		DefaultSettings.Device.Kind,
+		"device to scan with: ble, replay, simulated or none",
+	)
+	deviceHci = flag.Int(
+		"device-hci",
+		DefaultSettings.Device.Hci,
+		"host controller interface index of a ble device, e.g. 1 is hci1",
+	)
+	deviceReplay = flag.String(
+		"device-replay",
+		DefaultSettings.Device.Replay,
+		"file of recorded advertisements replayed by a replay device",
+	)
+	deviceScenario = flag.String(
+		"device-scenario",
+		DefaultSettings.Device.Simulated.Scenario,
+		"YAML scenario file of a fleet simulated by a simulated device",
+	)
 	trackingTtl = flag.Int(
 		"tracking-ttl",
 		int(DefaultSettings.Tracking.Ttl/time.Second),
@@ -80,85 +169,279 @@ var (
 		DefaultSettings.Storage.ConnectionString,
 		"storage connection string",
 	)
+	deliveryRateLimit = flag.Float64(
+		"delivery-rate-limit",
+		DefaultSettings.Delivery.Policy.RateLimit,
+		"max deliveries per second per endpoint, 0 means no limit",
+	)
+	deliveryBurst = flag.Int(
+		"delivery-burst",
+		DefaultSettings.Delivery.Policy.Burst,
+		"max deliveries above the rate limit allowed at once per endpoint",
+	)
+	deliveryMaxInFlight = flag.Int(
+		"delivery-max-in-flight",
+		DefaultSettings.Delivery.Policy.MaxInFlight,
+		"max concurrent deliveries per endpoint, 0 means no limit",
+	)
+	deliveryTimeout = flag.Int(
+		"delivery-timeout",
+		int(DefaultSettings.Delivery.Policy.Timeout/time.Second),
+		"delivery timeout in seconds, 0 means no timeout",
+	)
+	deliveryBreakerThreshold = flag.Int(
+		"delivery-breaker-threshold",
+		DefaultSettings.Delivery.Policy.BreakerThreshold,
+		"consecutive failures after which deliveries to an endpoint are suspended, 0 disables the breaker",
+	)
+	deliveryBreakerCooldown = flag.Int(
+		"delivery-breaker-cooldown",
+		int(DefaultSettings.Delivery.Policy.BreakerCooldown/time.Second),
+		"time in seconds after which suspended deliveries to an endpoint are retried",
+	)
+	deliveryCommand = flag.Bool(
+		"delivery-command",
+		DefaultSettings.Delivery.Command.Enabled,
+		"enables delivery to local command endpoints",
+	)
+	deliveryCommandTimeout = flag.Int(
+		"delivery-command-timeout",
+		int(DefaultSettings.Delivery.Command.Timeout/time.Second),
+		"local command execution timeout in seconds",
+	)
+	deliveryCommandConcurrency = flag.Int(
+		"delivery-command-concurrency",
+		DefaultSettings.Delivery.Command.Concurrency,
+		"max number of concurrently running local commands",
+	)
+	streamHistory = flag.Int(
+		"stream-history",
+		DefaultSettings.Streaming.History,
+		"number of latest events replayed to event stream clients on connect",
+	)
+	streamBuffer = flag.Int(
+		"stream-buffer",
+		DefaultSettings.Streaming.Buffer,
+		"number of pending events after which a slow event stream client is dropped",
+	)
 )
 
-func setHttpSettings(settings *http.Settings) error {
-	settings.Enabled = *httpEnable
+func setHttpSettings(settings *http.Settings, isSet func(string) bool) {
+	if isSet("http") {
+		settings.Enabled = *httpEnable
+	}
+
+	if isSet("http-address") {
+		settings.Address = strings.TrimSpace(*httpAddress)
+	}
 
-	if !settings.Enabled {
-		return nil
+	if isSet("http-interface") {
+		settings.Interface = strings.TrimSpace(*httpInterface)
 	}
 
-	settings.Api.Route = strings.TrimSpace(*httpApiRoute)
-	settings.Port = *httpPort
-	settings.Headless = true
+	if isSet("http-port") {
+		settings.Port = *httpPort
+	}
 
-	httpStaticsDirVal := strings.TrimSpace(*httpStaticsDir)
+	if isSet("http-api-route") {
+		settings.Api.Route = strings.TrimSpace(*httpApiRoute)
+	}
 
-	if httpStaticsDirVal != "" {
-		settings.Static.Directory = httpStaticsDirVal
+	if isSet("http-static-dir") {
+		settings.Static.Directory = strings.TrimSpace(*httpStaticsDir)
+	}
+
+	if isSet("http-static-route") {
 		settings.Static.Route = strings.TrimSpace(*httpStaticsRoute)
+	}
 
-		if settings.Static.Route == settings.Api.Route {
-			return ErrRouteCollision
-		}
+	if isSet("http-tls") {
+		settings.Tls.Enabled = *httpTls
+	}
 
-		if settings.Static.Route == "" {
-			return ErrStaticRoute
-		}
+	if isSet("http-tls-certificate") {
+		settings.Tls.Certificate = strings.TrimSpace(*httpTlsCertificate)
+	}
+
+	if isSet("http-tls-key") {
+		settings.Tls.Key = strings.TrimSpace(*httpTlsKey)
+	}
+
+	if isSet("http-tls-self-signed") {
+		settings.Tls.SelfSigned = *httpTlsSelfSigned
+	}
+
+	if isSet("http-tls-client-ca") {
+		settings.Tls.ClientCa = strings.TrimSpace(*httpTlsClientCa)
+	}
+
+	if isSet("http-tls-client-auth") {
+		settings.Tls.ClientAuth = strings.TrimSpace(*httpTlsClientAuth)
+	}
+
+	if isSet("http-metrics") {
+		settings.Metrics.Enabled = *httpMetrics
+	}
+
+	if isSet("http-metrics-route") {
+		settings.Metrics.Route = strings.TrimSpace(*httpMetricsRoute)
+	}
+}
+
+func setAuthSettings(settings *auth.Settings, isSet func(string) bool) {
+	if isSet("auth") {
+		settings.Enabled = *authEnable
 	}
 
-	return nil
+	if isSet("auth-session-ttl") {
+		settings.SessionTtl = time.Second * time.Duration(*authSessionTtl)
+	}
 }
 
-func setTrackingSettings(settings *tracking.Settings) error {
-	trackingTtlVal := *trackingTtl
+func setDeviceSettings(settings *devices.Settings, isSet func(string) bool) {
+	if isSet("device") {
+		settings.Kind = strings.TrimSpace(*deviceKind)
+	}
+
+	if isSet("device-hci") {
+		settings.Hci = *deviceHci
+	}
 
-	if trackingTtlVal < 0 {
-		return ErrInvalidTtlDuration
+	if isSet("device-replay") {
+		settings.Replay = strings.TrimSpace(*deviceReplay)
 	}
 
-	trackingHeartbeat := *trackingHeartbeat
+	if isSet("device-scenario") {
+		settings.Simulated.Scenario = strings.TrimSpace(*deviceScenario)
+	}
+}
 
-	if trackingHeartbeat < 0 {
-		return ErrInvalidHeartbeatInterval
+func setTrackingSettings(settings *tracking.Settings, isSet func(string) bool) {
+	if isSet("tracking-ttl") {
+		settings.Ttl = time.Second * time.Duration(*trackingTtl)
 	}
 
-	settings.Ttl = time.Second * time.Duration(trackingTtlVal)
-	settings.Heartbeat = time.Second * time.Duration(trackingHeartbeat)
+	if isSet("tracking-heartbeat") {
+		settings.Heartbeat = time.Second * time.Duration(*trackingHeartbeat)
+	}
+}
 
-	return nil
+func setStorageSettings(settings *storage.Settings, isSet func(string) bool) {
+	if isSet("storage-connection") {
+		settings.ConnectionString = strings.TrimSpace(*storageConnection)
+	}
 }
 
-func setStorageSettings(settings *storage.Settings) error {
-	settings.ConnectionString = strings.TrimSpace(*storageConnection)
+func setDeliverySettings(settings *delivery.Settings, isSet func(string) bool) {
+	if isSet("delivery-rate-limit") {
+		settings.Policy.RateLimit = *deliveryRateLimit
+	}
+
+	if isSet("delivery-burst") {
+		settings.Policy.Burst = *deliveryBurst
+	}
+
+	if isSet("delivery-max-in-flight") {
+		settings.Policy.MaxInFlight = *deliveryMaxInFlight
+	}
+
+	if isSet("delivery-timeout") {
+		settings.Policy.Timeout = time.Second * time.Duration(*deliveryTimeout)
+	}
+
+	if isSet("delivery-breaker-threshold") {
+		settings.Policy.BreakerThreshold = *deliveryBreakerThreshold
+	}
+
+	if isSet("delivery-breaker-cooldown") {
+		settings.Policy.BreakerCooldown = time.Second * time.Duration(*deliveryBreakerCooldown)
+	}
 
-	if settings.ConnectionString == "" {
-		return ErrInvalidStorageConnection
+	if isSet("delivery-command") {
+		settings.Command.Enabled = *deliveryCommand
 	}
 
-	return nil
+	if isSet("delivery-command-timeout") {
+		settings.Command.Timeout = time.Second * time.Duration(*deliveryCommandTimeout)
+	}
+
+	if isSet("delivery-command-concurrency") {
+		settings.Command.Concurrency = *deliveryCommandConcurrency
+	}
 }
 
+func setStreamingSettings(settings *streaming.Settings, isSet func(string) bool) {
+	if isSet("stream-history") {
+		settings.History = *streamHistory
+	}
+
+	if isSet("stream-buffer") {
+		settings.Buffer = *streamBuffer
+	}
+}
+
+// setFlags overrides settings by flags passed explicitly
+func setFlags(settings *server.Settings) {
+	passed := make(map[string]bool)
+
+	flag.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+
+	isSet := func(name string) bool {
+		return passed[name]
+	}
+
+	if isSet("name") {
+		settings.Name = strings.TrimSpace(*name)
+	}
+
+	if isSet("shutdown-timeout") {
+		settings.ShutdownTimeout = time.Second * time.Duration(*shutdownTimeout)
+	}
+
+	if isSet("log-level") {
+		settings.Log.Level = strings.TrimSpace(*logLevel)
+	}
+
+	setHttpSettings(settings.Http, isSet)
+	setAuthSettings(settings.Auth, isSet)
+	setDeviceSettings(settings.Device, isSet)
+	setTrackingSettings(settings.Tracking, isSet)
+	setStorageSettings(settings.Storage, isSet)
+	setDeliverySettings(settings.Delivery, isSet)
+	setStreamingSettings(settings.Streaming, isSet)
+}
+
+func getConfigPath() string {
+	if *configFile != "" {
+		return *configFile
+	}
+
+	return os.Getenv(server.EnvironmentPrefix + "_CONFIG")
+}
+
+// createSettings merges defaults, the settings file, environment variables and flags,
+// each of them overriding the previous ones
 func createSettings() (*server.Settings, error) {
 	res := server.NewDefaultSettings()
 
 	res.Version = Version
-	res.Name = strings.TrimSpace(*name)
 
-	if res.Name == "" {
-		return nil, ErrInvalidName
+	if path := getConfigPath(); path != "" {
+		if err := server.LoadSettingsFile(res, path); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := setHttpSettings(res.Http); err != nil {
+	// creates sections missing in the file, so flags can be applied to them
+	if err := server.ApplyEnvironment(res, os.LookupEnv); err != nil {
 		return nil, err
 	}
 
-	if err := setTrackingSettings(res.Tracking); err != nil {
-		return nil, err
-	}
+	setFlags(res)
 
-	if err := setStorageSettings(res.Storage); err != nil {
+	if err := res.Validate(); err != nil {
 		return nil, err
 	}
 
@@ -180,12 +463,6 @@ func main() {
 		return
 	}
 
-	if os.Geteuid() != 0 {
-		fmt.Println(os.ErrPermission.Error())
-		os.Exit(1)
-		return
-	}
-
 	settings, err := createSettings()
 
 	if err != nil {
@@ -202,7 +479,7 @@ func main() {
 		return
 	}
 
-	if err := app.Run(); err != nil {
+	if err := app.WatchSettings(getConfigPath(), createSettings).Run(); err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
 		return
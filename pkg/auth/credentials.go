@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	SESSION_TOKEN_PREFIX = "bgs_"
+	API_KEY_PREFIX       = "bgk_"
+
+	tokenSize          = 32
+	keyDisplayedLength = 8
+	minPasswordLength  = 8
+)
+
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrWeakPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func ComparePassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// GenerateToken returns a random token with a given prefix
+func GenerateToken(prefix string) (string, error) {
+	buf := make([]byte, tokenSize)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// HashToken returns a digest of a token stored instead of the token itself
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// NewApiKey generates a key for a given user.
+// The returned token is shown once, only its hash is stored.
+func NewApiKey(userId uint64, name string) (string, *ApiKey, error) {
+	token, err := GenerateToken(API_KEY_PREFIX)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &ApiKey{
+		Name:   name,
+		Prefix: token[:len(API_KEY_PREFIX)+keyDisplayedLength],
+		Hash:   HashToken(token),
+		UserId: userId,
+	}, nil
+}
+
+func isApiKey(token string) bool {
+	return strings.HasPrefix(token, API_KEY_PREFIX)
+}
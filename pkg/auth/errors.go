@@ -0,0 +1,9 @@
+package auth
+
+import "github.com/pkg/errors"
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUnauthenticated    = errors.New("missing or invalid credentials")
+	ErrWeakPassword       = errors.New("password must be at least 8 characters long")
+)
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type (
+	Store interface {
+		GetUser(id uint64) (*User, error)
+		GetUserByName(username string) (*User, error)
+		CountUsers() (uint64, error)
+		CreateUser(user *User) (uint64, error)
+		GetApiKeyByHash(hash string) (*ApiKey, error)
+		CreateSession(session *Session) (uint64, error)
+		GetSessionByHash(hash string) (*Session, error)
+		DeleteSessionByHash(hash string) error
+		DeleteExpiredSessions(now time.Time) error
+	}
+
+	Service struct {
+		logger   *zap.Logger
+		store    Store
+		settings *Settings
+		// compared against when a user does not exist, so the response time does not reveal it
+		dummyHash string
+		dummyOnce *sync.Once
+	}
+)
+
+func NewService(logger *zap.Logger, store Store, settings *Settings) *Service {
+	return &Service{
+		logger:    logger,
+		store:     store,
+		settings:  settings,
+		dummyOnce: &sync.Once{},
+	}
+}
+
+// Bootstrap creates an admin if there are no users yet
+func (s *Service) Bootstrap() error {
+	count, err := s.store.CountUsers()
+
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	password := s.settings.Admin.Password
+	generated := password == ""
+
+	if generated {
+		password, err = GenerateToken("")
+
+		if err != nil {
+			return err
+		}
+
+		password = password[:16]
+	}
+
+	hash, err := HashPassword(password)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.store.CreateUser(&User{
+		Username:     s.settings.Admin.Username,
+		PasswordHash: hash,
+		Role:         ROLE_ADMIN,
+		Enabled:      true,
+		CreatedAt:    time.Now(),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if generated {
+		s.logger.Warn(
+			"Created an admin with a generated password, change it after the first login",
+			zap.String("username", s.settings.Admin.Username),
+			zap.String("password", password),
+		)
+	} else {
+		s.logger.Info(
+			"Created an admin",
+			zap.String("username", s.settings.Admin.Username),
+		)
+	}
+
+	return nil
+}
+
+// Login checks user credentials and starts a new session.
+// The returned token is never stored, only its hash is.
+func (s *Service) Login(username, password string) (string, *Session, *User, error) {
+	user, err := s.store.GetUserByName(username)
+
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if user == nil {
+		ComparePassword(s.getDummyHash(), password)
+
+		return "", nil, nil, ErrInvalidCredentials
+	}
+
+	if !ComparePassword(user.PasswordHash, password) || !user.Enabled {
+		return "", nil, nil, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+
+	if err := s.store.DeleteExpiredSessions(now); err != nil {
+		s.logger.Error(
+			"Failed to delete expired sessions",
+			zap.Error(err),
+		)
+	}
+
+	token, err := GenerateToken(SESSION_TOKEN_PREFIX)
+
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	session := &Session{
+		Hash:      HashToken(token),
+		UserId:    user.Id,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.settings.SessionTtl),
+	}
+
+	session.Id, err = s.store.CreateSession(session)
+
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	s.logger.Info(
+		"User logged in",
+		zap.String("username", user.Username),
+	)
+
+	return token, session, user, nil
+}
+
+func (s *Service) Logout(token string) error {
+	if isApiKey(token) {
+		return nil
+	}
+
+	return s.store.DeleteSessionByHash(HashToken(token))
+}
+
+// Authenticate returns a user owning a given session token or api key
+func (s *Service) Authenticate(token string) (*User, error) {
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	var userId uint64
+
+	if isApiKey(token) {
+		key, err := s.store.GetApiKeyByHash(HashToken(token))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if key == nil {
+			return nil, ErrUnauthenticated
+		}
+
+		userId = key.UserId
+	} else {
+		session, err := s.store.GetSessionByHash(HashToken(token))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if session == nil || session.IsExpired(time.Now()) {
+			return nil, ErrUnauthenticated
+		}
+
+		userId = session.UserId
+	}
+
+	user, err := s.store.GetUser(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !user.Enabled {
+		return nil, ErrUnauthenticated
+	}
+
+	return user, nil
+}
+
+// AuthenticateCertificate returns a user named after a common name of a verified client certificate
+func (s *Service) AuthenticateCertificate(commonName string) (*User, error) {
+	if commonName == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	user, err := s.store.GetUserByName(commonName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !user.Enabled {
+		return nil, ErrUnauthenticated
+	}
+
+	return user, nil
+}
+
+func (s *Service) getDummyHash() string {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = HashPassword("beagle-dummy-password")
+	})
+
+	return s.dummyHash
+}
@@ -0,0 +1,263 @@
+package auth_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type memoryStore struct {
+	mu       sync.Mutex
+	users    []*auth.User
+	keys     []*auth.ApiKey
+	sessions []*auth.Session
+}
+
+func (s *memoryStore) GetUser(id uint64) (*auth.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Id == id {
+			return user, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *memoryStore) GetUserByName(username string) (*auth.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *memoryStore) CountUsers() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return uint64(len(s.users)), nil
+}
+
+func (s *memoryStore) CreateUser(user *auth.User) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user.Id = uint64(len(s.users) + 1)
+	s.users = append(s.users, user)
+
+	return user.Id, nil
+}
+
+func (s *memoryStore) GetApiKeyByHash(hash string) (*auth.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *memoryStore) CreateSession(session *auth.Session) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.Id = uint64(len(s.sessions) + 1)
+	s.sessions = append(s.sessions, session)
+
+	return session.Id, nil
+}
+
+func (s *memoryStore) GetSessionByHash(hash string) (*auth.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.Hash == hash {
+			return session, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *memoryStore) DeleteSessionByHash(hash string) error {
+	return s.deleteSessions(func(session *auth.Session) bool {
+		return session.Hash == hash
+	})
+}
+
+func (s *memoryStore) DeleteExpiredSessions(now time.Time) error {
+	return s.deleteSessions(func(session *auth.Session) bool {
+		return session.IsExpired(now)
+	})
+}
+
+func (s *memoryStore) deleteSessions(predicate func(*auth.Session) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := make([]*auth.Session, 0, len(s.sessions))
+
+	for _, session := range s.sessions {
+		if !predicate(session) {
+			active = append(active, session)
+		}
+	}
+
+	s.sessions = active
+
+	return nil
+}
+
+func createService(ttl time.Duration) (*auth.Service, *memoryStore) {
+	store := &memoryStore{}
+
+	return auth.NewService(zap.NewNop(), store, &auth.Settings{
+		Enabled:    true,
+		SessionTtl: ttl,
+		Admin: &auth.AdminSettings{
+			Username: "admin",
+			Password: "secret-password",
+		},
+	}), store
+}
+
+func TestBootstrap(t *testing.T) {
+	service, store := createService(time.Hour)
+
+	assert.NoError(t, service.Bootstrap())
+	assert.NoError(t, service.Bootstrap())
+
+	if assert.Len(t, store.users, 1, "admin is created once") {
+		assert.Equal(t, "admin", store.users[0].Username)
+		assert.Equal(t, auth.ROLE_ADMIN, store.users[0].Role)
+		assert.NotEqual(t, "secret-password", store.users[0].PasswordHash, "password is hashed")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	service, _ := createService(time.Hour)
+
+	assert.NoError(t, service.Bootstrap())
+
+	_, _, _, err := service.Login("admin", "wrong-password")
+	assert.Equal(t, auth.ErrInvalidCredentials, err)
+
+	_, _, _, err = service.Login("nobody", "secret-password")
+	assert.Equal(t, auth.ErrInvalidCredentials, err)
+
+	token, session, user, err := service.Login("admin", "secret-password")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", user.Username)
+	assert.True(t, session.ExpiresAt.After(time.Now()))
+
+	authenticated, err := service.Authenticate(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id, authenticated.Id)
+
+	assert.NoError(t, service.Logout(token))
+
+	_, err = service.Authenticate(token)
+	assert.Equal(t, auth.ErrUnauthenticated, err, "session is ended")
+}
+
+func TestLoginDisabledUser(t *testing.T) {
+	service, store := createService(time.Hour)
+
+	assert.NoError(t, service.Bootstrap())
+
+	store.users[0].Enabled = false
+
+	_, _, _, err := service.Login("admin", "secret-password")
+
+	assert.Equal(t, auth.ErrInvalidCredentials, err)
+}
+
+func TestAuthenticateExpiredSession(t *testing.T) {
+	service, _ := createService(time.Millisecond * 10)
+
+	assert.NoError(t, service.Bootstrap())
+
+	token, _, _, err := service.Login("admin", "secret-password")
+	assert.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 20)
+
+	_, err = service.Authenticate(token)
+	assert.Equal(t, auth.ErrUnauthenticated, err)
+}
+
+func TestAuthenticateApiKey(t *testing.T) {
+	service, store := createService(time.Hour)
+
+	assert.NoError(t, service.Bootstrap())
+
+	token, key, err := auth.NewApiKey(store.users[0].Id, "ci")
+
+	assert.NoError(t, err)
+	assert.NotContains(t, key.Hash, token)
+	assert.True(t, len(key.Prefix) < len(token))
+
+	store.keys = append(store.keys, key)
+
+	user, err := service.Authenticate(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", user.Username)
+
+	_, err = service.Authenticate(auth.API_KEY_PREFIX + "unknown")
+	assert.Equal(t, auth.ErrUnauthenticated, err)
+
+	_, err = service.Authenticate("")
+	assert.Equal(t, auth.ErrUnauthenticated, err)
+}
+
+func TestAuthenticateCertificate(t *testing.T) {
+	service, _ := createService(time.Hour)
+
+	assert.NoError(t, service.Bootstrap())
+
+	user, err := service.AuthenticateCertificate("admin")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", user.Username)
+
+	_, err = service.AuthenticateCertificate("unknown")
+	assert.Equal(t, auth.ErrUnauthenticated, err)
+}
+
+func TestUserAllows(t *testing.T) {
+	admin := &auth.User{Role: auth.ROLE_ADMIN, Enabled: true}
+	viewer := &auth.User{Role: auth.ROLE_VIEWER, Enabled: true}
+	disabled := &auth.User{Role: auth.ROLE_ADMIN, Enabled: false}
+
+	assert.True(t, admin.Allows(auth.ROLE_ADMIN))
+	assert.True(t, admin.Allows(auth.ROLE_VIEWER))
+	assert.True(t, viewer.Allows(auth.ROLE_VIEWER))
+	assert.False(t, viewer.Allows(auth.ROLE_ADMIN))
+	assert.False(t, disabled.Allows(auth.ROLE_VIEWER))
+}
+
+func TestHashPasswordTooShort(t *testing.T) {
+	_, err := auth.HashPassword("short")
+
+	assert.Equal(t, auth.ErrWeakPassword, err)
+}
@@ -0,0 +1,18 @@
+package auth
+
+import "time"
+
+type (
+	Settings struct {
+		Enabled    bool           `yaml:"enabled"`
+		SessionTtl time.Duration  `yaml:"sessionTtl"`
+		Admin      *AdminSettings `yaml:"admin"`
+	}
+
+	// AdminSettings describes an admin created on the first start.
+	// A random password is generated and logged when it is empty.
+	AdminSettings struct {
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	}
+)
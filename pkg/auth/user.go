@@ -0,0 +1,58 @@
+package auth
+
+import "time"
+
+const (
+	ROLE_ADMIN  = "admin"
+	ROLE_VIEWER = "viewer"
+)
+
+type (
+	User struct {
+		Id           uint64    `json:"id"`
+		Username     string    `json:"username"`
+		PasswordHash string    `json:"-"`
+		Role         string    `json:"role"`
+		Enabled      bool      `json:"enabled"`
+		CreatedAt    time.Time `json:"createdAt"`
+	}
+
+	ApiKey struct {
+		Id        uint64    `json:"id"`
+		Name      string    `json:"name"`
+		Prefix    string    `json:"prefix"`
+		Hash      string    `json:"-"`
+		UserId    uint64    `json:"userId"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+
+	Session struct {
+		Id        uint64    `json:"-"`
+		Hash      string    `json:"-"`
+		UserId    uint64    `json:"userId"`
+		CreatedAt time.Time `json:"createdAt"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+)
+
+func IsSupportedRole(role string) bool {
+	return role == ROLE_ADMIN || role == ROLE_VIEWER
+}
+
+// Allows tells whether the user has a given role.
+// Admins have all roles.
+func (u *User) Allows(role string) bool {
+	if u == nil || !u.Enabled {
+		return false
+	}
+
+	if u.Role == ROLE_ADMIN {
+		return true
+	}
+
+	return u.Role == role
+}
+
+func (s *Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const apiKeyHeader = "X-Api-Key"
+
+type (
+	// Client calls the REST API of beagle, see /api/openapi.json for the description of routes
+	Client struct {
+		baseUrl string
+		http    *http.Client
+		token   string
+		apiKey  string
+	}
+
+	// FieldError describes an invalid field of a request
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+
+	// Error is returned for failed requests
+	Error struct {
+		Status  int           `json:"-"`
+		Code    string        `json:"code"`
+		Message string        `json:"message"`
+		Fields  []*FieldError `json:"fields,omitempty"`
+	}
+)
+
+// New creates a client of an api with a given base url, e.g. http://localhost:8080/api
+func New(baseUrl string) *Client {
+	return &Client{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// UseHttpClient replaces the default http client, e.g. to trust a self-signed certificate
+func (c *Client) UseHttpClient(client *http.Client) *Client {
+	if client != nil {
+		c.http = client
+	}
+
+	return c
+}
+
+// UseToken authenticates requests by a session token
+func (c *Client) UseToken(token string) *Client {
+	c.token = token
+
+	return c
+}
+
+// UseApiKey authenticates requests by an api key
+func (c *Client) UseApiKey(key string) *Client {
+	c.apiKey = key
+
+	return c
+}
+
+func (err *Error) Error() string {
+	if len(err.Fields) == 0 {
+		return fmt.Sprintf("%d %s", err.Status, err.Message)
+	}
+
+	fields := make([]string, 0, len(err.Fields))
+
+	for _, field := range err.Fields {
+		fields = append(fields, field.Field+": "+field.Message)
+	}
+
+	return fmt.Sprintf("%d %s: %s", err.Status, err.Message, strings.Join(fields, ", "))
+}
+
+// GetSpec returns the OpenAPI description of the api
+func (c *Client) GetSpec() (map[string]interface{}, error) {
+	var spec map[string]interface{}
+
+	if err := c.do(http.MethodGet, "/openapi.json", nil, nil, &spec); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// IsNotFound returns true if an error is a response with the 404 status
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict returns true if an error is a response with the 409 status
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// IsInvalid returns true if an error is a response with the 422 status
+func IsInvalid(err error) bool {
+	return hasStatus(err, http.StatusUnprocessableEntity)
+}
+
+func hasStatus(err error, status int) bool {
+	res, ok := errors.Cause(err).(*Error)
+
+	return ok && res.Status == status
+}
+
+// do sends a request with an optional json body and decodes a json response into out, if given
+func (c *Client) do(method, route string, query url.Values, body, out interface{}) error {
+	res, err := c.send(method, route, query, body)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(ioutil.Discard, res.Body)
+
+		return err
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return errors.Wrapf(err, "%s %s: invalid response", method, route)
+	}
+
+	return nil
+}
+
+// doId sends a request creating an entity and returns its id
+func (c *Client) doId(method, route string, body interface{}) (uint64, error) {
+	res, err := c.send(method, route, nil, body)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close()
+
+	text, err := ioutil.ReadAll(res.Body)
+
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseUint(strings.TrimSpace(string(text)), 10, 64)
+
+	if err != nil {
+		return 0, errors.Wrapf(err, "%s %s: invalid id", method, route)
+	}
+
+	return id, nil
+}
+
+func (c *Client) send(method, route string, query url.Values, body interface{}) (*http.Response, error) {
+	if body == nil {
+		return c.sendRaw(method, route, query, "", nil)
+	}
+
+	data, err := json.Marshal(body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.sendRaw(method, route, query, "application/json", bytes.NewReader(data))
+}
+
+// sendRaw sends a body of a given content type, failed responses are returned as errors
+func (c *Client) sendRaw(method, route string, query url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	address := c.baseUrl + route
+
+	if len(query) > 0 {
+		address += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, address, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	if c.apiKey != "" {
+		req.Header.Set(apiKeyHeader, c.apiKey)
+	}
+
+	res, err := c.http.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+
+		return nil, toError(res)
+	}
+
+	return res, nil
+}
+
+func toError(res *http.Response) error {
+	failure := &Error{Status: res.StatusCode}
+
+	if err := json.NewDecoder(res.Body).Decode(failure); err != nil || failure.Message == "" {
+		failure.Message = http.StatusText(res.StatusCode)
+	}
+
+	return failure
+}
+
+// ListQuery contains pagination and sorting params of lists, zero values are omitted
+type ListQuery struct {
+	Take       uint64
+	Skip       uint64
+	Sort       string
+	Descending bool
+	// Cursor of the next page returned by a previous page
+	Cursor string
+}
+
+func (q *ListQuery) values() url.Values {
+	values := url.Values{}
+
+	if q == nil {
+		return values
+	}
+
+	setUint(values, "take", q.Take)
+	setUint(values, "skip", q.Skip)
+	setString(values, "sort", q.Sort)
+	setString(values, "cursor", q.Cursor)
+
+	if q.Descending {
+		values.Set("order", "desc")
+	}
+
+	return values
+}
+
+func setString(values url.Values, name, value string) {
+	if value != "" {
+		values.Set(name, value)
+	}
+}
+
+func setUint(values url.Values, name string, value uint64) {
+	if value > 0 {
+		values.Set(name, strconv.FormatUint(value, 10))
+	}
+}
+
+func setBool(values url.Values, name string, value *bool) {
+	if value != nil {
+		values.Set(name, strconv.FormatBool(*value))
+	}
+}
+
+func idRoute(prefix string, id uint64, suffix ...string) string {
+	return strings.Join(append([]string{prefix, strconv.FormatUint(id, 10)}, suffix...), "/")
+}
@@ -0,0 +1,71 @@
+package client_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blent/beagle/pkg/client"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/registry/endpoint":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"code":"unprocessable_entity","message":"invalid fields","fields":[{"field":"url","message":"is required"}]}`))
+		case "/api/registry/endpoint/1":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"endpoint: not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL + "/api/")
+
+	_, err := c.CreateEndpoint(&notification.Endpoint{Name: "hook"})
+	assert.True(t, client.IsInvalid(err), "validation error")
+	assert.EqualError(t, err, "422 invalid fields: url: is required")
+
+	_, err = c.GetEndpoint(1)
+	assert.EqualError(t, err, "502 Bad Gateway", "error without a body")
+
+	_, err = c.GetEndpoint(2)
+	assert.True(t, client.IsNotFound(err))
+	assert.False(t, client.IsConflict(err))
+}
+
+func TestClientCredentials(t *testing.T) {
+	headers := make(chan http.Header, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		headers <- req.Header
+		w.Write([]byte("42"))
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL).UseToken("token")
+
+	id, err := c.CreateEndpoint(&notification.Endpoint{Name: "hook"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), id)
+
+	header := <-headers
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+
+	c.UseToken("").UseApiKey("key")
+
+	_, err = c.CreateEndpoint(&notification.Endpoint{Name: "hook"})
+	assert.NoError(t, err)
+
+	header = <-headers
+	assert.Empty(t, header.Get("Authorization"))
+	assert.Equal(t, "key", header.Get("X-Api-Key"))
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/events"
+	"github.com/blent/beagle/pkg/monitoring/activity"
+	"github.com/blent/beagle/pkg/monitoring/system"
+)
+
+type (
+	ActivityPage struct {
+		Items    []*activity.Record `json:"items"`
+		Quantity uint64             `json:"quantity"`
+	}
+
+	// RegistryStats describes the registry lookup cache
+	RegistryStats struct {
+		Targets       int    `json:"targets"`
+		Subscribers   int    `json:"subscribers"`
+		Groups        int    `json:"groups"`
+		Hits          uint64 `json:"hits"`
+		Misses        uint64 `json:"misses"`
+		Invalidations uint64 `json:"invalidations"`
+	}
+
+	// StartupStep is a state of a server initialization step, Duration is in milliseconds
+	StartupStep struct {
+		Name      string     `json:"name"`
+		State     string     `json:"state"`
+		Optional  bool       `json:"optional"`
+		DependsOn []string   `json:"dependsOn"`
+		StartedAt *time.Time `json:"startedAt"`
+		Duration  float64    `json:"duration"`
+		Error     string     `json:"error"`
+		LateState string     `json:"lateState"`
+	}
+)
+
+const monitoringRoute = "/monitoring"
+
+// GetActivity returns active peripherals, registered and not registered
+func (c *Client) GetActivity(take, skip uint64) (*ActivityPage, error) {
+	var page ActivityPage
+
+	query := &ListQuery{Take: take, Skip: skip}
+
+	if err := c.do(http.MethodGet, monitoringRoute+"/activity", query.values(), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *Client) GetSystemStats() (*system.Stats, error) {
+	var stats system.Stats
+
+	if err := c.do(http.MethodGet, monitoringRoute+"/system", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// GetDeliveryStates returns circuit breaker states and in-flight deliveries of endpoints
+func (c *Client) GetDeliveryStates() ([]*delivery.GateState, error) {
+	var page struct {
+		Items []*delivery.GateState `json:"items"`
+	}
+
+	if err := c.do(http.MethodGet, monitoringRoute+"/delivery", nil, nil, &page); err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+// GetListenerStats returns stats of internal event listeners by their source
+func (c *Client) GetListenerStats() (map[string]events.Stats, error) {
+	var stats map[string]events.Stats
+
+	if err := c.do(http.MethodGet, monitoringRoute+"/listeners", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (c *Client) GetRegistryStats() (*RegistryStats, error) {
+	var stats RegistryStats
+
+	if err := c.do(http.MethodGet, monitoringRoute+"/registry", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// GetStartupStatus returns initialization steps of the server in the order they run
+func (c *Client) GetStartupStatus() ([]*StartupStep, error) {
+	var page struct {
+		Items []*StartupStep `json:"items"`
+	}
+
+	if err := c.do(http.MethodGet, monitoringRoute+"/startup", nil, nil, &page); err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
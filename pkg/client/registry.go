@@ -0,0 +1,442 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Peripheral is a registration of one or many peripherals with their subscribers.
+	// Omitted major and minor numbers of a Pattern match any, MajorMax and MinorMax turn them into ranges.
+	// Address registers all peripherals with a given address prefix instead.
+	Peripheral struct {
+		Id          uint64                     `json:"id,omitempty"`
+		Kind        string                     `json:"kind"`
+		Name        string                     `json:"name"`
+		Enabled     bool                       `json:"enabled"`
+		Pattern     bool                       `json:"pattern,omitempty"`
+		Uuid        string                     `json:"uuid,omitempty"`
+		Major       uint16                     `json:"major,omitempty"`
+		MajorMax    uint16                     `json:"majorMax,omitempty"`
+		Minor       uint16                     `json:"minor,omitempty"`
+		MinorMax    uint16                     `json:"minorMax,omitempty"`
+		Address     string                     `json:"address,omitempty"`
+		Subscribers []*notification.Subscriber `json:"subscribers"`
+	}
+
+	// Group contains peripherals by ids and, if Uuid is set, all iBeacons with the uuid
+	Group struct {
+		Id          uint64                     `json:"id,omitempty"`
+		Name        string                     `json:"name"`
+		Enabled     bool                       `json:"enabled"`
+		Uuid        string                     `json:"uuid,omitempty"`
+		Major       uint16                     `json:"major,omitempty"`
+		Members     []uint64                   `json:"members"`
+		Subscribers []*notification.Subscriber `json:"subscribers"`
+	}
+
+	// Claim is an unregistered iBeacon found by a pairing, its peripheral can be passed to CreatePeripheral
+	Claim struct {
+		Peripheral *Peripheral `json:"peripheral"`
+		Address    string      `json:"address"`
+		Rssi       float64     `json:"rssi"`
+		Accuracy   float64     `json:"accuracy"`
+	}
+
+	PeripheralQuery struct {
+		ListQuery
+		// Search matches a part of a name or a key
+		Search  string
+		Kind    string
+		Enabled *bool
+		Uuid    string
+		Major   uint16
+		// Event matches peripherals with subscribers of the event
+		Event string
+	}
+
+	EndpointQuery struct {
+		ListQuery
+		// Name matches names, * is a wildcard
+		Name string
+		// Search matches a part of a name or an url
+		Search string
+		Kind   string
+	}
+
+	SubscriberQuery struct {
+		ListQuery
+		Search       string
+		Events       []string
+		Enabled      *bool
+		PeripheralId uint64
+		GroupId      uint64
+		EndpointId   uint64
+		RuleId       uint64
+	}
+
+	// ImportedPeripheral is a row of an import, subscribers reference endpoints by name.
+	// Omitted kind is iBeacon, omitted enabled is true.
+	ImportedPeripheral struct {
+		Kind        string                `json:"kind,omitempty"`
+		Name        string                `json:"name"`
+		Uuid        string                `json:"uuid,omitempty"`
+		Major       uint16                `json:"major,omitempty"`
+		MajorMax    uint16                `json:"majorMax,omitempty"`
+		Minor       uint16                `json:"minor,omitempty"`
+		MinorMax    uint16                `json:"minorMax,omitempty"`
+		Address     string                `json:"address,omitempty"`
+		Enabled     *bool                 `json:"enabled,omitempty"`
+		Subscribers []*ImportedSubscriber `json:"subscribers,omitempty"`
+	}
+
+	ImportedSubscriber struct {
+		Name     string              `json:"name"`
+		Event    string              `json:"event"`
+		Endpoint string              `json:"endpoint"`
+		Enabled  *bool               `json:"enabled,omitempty"`
+		Batch    *notification.Batch `json:"batch,omitempty"`
+	}
+
+	// ImportResult contains ids of created peripherals in the order of rows, dry runs return no ids
+	ImportResult struct {
+		Quantity uint64   `json:"quantity"`
+		Ids      []uint64 `json:"ids"`
+	}
+
+	PeripheralPage struct {
+		Items    []*tracking.Peripheral `json:"items"`
+		Quantity uint64                 `json:"quantity"`
+		Next     string                 `json:"next"`
+	}
+
+	GroupPage struct {
+		Items    []*tracking.Group `json:"items"`
+		Quantity uint64            `json:"quantity"`
+	}
+
+	EndpointPage struct {
+		Items    []*notification.Endpoint `json:"items"`
+		Quantity uint64                   `json:"quantity"`
+		Next     string                   `json:"next"`
+	}
+
+	SubscriberPage struct {
+		Items    []*notification.Subscriber `json:"items"`
+		Quantity uint64                     `json:"quantity"`
+		Next     string                     `json:"next"`
+	}
+)
+
+const registryRoute = "/registry"
+
+func (c *Client) FindPeripherals(query *PeripheralQuery) (*PeripheralPage, error) {
+	if query == nil {
+		query = &PeripheralQuery{}
+	}
+
+	values := query.values()
+
+	setString(values, "search", query.Search)
+	setString(values, "kind", query.Kind)
+	setBool(values, "enabled", query.Enabled)
+	setString(values, "uuid", query.Uuid)
+	setUint(values, "major", uint64(query.Major))
+	setString(values, "event", query.Event)
+
+	var page PeripheralPage
+
+	if err := c.do(http.MethodGet, registryRoute+"/peripherals", values, nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *Client) GetPeripheral(id uint64) (*Peripheral, error) {
+	var peripheral Peripheral
+
+	if err := c.do(http.MethodGet, idRoute(registryRoute+"/peripheral", id), nil, nil, &peripheral); err != nil {
+		return nil, err
+	}
+
+	return &peripheral, nil
+}
+
+func (c *Client) CreatePeripheral(peripheral *Peripheral) (uint64, error) {
+	return c.doId(http.MethodPost, registryRoute+"/peripheral", peripheral)
+}
+
+func (c *Client) UpdatePeripheral(peripheral *Peripheral) error {
+	return c.do(http.MethodPut, registryRoute+"/peripheral", nil, peripheral, nil)
+}
+
+func (c *Client) DeletePeripheral(id uint64) error {
+	return c.do(http.MethodDelete, idRoute(registryRoute+"/peripheral", id), nil, nil, nil)
+}
+
+func (c *Client) DeletePeripherals(ids []uint64) error {
+	return c.do(http.MethodDelete, registryRoute+"/peripherals", nil, ids, nil)
+}
+
+// ImportPeripherals creates all peripherals or none, invalid rows are reported by fields of an error
+func (c *Client) ImportPeripherals(rows []*ImportedPeripheral, dryRun bool) (*ImportResult, error) {
+	var result ImportResult
+
+	if err := c.do(http.MethodPost, registryRoute+"/peripherals/import", dryRunValues(dryRun), rows, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ImportPeripheralsCsv is like ImportPeripherals, but reads rows from a csv with a header
+func (c *Client) ImportPeripheralsCsv(data io.Reader, dryRun bool) (*ImportResult, error) {
+	res, err := c.sendRaw(http.MethodPost, registryRoute+"/peripherals/import", dryRunValues(dryRun), "text/csv", data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	var result ImportResult
+
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "invalid response")
+	}
+
+	return &result, nil
+}
+
+// ExportPeripherals returns a csv of all peripherals, which can be imported back
+func (c *Client) ExportPeripherals() ([]byte, error) {
+	res, err := c.sendRaw(http.MethodGet, registryRoute+"/peripherals/export", nil, "", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	return ioutil.ReadAll(res.Body)
+}
+
+// ClaimPeripheral waits for the given number of seconds for an unregistered iBeacon brought close to the gateway.
+// It fails with a not found error if there was none. Windows over 30 seconds need a http client with a longer timeout.
+func (c *Client) ClaimPeripheral(seconds uint64, name string) (*Claim, error) {
+	var claim Claim
+
+	body := map[string]interface{}{"seconds": seconds, "name": name}
+
+	if err := c.do(http.MethodPost, registryRoute+"/claim", nil, body, &claim); err != nil {
+		return nil, err
+	}
+
+	return &claim, nil
+}
+
+func (c *Client) FindGroups(take, skip uint64) (*GroupPage, error) {
+	var page GroupPage
+
+	query := &ListQuery{Take: take, Skip: skip}
+
+	if err := c.do(http.MethodGet, registryRoute+"/groups", query.values(), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *Client) GetGroup(id uint64) (*Group, error) {
+	var group Group
+
+	if err := c.do(http.MethodGet, idRoute(registryRoute+"/group", id), nil, nil, &group); err != nil {
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+func (c *Client) CreateGroup(group *Group) (uint64, error) {
+	return c.doId(http.MethodPost, registryRoute+"/group", group)
+}
+
+func (c *Client) UpdateGroup(group *Group) error {
+	return c.do(http.MethodPut, registryRoute+"/group", nil, group, nil)
+}
+
+func (c *Client) DeleteGroup(id uint64) error {
+	return c.do(http.MethodDelete, idRoute(registryRoute+"/group", id), nil, nil, nil)
+}
+
+func (c *Client) DeleteGroups(ids []uint64) error {
+	return c.do(http.MethodDelete, registryRoute+"/groups", nil, ids, nil)
+}
+
+func (c *Client) FindEndpoints(query *EndpointQuery) (*EndpointPage, error) {
+	if query == nil {
+		query = &EndpointQuery{}
+	}
+
+	values := query.values()
+
+	setString(values, "name", query.Name)
+	setString(values, "search", query.Search)
+	setString(values, "kind", query.Kind)
+
+	var page EndpointPage
+
+	if err := c.do(http.MethodGet, registryRoute+"/endpoints", values, nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *Client) GetEndpoint(id uint64) (*notification.Endpoint, error) {
+	var endpoint notification.Endpoint
+
+	if err := c.do(http.MethodGet, idRoute(registryRoute+"/endpoint", id), nil, nil, &endpoint); err != nil {
+		return nil, err
+	}
+
+	return &endpoint, nil
+}
+
+// GetEndpointSubscribers returns all subscribers pointing at an endpoint, they are deleted along with it
+func (c *Client) GetEndpointSubscribers(id uint64) ([]*notification.Subscriber, error) {
+	var subscribers []*notification.Subscriber
+
+	if err := c.do(http.MethodGet, idRoute(registryRoute+"/endpoint", id, "subscribers"), nil, nil, &subscribers); err != nil {
+		return nil, err
+	}
+
+	return subscribers, nil
+}
+
+func (c *Client) CreateEndpoint(endpoint *notification.Endpoint) (uint64, error) {
+	return c.doId(http.MethodPost, registryRoute+"/endpoint", endpoint)
+}
+
+func (c *Client) UpdateEndpoint(endpoint *notification.Endpoint) error {
+	return c.do(http.MethodPut, registryRoute+"/endpoint", nil, endpoint, nil)
+}
+
+func (c *Client) DeleteEndpoint(id uint64) error {
+	return c.do(http.MethodDelete, idRoute(registryRoute+"/endpoint", id), nil, nil, nil)
+}
+
+func (c *Client) DeleteEndpoints(ids []uint64) error {
+	return c.do(http.MethodDelete, registryRoute+"/endpoints", nil, ids, nil)
+}
+
+// TestEndpoint sends a synthetic event to a saved endpoint, with dryRun the request is only rendered
+func (c *Client) TestEndpoint(id uint64, eventName string, dryRun bool) (*delivery.TestResult, error) {
+	var result delivery.TestResult
+
+	if err := c.do(http.MethodPost, idRoute(registryRoute+"/endpoint", id, "test"), testValues(eventName, dryRun), nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// TestUnsavedEndpoint sends a synthetic event to an endpoint which is not saved yet
+func (c *Client) TestUnsavedEndpoint(endpoint *notification.Endpoint, eventName string, dryRun bool) (*delivery.TestResult, error) {
+	var result delivery.TestResult
+
+	if err := c.do(http.MethodPost, registryRoute+"/endpoints/test", testValues(eventName, dryRun), endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) FindSubscribers(query *SubscriberQuery) (*SubscriberPage, error) {
+	if query == nil {
+		query = &SubscriberQuery{}
+	}
+
+	values := query.values()
+
+	setString(values, "search", query.Search)
+	setBool(values, "enabled", query.Enabled)
+	setUint(values, "peripheral", query.PeripheralId)
+	setUint(values, "group", query.GroupId)
+	setUint(values, "endpoint", query.EndpointId)
+	setUint(values, "rule", query.RuleId)
+
+	for _, event := range query.Events {
+		values.Add("event", event)
+	}
+
+	var page SubscriberPage
+
+	if err := c.do(http.MethodGet, registryRoute+"/subscribers", values, nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *Client) GetSubscriber(id uint64) (*notification.Subscriber, error) {
+	var subscriber notification.Subscriber
+
+	if err := c.do(http.MethodGet, idRoute(registryRoute+"/subscriber", id), nil, nil, &subscriber); err != nil {
+		return nil, err
+	}
+
+	return &subscriber, nil
+}
+
+// CreateSubscriber creates a subscriber of a peripheral given by TargetId or of a group given by GroupId
+func (c *Client) CreateSubscriber(subscriber *notification.Subscriber) (uint64, error) {
+	return c.doId(http.MethodPost, registryRoute+"/subscriber", subscriber)
+}
+
+// UpdateSubscriber updates a subscriber, it stays with its peripheral or group
+func (c *Client) UpdateSubscriber(subscriber *notification.Subscriber) error {
+	return c.do(http.MethodPut, registryRoute+"/subscriber", nil, subscriber, nil)
+}
+
+func (c *Client) DeleteSubscriber(id uint64) error {
+	return c.do(http.MethodDelete, idRoute(registryRoute+"/subscriber", id), nil, nil, nil)
+}
+
+func (c *Client) DeleteSubscribers(ids []uint64) error {
+	return c.do(http.MethodDelete, registryRoute+"/subscribers", nil, ids, nil)
+}
+
+func (c *Client) EnableSubscribers(ids []uint64) error {
+	return c.do(http.MethodPost, registryRoute+"/subscribers/enable", nil, ids, nil)
+}
+
+func (c *Client) DisableSubscribers(ids []uint64) error {
+	return c.do(http.MethodPost, registryRoute+"/subscribers/disable", nil, ids, nil)
+}
+
+func testValues(eventName string, dryRun bool) url.Values {
+	values := dryRunValues(dryRun)
+
+	setString(values, "event", eventName)
+
+	return values
+}
+
+func dryRunValues(dryRun bool) url.Values {
+	values := url.Values{}
+
+	values.Set("dryRun", strconv.FormatBool(dryRun))
+
+	return values
+}
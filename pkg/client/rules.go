@@ -0,0 +1,130 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+)
+
+type (
+	// Rule registers unregistered peripherals matched the same way as by a pattern Peripheral.
+	// NameTemplate may contain {kind}, {key}, {address}, {uuid}, {major} and {minor},
+	// subscribers are copied to every registered peripheral.
+	Rule struct {
+		Id                uint64                     `json:"id,omitempty"`
+		Name              string                     `json:"name"`
+		Enabled           bool                       `json:"enabled"`
+		Mode              string                     `json:"mode"`
+		NameTemplate      string                     `json:"nameTemplate"`
+		PeripheralEnabled bool                       `json:"peripheralEnabled"`
+		Uuid              string                     `json:"uuid,omitempty"`
+		Major             uint16                     `json:"major,omitempty"`
+		MajorMax          uint16                     `json:"majorMax,omitempty"`
+		Minor             uint16                     `json:"minor,omitempty"`
+		MinorMax          uint16                     `json:"minorMax,omitempty"`
+		Address           string                     `json:"address,omitempty"`
+		Subscribers       []*notification.Subscriber `json:"subscribers"`
+	}
+
+	CandidateQuery struct {
+		ListQuery
+		Status string
+		RuleId uint64
+	}
+
+	RulePage struct {
+		Items    []*tracking.Rule `json:"items"`
+		Quantity uint64           `json:"quantity"`
+	}
+
+	CandidatePage struct {
+		Items    []*tracking.Candidate `json:"items"`
+		Quantity uint64                `json:"quantity"`
+	}
+)
+
+func (c *Client) FindRules(take, skip uint64) (*RulePage, error) {
+	var page RulePage
+
+	query := &ListQuery{Take: take, Skip: skip}
+
+	if err := c.do(http.MethodGet, registryRoute+"/rules", query.values(), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *Client) GetRule(id uint64) (*Rule, error) {
+	var rule Rule
+
+	if err := c.do(http.MethodGet, idRoute(registryRoute+"/rule", id), nil, nil, &rule); err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (c *Client) CreateRule(rule *Rule) (uint64, error) {
+	return c.doId(http.MethodPost, registryRoute+"/rule", rule)
+}
+
+func (c *Client) UpdateRule(rule *Rule) error {
+	return c.do(http.MethodPut, registryRoute+"/rule", nil, rule, nil)
+}
+
+func (c *Client) DeleteRule(id uint64) error {
+	return c.do(http.MethodDelete, idRoute(registryRoute+"/rule", id), nil, nil, nil)
+}
+
+func (c *Client) DeleteRules(ids []uint64) error {
+	return c.do(http.MethodDelete, registryRoute+"/rules", nil, ids, nil)
+}
+
+// FindCandidates returns peripherals matched by rules, newest first
+func (c *Client) FindCandidates(query *CandidateQuery) (*CandidatePage, error) {
+	if query == nil {
+		query = &CandidateQuery{}
+	}
+
+	values := query.values()
+
+	setString(values, "status", query.Status)
+	setUint(values, "rule", query.RuleId)
+
+	var page CandidatePage
+
+	if err := c.do(http.MethodGet, registryRoute+"/candidates", values, nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// ApproveCandidate registers a pending or failed candidate, a non-empty name replaces the rendered one
+func (c *Client) ApproveCandidate(id uint64, name string) (*tracking.Candidate, error) {
+	var candidate tracking.Candidate
+
+	body := map[string]string{"name": name}
+
+	if err := c.do(http.MethodPost, idRoute(registryRoute+"/candidate", id, "approve"), nil, body, &candidate); err != nil {
+		return nil, err
+	}
+
+	return &candidate, nil
+}
+
+func (c *Client) RejectCandidate(id uint64) (*tracking.Candidate, error) {
+	var candidate tracking.Candidate
+
+	if err := c.do(http.MethodPost, idRoute(registryRoute+"/candidate", id, "reject"), nil, nil, &candidate); err != nil {
+		return nil, err
+	}
+
+	return &candidate, nil
+}
+
+func (c *Client) DeleteCandidate(id uint64) error {
+	return c.do(http.MethodDelete, idRoute(registryRoute+"/candidate", id), nil, nil, nil)
+}
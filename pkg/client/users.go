@@ -0,0 +1,141 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blent/beagle/pkg/auth"
+)
+
+type (
+	Session struct {
+		Token     string     `json:"token"`
+		ExpiresAt time.Time  `json:"expiresAt"`
+		User      *auth.User `json:"user"`
+	}
+
+	// UserInput creates or updates a user, an empty password keeps the current one on updates
+	UserInput struct {
+		Id       uint64 `json:"id,omitempty"`
+		Username string `json:"username"`
+		Password string `json:"password,omitempty"`
+		Role     string `json:"role"`
+		Enabled  *bool  `json:"enabled,omitempty"`
+	}
+
+	UserPage struct {
+		Items    []*auth.User `json:"items"`
+		Quantity uint64       `json:"quantity"`
+	}
+
+	// NewApiKey contains a token of a new api key, it is returned only once
+	NewApiKey struct {
+		Token string       `json:"token"`
+		Key   *auth.ApiKey `json:"key"`
+	}
+)
+
+const authRoute = "/auth"
+
+// Login starts a new session and authenticates following requests by its token
+func (c *Client) Login(username, password string) (*Session, error) {
+	var session Session
+
+	err := c.do(http.MethodPost, authRoute+"/login", nil, map[string]string{
+		"username": username,
+		"password": password,
+	}, &session)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.UseToken(session.Token)
+
+	return &session, nil
+}
+
+// Logout ends the current session
+func (c *Client) Logout() error {
+	if err := c.do(http.MethodPost, authRoute+"/logout", nil, nil, nil); err != nil {
+		return err
+	}
+
+	c.UseToken("")
+
+	return nil
+}
+
+// Me returns the current user
+func (c *Client) Me() (*auth.User, error) {
+	var user auth.User
+
+	if err := c.do(http.MethodGet, authRoute+"/me", nil, nil, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (c *Client) FindUsers(take, skip uint64) (*UserPage, error) {
+	var page UserPage
+
+	query := &ListQuery{Take: take, Skip: skip}
+
+	if err := c.do(http.MethodGet, "/users", query.values(), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *Client) GetUser(id uint64) (*auth.User, error) {
+	var user auth.User
+
+	if err := c.do(http.MethodGet, idRoute("/user", id), nil, nil, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (c *Client) CreateUser(user *UserInput) (uint64, error) {
+	return c.doId(http.MethodPost, "/user", user)
+}
+
+func (c *Client) UpdateUser(user *UserInput) error {
+	return c.do(http.MethodPut, "/user", nil, user, nil)
+}
+
+func (c *Client) DeleteUser(id uint64) error {
+	return c.do(http.MethodDelete, idRoute("/user", id), nil, nil, nil)
+}
+
+func (c *Client) FindApiKeys(userId uint64) ([]*auth.ApiKey, error) {
+	var keys []*auth.ApiKey
+
+	if err := c.do(http.MethodGet, idRoute("/user", userId, "keys"), nil, nil, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (c *Client) CreateApiKey(userId uint64, name string) (*NewApiKey, error) {
+	var key NewApiKey
+
+	err := c.do(http.MethodPost, idRoute("/user", userId, "key"), nil, map[string]string{
+		"name": name,
+	}, &key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (c *Client) DeleteApiKey(userId, id uint64) error {
+	return c.do(http.MethodDelete, idRoute("/user", userId, "key", strconv.FormatUint(id, 10)), nil, nil, nil)
+}
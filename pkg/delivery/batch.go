@@ -0,0 +1,215 @@
+package delivery
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+)
+
+type (
+	batchItem struct {
+		eventName  string
+		targetName string
+		peripheral peripherals.Peripheral
+		timestamp  time.Time
+	}
+
+	pendingBatch struct {
+		subscriber *notification.Subscriber
+		items      []*batchItem
+		timer      *time.Timer
+	}
+
+	// Batcher collects events per subscriber and flushes them
+	// once a batch window is over or a batch is full
+	Batcher struct {
+		mu       sync.Mutex
+		pending  map[uint64]*pendingBatch
+		flush    func(*notification.Subscriber, []*batchItem)
+		inFlight sync.WaitGroup
+	}
+)
+
+func NewBatcher(flush func(*notification.Subscriber, []*batchItem)) *Batcher {
+	return &Batcher{
+		pending: make(map[uint64]*pendingBatch),
+		flush:   flush,
+	}
+}
+
+// Accepts determines whether events for a given subscriber must be batched.
+// Arrays can be sent only to command endpoints and in bodies of POST requests.
+func (b *Batcher) Accepts(subscriber *notification.Subscriber) bool {
+	if !subscriber.Batch.IsEnabled() || subscriber.Endpoint == nil {
+		return false
+	}
+
+	endpoint := subscriber.Endpoint
+
+	return endpoint.Kind == notification.ENDPOINT_KIND_COMMAND ||
+		strings.ToUpper(endpoint.Method) == http.MethodPost
+}
+
+// Add puts an item to a subscriber's batch.
+// Returns items that were coalesced and will not be sent.
+func (b *Batcher) Add(subscriber *notification.Subscriber, item *batchItem) []*batchItem {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch, ok := b.pending[subscriber.Id]
+
+	if !ok {
+		batch = &pendingBatch{
+			subscriber: subscriber,
+			items:      make([]*batchItem, 0, batchCapacity(subscriber.Batch)),
+		}
+
+		// done either by the timer or by detaching a batch before the timer fires
+		b.inFlight.Add(1)
+
+		batch.timer = time.AfterFunc(
+			time.Duration(batchWindow(subscriber.Batch))*time.Millisecond,
+			func() {
+				defer b.inFlight.Done()
+
+				b.flushOne(subscriber.Id, batch)
+			},
+		)
+
+		b.pending[subscriber.Id] = batch
+	}
+
+	coalesced := batch.coalesce(item)
+
+	if coalesced == nil {
+		batch.items = append(batch.items, item)
+	}
+
+	if subscriber.Batch.Size > 0 && len(batch.items) >= subscriber.Batch.Size {
+		b.detach(subscriber.Id, batch)
+		b.inFlight.Add(1)
+
+		go func() {
+			defer b.inFlight.Done()
+
+			b.flush(batch.subscriber, batch.items)
+		}()
+	}
+
+	return coalesced
+}
+
+// batchWindow keeps batches stored without a window from waiting for more events forever
+func batchWindow(batch *notification.Batch) uint64 {
+	if batch.Window == 0 {
+		return notification.DEFAULT_BATCH_WINDOW
+	}
+
+	return batch.Window
+}
+
+// batchCapacity keeps sizes stored before they were validated from crashing the sender
+func batchCapacity(batch *notification.Batch) int {
+	if batch.Size < 0 {
+		return 0
+	}
+
+	if batch.Size > notification.MAX_BATCH_SIZE {
+		return notification.MAX_BATCH_SIZE
+	}
+
+	return batch.Size
+}
+
+// Flush sends all pending batches synchronously
+// and waits for batches that are already being sent
+func (b *Batcher) Flush() {
+	b.mu.Lock()
+	batches := make([]*pendingBatch, 0, len(b.pending))
+
+	for id, batch := range b.pending {
+		b.detach(id, batch)
+		batches = append(batches, batch)
+	}
+
+	b.mu.Unlock()
+
+	for _, batch := range batches {
+		if len(batch.items) > 0 {
+			b.flush(batch.subscriber, batch.items)
+		}
+	}
+
+	b.inFlight.Wait()
+}
+
+func (b *Batcher) flushOne(id uint64, batch *pendingBatch) {
+	b.mu.Lock()
+
+	if b.pending[id] != batch {
+		// already flushed
+		b.mu.Unlock()
+		return
+	}
+
+	b.detach(id, batch)
+	b.mu.Unlock()
+
+	if len(batch.items) > 0 {
+		b.flush(batch.subscriber, batch.items)
+	}
+}
+
+func (b *Batcher) detach(id uint64, batch *pendingBatch) {
+	if batch.timer != nil && batch.timer.Stop() {
+		b.inFlight.Done()
+	}
+
+	delete(b.pending, id)
+}
+
+// coalesce tries to match a lost event with the latest found event of the same peripheral
+func (batch *pendingBatch) coalesce(item *batchItem) []*batchItem {
+	mode := batch.subscriber.Batch.Coalesce
+
+	if mode != notification.COALESCE_DROP && mode != notification.COALESCE_BLIP {
+		return nil
+	}
+
+	if item.eventName != notification.LOST {
+		return nil
+	}
+
+	key := item.peripheral.UniqueKey()
+
+	for idx := len(batch.items) - 1; idx >= 0; idx-- {
+		found := batch.items[idx]
+
+		if found.peripheral.UniqueKey() != key {
+			continue
+		}
+
+		if found.eventName != notification.FOUND {
+			return nil
+		}
+
+		if mode == notification.COALESCE_DROP {
+			batch.items = append(batch.items[:idx], batch.items[idx+1:]...)
+		} else {
+			batch.items[idx] = &batchItem{
+				eventName:  notification.BLIP,
+				targetName: found.targetName,
+				peripheral: item.peripheral,
+				timestamp:  found.timestamp,
+			}
+		}
+
+		return []*batchItem{found, item}
+	}
+
+	return nil
+}
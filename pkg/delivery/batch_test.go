@@ -0,0 +1,224 @@
+package delivery_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type batchRecorder struct {
+	mu       sync.Mutex
+	requests [][]map[string]interface{}
+	events   []delivery.Event
+}
+
+func TestSenderBatchBySize(t *testing.T) {
+	recorder, sender := createBatchSender(t)
+	sub := createBatchSubscriber(&notification.Batch{Window: 60000, Size: 3})
+
+	for i := 0; i < 3; i++ {
+		send(t, sender, notification.FOUND, createPeripheral(), sub)
+	}
+
+	recorder.wait(t, 3)
+
+	requests, events := recorder.snapshot()
+
+	assert.Len(t, requests, 1, "requests")
+	assert.Len(t, requests[0], 3, "batch size")
+
+	for _, evt := range events {
+		assert.Equal(t, delivery.OUTCOME_DELIVERED, evt.Outcome, "outcome")
+		assert.True(t, evt.Duration > 0, "duration")
+	}
+}
+
+func TestSenderBatchByWindow(t *testing.T) {
+	recorder, sender := createBatchSender(t)
+	sub := createBatchSubscriber(&notification.Batch{Window: 100, Size: 100})
+
+	send(t, sender, notification.FOUND, createPeripheral(), sub)
+	send(t, sender, notification.FOUND, createPeripheral(), sub)
+
+	recorder.wait(t, 2)
+
+	requests, _ := recorder.snapshot()
+
+	assert.Len(t, requests, 1, "requests")
+	assert.Len(t, requests[0], 2, "batch size")
+	assert.Equal(t, notification.FOUND, requests[0][0]["event"], "event name")
+}
+
+func TestSenderBatchWithoutWindow(t *testing.T) {
+	recorder, sender := createBatchSender(t)
+	sub := createBatchSubscriber(&notification.Batch{Window: 0, Size: 5})
+
+	send(t, sender, notification.LOST, createPeripheral(), sub)
+
+	recorder.wait(t, 1)
+
+	requests, _ := recorder.snapshot()
+
+	assert.Len(t, requests, 1, "requests")
+	assert.Len(t, requests[0], 1, "a single event is flushed by the default window")
+}
+
+func TestSenderBatchInvalidSize(t *testing.T) {
+	recorder, sender := createBatchSender(t)
+	sub := createBatchSubscriber(&notification.Batch{Window: 100, Size: -1})
+
+	send(t, sender, notification.FOUND, createPeripheral(), sub)
+
+	recorder.wait(t, 1)
+
+	requests, _ := recorder.snapshot()
+
+	assert.Len(t, requests, 1, "stored invalid size is ignored")
+}
+
+func TestSenderBatchCoalesceDrop(t *testing.T) {
+	recorder, sender := createBatchSender(t)
+	sub := createBatchSubscriber(&notification.Batch{
+		Window:   200,
+		Size:     100,
+		Coalesce: notification.COALESCE_DROP,
+	})
+
+	blinking := createPeripheral()
+	staying := createPeripheral()
+
+	send(t, sender, notification.FOUND, blinking, sub)
+	send(t, sender, notification.FOUND, staying, sub)
+	send(t, sender, notification.LOST, blinking, sub)
+
+	recorder.wait(t, 3)
+
+	requests, events := recorder.snapshot()
+
+	assert.Len(t, requests, 1, "requests")
+	assert.Len(t, requests[0], 1, "batch size")
+	assert.Equal(t, notification.FOUND, requests[0][0]["event"], "event name")
+
+	coalesced := 0
+
+	for _, evt := range events {
+		if evt.Outcome == delivery.OUTCOME_COALESCED {
+			coalesced++
+		}
+	}
+
+	assert.Equal(t, 2, coalesced, "coalesced events")
+}
+
+func TestSenderBatchCoalesceBlip(t *testing.T) {
+	recorder, sender := createBatchSender(t)
+	sub := createBatchSubscriber(&notification.Batch{
+		Window:   100,
+		Size:     100,
+		Coalesce: notification.COALESCE_BLIP,
+	})
+
+	blinking := createPeripheral()
+
+	send(t, sender, notification.FOUND, blinking, sub)
+	send(t, sender, notification.LOST, blinking, sub)
+
+	recorder.wait(t, 3)
+
+	requests, _ := recorder.snapshot()
+
+	assert.Len(t, requests, 1, "requests")
+	assert.Len(t, requests[0], 1, "batch size")
+	assert.Equal(t, notification.BLIP, requests[0][0]["event"], "event name")
+}
+
+func createBatchSender(t *testing.T) (*batchRecorder, *delivery.Sender) {
+	recorder := &batchRecorder{}
+
+	sender := delivery.New(zap.NewNop(), delivery.NewMockTransport(func(req *http.Request) error {
+		var body []map[string]interface{}
+
+		content, err := ioutil.ReadAll(req.Body)
+
+		assert.NoError(t, err, "read body")
+		assert.NoError(t, json.Unmarshal(content, &body), "batch body")
+
+		recorder.mu.Lock()
+		recorder.requests = append(recorder.requests, body)
+		recorder.mu.Unlock()
+
+		return nil
+	}))
+
+	sender.Subscribe(func(evt delivery.Event) {
+		recorder.mu.Lock()
+		recorder.events = append(recorder.events, evt)
+		recorder.mu.Unlock()
+	})
+
+	return recorder, sender
+}
+
+func createBatchSubscriber(batch *notification.Batch) *notification.Subscriber {
+	return &notification.Subscriber{
+		Id:    1,
+		Name:  "batch",
+		Event: "*",
+		Endpoint: &notification.Endpoint{
+			Id:     1,
+			Name:   "batch",
+			Url:    "http://localhost/batch",
+			Method: http.MethodPost,
+		},
+		Enabled: true,
+		Batch:   batch,
+	}
+}
+
+func send(t *testing.T, sender *delivery.Sender, eventName string, peripheral peripherals.Peripheral, sub *notification.Subscriber) {
+	err := sender.Send(notification.NewMessage(
+		eventName,
+		"test",
+		peripheral,
+		[]*notification.Subscriber{sub},
+	))
+
+	assert.NoError(t, err, "send error")
+
+	// keeps the order of events since every message is dispatched in its own goroutine
+	time.Sleep(time.Millisecond * 10)
+}
+
+func (r *batchRecorder) wait(t *testing.T, events int) {
+	deadline := time.Now().Add(time.Second * 5)
+
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		count := len(r.events)
+		r.mu.Unlock()
+
+		if count >= events {
+			return
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatal("events timeout")
+}
+
+func (r *batchRecorder) snapshot() ([][]map[string]interface{}, []delivery.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.requests, r.events
+}
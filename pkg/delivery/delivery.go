@@ -2,46 +2,136 @@ package delivery
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/events"
 	"github.com/blent/beagle/pkg/notification"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
-	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	OUTCOME_DELIVERED    = "delivered"
+	OUTCOME_FAILED       = "failed"
+	OUTCOME_RATE_LIMITED = "rate-limited"
+	OUTCOME_CIRCUIT_OPEN = "circuit-open"
+	OUTCOME_COALESCED    = "coalesced"
+)
+
+const (
+	batchEventName    = "batch"
+	listenerQueueSize = 100
+	laneQueueSize     = 100
+)
+
 type (
 	Event struct {
 		Name       string
 		Timestamp  time.Time
 		TargetName string
+		Peripheral peripherals.Peripheral
 		Subscriber *notification.Subscriber
 		Delivered  bool
+		Outcome    string
 		Error      error
+		Duration   time.Duration // time spent on the attempt, zero if nothing has been sent
 	}
 
 	EventListener func(evt Event)
 
 	Sender struct {
+		mu        *sync.RWMutex
 		logger    *zap.Logger
 		transport Transport
-		listeners []EventListener
+		commands  CommandTransport
+		gates     *Gates
+		batches   *Batcher
+		listeners *events.Dispatcher
+		workers   *events.Lanes
+	}
+
+	// payload is a content of a single delivery: either a single event or a batch of them
+	payload struct {
+		event  string
+		fields map[string]interface{}
+		items  []map[string]interface{}
 	}
 )
 
 func New(logger *zap.Logger, transport Transport) *Sender {
-	return &Sender{
-		logger,
-		transport,
-		make([]EventListener, 0, 5),
+	sender := &Sender{
+		mu:        &sync.RWMutex{},
+		logger:    logger,
+		transport: transport,
+		gates:     NewGates(&PolicySettings{}),
+		listeners: events.NewDispatcher(logger.Named("listeners"), listenerQueueSize),
+		workers:   events.NewLanes(laneQueueSize),
 	}
+
+	sender.batches = NewBatcher(sender.sendItems)
+
+	return sender
+}
+
+// Flush immediately sends all pending batches
+func (sender *Sender) Flush() {
+	sender.batches.Flush()
+}
+
+// Stop waits for in-flight deliveries, sends pending batches and waits until listeners handle all events.
+// The sender can not be used after that.
+func (sender *Sender) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		sender.workers.Close()
+		sender.batches.Flush()
+		sender.listeners.Close()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetPolicy sets default delivery limits applied to every endpoint.
+// Endpoints can override them by their own policies.
+func (sender *Sender) SetPolicy(settings *PolicySettings) *Sender {
+	sender.gates.SetDefaults(settings)
+
+	return sender
+}
+
+// EndpointStates returns delivery states of all endpoints used so far
+func (sender *Sender) EndpointStates() []*GateState {
+	return sender.gates.States()
+}
+
+// SetCommandTransport enables delivery to command endpoints.
+// Without it, all deliveries to such endpoints fail with ErrCommandTransportDisabled.
+// It can be called at any time, nil disables command endpoints.
+func (sender *Sender) SetCommandTransport(transport CommandTransport) *Sender {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	sender.commands = transport
+
+	return sender
 }
 
 func (sender *Sender) Send(msg *notification.Message) error {
@@ -49,43 +139,69 @@ func (sender *Sender) Send(msg *notification.Message) error {
 		return fmt.Errorf("%s %s", ErrUnsupportedEventName, msg.EventName())
 	}
 
-	// Call endpoints in batch inside a separate goroutine
-	go sender.sendBatch(msg)
+	// Messages of the same peripheral are delivered in order,
+	// messages of different peripherals are delivered in parallel, so a slow endpoint only delays its peripheral
+	key := ""
+
+	if msg.Peripheral() != nil {
+		key = msg.Peripheral().UniqueKey()
+	}
+
+	queued := sender.workers.Submit(key, func() {
+		sender.dispatch(msg)
+	})
+
+	if !queued {
+		sender.drop(msg)
+
+		return errors.Wrap(ErrQueueFull, key)
+	}
 
 	return nil
 }
 
-func (sender *Sender) AddEventListener(listener EventListener) {
+// DroppedMessages returns a number of messages dropped since too many messages of their peripherals were waiting
+func (sender *Sender) DroppedMessages() uint64 {
+	return sender.workers.Dropped()
+}
+
+// Subscribe registers a listener of delivery events.
+// Every listener is called sequentially from its own goroutine.
+func (sender *Sender) Subscribe(listener EventListener) *events.Subscription {
 	if listener == nil {
-		return
+		return nil
 	}
 
-	sender.listeners = append(sender.listeners, listener)
+	return sender.listeners.Subscribe(func(payload interface{}) {
+		listener(payload.(Event))
+	})
 }
 
-func (sender *Sender) RemoveEventListener(listener EventListener) bool {
+// SubscribeUnbounded registers a listener which receives every event, however slow it is
+func (sender *Sender) SubscribeUnbounded(listener EventListener) *events.Subscription {
 	if listener == nil {
-		return false
+		return nil
 	}
 
-	idx := -1
-	handlerPointer := reflect.ValueOf(listener).Pointer()
-
-	for i, element := range sender.listeners {
-		currentPointer := reflect.ValueOf(element).Pointer()
+	return sender.listeners.SubscribeUnbounded(func(payload interface{}) {
+		listener(payload.(Event))
+	})
+}
 
-		if currentPointer == handlerPointer {
-			idx = i
-		}
-	}
+func (sender *Sender) ListenerStats() events.Stats {
+	return sender.listeners.Stats()
+}
 
-	if idx < 0 {
-		return false
-	}
+// QueueDepth returns a number of messages waiting for delivery
+func (sender *Sender) QueueDepth() int {
+	return sender.workers.Pending()
+}
 
-	sender.listeners = append(sender.listeners[:idx], sender.listeners[idx+1:]...)
+func (sender *Sender) commandTransport() CommandTransport {
+	sender.mu.RLock()
+	defer sender.mu.RUnlock()
 
-	return true
+	return sender.commands
 }
 
 func (sender *Sender) isSupportedEventName(name string) bool {
@@ -96,23 +212,38 @@ func (sender *Sender) isSupportedEventName(name string) bool {
 	return name == "found" || name == "lost"
 }
 
-func (sender *Sender) sendBatch(msg *notification.Message) {
+func (sender *Sender) dispatch(msg *notification.Message) {
 	subscribers := msg.Subscribers()
 	events := make([]*Event, 0, len(subscribers))
 
 	for _, subscriber := range subscribers {
-		err := sender.sendSingle(msg.TargetName(), msg.Peripheral(), subscriber)
-
-		evt := &Event{
-			Name:       msg.EventName(),
-			Timestamp:  time.Now(),
-			TargetName: msg.TargetName(),
-			Subscriber: subscriber,
-			Delivered:  err == nil,
-			Error:      err,
+		if sender.batches.Accepts(subscriber) {
+			coalesced := sender.batches.Add(subscriber, &batchItem{
+				eventName:  msg.EventName(),
+				targetName: msg.TargetName(),
+				peripheral: msg.Peripheral(),
+				timestamp:  time.Now(),
+			})
+
+			for _, item := range coalesced {
+				events = append(events, &Event{
+					Name:       item.eventName,
+					Timestamp:  time.Now(),
+					TargetName: item.targetName,
+					Peripheral: item.peripheral,
+					Subscriber: subscriber,
+					Delivered:  false,
+					Outcome:    OUTCOME_COALESCED,
+				})
+			}
+
+			continue
 		}
 
-		events = append(events, evt)
+		start := time.Now()
+		err := sender.sendSingle(msg.EventName(), msg.TargetName(), msg.Peripheral(), subscriber)
+
+		events = append(events, newEvent(msg.EventName(), msg.TargetName(), msg.Peripheral(), subscriber, err, time.Since(start)))
 
 		if err == nil {
 			sender.logger.Info(
@@ -133,7 +264,78 @@ func (sender *Sender) sendBatch(msg *notification.Message) {
 	sender.emit(events)
 }
 
-func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral, subscriber *notification.Subscriber) error {
+// drop reports a message which is not queued as failed for all its subscribers
+func (sender *Sender) drop(msg *notification.Message) {
+	subscribers := msg.Subscribers()
+	events := make([]*Event, 0, len(subscribers))
+
+	for _, subscriber := range subscribers {
+		events = append(events, newEvent(msg.EventName(), msg.TargetName(), msg.Peripheral(), subscriber, ErrQueueFull, 0))
+	}
+
+	sender.logger.Error(
+		"Failed to queue a message",
+		zap.String("peripheral", msg.TargetName()),
+		zap.Int("subscribers", len(subscribers)),
+		zap.Uint64("dropped", sender.workers.Dropped()),
+	)
+
+	sender.emit(events)
+}
+
+func (sender *Sender) sendItems(subscriber *notification.Subscriber, items []*batchItem) {
+	serialized := make([]map[string]interface{}, 0, len(items))
+	sent := make([]*batchItem, 0, len(items))
+	events := make([]*Event, 0, len(items))
+
+	for _, item := range items {
+		fields, err := sender.serializePeripheral(item.targetName, item.peripheral)
+
+		if err != nil {
+			sender.logger.Error(err.Error())
+			events = append(events, newEvent(item.eventName, item.targetName, item.peripheral, subscriber, err, 0))
+			continue
+		}
+
+		fields["event"] = item.eventName
+		fields["timestamp"] = item.timestamp.Format(time.RFC3339Nano)
+		serialized = append(serialized, fields)
+		sent = append(sent, item)
+	}
+
+	if len(serialized) > 0 {
+		start := time.Now()
+		err := sender.deliver(subscriber, &payload{
+			event: batchEventName,
+			items: serialized,
+		})
+
+		duration := time.Since(start)
+
+		for _, item := range sent {
+			events = append(events, newEvent(item.eventName, item.targetName, item.peripheral, subscriber, err, duration))
+		}
+
+		if err == nil {
+			sender.logger.Info(
+				"Succeeded to notify a subscriber with a batch",
+				zap.String("subscriber", subscriber.Name),
+				zap.Int("size", len(serialized)),
+			)
+		} else {
+			sender.logger.Info(
+				"Failed to notify a subscriber with a batch",
+				zap.String("subscriber", subscriber.Name),
+				zap.Int("size", len(serialized)),
+				zap.Error(err),
+			)
+		}
+	}
+
+	sender.emit(events)
+}
+
+func (sender *Sender) sendSingle(eventName, name string, peripheral peripherals.Peripheral, subscriber *notification.Subscriber) error {
 	serialized, err := sender.serializePeripheral(name, peripheral)
 
 	if err != nil {
@@ -141,6 +343,15 @@ func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral,
 		return err
 	}
 
+	return sender.deliver(subscriber, &payload{
+		event:  eventName,
+		fields: serialized,
+	})
+}
+
+func (sender *Sender) deliver(subscriber *notification.Subscriber, content *payload) error {
+	var err error
+
 	endpoint := subscriber.Endpoint
 
 	if endpoint == nil {
@@ -151,11 +362,11 @@ func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral,
 		return nil
 	}
 
-	if endpoint.Url == "" {
-		err = errors.New("Endpoint has an empty url")
+	if !notification.IsSupportedEndpointKind(endpoint.Kind) && endpoint.Kind != "" {
+		err = fmt.Errorf("%s: %s", ErrUnsupportedEndpointKind, endpoint.Kind)
 
 		sender.logger.Error(
-			"endpoint has an empty url: %s",
+			"Failed to deliver a message",
 			zap.String("endpoint", endpoint.Name),
 			zap.Error(err),
 		)
@@ -163,40 +374,96 @@ func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral,
 		return err
 	}
 
-	method := strings.ToUpper(endpoint.Method)
-	req, err := http.NewRequest(method, subscriber.Endpoint.Url, nil)
+	gate := sender.gates.Get(endpoint)
+	ctx := context.Background()
 
-	if err != nil {
-		sender.logger.Error(
-			"failed to create a new request",
-			zap.Error(err),
-			zap.String("endpoint", endpoint.Name),
-		)
+	if gate.Timeout() > 0 {
+		var cancel context.CancelFunc
 
-		return errors.Wrap(err, "failed to create a new request")
+		ctx, cancel = context.WithTimeout(ctx, gate.Timeout())
+		defer cancel()
 	}
 
-	if method == http.MethodPost {
-		req.Header.Set("Content-Type", "application/json")
+	// requests and commands are prepared before a slot is taken,
+	// so that only results of transports are counted by the breaker
+	var send func() error
 
-		body, err := json.Marshal(serialized)
+	if endpoint.Kind == notification.ENDPOINT_KIND_COMMAND {
+		commands, cmd, err := sender.prepareCommand(endpoint, content)
 
 		if err != nil {
 			return err
 		}
 
-		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		send = func() error {
+			return sender.executeCommand(ctx, commands, endpoint, cmd)
+		}
 	} else {
-		query, err := sender.encode(serialized)
+		req, err := sender.createRequest(ctx, endpoint, content)
 
 		if err != nil {
 			return err
 		}
 
-		req.URL.RawQuery = query
+		send = func() error {
+			return sender.sendRequest(endpoint, req)
+		}
+	}
+
+	release, err := gate.Acquire(ctx)
+
+	if err != nil {
+		sender.logger.Warn(
+			"Delivery is rejected by the endpoint policy",
+			zap.String("endpoint", endpoint.Name),
+			zap.Error(err),
+		)
+
+		return err
+	}
+
+	err = send()
+
+	release(err)
+
+	return err
+}
+
+func (sender *Sender) sendRequest(endpoint *notification.Endpoint, req *http.Request) error {
+	_, err := sender.transport.Do(req)
+
+	if err != nil {
+		sender.logger.Error(
+			"Failed to reach out the endpoint",
+			zap.String("endpoint name", endpoint.Name),
+			zap.String("endpoint url", endpoint.Url),
+			zap.Error(err),
+		)
+
+		return err
 	}
 
-	if req == nil {
+	return nil
+}
+
+func (sender *Sender) createRequest(ctx context.Context, endpoint *notification.Endpoint, content *payload) (*http.Request, error) {
+	var err error
+
+	if endpoint.Url == "" {
+		err = errors.New("Endpoint has an empty url")
+
+		sender.logger.Error(
+			"endpoint has an empty url: %s",
+			zap.String("endpoint", endpoint.Name),
+			zap.Error(err),
+		)
+
+		return nil, err
+	}
+
+	method := strings.ToUpper(endpoint.Method)
+
+	if method != http.MethodPost && content.isBatch() {
 		err = fmt.Errorf(
 			"%s: %s for endpoint %s",
 			ErrUnsupportedHttpMethod,
@@ -210,7 +477,43 @@ func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral,
 			zap.Error(err),
 		)
 
-		return err
+		return nil, err
+	}
+
+	var body io.Reader
+
+	if method == http.MethodPost {
+		serialized, err := json.Marshal(content.body())
+
+		if err != nil {
+			return nil, err
+		}
+
+		body = bytes.NewReader(serialized)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.Url, body)
+
+	if err != nil {
+		sender.logger.Error(
+			"failed to create a new request",
+			zap.Error(err),
+			zap.String("endpoint", endpoint.Name),
+		)
+
+		return nil, errors.Wrap(err, "failed to create a new request")
+	}
+
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		query, err := sender.encode(content.fields)
+
+		if err != nil {
+			return nil, err
+		}
+
+		req.URL.RawQuery = query
 	}
 
 	headers := endpoint.Headers
@@ -221,13 +524,40 @@ func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral,
 		}
 	}
 
-	err = sender.transport.Do(req)
+	return req, nil
+}
+
+// prepareCommand returns the command transport and a command of an endpoint
+func (sender *Sender) prepareCommand(endpoint *notification.Endpoint, content *payload) (CommandTransport, *Command, error) {
+	commands := sender.commandTransport()
+
+	if commands == nil {
+		sender.logger.Error(
+			"Failed to execute a command",
+			zap.String("endpoint", endpoint.Name),
+			zap.Error(ErrCommandTransportDisabled),
+		)
+
+		return nil, nil, ErrCommandTransportDisabled
+	}
+
+	cmd, err := sender.createCommand(endpoint, content)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return commands, cmd, nil
+}
+
+func (sender *Sender) executeCommand(ctx context.Context, commands CommandTransport, endpoint *notification.Endpoint, cmd *Command) error {
+	err := commands.Execute(ctx, cmd)
 
 	if err != nil {
 		sender.logger.Error(
-			"Failed to reach out the endpoint",
+			"Failed to execute the endpoint command",
 			zap.String("endpoint name", endpoint.Name),
-			zap.String("endpoint url", endpoint.Url),
+			zap.String("endpoint command", endpoint.Url),
 			zap.Error(err),
 		)
 
@@ -237,6 +567,68 @@ func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral,
 	return nil
 }
 
+func (sender *Sender) createCommand(endpoint *notification.Endpoint, content *payload) (*Command, error) {
+	var input interface{}
+	env := make([]string, 0, len(content.fields)+len(endpoint.Headers)+2)
+
+	env = append(env, "BEAGLE_EVENT="+content.event)
+
+	if content.isBatch() {
+		input = content.items
+		env = append(env, fmt.Sprintf("BEAGLE_COUNT=%d", len(content.items)))
+	} else {
+		fields := make(map[string]interface{}, len(content.fields)+1)
+		fields["event"] = content.event
+
+		for key, value := range content.fields {
+			fields[key] = value
+			env = append(env, fmt.Sprintf("BEAGLE_%s=%v", strings.ToUpper(key), value))
+		}
+
+		input = fields
+	}
+
+	// headers are prefixed, so that they never override variables like PATH or LD_PRELOAD
+	for key, value := range endpoint.Headers {
+		if name := headerVariable(key); name != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	sort.Strings(env)
+
+	stdin, err := json.Marshal(input)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Command{
+		Path:  endpoint.Url,
+		Env:   env,
+		Stdin: stdin,
+	}, nil
+}
+
+// headerVariable converts a header name to an environment variable name, e.g. X-Token is BEAGLE_HEADER_X_TOKEN
+func headerVariable(header string) string {
+	var name strings.Builder
+
+	for _, r := range strings.ToUpper(strings.TrimSpace(header)) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			name.WriteRune(r)
+		} else {
+			name.WriteRune('_')
+		}
+	}
+
+	if name.Len() == 0 {
+		return ""
+	}
+
+	return "BEAGLE_HEADER_" + name.String()
+}
+
 func (sender *Sender) serializePeripheral(name string, peripheral peripherals.Peripheral) (map[string]interface{}, error) {
 	if peripheral == nil {
 		return nil, errors.New("missed peripheral")
@@ -286,9 +678,46 @@ func (sender *Sender) emit(events []*Event) {
 		return
 	}
 
-	for _, listener := range sender.listeners {
-		for _, evt := range events {
-			listener(*evt)
-		}
+	for _, evt := range events {
+		sender.listeners.Publish(*evt)
+	}
+}
+
+func (p *payload) isBatch() bool {
+	return p.items != nil
+}
+
+func (p *payload) body() interface{} {
+	if p.isBatch() {
+		return p.items
+	}
+
+	return p.fields
+}
+
+func newEvent(name, targetName string, peripheral peripherals.Peripheral, subscriber *notification.Subscriber, err error, duration time.Duration) *Event {
+	return &Event{
+		Name:       name,
+		Timestamp:  time.Now(),
+		TargetName: targetName,
+		Peripheral: peripheral,
+		Subscriber: subscriber,
+		Delivered:  err == nil,
+		Outcome:    toOutcome(err),
+		Error:      err,
+		Duration:   duration,
+	}
+}
+
+func toOutcome(err error) string {
+	switch errors.Cause(err) {
+	case nil:
+		return OUTCOME_DELIVERED
+	case ErrRateLimited:
+		return OUTCOME_RATE_LIMITED
+	case ErrCircuitOpen:
+		return OUTCOME_CIRCUIT_OPEN
+	default:
+		return OUTCOME_FAILED
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -36,10 +37,10 @@ func TestSenderSingleSubscriber(t *testing.T) {
 	logger := zap.NewNop()
 	sender := delivery.New(logger, delivery.NewMockTransport(resolver))
 
-	var notificationErr error
+	events := make(chan delivery.Event, 1)
 
-	sender.AddEventListener(func(evt delivery.Event) {
-		notificationErr = evt.Error
+	sender.Subscribe(func(evt delivery.Event) {
+		events <- evt
 	})
 
 	err := sender.Send(notification.NewMessage(
@@ -51,9 +52,9 @@ func TestSenderSingleSubscriber(t *testing.T) {
 
 	assert.NoError(t, err, "send error")
 
-	time.Sleep(time.Second + 5)
+	evt := receiveEvents(t, events, 1)[0]
 
-	assert.NoError(t, notificationErr, "delivery error")
+	assert.NoError(t, evt.Error, "delivery error")
 }
 
 func TestSenderMultipleSubscribers(t *testing.T) {
@@ -62,7 +63,8 @@ func TestSenderMultipleSubscribers(t *testing.T) {
 	urls := make(map[string]string)
 
 	for i := 0; i < max; i++ {
-		url := gofakeit.URL()
+		// fake urls may contain spaces, which are escaped in requests
+		url := strings.Replace(gofakeit.URL(), " ", "-", -1)
 		endpointName := gofakeit.Username()
 
 		_, has := urls[url]
@@ -96,15 +98,10 @@ func TestSenderMultipleSubscribers(t *testing.T) {
 	logger := zap.NewNop()
 	sender := delivery.New(logger, delivery.NewMockTransport(resolver))
 
-	var notificationErr error
-	var counter int
+	events := make(chan delivery.Event, max)
 
-	sender.AddEventListener(func(evt delivery.Event) {
-		counter++
-
-		if evt.Error != nil {
-			notificationErr = evt.Error
-		}
+	sender.Subscribe(func(evt delivery.Event) {
+		events <- evt
 	})
 
 	err := sender.Send(notification.NewMessage(
@@ -116,11 +113,13 @@ func TestSenderMultipleSubscribers(t *testing.T) {
 
 	assert.NoError(t, err, "send error")
 
-	time.Sleep(time.Second + 5)
+	received := receiveEvents(t, events, max)
 
-	assert.Equal(t, counter, max, "dispatches")
+	assert.Equal(t, len(received), max, "dispatches")
 
-	assert.NoError(t, notificationErr, "delivery error")
+	for _, evt := range received {
+		assert.NoError(t, evt.Error, "delivery error")
+	}
 }
 
 func TestSenderHandleFailure(t *testing.T) {
@@ -144,12 +143,10 @@ func TestSenderHandleFailure(t *testing.T) {
 	logger := zap.NewNop()
 	sender := delivery.New(logger, delivery.NewMockTransport(resolver))
 
-	var notificationErr error
+	events := make(chan delivery.Event, 1)
 
-	sender.AddEventListener(func(evt delivery.Event) {
-		if evt.Error != nil {
-			notificationErr = evt.Error
-		}
+	sender.Subscribe(func(evt delivery.Event) {
+		events <- evt
 	})
 
 	err := sender.Send(notification.NewMessage(
@@ -161,9 +158,151 @@ func TestSenderHandleFailure(t *testing.T) {
 
 	assert.NoError(t, err, "send error")
 
-	time.Sleep(time.Second + 5)
+	evt := receiveEvents(t, events, 1)[0]
+
+	assert.Error(t, evt.Error, "must be delivery error")
+}
+
+func TestSenderOrderPerPeripheral(t *testing.T) {
+	sub := &notification.Subscriber{
+		Id:    gofakeit.Uint64(),
+		Name:  gofakeit.Username(),
+		Event: "*",
+		Endpoint: &notification.Endpoint{
+			Id:     gofakeit.Uint64(),
+			Name:   gofakeit.Username(),
+			Url:    "http://localhost/test",
+			Method: http.MethodGet,
+		},
+		Enabled: true,
+	}
+
+	calls := 0
+
+	// the first delivery is slow, so that the next one would overtake it if delivered concurrently
+	resolver := func(req *http.Request) error {
+		calls++
+
+		if calls == 1 {
+			time.Sleep(time.Millisecond * 50)
+		}
+
+		return nil
+	}
+
+	sender := delivery.New(zap.NewNop(), delivery.NewMockTransport(resolver))
+	script := []string{notification.FOUND, notification.LOST, notification.FOUND}
+	events := make(chan delivery.Event, len(script))
+
+	sender.Subscribe(func(evt delivery.Event) {
+		events <- evt
+	})
+
+	peripheral := createPeripheral()
+
+	for _, name := range script {
+		err := sender.Send(notification.NewMessage(name, "test", peripheral, []*notification.Subscriber{sub}))
+
+		assert.NoError(t, err, "send error")
+	}
+
+	names := make([]string, 0, len(script))
+
+	for _, evt := range receiveEvents(t, events, len(script)) {
+		names = append(names, evt.Name)
+	}
+
+	assert.Equal(t, script, names, "delivery order")
+}
+
+func TestSenderSlowEndpoint(t *testing.T) {
+	endpoint := func(url string) *notification.Subscriber {
+		return &notification.Subscriber{
+			Id:    gofakeit.Uint64(),
+			Name:  gofakeit.Username(),
+			Event: "*",
+			Endpoint: &notification.Endpoint{
+				Id:     gofakeit.Uint64(),
+				Name:   gofakeit.Username(),
+				Url:    url,
+				Method: http.MethodGet,
+			},
+			Enabled: true,
+		}
+	}
+
+	hanging := endpoint("http://localhost/hang")
+	working := endpoint("http://localhost/ok")
+	release := make(chan struct{})
+
+	resolver := func(req *http.Request) error {
+		if req.URL.Path == "/hang" {
+			<-release
+		}
+
+		return nil
+	}
+
+	sender := delivery.New(zap.NewNop(), delivery.NewMockTransport(resolver))
+	events := make(chan delivery.Event, 200)
+
+	// queued messages are delivered at once when released, more than a bounded listener keeps
+	sender.SubscribeUnbounded(func(evt delivery.Event) {
+		events <- evt
+	})
+
+	slow := createPeripheral()
+
+	assert.NoError(t, sender.Send(notification.NewMessage(notification.FOUND, "slow", slow, []*notification.Subscriber{hanging})))
+
+	// with workers shared by hashed keys, many of these peripherals would wait for the slow one
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, sender.Send(notification.NewMessage(notification.FOUND, "fast", createPeripheral(), []*notification.Subscriber{working})))
+	}
+
+	for _, evt := range receiveEvents(t, events, 50) {
+		assert.Equal(t, "fast", evt.TargetName, "other peripherals are delivered")
+		assert.True(t, evt.Delivered)
+	}
+
+	// the first message is being delivered, so the rest fill the queue of the slow peripheral
+	var err error
+
+	for i := 0; i < 101 && err == nil; i++ {
+		err = sender.Send(notification.NewMessage(notification.LOST, "slow", slow, []*notification.Subscriber{hanging}))
+	}
+
+	if assert.Error(t, err, "queue of the slow peripheral is full") {
+		assert.True(t, strings.HasSuffix(err.Error(), delivery.ErrQueueFull.Error()), err.Error())
+	}
+
+	assert.Equal(t, uint64(1), sender.DroppedMessages())
+
+	dropped := receiveEvents(t, events, 1)[0]
+
+	assert.Equal(t, delivery.ErrQueueFull, dropped.Error, "dropped message")
+	assert.Equal(t, delivery.OUTCOME_FAILED, dropped.Outcome)
+	assert.False(t, dropped.Delivered)
+
+	close(release)
+
+	assert.Len(t, receiveEvents(t, events, 101), 101, "queued messages are delivered once the endpoint responds")
+}
+
+func receiveEvents(t *testing.T, events <-chan delivery.Event, count int) []delivery.Event {
+	res := make([]delivery.Event, 0, count)
+	timeout := time.After(time.Second * 5)
+
+	for len(res) < count {
+		select {
+		case evt := <-events:
+			res = append(res, evt)
+		case <-timeout:
+			t.Fatalf("expected %d events, got %d", count, len(res))
+		}
+	}
 
-	assert.Error(t, notificationErr, "must be delivery error")
+	return res
 }
 
 func createPeripheral() peripherals.Peripheral {
@@ -5,5 +5,11 @@ import "github.com/pkg/errors"
 var (
 	ErrUnsupportedEventName        = errors.New("unsupported event name")
 	ErrUnsupportedHttpMethod       = errors.New("unsupported http method")
+	ErrUnsupportedEndpointKind     = errors.New("unsupported endpoint kind")
 	ErrUnableToSerializePeripheral = errors.New("unable to serialize peripheral")
+	ErrCommandTransportDisabled    = errors.New("command endpoints are disabled")
+	ErrCommandTimeout              = errors.New("command execution timed out")
+	ErrRateLimited                 = errors.New("endpoint rate limit exceeded")
+	ErrCircuitOpen                 = errors.New("endpoint circuit breaker is open")
+	ErrQueueFull                   = errors.New("too many messages of a peripheral are waiting for delivery")
 )
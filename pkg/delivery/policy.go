@@ -0,0 +1,313 @@
+package delivery
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blent/beagle/pkg/notification"
+)
+
+const (
+	BREAKER_STATE_CLOSED    = "closed"
+	BREAKER_STATE_OPEN      = "open"
+	BREAKER_STATE_HALF_OPEN = "half-open"
+)
+
+type (
+	// Gate guards deliveries to a single endpoint
+	Gate struct {
+		mu         sync.Mutex
+		endpointId uint64
+		name       string
+		settings   PolicySettings
+		tokens     float64
+		refilledAt time.Time
+		slots      chan struct{}
+		state      string
+		failures   int
+		openedAt   time.Time
+		probing    bool
+		now        func() time.Time
+	}
+
+	GateState struct {
+		EndpointId uint64    `json:"endpointId"`
+		Endpoint   string    `json:"endpoint"`
+		State      string    `json:"state"`
+		Failures   int       `json:"failures"`
+		InFlight   int       `json:"inFlight"`
+		OpenedAt   time.Time `json:"openedAt,omitempty"`
+	}
+
+	Gates struct {
+		mu       sync.Mutex
+		defaults *PolicySettings
+		items    map[uint64]*Gate
+	}
+)
+
+func NewGates(defaults *PolicySettings) *Gates {
+	return &Gates{
+		defaults: defaults,
+		items:    make(map[uint64]*Gate),
+	}
+}
+
+// Get returns a gate for a given endpoint.
+// The gate is recreated whenever the endpoint policy changes.
+func (g *Gates) Get(endpoint *notification.Endpoint) *Gate {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	settings := g.defaults.Merge(endpoint.Policy)
+
+	gate, ok := g.items[endpoint.Id]
+
+	if !ok || gate.settings != settings {
+		gate = NewGate(endpoint.Id, endpoint.Name, settings)
+		g.items[endpoint.Id] = gate
+	} else {
+		gate.rename(endpoint.Name)
+	}
+
+	return gate
+}
+
+// SetDefaults replaces default limits.
+// Gates affected by the change are recreated on their next use.
+func (g *Gates) SetDefaults(defaults *PolicySettings) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.defaults = defaults
+}
+
+// Settings returns limits applied to a given endpoint
+func (g *Gates) Settings(endpoint *notification.Endpoint) PolicySettings {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.defaults.Merge(endpoint.Policy)
+}
+
+func (g *Gates) States() []*GateState {
+	g.mu.Lock()
+	items := make([]*Gate, 0, len(g.items))
+
+	for _, gate := range g.items {
+		items = append(items, gate)
+	}
+
+	g.mu.Unlock()
+
+	result := make([]*GateState, 0, len(items))
+
+	for _, gate := range items {
+		result = append(result, gate.State())
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].EndpointId < result[j].EndpointId
+	})
+
+	return result
+}
+
+func NewGate(endpointId uint64, name string, settings PolicySettings) *Gate {
+	var slots chan struct{}
+
+	// no limit otherwise
+	if settings.MaxInFlight > 0 {
+		slots = make(chan struct{}, settings.MaxInFlight)
+	}
+
+	return &Gate{
+		endpointId: endpointId,
+		name:       name,
+		settings:   settings,
+		tokens:     float64(settings.burst()),
+		refilledAt: time.Now(),
+		slots:      slots,
+		state:      BREAKER_STATE_CLOSED,
+		now:        time.Now,
+	}
+}
+
+// Acquire reserves a delivery slot.
+// Returns a release function that must be called with the delivery result.
+// Waiting for a slot past the context is not a failure of the endpoint, so the breaker does not count it.
+func (gate *Gate) Acquire(ctx context.Context) (func(error), error) {
+	probe, err := gate.admit()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if gate.slots != nil {
+		select {
+		case gate.slots <- struct{}{}:
+		case <-ctx.Done():
+			gate.abandon(probe)
+
+			return nil, ctx.Err()
+		}
+	}
+
+	return func(result error) {
+		if gate.slots != nil {
+			<-gate.slots
+		}
+
+		gate.record(result, probe)
+	}, nil
+}
+
+func (gate *Gate) Timeout() time.Duration {
+	return gate.settings.Timeout
+}
+
+func (gate *Gate) State() *GateState {
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+
+	return &GateState{
+		EndpointId: gate.endpointId,
+		Endpoint:   gate.name,
+		State:      gate.currentState(),
+		Failures:   gate.failures,
+		InFlight:   len(gate.slots),
+		OpenedAt:   gate.openedAt,
+	}
+}
+
+func (gate *Gate) admit() (bool, error) {
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+
+	probe := false
+
+	switch gate.currentState() {
+	case BREAKER_STATE_OPEN:
+		return false, ErrCircuitOpen
+	case BREAKER_STATE_HALF_OPEN:
+		if gate.probing {
+			return false, ErrCircuitOpen
+		}
+
+		probe = true
+	}
+
+	if gate.settings.RateLimit > 0 {
+		now := gate.now()
+		elapsed := now.Sub(gate.refilledAt).Seconds()
+		gate.refilledAt = now
+		gate.tokens += elapsed * gate.settings.RateLimit
+
+		if max := float64(gate.settings.burst()); gate.tokens > max {
+			gate.tokens = max
+		}
+
+		if gate.tokens < 1 {
+			return false, ErrRateLimited
+		}
+
+		gate.tokens--
+	}
+
+	gate.probing = probe
+
+	return probe, nil
+}
+
+// abandon lets another delivery probe the endpoint if a probe has not been sent
+func (gate *Gate) abandon(probe bool) {
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+
+	if probe {
+		gate.probing = false
+	}
+}
+
+func (gate *Gate) record(result error, probe bool) {
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+
+	if probe {
+		gate.probing = false
+	}
+
+	if result == nil {
+		gate.failures = 0
+		gate.state = BREAKER_STATE_CLOSED
+		gate.openedAt = time.Time{}
+
+		return
+	}
+
+	gate.failures++
+
+	if probe || (gate.settings.BreakerThreshold > 0 && gate.failures >= gate.settings.BreakerThreshold) {
+		gate.state = BREAKER_STATE_OPEN
+		gate.openedAt = gate.now()
+	}
+}
+
+func (gate *Gate) currentState() string {
+	if gate.state == BREAKER_STATE_OPEN && gate.now().Sub(gate.openedAt) >= gate.settings.BreakerCooldown {
+		return BREAKER_STATE_HALF_OPEN
+	}
+
+	return gate.state
+}
+
+func (gate *Gate) rename(name string) {
+	gate.mu.Lock()
+	gate.name = name
+	gate.mu.Unlock()
+}
+
+// Merge applies endpoint specific overrides to the default policy
+func (s *PolicySettings) Merge(policy *notification.Policy) PolicySettings {
+	res := *s
+
+	if policy == nil {
+		return res
+	}
+
+	if policy.RateLimit > 0 {
+		res.RateLimit = policy.RateLimit
+	}
+
+	if policy.Burst > 0 {
+		res.Burst = policy.Burst
+	}
+
+	if policy.MaxInFlight > 0 {
+		res.MaxInFlight = policy.MaxInFlight
+	}
+
+	if policy.Timeout > 0 {
+		res.Timeout = time.Duration(policy.Timeout) * time.Millisecond
+	}
+
+	if policy.BreakerThreshold > 0 {
+		res.BreakerThreshold = policy.BreakerThreshold
+	}
+
+	if policy.BreakerCooldown > 0 {
+		res.BreakerCooldown = time.Duration(policy.BreakerCooldown) * time.Millisecond
+	}
+
+	return res
+}
+
+func (s *PolicySettings) burst() int {
+	if s.Burst < 1 {
+		return 1
+	}
+
+	return s.Burst
+}
@@ -0,0 +1,104 @@
+package delivery_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGateRateLimit(t *testing.T) {
+	gate := delivery.NewGate(1, "test", delivery.PolicySettings{
+		RateLimit: 1,
+		Burst:     2,
+	})
+
+	for i := 0; i < 2; i++ {
+		release, err := gate.Acquire(context.Background())
+
+		assert.NoError(t, err, "within burst")
+
+		release(nil)
+	}
+
+	_, err := gate.Acquire(context.Background())
+
+	assert.Equal(t, delivery.ErrRateLimited, err, "above burst")
+}
+
+func TestGateMaxInFlight(t *testing.T) {
+	gate := delivery.NewGate(1, "test", delivery.PolicySettings{
+		MaxInFlight:      1,
+		BreakerThreshold: 1,
+	})
+
+	release, err := gate.Acquire(context.Background())
+
+	assert.NoError(t, err, "first slot")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	_, err = gate.Acquire(ctx)
+
+	assert.Equal(t, context.DeadlineExceeded, err, "no free slots")
+	assert.Equal(t, 1, gate.State().InFlight, "in flight")
+	assert.Equal(t, 0, gate.State().Failures, "waiting for a slot is not a failure")
+	assert.Equal(t, delivery.BREAKER_STATE_CLOSED, gate.State().State, "closed")
+
+	release(nil)
+
+	assert.Equal(t, 0, gate.State().InFlight, "released")
+}
+
+func TestGateCircuitBreaker(t *testing.T) {
+	failure := errors.New("test error")
+	gate := delivery.NewGate(1, "test", delivery.PolicySettings{
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Millisecond * 100,
+	})
+
+	for i := 0; i < 2; i++ {
+		release, err := gate.Acquire(context.Background())
+
+		assert.NoError(t, err, "closed breaker")
+
+		release(failure)
+	}
+
+	assert.Equal(t, delivery.BREAKER_STATE_OPEN, gate.State().State, "opened")
+
+	_, err := gate.Acquire(context.Background())
+
+	assert.Equal(t, delivery.ErrCircuitOpen, err, "open breaker")
+
+	time.Sleep(time.Millisecond * 150)
+
+	assert.Equal(t, delivery.BREAKER_STATE_HALF_OPEN, gate.State().State, "half opened")
+
+	release, err := gate.Acquire(context.Background())
+
+	assert.NoError(t, err, "probe")
+
+	_, err = gate.Acquire(context.Background())
+
+	assert.Equal(t, delivery.ErrCircuitOpen, err, "single probe")
+
+	release(failure)
+
+	assert.Equal(t, delivery.BREAKER_STATE_OPEN, gate.State().State, "failed probe")
+
+	time.Sleep(time.Millisecond * 150)
+
+	release, err = gate.Acquire(context.Background())
+
+	assert.NoError(t, err, "second probe")
+
+	release(nil)
+
+	assert.Equal(t, delivery.BREAKER_STATE_CLOSED, gate.State().State, "closed")
+	assert.Equal(t, 0, gate.State().Failures, "reset failures")
+}
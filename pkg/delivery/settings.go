@@ -0,0 +1,26 @@
+package delivery
+
+import "time"
+
+type (
+	Settings struct {
+		Policy  *PolicySettings  `yaml:"policy"`
+		Command *CommandSettings `yaml:"command"`
+	}
+
+	// PolicySettings describes default delivery limits applied to each endpoint separately
+	PolicySettings struct {
+		RateLimit        float64       `yaml:"rateLimit"`
+		Burst            int           `yaml:"burst"`
+		MaxInFlight      int           `yaml:"maxInFlight"`
+		Timeout          time.Duration `yaml:"timeout"`
+		BreakerThreshold int           `yaml:"breakerThreshold"`
+		BreakerCooldown  time.Duration `yaml:"breakerCooldown"`
+	}
+
+	CommandSettings struct {
+		Enabled     bool          `yaml:"enabled"`
+		Timeout     time.Duration `yaml:"timeout"`
+		Concurrency int           `yaml:"concurrency"`
+	}
+)
@@ -0,0 +1,218 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+)
+
+const (
+	testTargetName = "test"
+	testUuid       = "00000000000000000000000000000000"
+	testPower      = -59
+)
+
+type (
+	TestRequest struct {
+		Method  string            `json:"method"`
+		Url     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Body    string            `json:"body"`
+	}
+
+	TestResult struct {
+		Request *TestRequest `json:"request"`
+		Sent    bool         `json:"sent"`
+		Status  int          `json:"status,omitempty"`
+		// Latency in milliseconds
+		Latency float64 `json:"latency"`
+		Error   string  `json:"error,omitempty"`
+	}
+)
+
+// Test sends a synthetic event to a given endpoint and reports how it went.
+// Endpoint policies are not applied, so test deliveries do not affect real ones.
+// With dryRun the request is rendered but not sent.
+func (sender *Sender) Test(eventName string, endpoint *notification.Endpoint, dryRun bool) (*TestResult, error) {
+	if !sender.isSupportedEventName(eventName) {
+		return nil, fmt.Errorf("%s %s", ErrUnsupportedEventName, eventName)
+	}
+
+	if endpoint == nil {
+		return nil, errors.New("missed endpoint")
+	}
+
+	if !notification.IsSupportedEndpointKind(endpoint.Kind) && endpoint.Kind != "" {
+		return nil, fmt.Errorf("%s: %s", ErrUnsupportedEndpointKind, endpoint.Kind)
+	}
+
+	peripheral, err := createTestPeripheral()
+
+	if err != nil {
+		return nil, err
+	}
+
+	serialized, err := sender.serializePeripheral(testTargetName, peripheral)
+
+	if err != nil {
+		return nil, err
+	}
+
+	content := &payload{
+		event:  eventName,
+		fields: serialized,
+	}
+
+	if endpoint.Kind == notification.ENDPOINT_KIND_COMMAND {
+		return sender.testCommand(endpoint, content, dryRun)
+	}
+
+	return sender.testRequest(endpoint, content, dryRun)
+}
+
+func (sender *Sender) testRequest(endpoint *notification.Endpoint, content *payload, dryRun bool) (*TestResult, error) {
+	ctx := context.Background()
+
+	if timeout := sender.gates.Settings(endpoint).Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req, err := sender.createRequest(ctx, endpoint, content)
+
+	if err != nil {
+		return &TestResult{Error: err.Error()}, nil
+	}
+
+	rendered := &TestRequest{
+		Method:  req.Method,
+		Url:     req.URL.String(),
+		Headers: make(map[string]string, len(req.Header)),
+	}
+
+	for key := range req.Header {
+		rendered.Headers[key] = req.Header.Get(key)
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+
+		if err != nil {
+			return nil, err
+		}
+
+		serialized, err := ioutil.ReadAll(body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rendered.Body = string(serialized)
+	}
+
+	result := &TestResult{Request: rendered}
+
+	if dryRun {
+		return result, nil
+	}
+
+	start := time.Now()
+	res, err := sender.transport.Do(req)
+
+	result.Sent = true
+	result.Latency = toMilliseconds(time.Since(start))
+
+	if res != nil {
+		result.Status = res.StatusCode
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result, nil
+}
+
+func (sender *Sender) testCommand(endpoint *notification.Endpoint, content *payload, dryRun bool) (*TestResult, error) {
+	cmd, err := sender.createCommand(endpoint, content)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := &TestRequest{
+		Method:  "EXEC",
+		Url:     cmd.Path,
+		Headers: make(map[string]string, len(cmd.Env)),
+		Body:    string(cmd.Stdin),
+	}
+
+	for _, variable := range cmd.Env {
+		pair := strings.SplitN(variable, "=", 2)
+
+		if len(pair) == 2 {
+			rendered.Headers[pair[0]] = pair[1]
+		}
+	}
+
+	result := &TestResult{Request: rendered}
+
+	if dryRun {
+		return result, nil
+	}
+
+	commands := sender.commandTransport()
+
+	if commands == nil {
+		result.Error = ErrCommandTransportDisabled.Error()
+
+		return result, nil
+	}
+
+	ctx := context.Background()
+
+	if timeout := sender.gates.Settings(endpoint).Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err = commands.Execute(ctx, cmd)
+
+	result.Sent = true
+	result.Latency = toMilliseconds(time.Since(start))
+
+	if err != nil {
+		result.Error = err.Error()
+
+		if cmdErr, ok := err.(*CommandError); ok {
+			result.Status = cmdErr.ExitCode
+		}
+	}
+
+	return result, nil
+}
+
+func createTestPeripheral() (peripherals.Peripheral, error) {
+	data, err := peripherals.CreateIBeaconData(testUuid, 1, 1, testPower)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return peripherals.NewIBeaconPeripheral(testTargetName, data, testPower, testPower, "")
+}
+
+func toMilliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
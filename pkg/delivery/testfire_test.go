@@ -0,0 +1,55 @@
+package delivery_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSenderTestDryRun(t *testing.T) {
+	called := false
+
+	sender := delivery.New(zap.NewNop(), delivery.NewMockTransport(func(req *http.Request) error {
+		called = true
+
+		return nil
+	}))
+
+	result, err := sender.Test(notification.FOUND, &notification.Endpoint{
+		Name:    "test",
+		Url:     "http://localhost/hook",
+		Method:  http.MethodPost,
+		Headers: notification.Headers{"X-Token": "secret"},
+	}, true)
+
+	assert.NoError(t, err, "test error")
+	assert.False(t, called, "transport call")
+	assert.False(t, result.Sent, "sent")
+	assert.Equal(t, http.MethodPost, result.Request.Method, "method")
+	assert.Equal(t, "http://localhost/hook", result.Request.Url, "url")
+	assert.Equal(t, "secret", result.Request.Headers["X-Token"], "headers")
+	assert.Contains(t, result.Request.Body, `"uuid":"00000000000000000000000000000000"`, "body")
+}
+
+func TestSenderTestSend(t *testing.T) {
+	sender := delivery.New(zap.NewNop(), delivery.NewMockTransport(func(req *http.Request) error {
+		assert.Equal(t, "1", req.URL.Query().Get("major"), "query")
+
+		return nil
+	}))
+
+	result, err := sender.Test(notification.LOST, &notification.Endpoint{
+		Name:   "test",
+		Url:    "http://localhost/hook",
+		Method: http.MethodGet,
+	}, false)
+
+	assert.NoError(t, err, "test error")
+	assert.True(t, result.Sent, "sent")
+	assert.Equal(t, http.StatusOK, result.Status, "status")
+	assert.Empty(t, result.Error, "delivery error")
+}
@@ -1,9 +1,17 @@
 package delivery
 
 import (
+	"context"
 	"net/http"
 )
 
-type Transport interface {
-	Do(*http.Request) error
-}
+type (
+	Transport interface {
+		Do(*http.Request) (*http.Response, error)
+	}
+
+	// CommandTransport executes commands until they exit or the context is done
+	CommandTransport interface {
+		Execute(context.Context, *Command) error
+	}
+)
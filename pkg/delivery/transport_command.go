@@ -0,0 +1,199 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Maximum amount of stdout/stderr bytes kept per command execution
+	maxCommandOutput = 4096
+	// Time given to processes which escaped the killed process group to release stdout and stderr
+	commandWaitDelay = time.Second
+)
+
+type (
+	Command struct {
+		Path  string
+		Args  []string
+		Env   []string
+		Stdin []byte
+	}
+
+	CommandError struct {
+		Path     string
+		ExitCode int
+		Stdout   string
+		Stderr   string
+		Err      error
+	}
+
+	ExecTransport struct {
+		logger  *zap.Logger
+		timeout time.Duration
+		slots   chan struct{}
+	}
+
+	limitedBuffer struct {
+		bytes.Buffer
+		limit int
+	}
+)
+
+func NewExecTransport(logger *zap.Logger, settings *CommandSettings) *ExecTransport {
+	concurrency := settings.Concurrency
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &ExecTransport{
+		logger,
+		settings.Timeout,
+		make(chan struct{}, concurrency),
+	}
+}
+
+// Execute runs a command until it exits, the context is done or the transport timeout is over,
+// whichever comes first
+func (t *ExecTransport) Execute(ctx context.Context, cmd *Command) error {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	select {
+	case t.slots <- struct{}{}:
+		defer func() { <-t.slots }()
+	case <-ctx.Done():
+		return &CommandError{
+			Path:     cmd.Path,
+			ExitCode: -1,
+			Err:      ErrCommandTimeout,
+		}
+	}
+
+	stdout := &limitedBuffer{limit: maxCommandOutput}
+	stderr := &limitedBuffer{limit: maxCommandOutput}
+
+	proc := exec.Command(cmd.Path, cmd.Args...)
+	proc.Env = append(os.Environ(), cmd.Env...)
+	proc.Stdin = bytes.NewReader(cmd.Stdin)
+	proc.Stdout = stdout
+	proc.Stderr = stderr
+
+	setProcessGroup(proc)
+
+	released, err := run(ctx, proc)
+
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = ErrCommandTimeout
+	}
+
+	failed := &CommandError{
+		Path:     cmd.Path,
+		ExitCode: -1,
+		Err:      err,
+	}
+
+	// the output is still being written while processes which escaped the group keep it open
+	if released {
+		if proc.ProcessState != nil {
+			failed.ExitCode = proc.ProcessState.ExitCode()
+		}
+
+		failed.Stdout = stdout.String()
+		failed.Stderr = stderr.String()
+	}
+
+	t.logger.Error(
+		"command failed",
+		zap.String("path", cmd.Path),
+		zap.Int("exit code", failed.ExitCode),
+		zap.Error(err),
+	)
+
+	return failed
+}
+
+// run waits for a process and kills its process group once the context is done.
+// Processes which escaped the group are given commandWaitDelay to release stdout and stderr,
+// released is false if they did not and the process is left behind.
+func run(ctx context.Context, proc *exec.Cmd) (released bool, err error) {
+	if err := proc.Start(); err != nil {
+		return true, err
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- proc.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return true, err
+	case <-ctx.Done():
+	}
+
+	killProcessGroup(proc)
+
+	timer := time.NewTimer(commandWaitDelay)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			// exited on its own right before the deadline
+			return true, nil
+		}
+
+		return true, ctx.Err()
+	case <-timer.C:
+		return false, ctx.Err()
+	}
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf(
+		"command %s failed with exit code %d: %s (stdout: %q, stderr: %q)",
+		e.Path,
+		e.ExitCode,
+		e.Err,
+		e.Stdout,
+		e.Stderr,
+	)
+}
+
+func (e *CommandError) Cause() error {
+	return e.Err
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	size := len(p)
+	available := b.limit - b.Len()
+
+	if available <= 0 {
+		return size, nil
+	}
+
+	if len(p) > available {
+		p = p[:available]
+	}
+
+	b.Buffer.Write(p)
+
+	return size, nil
+}
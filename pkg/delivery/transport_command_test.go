@@ -0,0 +1,243 @@
+package delivery_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestExecTransportCapturesFailure(t *testing.T) {
+	script := createScript(t, "echo out; echo err >&2; exit 3")
+
+	transport := delivery.NewExecTransport(zap.NewNop(), &delivery.CommandSettings{
+		Enabled:     true,
+		Timeout:     time.Second * 5,
+		Concurrency: 1,
+	})
+
+	err := transport.Execute(context.Background(), &delivery.Command{Path: script})
+
+	cmdErr, ok := err.(*delivery.CommandError)
+
+	assert.True(t, ok, "command error")
+	assert.Equal(t, 3, cmdErr.ExitCode, "exit code")
+	assert.Equal(t, "out\n", cmdErr.Stdout, "stdout")
+	assert.Equal(t, "err\n", cmdErr.Stderr, "stderr")
+}
+
+func TestExecTransportTimeout(t *testing.T) {
+	script := createScript(t, "sleep 5")
+
+	transport := delivery.NewExecTransport(zap.NewNop(), &delivery.CommandSettings{
+		Enabled:     true,
+		Timeout:     time.Millisecond * 100,
+		Concurrency: 1,
+	})
+
+	started := time.Now()
+	err := transport.Execute(context.Background(), &delivery.Command{Path: script})
+
+	cmdErr, ok := err.(*delivery.CommandError)
+
+	assert.True(t, ok, "command error")
+	assert.Equal(t, delivery.ErrCommandTimeout, cmdErr.Err, "timeout error")
+	assert.True(t, time.Since(started) < time.Second*2, "children are killed with the command")
+}
+
+func TestExecTransportEscapedTimeout(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid is not available")
+	}
+
+	// the child leaves the process group and keeps stdout open after the command is killed
+	script := createScript(t, "setsid sleep 5 &\nsleep 5")
+
+	transport := delivery.NewExecTransport(zap.NewNop(), &delivery.CommandSettings{
+		Enabled:     true,
+		Timeout:     time.Millisecond * 100,
+		Concurrency: 1,
+	})
+
+	started := time.Now()
+	err := transport.Execute(context.Background(), &delivery.Command{Path: script})
+
+	cmdErr, ok := err.(*delivery.CommandError)
+
+	assert.True(t, ok, "command error")
+	assert.Equal(t, delivery.ErrCommandTimeout, cmdErr.Err, "timeout error")
+	assert.True(t, time.Since(started) < time.Second*3, "escaped processes are not waited for")
+}
+
+func TestSenderCommandEndpointTimeout(t *testing.T) {
+	script := createScript(t, "sleep 5")
+
+	sender := delivery.New(zap.NewNop(), delivery.NewMockTransport(nil))
+	sender.SetCommandTransport(delivery.NewExecTransport(zap.NewNop(), &delivery.CommandSettings{
+		Enabled:     true,
+		Timeout:     time.Second * 10,
+		Concurrency: 1,
+	}))
+
+	done := make(chan delivery.Event, 1)
+
+	sender.Subscribe(func(evt delivery.Event) {
+		done <- evt
+	})
+
+	subscriber := createCommandSubscriber(script)
+	subscriber.Endpoint.Policy = &notification.Policy{Timeout: 100}
+
+	started := time.Now()
+
+	assert.NoError(t, sender.Send(notification.NewMessage(
+		notification.FOUND,
+		"test",
+		createPeripheral(),
+		[]*notification.Subscriber{subscriber},
+	)))
+
+	select {
+	case evt := <-done:
+		cmdErr, ok := evt.Error.(*delivery.CommandError)
+
+		if assert.True(t, ok, "command error") {
+			assert.Equal(t, delivery.ErrCommandTimeout, cmdErr.Err, "timeout error")
+		}
+
+		assert.True(t, time.Since(started) < time.Second*2, "endpoint timeout is shorter than the transport one")
+	case <-time.After(time.Second * 5):
+		t.Fatal("delivery timeout")
+	}
+
+	states := sender.EndpointStates()
+
+	if assert.Len(t, states, 1) {
+		assert.Equal(t, 1, states[0].Failures, "timed out command is a failure")
+	}
+}
+
+func TestSenderCommandEndpoint(t *testing.T) {
+	dir := createTempDir(t)
+	output := filepath.Join(dir, "output")
+	script := createScript(t, "cat > "+output+"; echo >> "+output+"; echo $BEAGLE_EVENT >> "+output+
+		"; echo $BEAGLE_HEADER_X_TOKEN $BEAGLE_HEADER_PATH >> "+output)
+
+	sender := delivery.New(zap.NewNop(), delivery.NewMockTransport(nil))
+	sender.SetCommandTransport(delivery.NewExecTransport(zap.NewNop(), &delivery.CommandSettings{
+		Enabled:     true,
+		Timeout:     time.Second * 5,
+		Concurrency: 1,
+	}))
+
+	done := make(chan delivery.Event, 1)
+
+	sender.Subscribe(func(evt delivery.Event) {
+		done <- evt
+	})
+
+	subscriber := createCommandSubscriber(script)
+	subscriber.Endpoint.Headers = notification.Headers{"X-Token": "secret", "PATH": "/nowhere"}
+
+	err := sender.Send(notification.NewMessage(
+		notification.FOUND,
+		"test",
+		createPeripheral(),
+		[]*notification.Subscriber{subscriber},
+	))
+
+	assert.NoError(t, err, "send error")
+
+	select {
+	case evt := <-done:
+		assert.NoError(t, evt.Error, "delivery error")
+	case <-time.After(time.Second * 5):
+		t.Fatal("delivery timeout")
+	}
+
+	content, err := ioutil.ReadFile(output)
+
+	assert.NoError(t, err, "read output")
+	assert.Contains(t, string(content), `"event":"found"`, "stdin payload")
+	assert.Contains(t, string(content), "\nfound\n", "env variable")
+	assert.Contains(t, string(content), "\nsecret /nowhere\n", "prefixed headers do not override PATH")
+}
+
+func TestSenderCommandEndpointDisabled(t *testing.T) {
+	sender := delivery.New(zap.NewNop(), delivery.NewMockTransport(nil))
+
+	done := make(chan delivery.Event, 1)
+
+	sender.Subscribe(func(evt delivery.Event) {
+		done <- evt
+	})
+
+	err := sender.Send(notification.NewMessage(
+		notification.FOUND,
+		"test",
+		createPeripheral(),
+		[]*notification.Subscriber{createCommandSubscriber("/bin/true")},
+	))
+
+	assert.NoError(t, err, "send error")
+
+	select {
+	case evt := <-done:
+		assert.Equal(t, delivery.ErrCommandTransportDisabled, evt.Error, "delivery error")
+	case <-time.After(time.Second * 5):
+		t.Fatal("delivery timeout")
+	}
+
+	for _, state := range sender.EndpointStates() {
+		assert.Equal(t, 0, state.Failures, "disabled commands are not failures of endpoints")
+	}
+}
+
+func createCommandSubscriber(path string) *notification.Subscriber {
+	return &notification.Subscriber{
+		Id:    1,
+		Name:  "command",
+		Event: notification.FOUND,
+		Endpoint: &notification.Endpoint{
+			Id:   1,
+			Name: "command",
+			Kind: notification.ENDPOINT_KIND_COMMAND,
+			Url:  path,
+		},
+		Enabled: true,
+	}
+}
+
+func createTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	return dir
+}
+
+func createScript(t *testing.T, body string) string {
+	script := filepath.Join(createTempDir(t), "script.sh")
+
+	err := ioutil.WriteFile(script, []byte("#!/bin/sh\n"+body+"\n"), 0755)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return script
+}
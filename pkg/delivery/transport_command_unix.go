@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package delivery
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts a command in its own process group, so that its children can be killed with it
+func setProcessGroup(proc *exec.Cmd) {
+	proc.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills a command along with all processes it started
+func killProcessGroup(proc *exec.Cmd) error {
+	return syscall.Kill(-proc.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,9 @@
+package delivery
+
+import "os/exec"
+
+func setProcessGroup(proc *exec.Cmd) {}
+
+func killProcessGroup(proc *exec.Cmd) error {
+	return proc.Process.Kill()
+}
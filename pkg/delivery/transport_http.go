@@ -1,22 +1,33 @@
 package delivery
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
 	"github.com/sethgrid/pester"
 	"go.uber.org/zap"
-	"net/http"
 )
 
-const maxConcurrency = 250
+type (
+	HttpTransport struct {
+		engine *pester.Client
+	}
 
-type HttpTransport struct {
-	engine *pester.Client
-}
+	StatusError struct {
+		Url        string
+		StatusCode int
+	}
+)
 
 func NewHttpTransport(logger *zap.Logger) *HttpTransport {
 	engine := pester.New()
 	engine.Backoff = pester.ExponentialBackoff
 	engine.MaxRetries = 5
-	engine.Concurrency = maxConcurrency
+	// pester sends this number of identical requests at once,
+	// concurrency across deliveries is limited by endpoint policies instead
+	engine.Concurrency = 1
 	engine.KeepLog = true
 	engine.LogHook = func(e pester.ErrEntry) {
 		logger.Error(
@@ -35,8 +46,24 @@ func NewHttpTransport(logger *zap.Logger) *HttpTransport {
 	}
 }
 
-func (t *HttpTransport) Do(req *http.Request) error {
-	_, err := t.engine.Do(req)
+// Do sends a request and returns its response with an already consumed body
+func (t *HttpTransport) Do(req *http.Request) (*http.Response, error) {
+	res, err := t.engine.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return res, &StatusError{req.URL.String(), res.StatusCode}
+	}
+
+	return res, nil
+}
 
-	return err
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("endpoint %s responded with status %d", e.Url, e.StatusCode)
 }
@@ -1,7 +1,9 @@
 package delivery
 
 import (
+	"io/ioutil"
 	"net/http"
+	"strings"
 )
 
 type (
@@ -14,10 +16,17 @@ func NewMockTransport(engine func(req *http.Request) error) *MockTransport {
 	return &MockTransport{engine}
 }
 
-func (transport *MockTransport) Do(req *http.Request) error {
+func (transport *MockTransport) Do(req *http.Request) (*http.Response, error) {
 	if transport.engine != nil {
-		return transport.engine(req)
+		if err := transport.engine(req); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return &http.Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
 }
@@ -0,0 +1,88 @@
+//go:build !noble
+// +build !noble
+
+package devices
+
+import (
+	"context"
+
+	"github.com/blent/beagle/pkg/discovery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/go-ble/ble"
+	"go.uber.org/zap"
+)
+
+type BleDevice struct {
+	isScanning bool
+	logger     *zap.Logger
+	engine     ble.Device
+}
+
+func NewBleDevice(logger *zap.Logger, engine ble.Device) *BleDevice {
+	ble.SetDefaultDevice(engine)
+
+	device := &BleDevice{
+		isScanning: false,
+		logger:     logger,
+		engine:     engine,
+	}
+
+	return device
+}
+
+func (device *BleDevice) IsScanning() bool {
+	return device.isScanning
+}
+
+func (device *BleDevice) Scan(ctx context.Context) (*discovery.Stream, error) {
+	if device.isScanning {
+		return nil, ErrStartScanning
+	}
+
+	onData := make(chan peripherals.Peripheral, bufferSize)
+	onError := make(chan error, 1)
+
+	device.isScanning = true
+	go device.start(ctx, onData, onError)
+
+	return discovery.NewStream(onData, onError), nil
+}
+
+// start scans until the context is done and closes the channels once no more data can be sent
+func (device *BleDevice) start(ctx context.Context, inData chan<- peripherals.Peripheral, inError chan<- error) {
+	defer func() {
+		device.isScanning = false
+		close(inData)
+		close(inError)
+	}()
+
+	err := ble.Scan(ctx, true, func(adv ble.Advertisement) {
+		localName := adv.LocalName()
+		manufacturerData := adv.ManufacturerData()
+
+		if !peripherals.IsSupportedPeripheral(manufacturerData) {
+			return
+		}
+
+		peripheral, err := peripherals.NewPeripheral(
+			localName,
+			manufacturerData,
+			float64(adv.TxPowerLevel()),
+			float64(adv.RSSI()),
+			adv.Addr().String(),
+		)
+
+		if err == nil {
+			inData <- peripheral
+		} else {
+			device.logger.Error(
+				"failed to parse peripheral",
+				zap.Error(err),
+			)
+		}
+	}, nil)
+
+	if err != nil && ctx.Err() == nil {
+		inError <- err
+	}
+}
@@ -4,49 +4,73 @@ import (
 	"context"
 	"math/rand"
 	"strconv"
+	"sync"
 
 	"github.com/blent/beagle/pkg/discovery"
 	"github.com/blent/beagle/pkg/discovery/peripherals"
 )
 
 type MockDevice struct {
-	isScanning bool
+	mu          *sync.RWMutex
+	isScanning  bool
+	discoveries chan peripherals.Peripheral
 }
 
 func NewMockDevice() *MockDevice {
 	return &MockDevice{
-		isScanning: false,
+		mu:          &sync.RWMutex{},
+		isScanning:  false,
+		discoveries: make(chan peripherals.Peripheral, bufferSize),
 	}
 }
 
 func (device *MockDevice) IsScanning() bool {
+	device.mu.RLock()
+	defer device.mu.RUnlock()
+
 	return device.isScanning
 }
 
 func (device *MockDevice) Scan(ctx context.Context) (*discovery.Stream, error) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
 	if device.isScanning {
 		return nil, ErrStartScanning
 	}
 
-	onData := make(chan peripherals.Peripheral, 1000)
-	onError := make(chan error)
+	onData := make(chan peripherals.Peripheral, bufferSize)
+	onError := make(chan error, 1)
 
 	device.isScanning = true
 	go device.start(ctx, onData, onError)
-	go device.stopOnDone(ctx, onData, onError)
 
 	return discovery.NewStream(onData, onError), nil
 }
 
+// start forwards emitted discoveries until the context is done
 func (device *MockDevice) start(ctx context.Context, inData chan<- peripherals.Peripheral, inError chan<- error) {
+	defer func() {
+		device.mu.Lock()
+		device.isScanning = false
+		device.mu.Unlock()
 
-}
+		close(inData)
+		close(inError)
+	}()
 
-func (device *MockDevice) stopOnDone(ctx context.Context, inData chan peripherals.Peripheral, inError chan error) {
-	<-ctx.Done()
-	device.isScanning = false
-	close(inData)
-	close(inError)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case peripheral := <-device.discoveries:
+			select {
+			case inData <- peripheral:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
 }
 
 func (device *MockDevice) EmitDefaultDiscovery() {
@@ -62,19 +86,18 @@ func (device *MockDevice) EmitDefaultDiscovery() {
 }
 
 func (device *MockDevice) EmitDiscovery(id string, kind string, localName string, data []byte, power float64, rssi float64, address string) {
-	//if device.discoveryHandler != nil {
-	//	peripheral, err := peripherals.NewMockPeripheral(
-	//		id,
-	//		kind,
-	//		localName,
-	//		data,
-	//		power,
-	//		rssi,
-	//		address,
-	//	)
-	//
-	//	if err == nil {
-	//		device.discoveryHandler(peripheral)
-	//	}
-	//}
+	device.EmitPeripheral(peripherals.NewMockPeripheral(
+		id,
+		kind,
+		localName,
+		data,
+		power,
+		rssi,
+		address,
+	))
+}
+
+// EmitPeripheral queues a discovery, which is delivered once the device is scanning
+func (device *MockDevice) EmitPeripheral(peripheral peripherals.Peripheral) {
+	device.discoveries <- peripheral
 }
@@ -0,0 +1,11 @@
+//go:build noble || (!linux && !darwin)
+// +build noble !linux,!darwin
+
+package devices
+
+import "go.uber.org/zap"
+
+// NewDevice fails in builds without ble support, other kinds of devices are still available
+func NewDevice(logger *zap.Logger, hci int) (Device, error) {
+	return nil, ErrBleUnsupported
+}
@@ -0,0 +1,22 @@
+package devices
+
+import (
+	"context"
+
+	"github.com/blent/beagle/pkg/discovery"
+)
+
+// NoneDevice discovers nothing, it lets the server run without any hardware
+type NoneDevice struct {
+	*scanner
+}
+
+func NewNoneDevice() *NoneDevice {
+	return &NoneDevice{newScanner()}
+}
+
+func (device *NoneDevice) Scan(ctx context.Context) (*discovery.Stream, error) {
+	return device.scan(ctx, func(ctx context.Context, emit emitFunc) error {
+		return nil
+	})
+}
@@ -0,0 +1,143 @@
+package devices
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type (
+	// Record is a recorded advertisement, At is a time since the start of the recording, e.g. "1.5s",
+	// Data is hex encoded manufacturer data
+	Record struct {
+		At      string  `json:"at"`
+		Name    string  `json:"name"`
+		Data    string  `json:"data"`
+		Power   float64 `json:"power"`
+		Rssi    float64 `json:"rssi"`
+		Address string  `json:"address"`
+	}
+
+	replayed struct {
+		at         time.Duration
+		peripheral peripherals.Peripheral
+	}
+
+	// ReplayDevice emits recorded advertisements at their time since the start of a scan
+	ReplayDevice struct {
+		*scanner
+		logger  *zap.Logger
+		records []*replayed
+	}
+)
+
+// NewReplayDevice reads a file of records, one JSON object per line.
+// Empty lines and lines starting with # are skipped.
+func NewReplayDevice(logger *zap.Logger, path string) (*ReplayDevice, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	records := make([]*replayed, 0, 100)
+	lines := bufio.NewScanner(file)
+	number := 0
+
+	for lines.Scan() {
+		number++
+
+		line := bytes.TrimSpace(lines.Bytes())
+
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		record, err := parseRecord(line)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d", path, number)
+		}
+
+		if len(records) > 0 && record.at < records[len(records)-1].at {
+			return nil, errors.Wrapf(ErrInvalidRecord, "%s:%d: records must be ordered by time", path, number)
+		}
+
+		records = append(records, record)
+	}
+
+	if err := lines.Err(); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	logger.Info("Replay is loaded", zap.String("path", path), zap.Int("records", len(records)))
+
+	return &ReplayDevice{newScanner(), logger, records}, nil
+}
+
+func parseRecord(line []byte) (*replayed, error) {
+	var record Record
+
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, errors.Wrap(ErrInvalidRecord, err.Error())
+	}
+
+	at, err := time.ParseDuration(record.At)
+
+	if err != nil || at < 0 {
+		return nil, errors.Wrap(ErrInvalidRecord, "at must be a non-negative duration")
+	}
+
+	data, err := hex.DecodeString(record.Data)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidRecord, "data must be hex encoded manufacturer data")
+	}
+
+	peripheral, err := peripherals.NewPeripheral(record.Name, data, record.Power, record.Rssi, record.Address)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidRecord, err.Error())
+	}
+
+	return &replayed{at, peripheral}, nil
+}
+
+// Scan replays all records once, after the last one the device stays silent until the context is done
+func (device *ReplayDevice) Scan(ctx context.Context) (*discovery.Stream, error) {
+	return device.scan(ctx, func(ctx context.Context, emit emitFunc) error {
+		started := time.Now()
+
+		for _, record := range device.records {
+			if wait := record.at - time.Since(started); wait > 0 {
+				timer := time.NewTimer(wait)
+
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil
+				case <-timer.C:
+				}
+			}
+
+			if !emit(record.peripheral) {
+				return nil
+			}
+		}
+
+		device.logger.Info("Replay is finished")
+
+		return nil
+	})
+}
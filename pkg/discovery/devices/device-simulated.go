@@ -0,0 +1,70 @@
+package devices
+
+import (
+	"context"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery"
+	"go.uber.org/zap"
+)
+
+// SimulatedDevice advertises a simulated fleet, each scan starts the scenario over
+type SimulatedDevice struct {
+	*scanner
+	logger   *zap.Logger
+	scenario *Scenario
+}
+
+// NewSimulatedDevice loads a scenario file given by settings,
+// without it the fleet is made of iBeacons which stay in range at a fixed distance
+func NewSimulatedDevice(logger *zap.Logger, settings *SimulatedSettings) (*SimulatedDevice, error) {
+	if settings.Scenario == "" {
+		return NewScenarioDevice(logger, newSettingsScenario(settings)), nil
+	}
+
+	scenario, err := LoadScenario(settings.Scenario)
+
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info(
+		"Scenario is loaded",
+		zap.String("path", settings.Scenario),
+		zap.Int("peripherals", scenario.Size()),
+		zap.Int64("seed", scenario.Seed),
+	)
+
+	return NewScenarioDevice(logger, scenario), nil
+}
+
+// NewScenarioDevice simulates a given valid scenario
+func NewScenarioDevice(logger *zap.Logger, scenario *Scenario) *SimulatedDevice {
+	return &SimulatedDevice{newScanner(), logger, scenario}
+}
+
+// Scan advances the simulation every tick by the time passed since the start,
+// it catches up when discoveries are consumed slower than they are sent
+func (device *SimulatedDevice) Scan(ctx context.Context) (*discovery.Stream, error) {
+	return device.scan(ctx, func(ctx context.Context, emit emitFunc) error {
+		fleet := NewFleet(device.scenario)
+		started := time.Now()
+
+		ticker := time.NewTicker(device.scenario.Tick)
+		defer ticker.Stop()
+
+		for {
+			for _, peripheral := range fleet.Advance(time.Since(started)) {
+				if !emit(peripheral) {
+					return nil
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+}
@@ -4,93 +4,29 @@ import (
 	"context"
 
 	"github.com/blent/beagle/pkg/discovery"
-	"github.com/blent/beagle/pkg/discovery/peripherals"
-	"github.com/go-ble/ble"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
-type (
-	Device interface {
-		IsScanning() bool
-		Scan(context.Context) (*discovery.Stream, error)
-	}
-
-	BleDevice struct {
-		isScanning bool
-		logger     *zap.Logger
-		engine     ble.Device
-	}
-)
-
-const bufferSize = 500
-
-func NewBleDevice(logger *zap.Logger, engine ble.Device) *BleDevice {
-	ble.SetDefaultDevice(engine)
-
-	device := &BleDevice{
-		isScanning: false,
-		logger:     logger,
-		engine:     engine,
-	}
-
-	return device
-}
-
-func (device *BleDevice) IsScanning() bool {
-	return device.isScanning
+type Device interface {
+	IsScanning() bool
+	Scan(context.Context) (*discovery.Stream, error)
 }
 
-func (device *BleDevice) Scan(ctx context.Context) (*discovery.Stream, error) {
-	if device.isScanning {
-		return nil, ErrStartScanning
-	}
-
-	onData := make(chan peripherals.Peripheral, bufferSize)
-	onError := make(chan error)
-
-	device.isScanning = true
-	go device.start(ctx, onData, onError)
-	go device.stopOnDone(ctx, onData, onError)
-
-	return discovery.NewStream(onData, onError), nil
-}
-
-func (device *BleDevice) start(ctx context.Context, inData chan<- peripherals.Peripheral, inError chan<- error) {
-	err := ble.Scan(ctx, true, func(adv ble.Advertisement) {
-		localName := adv.LocalName()
-		manufacturerData := adv.ManufacturerData()
-
-		if !peripherals.IsSupportedPeripheral(manufacturerData) {
-			return
-		}
-
-		peripheral, err := peripherals.NewPeripheral(
-			localName,
-			manufacturerData,
-			float64(adv.TxPowerLevel()),
-			float64(adv.RSSI()),
-			adv.Addr().String(),
-		)
-
-		if err == nil {
-			inData <- peripheral
-		} else {
-			device.logger.Error(
-				"failed to parse peripheral",
-				zap.Error(err),
-			)
-		}
-	}, nil)
+const bufferSize = 500
 
-	if err != nil {
-		device.isScanning = false
-		inError <- err
+// New creates a device of a kind given by settings
+func New(logger *zap.Logger, settings *Settings) (Device, error) {
+	switch settings.Kind {
+	case DEVICE_KIND_BLE:
+		return NewDevice(logger, settings.Hci)
+	case DEVICE_KIND_REPLAY:
+		return NewReplayDevice(logger, settings.Replay)
+	case DEVICE_KIND_SIMULATED:
+		return NewSimulatedDevice(logger, settings.Simulated)
+	case DEVICE_KIND_NONE:
+		return NewNoneDevice(), nil
+	default:
+		return nil, errors.Wrap(ErrUnsupportedKind, settings.Kind)
 	}
 }
-
-func (device *BleDevice) stopOnDone(ctx context.Context, inData chan peripherals.Peripheral, inError chan error) {
-	<-ctx.Done()
-	device.isScanning = false
-	close(inData)
-	close(inError)
-}
@@ -1,3 +1,6 @@
+//go:build !noble
+// +build !noble
+
 package devices
 
 import (
@@ -5,7 +8,8 @@ import (
 	"go.uber.org/zap"
 )
 
-func NewDevice(logger *zap.Logger) (*BleDevice, error) {
+// NewDevice opens the default bluetooth controller, the hci index is ignored
+func NewDevice(logger *zap.Logger, hci int) (Device, error) {
 	engine, err := darwin.NewDevice()
 
 	if err != nil {
@@ -1,15 +1,22 @@
+//go:build !noble
+// +build !noble
+
 package devices
 
 import (
+	"github.com/go-ble/ble"
 	"github.com/go-ble/ble/linux"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
-func NewDevice(logger *zap.Logger) (*BleDevice, error) {
-	engine, err := linux.NewDevice()
+// NewDevice opens a given host controller interface,
+// which requires root or CAP_NET_RAW and CAP_NET_ADMIN capabilities
+func NewDevice(logger *zap.Logger, hci int) (Device, error) {
+	engine, err := linux.NewDevice(ble.OptDeviceID(hci))
 
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "failed to open hci%d", hci)
 	}
 
 	return NewBleDevice(logger, engine), nil
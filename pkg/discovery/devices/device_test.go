@@ -0,0 +1,220 @@
+package devices_test
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery"
+	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testUuid = "f7826da64fa24e988024bc5b71e0893e"
+
+func writeReplay(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "replay.jsonl")
+
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func record(at string, minor uint16, rssi int) string {
+	data, _ := peripherals.CreateIBeaconData(testUuid, 1, minor, -59)
+
+	return fmt.Sprintf(
+		`{"at": "%s", "data": "%s", "power": -59, "rssi": %d, "address": "aa:bb:cc:dd:ee:%02x"}`,
+		at,
+		hex.EncodeToString(data),
+		rssi,
+		minor,
+	)
+}
+
+func receive(t *testing.T, stream *discovery.Stream, count int) []peripherals.Peripheral {
+	res := make([]peripherals.Peripheral, 0, count)
+	timeout := time.After(time.Second * 5)
+
+	for len(res) < count {
+		select {
+		case peripheral := <-stream.Data():
+			res = append(res, peripheral)
+		case err := <-stream.Error():
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("received %d of %d discoveries", len(res), count)
+		}
+	}
+
+	return res
+}
+
+// assertClosed checks that the stream is open until the scan is canceled
+func assertClosed(t *testing.T, device devices.Device, stream *discovery.Stream, cancel func()) {
+	select {
+	case peripheral, ok := <-stream.Data():
+		t.Fatalf("unexpected discovery %v, open %t", peripheral, ok)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	assert.True(t, device.IsScanning(), "scanning")
+
+	cancel()
+
+	select {
+	case _, ok := <-stream.Data():
+		assert.False(t, ok, "closed stream")
+	case <-time.After(time.Second * 5):
+		t.Fatal("stream is not closed")
+	}
+
+	assert.False(t, device.IsScanning(), "stopped")
+}
+
+func TestNew(t *testing.T) {
+	settings := &devices.Settings{
+		Kind:      devices.DEVICE_KIND_NONE,
+		Simulated: &devices.SimulatedSettings{Peripherals: 1, Interval: time.Second},
+	}
+
+	device, err := devices.New(zap.NewNop(), settings)
+
+	assert.NoError(t, err)
+	assert.IsType(t, &devices.NoneDevice{}, device)
+
+	settings.Kind = devices.DEVICE_KIND_SIMULATED
+	device, err = devices.New(zap.NewNop(), settings)
+
+	assert.NoError(t, err)
+	assert.IsType(t, &devices.SimulatedDevice{}, device)
+
+	settings.Kind = "usb"
+	_, err = devices.New(zap.NewNop(), settings)
+
+	assert.True(t, errors.Cause(err) == devices.ErrUnsupportedKind, "unsupported kind")
+}
+
+func TestNoneDevice(t *testing.T) {
+	device := devices.NewNoneDevice()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, err := device.Scan(ctx)
+	assert.NoError(t, err)
+
+	_, err = device.Scan(ctx)
+	assert.Equal(t, devices.ErrStartScanning, err, "second scan")
+
+	assertClosed(t, device, stream, cancel)
+}
+
+func TestReplayDevice(t *testing.T) {
+	path, cleanup := writeReplay(t, fmt.Sprintf(
+		"# recorded in the office\n%s\n\n%s\n%s\n",
+		record("0s", 1, -60),
+		record("100ms", 2, -70),
+		record("100ms", 1, -62),
+	))
+	defer cleanup()
+
+	device, err := devices.NewReplayDevice(zap.NewNop(), path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := time.Now()
+
+	stream, err := device.Scan(ctx)
+	assert.NoError(t, err)
+
+	replayed := receive(t, stream, 3)
+
+	assert.True(t, time.Since(started) >= time.Millisecond*100, "records are replayed at their time")
+	assert.Equal(t, peripherals.CreateIBeaconUniqueKey(testUuid, 1, 1), replayed[0].UniqueKey())
+	assert.Equal(t, peripherals.CreateIBeaconUniqueKey(testUuid, 1, 2), replayed[1].UniqueKey())
+	assert.Equal(t, float64(-62), replayed[2].RSSI())
+	assert.Equal(t, "aa:bb:cc:dd:ee:02", replayed[1].Address())
+
+	assertClosed(t, device, stream, cancel)
+}
+
+func TestReplayDeviceInvalidRecords(t *testing.T) {
+	invalid := map[string]string{
+		"unordered": record("1s", 1, -60) + "\n" + record("0s", 1, -60),
+		"duration":  record("soon", 1, -60),
+		"data":      `{"at": "0s", "data": "zz"}`,
+		"json":      `{"at": "0s"`,
+	}
+
+	for name, content := range invalid {
+		path, cleanup := writeReplay(t, content)
+
+		_, err := devices.NewReplayDevice(zap.NewNop(), path)
+
+		assert.True(t, errors.Cause(err) == devices.ErrInvalidRecord, name)
+
+		cleanup()
+	}
+
+	_, err := devices.NewReplayDevice(zap.NewNop(), "/nonexistent/replay.jsonl")
+
+	assert.Error(t, err, "missing file")
+}
+
+func TestSimulatedDevice(t *testing.T) {
+	device, err := devices.NewSimulatedDevice(zap.NewNop(), &devices.SimulatedSettings{
+		Peripherals: 3,
+		Interval:    time.Millisecond * 20,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := device.Scan(ctx)
+	assert.NoError(t, err)
+
+	discovered := receive(t, stream, 6)
+
+	for idx := 0; idx < len(discovered); idx += 3 {
+		keys := make([]string, 0, 3)
+
+		for _, peripheral := range discovered[idx : idx+3] {
+			keys = append(keys, peripheral.UniqueKey())
+			assert.Equal(t, float64(-65), peripheral.RSSI())
+		}
+
+		assert.ElementsMatch(t, []string{
+			peripherals.CreateIBeaconUniqueKey(devices.SIMULATED_UUID, 1, 1),
+			peripherals.CreateIBeaconUniqueKey(devices.SIMULATED_UUID, 1, 2),
+			peripherals.CreateIBeaconUniqueKey(devices.SIMULATED_UUID, 1, 3),
+		}, keys, "fleet is advertised every interval")
+	}
+
+	_, err = devices.NewSimulatedDevice(zap.NewNop(), &devices.SimulatedSettings{Scenario: "/nonexistent/fleet.yml"})
+
+	assert.Error(t, err, "missing scenario")
+}
@@ -3,6 +3,9 @@ package devices
 import "github.com/pkg/errors"
 
 var (
-	ErrStartScanning = errors.New("device is already started scanning")
-	ErrStopScanning  = errors.New("device is already stopped scanning")
+	ErrStartScanning   = errors.New("device is already started scanning")
+	ErrStopScanning    = errors.New("device is already stopped scanning")
+	ErrUnsupportedKind = errors.New("device kind is not supported")
+	ErrBleUnsupported  = errors.New("ble devices are not supported by this build")
+	ErrInvalidRecord   = errors.New("invalid advertisement record")
 )
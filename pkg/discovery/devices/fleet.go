@@ -0,0 +1,201 @@
+package devices
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+)
+
+type (
+	// beacon is a state of a simulated beacon, it advertises at next since the start of a simulation
+	beacon struct {
+		index    int
+		group    *BeaconGroup
+		data     []byte
+		instance string
+		address  string
+		offset   time.Duration
+		next     time.Duration
+		moves    int
+		present  bool
+		mode     string
+		rssi     float64
+	}
+
+	// schedule is a heap of beacons ordered by their next advertisement
+	schedule []*beacon
+
+	// Fleet is a simulation of a scenario, it is not safe for concurrent use.
+	// All randomness comes from the seed, so that the same scenario gives the same advertisements.
+	Fleet struct {
+		rng      *rand.Rand
+		schedule schedule
+	}
+)
+
+func NewFleet(scenario *Scenario) *Fleet {
+	rng := rand.New(rand.NewSource(scenario.Seed))
+	beacons := make(schedule, 0, scenario.Size())
+
+	for _, group := range scenario.Groups {
+		for member := 0; member < group.Count; member++ {
+			beacons = append(beacons, newBeacon(rng, len(beacons), group, member))
+		}
+	}
+
+	heap.Init(&beacons)
+
+	return &Fleet{rng, beacons}
+}
+
+func newBeacon(rng *rand.Rand, index int, group *BeaconGroup, member int) *beacon {
+	b := &beacon{
+		index:   index,
+		group:   group,
+		address: fmt.Sprintf("5e:00:%02x:%02x:%02x:%02x", (index+1)>>24&0xff, (index+1)>>16&0xff, (index+1)>>8&0xff, (index+1)&0xff),
+		present: true,
+		mode:    MOVE_WANDER,
+		rssi:    group.Far + rng.Float64()*(group.Near-group.Far),
+		// beacons advertise once within the first interval at a random phase
+		next: time.Duration(rng.Int63n(int64(group.Interval))),
+	}
+
+	if group.Spread > 0 {
+		b.offset = time.Duration(rng.Int63n(int64(group.Spread)))
+	}
+
+	if group.Kind == peripherals.PERIPHERAL_EDDYSTONE {
+		b.instance = fmt.Sprintf("%012x", int(group.Minor)+member)
+	} else {
+		b.data, _ = peripherals.CreateIBeaconData(group.Uuid, group.Major, group.Minor+uint16(member), int8(group.Power))
+	}
+
+	// beacons which arrive first are out of range at the start
+	for _, move := range group.Script {
+		if move.Do == MOVE_ARRIVE || move.Do == MOVE_LEAVE {
+			b.present = move.Do != MOVE_ARRIVE
+			break
+		}
+	}
+
+	return b
+}
+
+// Size returns a number of simulated beacons
+func (fleet *Fleet) Size() int {
+	return len(fleet.schedule)
+}
+
+// Advance moves the simulation to a given time since its start
+// and returns advertisements sent since the previous call in the order they are sent
+func (fleet *Fleet) Advance(elapsed time.Duration) []peripherals.Peripheral {
+	res := make([]peripherals.Peripheral, 0, 16)
+
+	for len(fleet.schedule) > 0 && fleet.schedule[0].next <= elapsed {
+		b := fleet.schedule[0]
+		group := b.group
+
+		b.move(b.next)
+		b.walk(fleet.rng)
+
+		if b.present && (group.Loss == 0 || fleet.rng.Float64() >= group.Loss) {
+			res = append(res, b.advertise())
+		}
+
+		b.next += group.Interval
+
+		if group.Jitter > 0 {
+			b.next += time.Duration(fleet.rng.Int63n(int64(group.Jitter) + 1))
+		}
+
+		heap.Fix(&fleet.schedule, 0)
+	}
+
+	return res
+}
+
+// move applies script moves due at a given time
+func (b *beacon) move(at time.Duration) {
+	script := b.group.Script
+
+	for b.moves < len(script) && script[b.moves].At+b.offset <= at {
+		switch script[b.moves].Do {
+		case MOVE_ARRIVE:
+			b.present = true
+			b.mode = MOVE_APPROACH
+			b.rssi = b.group.Far
+		case MOVE_LEAVE:
+			b.present = false
+		default:
+			b.mode = script[b.moves].Do
+		}
+
+		b.moves++
+	}
+}
+
+// walk changes RSSI by a random step, which drifts to near or far ends while approaching or retreating
+func (b *beacon) walk(rng *rand.Rand) {
+	group := b.group
+
+	if group.Step == 0 {
+		return
+	}
+
+	delta := (rng.Float64()*2 - 1) * group.Step
+
+	switch b.mode {
+	case MOVE_APPROACH:
+		delta += group.Step / 2
+	case MOVE_RETREAT:
+		delta -= group.Step / 2
+	}
+
+	b.rssi = math.Max(group.Far, math.Min(group.Near, b.rssi+delta))
+}
+
+func (b *beacon) advertise() peripherals.Peripheral {
+	group := b.group
+	rssi := math.Round(b.rssi)
+
+	if group.Kind == peripherals.PERIPHERAL_EDDYSTONE {
+		return peripherals.NewEddystoneUidPeripheral(group.Uuid, b.instance, group.Name, group.Power, rssi, b.address)
+	}
+
+	peripheral, _ := peripherals.NewIBeaconPeripheral(group.Name, b.data, group.Power, rssi, b.address)
+
+	return peripheral
+}
+
+func (s schedule) Len() int {
+	return len(s)
+}
+
+// Less orders beacons advertising at the same time by their index, which keeps simulations deterministic
+func (s schedule) Less(i, j int) bool {
+	if s[i].next == s[j].next {
+		return s[i].index < s[j].index
+	}
+
+	return s[i].next < s[j].next
+}
+
+func (s schedule) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s *schedule) Push(x interface{}) {
+	*s = append(*s, x.(*beacon))
+}
+
+func (s *schedule) Pop() interface{} {
+	old := *s
+	last := old[len(old)-1]
+	*s = old[:len(old)-1]
+
+	return last
+}
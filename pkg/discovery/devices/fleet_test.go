@@ -0,0 +1,261 @@
+package devices_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeScenario(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "fleet.yml")
+
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func createScenario(seed int64, groups ...*devices.BeaconGroup) *devices.Scenario {
+	for _, group := range groups {
+		if group.Kind == "" {
+			group.Kind = peripherals.PERIPHERAL_IBEACON
+			group.Uuid = testUuid
+		}
+
+		if group.Near == 0 && group.Far == 0 {
+			group.Near = -45
+			group.Far = -90
+		}
+
+		group.Power = -59
+	}
+
+	return &devices.Scenario{Seed: seed, Tick: time.Millisecond * 100, Groups: groups}
+}
+
+func advance(fleet *devices.Fleet, until, step time.Duration) []peripherals.Peripheral {
+	res := make([]peripherals.Peripheral, 0, 100)
+
+	for elapsed := step; elapsed <= until; elapsed += step {
+		res = append(res, fleet.Advance(elapsed)...)
+	}
+
+	return res
+}
+
+func TestLoadScenario(t *testing.T) {
+	path, cleanup := writeScenario(t, `
+seed: 42
+groups:
+  - count: 1000
+    minor: 100
+    jitter: 200ms
+    step: 2
+    loss: 0.1
+    spread: 5s
+    script:
+      - {at: 0s, do: arrive}
+      - {at: 30s, do: wander}
+      - {at: 1m, do: leave}
+  - kind: eddystone
+    name: tag
+    count: 5
+    interval: 100ms
+    near: -50
+    far: -70
+`)
+	defer cleanup()
+
+	scenario, err := devices.LoadScenario(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, int64(42), scenario.Seed)
+	assert.Equal(t, time.Millisecond*100, scenario.Tick, "default tick")
+	assert.Equal(t, 1005, scenario.Size())
+
+	beacons := scenario.Groups[0]
+
+	assert.Equal(t, peripherals.PERIPHERAL_IBEACON, beacons.Kind)
+	assert.Equal(t, devices.SIMULATED_UUID, beacons.Uuid)
+	assert.Equal(t, time.Second, beacons.Interval)
+	assert.Equal(t, float64(-45), beacons.Near)
+	assert.Equal(t, float64(-90), beacons.Far)
+	assert.Equal(t, time.Minute, beacons.Script[2].At)
+
+	tags := scenario.Groups[1]
+
+	assert.Equal(t, devices.SIMULATED_NAMESPACE, tags.Uuid)
+	assert.Equal(t, float64(-59), tags.Power)
+	assert.Equal(t, float64(-70), tags.Far)
+}
+
+func TestLoadScenarioInvalid(t *testing.T) {
+	invalid := map[string]string{
+		"no groups":   "seed: 1",
+		"count":       "groups: [{count: 0}]",
+		"kind":        "groups: [{count: 1, kind: altbeacon}]",
+		"uuid":        "groups: [{count: 1, uuid: abc}]",
+		"namespace":   "groups: [{count: 1, kind: eddystone, uuid: " + testUuid + "}]",
+		"minors":      "groups: [{count: 10, minor: 65530}]",
+		"near":        "groups: [{count: 1, near: -90, far: -40}]",
+		"loss":        "groups: [{count: 1, loss: 1}]",
+		"move":        "groups: [{count: 1, script: [{at: 1s, do: teleport}]}]",
+		"moves order": "groups: [{count: 1, script: [{at: 2s, do: leave}, {at: 1s, do: arrive}]}]",
+	}
+
+	for name, content := range invalid {
+		path, cleanup := writeScenario(t, content)
+
+		_, err := devices.LoadScenario(path)
+
+		assert.True(t, errors.Cause(err) == devices.ErrInvalidScenario, name)
+
+		cleanup()
+	}
+
+	path, cleanup := writeScenario(t, "groups: [{count: 1, intervl: 1s}]")
+	defer cleanup()
+
+	_, err := devices.LoadScenario(path)
+
+	assert.Error(t, err, "unknown key")
+}
+
+func TestFleetIsDeterministic(t *testing.T) {
+	group := func() *devices.BeaconGroup {
+		return &devices.BeaconGroup{
+			Count:    50,
+			Interval: time.Millisecond * 300,
+			Jitter:   time.Millisecond * 50,
+			Step:     3,
+			Loss:     0.2,
+			Spread:   time.Second,
+			Script: []*devices.Move{
+				{At: time.Second, Do: devices.MOVE_ARRIVE},
+				{At: time.Second * 3, Do: devices.MOVE_LEAVE},
+			},
+		}
+	}
+
+	first := advance(devices.NewFleet(createScenario(7, group())), time.Second*5, time.Millisecond*100)
+	second := advance(devices.NewFleet(createScenario(7, group())), time.Second*5, time.Millisecond*700)
+	other := advance(devices.NewFleet(createScenario(8, group())), time.Second*5, time.Millisecond*100)
+
+	if assert.Equal(t, len(first), len(second), "advertisements do not depend on ticks") {
+		for idx := range first {
+			assert.Equal(t, first[idx].UniqueKey(), second[idx].UniqueKey())
+			assert.Equal(t, first[idx].RSSI(), second[idx].RSSI())
+		}
+	}
+
+	assert.NotEqual(t, len(first), len(other), "another seed")
+}
+
+func TestFleetScript(t *testing.T) {
+	fleet := devices.NewFleet(createScenario(1, &devices.BeaconGroup{
+		Count:    10,
+		Interval: time.Millisecond * 100,
+		Step:     2,
+		Script: []*devices.Move{
+			{At: time.Second, Do: devices.MOVE_ARRIVE},
+			{At: time.Second * 3, Do: devices.MOVE_LEAVE},
+		},
+	}))
+
+	assert.Equal(t, 10, fleet.Size())
+	assert.Empty(t, fleet.Advance(time.Second-time.Millisecond), "not arrived yet")
+
+	arrived := fleet.Advance(time.Second + time.Millisecond*99)
+
+	if assert.Len(t, arrived, 10, "arrived") {
+		for _, peripheral := range arrived {
+			assert.True(t, peripheral.RSSI() <= -87, "arrived far away")
+		}
+	}
+
+	approached := fleet.Advance(time.Second*3 - time.Millisecond)
+	total := 0.0
+
+	for _, peripheral := range approached[len(approached)-10:] {
+		total += peripheral.RSSI()
+	}
+
+	assert.True(t, total/10 > -80, "approaching")
+	assert.Empty(t, fleet.Advance(time.Second*5), "left")
+}
+
+func TestFleetWalkAndLoss(t *testing.T) {
+	fleet := devices.NewFleet(createScenario(3,
+		&devices.BeaconGroup{
+			Count:    100,
+			Minor:    1,
+			Interval: time.Second,
+			Near:     -50,
+			Far:      -60,
+			Step:     5,
+			Loss:     0.25,
+		},
+		&devices.BeaconGroup{
+			Kind:     peripherals.PERIPHERAL_EDDYSTONE,
+			Uuid:     devices.SIMULATED_NAMESPACE,
+			Count:    2,
+			Minor:    255,
+			Interval: time.Second,
+		},
+	))
+
+	beacons := 0
+	keys := make(map[string]bool)
+
+	for _, peripheral := range fleet.Advance(time.Second*10 - 1) {
+		keys[peripheral.UniqueKey()] = true
+
+		if peripheral.Kind() == peripherals.PERIPHERAL_EDDYSTONE {
+			continue
+		}
+
+		beacons++
+
+		assert.True(t, peripheral.RSSI() >= -60 && peripheral.RSSI() <= -50, "rssi within bounds")
+	}
+
+	assert.InDelta(t, 750, beacons, 50, "a quarter of advertisements is lost")
+	assert.Len(t, keys, 102)
+	assert.True(t, keys[peripherals.CreateEddystoneUniqueKey(devices.SIMULATED_NAMESPACE, "0000000000ff")], "eddystone")
+	assert.True(t, keys[peripherals.CreateIBeaconUniqueKey(testUuid, 0, 100)], "ibeacon")
+}
+
+func BenchmarkFleetAdvance(b *testing.B) {
+	fleet := devices.NewFleet(createScenario(1, &devices.BeaconGroup{
+		Count:    1000,
+		Interval: time.Second,
+		Jitter:   time.Millisecond * 100,
+		Step:     2,
+		Loss:     0.1,
+	}))
+
+	b.ResetTimer()
+
+	for i := 1; i <= b.N; i++ {
+		fleet.Advance(time.Duration(i) * time.Second)
+	}
+}
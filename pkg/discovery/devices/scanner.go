@@ -0,0 +1,78 @@
+package devices
+
+import (
+	"context"
+	"sync"
+
+	"github.com/blent/beagle/pkg/discovery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+)
+
+type (
+	// emitFunc sends a discovery and reports false once the scan is stopped
+	emitFunc func(peripherals.Peripheral) bool
+
+	// producer emits discoveries until the context is done or it runs out of them
+	producer func(ctx context.Context, emit emitFunc) error
+
+	// scanner runs one scan at a time for devices which produce discoveries themselves
+	scanner struct {
+		mu         *sync.RWMutex
+		isScanning bool
+	}
+)
+
+func newScanner() *scanner {
+	return &scanner{mu: &sync.RWMutex{}}
+}
+
+func (s *scanner) IsScanning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.isScanning
+}
+
+// scan runs a producer in background, the stream stays open until the context is done
+func (s *scanner) scan(ctx context.Context, produce producer) (*discovery.Stream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isScanning {
+		return nil, ErrStartScanning
+	}
+
+	onData := make(chan peripherals.Peripheral, bufferSize)
+	onError := make(chan error, 1)
+
+	s.isScanning = true
+	go s.start(ctx, produce, onData, onError)
+
+	return discovery.NewStream(onData, onError), nil
+}
+
+func (s *scanner) start(ctx context.Context, produce producer, inData chan<- peripherals.Peripheral, inError chan<- error) {
+	defer func() {
+		s.mu.Lock()
+		s.isScanning = false
+		s.mu.Unlock()
+
+		close(inData)
+		close(inError)
+	}()
+
+	err := produce(ctx, func(peripheral peripherals.Peripheral) bool {
+		select {
+		case inData <- peripheral:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+
+	if err != nil && ctx.Err() == nil {
+		inError <- err
+	}
+
+	<-ctx.Done()
+}
@@ -0,0 +1,255 @@
+package devices
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// SIMULATED_UUID is a default uuid of simulated iBeacons
+	SIMULATED_UUID = "b10e5c0ffee04e0f8a8d5e1f5e1f5e1f"
+	// SIMULATED_NAMESPACE is a default namespace of simulated Eddystone beacons
+	SIMULATED_NAMESPACE = "b10e5c0ffee04e0f8a8d"
+
+	MOVE_ARRIVE   = "arrive"
+	MOVE_LEAVE    = "leave"
+	MOVE_APPROACH = "approach"
+	MOVE_RETREAT  = "retreat"
+	MOVE_WANDER   = "wander"
+
+	defaultTick     = time.Millisecond * 100
+	defaultInterval = time.Second
+	defaultPower    = -59
+	defaultNear     = -45
+	defaultFar      = -90
+)
+
+var ErrInvalidScenario = errors.New("invalid scenario")
+
+type (
+	// Scenario describes a simulated fleet, the same seed gives the same advertisements.
+	// Tick is how often the simulation is advanced while scanning.
+	Scenario struct {
+		Seed   int64          `yaml:"seed"`
+		Tick   time.Duration  `yaml:"tick"`
+		Groups []*BeaconGroup `yaml:"groups"`
+	}
+
+	// BeaconGroup is a number of beacons which behave alike.
+	// iBeacons get consecutive minors starting from Minor, Eddystone beacons get consecutive instances
+	// starting from Minor within the namespace given by Uuid.
+	// Every advertisement is sent after Interval plus a random delay up to Jitter and is lost with
+	// probability Loss. RSSI walks randomly by up to Step dBm between Far and Near.
+	// Script moves of each beacon are shifted by a random delay up to Spread.
+	BeaconGroup struct {
+		Name     string        `yaml:"name"`
+		Kind     string        `yaml:"kind"`
+		Count    int           `yaml:"count"`
+		Uuid     string        `yaml:"uuid"`
+		Major    uint16        `yaml:"major"`
+		Minor    uint16        `yaml:"minor"`
+		Power    float64       `yaml:"power"`
+		Interval time.Duration `yaml:"interval"`
+		Jitter   time.Duration `yaml:"jitter"`
+		Near     float64       `yaml:"near"`
+		Far      float64       `yaml:"far"`
+		Step     float64       `yaml:"step"`
+		Loss     float64       `yaml:"loss"`
+		Spread   time.Duration `yaml:"spread"`
+		Script   []*Move       `yaml:"script"`
+	}
+
+	// Move changes a behaviour of beacons at a time since the start of a scan:
+	// arrive puts them in range far away and makes them approach, leave takes them out of range,
+	// approach and retreat make RSSI drift to Near and Far, wander makes it walk evenly.
+	Move struct {
+		At time.Duration `yaml:"at"`
+		Do string        `yaml:"do"`
+	}
+)
+
+// LoadScenario reads a YAML scenario file, omitted values are set to defaults
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scenario := &Scenario{}
+
+	if err := yaml.UnmarshalStrict(data, scenario); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	scenario.setDefaults()
+
+	if err := scenario.Validate(); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	return scenario, nil
+}
+
+// newSettingsScenario describes a fleet of iBeacons which stay in range at a fixed distance
+func newSettingsScenario(settings *SimulatedSettings) *Scenario {
+	return &Scenario{
+		Seed: 1,
+		Tick: defaultTick,
+		Groups: []*BeaconGroup{{
+			Kind:     peripherals.PERIPHERAL_IBEACON,
+			Count:    settings.Peripherals,
+			Uuid:     SIMULATED_UUID,
+			Major:    1,
+			Minor:    1,
+			Power:    defaultPower,
+			Interval: settings.Interval,
+			Near:     -65,
+			Far:      -65,
+		}},
+	}
+}
+
+func (s *Scenario) setDefaults() {
+	if s.Tick == 0 {
+		s.Tick = defaultTick
+	}
+
+	for _, group := range s.Groups {
+		if group == nil {
+			continue
+		}
+
+		if group.Kind == "" {
+			group.Kind = peripherals.PERIPHERAL_IBEACON
+		}
+
+		if group.Uuid == "" && group.Kind == peripherals.PERIPHERAL_EDDYSTONE {
+			group.Uuid = SIMULATED_NAMESPACE
+		} else if group.Uuid == "" {
+			group.Uuid = SIMULATED_UUID
+		}
+
+		if group.Power == 0 {
+			group.Power = defaultPower
+		}
+
+		if group.Interval == 0 {
+			group.Interval = defaultInterval
+		}
+
+		if group.Near == 0 && group.Far == 0 {
+			group.Near = defaultNear
+			group.Far = defaultFar
+		}
+	}
+}
+
+// Size returns a number of beacons in all groups
+func (s *Scenario) Size() int {
+	res := 0
+
+	for _, group := range s.Groups {
+		res += group.Count
+	}
+
+	return res
+}
+
+// Validate returns ErrInvalidScenario pointing to the first invalid value
+func (s *Scenario) Validate() error {
+	if s.Tick <= 0 {
+		return errors.Wrap(ErrInvalidScenario, "tick must be greater than 0")
+	}
+
+	if len(s.Groups) == 0 {
+		return errors.Wrap(ErrInvalidScenario, "groups must not be empty")
+	}
+
+	for idx, group := range s.Groups {
+		if group == nil {
+			return errors.Wrapf(ErrInvalidScenario, "groups[%d] must not be empty", idx)
+		}
+
+		if err := group.validate(); err != nil {
+			return errors.Wrapf(err, "groups[%d]", idx)
+		}
+	}
+
+	return nil
+}
+
+func (g *BeaconGroup) validate() error {
+	if g.Count <= 0 {
+		return errors.Wrap(ErrInvalidScenario, "count must be greater than 0")
+	}
+
+	id, err := hex.DecodeString(g.Uuid)
+
+	switch g.Kind {
+	case peripherals.PERIPHERAL_IBEACON:
+		if err != nil || len(id) != 16 {
+			return errors.Wrap(ErrInvalidScenario, "uuid must be 32 hex digits")
+		}
+
+		if int(g.Minor)+g.Count-1 > 65535 {
+			return errors.Wrap(ErrInvalidScenario, "minors must not exceed 65535")
+		}
+	case peripherals.PERIPHERAL_EDDYSTONE:
+		if err != nil || len(id) != 10 {
+			return errors.Wrap(ErrInvalidScenario, "uuid must be a namespace of 20 hex digits")
+		}
+	default:
+		return errors.Wrap(ErrInvalidScenario, "kind must be one of: ibeacon, eddystone")
+	}
+
+	if g.Interval <= 0 {
+		return errors.Wrap(ErrInvalidScenario, "interval must be greater than 0")
+	}
+
+	if g.Jitter < 0 || g.Spread < 0 {
+		return errors.Wrap(ErrInvalidScenario, "jitter and spread must be greater than or equal to 0")
+	}
+
+	if g.Near < g.Far {
+		return errors.Wrap(ErrInvalidScenario, "near must be greater than or equal to far")
+	}
+
+	if g.Step < 0 {
+		return errors.Wrap(ErrInvalidScenario, "step must be greater than or equal to 0")
+	}
+
+	if g.Loss < 0 || g.Loss >= 1 {
+		return errors.Wrap(ErrInvalidScenario, "loss must be between 0 and 1")
+	}
+
+	var last time.Duration
+
+	for idx, move := range g.Script {
+		if move == nil || !isMove(move.Do) {
+			return errors.Wrapf(ErrInvalidScenario, "script[%d].do must be one of: arrive, leave, approach, retreat, wander", idx)
+		}
+
+		if move.At < last {
+			return errors.Wrapf(ErrInvalidScenario, "script[%d].at must not be before previous moves", idx)
+		}
+
+		last = move.At
+	}
+
+	return nil
+}
+
+func isMove(name string) bool {
+	switch name {
+	case MOVE_ARRIVE, MOVE_LEAVE, MOVE_APPROACH, MOVE_RETREAT, MOVE_WANDER:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,39 @@
+package devices
+
+import "time"
+
+const (
+	DEVICE_KIND_BLE       = "ble"
+	DEVICE_KIND_REPLAY    = "replay"
+	DEVICE_KIND_SIMULATED = "simulated"
+	DEVICE_KIND_NONE      = "none"
+)
+
+type (
+	// Settings select a device the tracker scans with.
+	// Hci is an index of a host controller interface of a ble device, e.g. 1 is hci1.
+	// Replay is a path to a file of recorded advertisements.
+	Settings struct {
+		Kind      string             `yaml:"kind"`
+		Hci       int                `yaml:"hci"`
+		Replay    string             `yaml:"replay"`
+		Simulated *SimulatedSettings `yaml:"simulated"`
+	}
+
+	// SimulatedSettings describe a fleet of iBeacons which advertise every interval,
+	// Scenario is a path to a YAML scenario file, which describes the fleet instead
+	SimulatedSettings struct {
+		Peripherals int           `yaml:"peripherals"`
+		Interval    time.Duration `yaml:"interval"`
+		Scenario    string        `yaml:"scenario"`
+	}
+)
+
+func IsSupportedKind(kind string) bool {
+	switch kind {
+	case DEVICE_KIND_BLE, DEVICE_KIND_REPLAY, DEVICE_KIND_SIMULATED, DEVICE_KIND_NONE:
+		return true
+	default:
+		return false
+	}
+}
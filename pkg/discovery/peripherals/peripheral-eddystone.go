@@ -1,5 +1,7 @@
 package peripherals
 
+import "strings"
+
 const (
 	EDDYSTONE_VARIANT_URL = "url"
 	EDDYSTONE_VARIANT_TLM = "tlm"
@@ -28,6 +30,31 @@ func NewEddystonePeripheral(localName string, data []byte, power float64, rssi f
 	}, nil
 }
 
+// NewEddystoneUidPeripheral creates an Eddystone-UID beacon identified by its hex encoded namespace and instance.
+// Eddystone frames are service data, so such peripherals come from simulated devices only.
+func NewEddystoneUidPeripheral(namespace, instance, localName string, power float64, rssi float64, address string) *EddystonePeripheral {
+	return &EddystonePeripheral{
+		GenericPeripheral: newGenericPeripheral(
+			CreateEddystoneUniqueKey(namespace, instance),
+			PERIPHERAL_EDDYSTONE,
+			localName,
+			nil,
+			power,
+			rssi,
+			address,
+		),
+		variant: EDDYSTONE_VARIANT_UID,
+	}
+}
+
+func CreateEddystoneUniqueKey(namespace, instance string) string {
+	return strings.ToLower(namespace) + ":" + strings.ToLower(instance)
+}
+
+func (peripheral *EddystonePeripheral) Variant() string {
+	return peripheral.variant
+}
+
 // TODO: add Eddystone support
 func isEddystone() bool {
 	//v := uuid[0]
@@ -94,6 +94,27 @@ func ParseIBeaconUniqueKey(key string) (string, uint16, uint16, error) {
 	return uuid, uint16(major), uint16(minor), nil
 }
 
+// CreateIBeaconData creates manufacturer data of an iBeacon advertisement
+func CreateIBeaconData(uuid string, major uint16, minor uint16, power int8) ([]byte, error) {
+	id, err := hex.DecodeString(uuid)
+
+	if err != nil || len(id) != 16 {
+		return nil, ErrInvalidIBeaconUuid
+	}
+
+	data := make([]byte, iBeaconManufacturerDataLength)
+	data[0] = byte(appleCompanyIdentifier)
+	data[1] = byte(appleCompanyIdentifier >> 8)
+	data[2] = byte(iBeaconType)
+	data[3] = byte(expectedIBeaconDataLength)
+	copy(data[4:20], id)
+	binary.BigEndian.PutUint16(data[20:22], major)
+	binary.BigEndian.PutUint16(data[22:24], minor)
+	data[24] = byte(power)
+
+	return data, nil
+}
+
 func isIBeacon(data []byte) bool {
 	if len(data) < iBeaconManufacturerDataLength {
 		return false
@@ -0,0 +1,245 @@
+package events
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	Handler func(payload interface{})
+
+	Stats struct {
+		Listeners int    `json:"listeners"`
+		Published uint64 `json:"published"`
+		Dropped   uint64 `json:"dropped"`
+	}
+
+	// Subscription is a handle of a registered listener.
+	// Each subscription has its own queue consumed by a separate goroutine,
+	// so that a slow listener does not stall the others.
+	// Bounded queues drop events once they are full, unbounded ones keep every event.
+	Subscription struct {
+		id         uint64
+		dispatcher *Dispatcher
+		handler    Handler
+		mu         *sync.Mutex
+		ready      *sync.Cond
+		queue      []interface{}
+		limit      int
+		closed     bool
+		done       chan struct{}
+		dropped    uint64
+	}
+
+	Dispatcher struct {
+		mu            *sync.RWMutex
+		logger        *zap.Logger
+		size          int
+		sequence      uint64
+		subscriptions map[uint64]*Subscription
+		published     uint64
+		dropped       uint64
+	}
+)
+
+func NewDispatcher(logger *zap.Logger, size int) *Dispatcher {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &Dispatcher{
+		mu:            &sync.RWMutex{},
+		logger:        logger,
+		size:          size,
+		subscriptions: make(map[uint64]*Subscription),
+	}
+}
+
+// Subscribe registers a handler which receives all events published after the call.
+// Events are dropped for the handler while its queue is full.
+func (d *Dispatcher) Subscribe(handler Handler) *Subscription {
+	return d.subscribe(handler, d.size)
+}
+
+// SubscribeUnbounded registers a handler which receives all events published after the call without drops.
+// Its queue grows while the handler does not keep up, so it is meant for handlers which must not lose events,
+// e.g. writers of a history.
+func (d *Dispatcher) SubscribeUnbounded(handler Handler) *Subscription {
+	return d.subscribe(handler, 0)
+}
+
+// subscribe registers a handler with a queue of a given limit, 0 means no limit
+func (d *Dispatcher) subscribe(handler Handler, limit int) *Subscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sequence++
+
+	mu := &sync.Mutex{}
+	sub := &Subscription{
+		id:         d.sequence,
+		dispatcher: d,
+		handler:    handler,
+		mu:         mu,
+		ready:      sync.NewCond(mu),
+		queue:      make([]interface{}, 0, d.size),
+		limit:      limit,
+		done:       make(chan struct{}),
+	}
+
+	d.subscriptions[sub.id] = sub
+
+	go sub.consume()
+
+	return sub
+}
+
+// Publish enqueues the payload for every listener without blocking.
+// If a listener queue is full, the payload is dropped for that listener.
+func (d *Dispatcher) Publish(payload interface{}) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	atomic.AddUint64(&d.published, 1)
+
+	for _, sub := range d.subscriptions {
+		if !sub.enqueue(payload) {
+			atomic.AddUint64(&sub.dropped, 1)
+			atomic.AddUint64(&d.dropped, 1)
+
+			d.logger.Warn(
+				"Listener queue is full, dropping event",
+				zap.Uint64("listener", sub.id),
+			)
+		}
+	}
+}
+
+func (d *Dispatcher) Stats() Stats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return Stats{
+		Listeners: len(d.subscriptions),
+		Published: atomic.LoadUint64(&d.published),
+		Dropped:   atomic.LoadUint64(&d.dropped),
+	}
+}
+
+// Close unsubscribes all listeners and waits until their pending events are handled.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+
+	subs := make([]*Subscription, 0, len(d.subscriptions))
+
+	for id, sub := range d.subscriptions {
+		delete(d.subscriptions, id)
+		sub.close()
+		subs = append(subs, sub)
+	}
+
+	d.mu.Unlock()
+
+	for _, sub := range subs {
+		<-sub.done
+	}
+}
+
+// Unsubscribe stops delivering new events to the listener.
+// Events that are already queued are still handled.
+// It returns false if the subscription has already been cancelled.
+func (s *Subscription) Unsubscribe() bool {
+	if s == nil {
+		return false
+	}
+
+	d := s.dispatcher
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.subscriptions[s.id]; !ok {
+		return false
+	}
+
+	delete(d.subscriptions, s.id)
+	s.close()
+
+	return true
+}
+
+// Done is closed once the listener has handled all its events after unsubscribing.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped returns a number of events dropped because the listener did not keep up.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// enqueue returns false if the queue is full
+func (s *Subscription) enqueue(payload interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limit > 0 && len(s.queue) >= s.limit {
+		return false
+	}
+
+	s.queue = append(s.queue, payload)
+	s.ready.Signal()
+
+	return true
+}
+
+// close lets the consumer stop once queued events are handled
+func (s *Subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.ready.Signal()
+}
+
+func (s *Subscription) consume() {
+	defer close(s.done)
+
+	for {
+		s.mu.Lock()
+
+		for len(s.queue) == 0 && !s.closed {
+			s.ready.Wait()
+		}
+
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+
+			return
+		}
+
+		payload := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+
+		s.mu.Unlock()
+
+		s.handle(payload)
+	}
+}
+
+func (s *Subscription) handle(payload interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.dispatcher.logger.Error(
+				"Listener failed to handle an event",
+				zap.Uint64("listener", s.id),
+				zap.String("error", fmt.Sprint(r)),
+			)
+		}
+	}()
+
+	s.handler(payload)
+}
@@ -0,0 +1,196 @@
+package events_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func collect(received chan<- interface{}) events.Handler {
+	return func(payload interface{}) {
+		received <- payload
+	}
+}
+
+func receive(t *testing.T, received <-chan interface{}, count int) []interface{} {
+	res := make([]interface{}, 0, count)
+	timeout := time.After(time.Second * 5)
+
+	for len(res) < count {
+		select {
+		case payload := <-received:
+			res = append(res, payload)
+		case <-timeout:
+			t.Fatalf("expected %d events, got %d", count, len(res))
+		}
+	}
+
+	return res
+}
+
+func TestDispatcherOrder(t *testing.T) {
+	dispatcher := events.NewDispatcher(zap.NewNop(), 10)
+	first := make(chan interface{}, 10)
+	second := make(chan interface{}, 10)
+
+	dispatcher.Subscribe(collect(first))
+	dispatcher.Subscribe(collect(second))
+
+	for i := 0; i < 5; i++ {
+		dispatcher.Publish(i)
+	}
+
+	expected := []interface{}{0, 1, 2, 3, 4}
+
+	assert.Equal(t, expected, receive(t, first, 5), "first listener")
+	assert.Equal(t, expected, receive(t, second, 5), "second listener")
+	assert.Equal(t, events.Stats{Listeners: 2, Published: 5}, dispatcher.Stats(), "stats")
+}
+
+func TestDispatcherUnsubscribe(t *testing.T) {
+	dispatcher := events.NewDispatcher(zap.NewNop(), 10)
+	first := make(chan interface{}, 10)
+	second := make(chan interface{}, 10)
+
+	// both handlers share the same code
+	sub := dispatcher.Subscribe(collect(first))
+	dispatcher.Subscribe(collect(second))
+
+	dispatcher.Publish(1)
+
+	assert.True(t, sub.Unsubscribe(), "unsubscribed")
+	assert.False(t, sub.Unsubscribe(), "already unsubscribed")
+
+	<-sub.Done()
+
+	dispatcher.Publish(2)
+
+	assert.Equal(t, []interface{}{1, 2}, receive(t, second, 2), "remaining listener")
+	assert.Equal(t, []interface{}{1}, receive(t, first, 1), "pending events are handled")
+	assert.Empty(t, first, "no events after unsubscribing")
+	assert.Equal(t, 1, dispatcher.Stats().Listeners, "listeners")
+}
+
+func TestDispatcherSlowListener(t *testing.T) {
+	dispatcher := events.NewDispatcher(zap.NewNop(), 2)
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+	fast := make(chan interface{}, 10)
+
+	slow := dispatcher.Subscribe(func(payload interface{}) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+
+		<-block
+	})
+	dispatcher.Subscribe(collect(fast))
+
+	for i := 0; i < 5; i++ {
+		dispatcher.Publish(i)
+
+		if i == 0 {
+			<-started
+		}
+
+		// lets the fast listener keep up
+		receive(t, fast, 1)
+	}
+
+	// one event is being handled, two are queued
+	assert.Equal(t, uint64(2), slow.Dropped(), "dropped by slow listener")
+	assert.Equal(t, uint64(2), dispatcher.Stats().Dropped, "dropped in total")
+
+	close(block)
+
+	dispatcher.Close()
+
+	assert.Equal(t, 0, dispatcher.Stats().Listeners, "closed")
+}
+
+func TestDispatcherUnboundedListener(t *testing.T) {
+	dispatcher := events.NewDispatcher(zap.NewNop(), 2)
+	block := make(chan struct{})
+	received := make(chan interface{}, 100)
+
+	sub := dispatcher.SubscribeUnbounded(func(payload interface{}) {
+		<-block
+		received <- payload
+	})
+
+	for i := 0; i < 100; i++ {
+		dispatcher.Publish(i)
+	}
+
+	close(block)
+
+	dispatcher.Close()
+
+	assert.Equal(t, uint64(0), sub.Dropped(), "no drops")
+	assert.Len(t, received, 100, "all events are handled before closing")
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, i, <-received, "order")
+	}
+}
+
+func TestDispatcherListenerPanic(t *testing.T) {
+	dispatcher := events.NewDispatcher(zap.NewNop(), 10)
+	received := make(chan interface{}, 10)
+
+	dispatcher.Subscribe(func(payload interface{}) {
+		if payload == 1 {
+			panic("test panic")
+		}
+
+		received <- payload
+	})
+
+	dispatcher.Publish(1)
+	dispatcher.Publish(2)
+
+	assert.Equal(t, []interface{}{2}, receive(t, received, 1))
+}
+
+func TestDispatcherConcurrency(t *testing.T) {
+	dispatcher := events.NewDispatcher(zap.NewNop(), 100)
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				sub := dispatcher.Subscribe(func(payload interface{}) {})
+
+				dispatcher.Publish(j)
+
+				sub.Unsubscribe()
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				dispatcher.Publish(j)
+				dispatcher.Stats()
+			}
+		}()
+	}
+
+	wg.Wait()
+	dispatcher.Close()
+
+	stats := dispatcher.Stats()
+
+	assert.Equal(t, 0, stats.Listeners, "listeners")
+	assert.Equal(t, uint64(200), stats.Published, "published")
+}
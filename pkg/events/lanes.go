@@ -0,0 +1,109 @@
+package events
+
+import (
+	"sync"
+)
+
+// Lanes runs tasks submitted with the same key one by one in submission order.
+// Every key with pending tasks has its own goroutine, so a slow task only delays later tasks of its key,
+// while Shards would also stall all keys sharing its worker.
+type Lanes struct {
+	mu       *sync.Mutex
+	lanes    map[string][]func()
+	capacity int
+	pending  int
+	dropped  uint64
+	closed   bool
+	wg       *sync.WaitGroup
+}
+
+func NewLanes(capacity int) *Lanes {
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	return &Lanes{
+		mu:       &sync.Mutex{},
+		lanes:    make(map[string][]func()),
+		capacity: capacity,
+		wg:       &sync.WaitGroup{},
+	}
+}
+
+// Submit enqueues the task to the lane of the key without blocking.
+// It drops the task and returns false if the lane already has capacity tasks waiting or lanes are closed.
+func (l *Lanes) Submit(key string, task func()) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	queue, active := l.lanes[key]
+
+	if l.closed || len(queue) >= l.capacity {
+		l.dropped++
+
+		return false
+	}
+
+	l.lanes[key] = append(queue, task)
+	l.pending++
+
+	if !active {
+		l.wg.Add(1)
+
+		go l.run(key)
+	}
+
+	return true
+}
+
+// Pending returns a number of queued tasks which are not started yet
+func (l *Lanes) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.pending
+}
+
+// Dropped returns a number of tasks dropped by full lanes so far
+func (l *Lanes) Dropped() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.dropped
+}
+
+// Close stops accepting tasks and waits until all submitted tasks are done.
+func (l *Lanes) Close() {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	l.wg.Wait()
+}
+
+// run executes tasks of a key until its lane is empty, the lane stays in the map while a task is running
+func (l *Lanes) run(key string) {
+	defer l.wg.Done()
+
+	for {
+		l.mu.Lock()
+
+		queue := l.lanes[key]
+
+		if len(queue) == 0 {
+			delete(l.lanes, key)
+			l.mu.Unlock()
+
+			return
+		}
+
+		task := queue[0]
+		queue[0] = nil
+		l.lanes[key] = queue[1:]
+		l.pending--
+
+		l.mu.Unlock()
+
+		task()
+	}
+}
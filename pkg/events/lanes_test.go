@@ -0,0 +1,84 @@
+package events_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanesOrderPerKey(t *testing.T) {
+	lanes := events.NewLanes(100)
+	mu := &sync.Mutex{}
+	results := make(map[string][]int)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i%10)
+		num := i
+
+		assert.True(t, lanes.Submit(key, func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[key] = append(results[key], num)
+		}))
+	}
+
+	// waits for all submitted tasks
+	lanes.Close()
+
+	assert.Len(t, results, 10, "keys")
+
+	for key, nums := range results {
+		assert.Len(t, nums, 10, key)
+
+		for i := 1; i < len(nums); i++ {
+			assert.True(t, nums[i-1] < nums[i], key)
+		}
+	}
+
+	assert.False(t, lanes.Submit("key-0", func() {}), "closed")
+}
+
+func TestLanesSlowKey(t *testing.T) {
+	lanes := events.NewLanes(2)
+	release := make(chan struct{})
+	done := make(chan string, 10)
+
+	lanes.Submit("slow", func() {
+		<-release
+		done <- "slow"
+	})
+
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("fast-%d", i)
+
+		lanes.Submit(key, func() {
+			done <- key
+		})
+	}
+
+	for i := 0; i < 5; i++ {
+		select {
+		case key := <-done:
+			assert.NotEqual(t, "slow", key)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("other keys are blocked by a slow one")
+		}
+	}
+
+	// the first task is running, so the lane holds two more
+	assert.True(t, lanes.Submit("slow", func() {}))
+	assert.True(t, lanes.Submit("slow", func() {}))
+	assert.False(t, lanes.Submit("slow", func() {}), "full lane")
+	assert.Equal(t, 2, lanes.Pending())
+	assert.Equal(t, uint64(1), lanes.Dropped())
+
+	close(release)
+	lanes.Close()
+
+	assert.Equal(t, 0, lanes.Pending())
+}
@@ -0,0 +1,75 @@
+package events
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// Shards runs tasks on a fixed set of workers.
+// Tasks submitted with the same key are always run by the same worker in submission order,
+// while tasks with different keys may run in parallel.
+type Shards struct {
+	queues []chan func()
+	wg     *sync.WaitGroup
+}
+
+func NewShards(size, capacity int) *Shards {
+	if size <= 0 {
+		size = 1
+	}
+
+	s := &Shards{
+		queues: make([]chan func(), size),
+		wg:     &sync.WaitGroup{},
+	}
+
+	for i := range s.queues {
+		s.queues[i] = make(chan func(), capacity)
+		s.wg.Add(1)
+
+		go s.work(s.queues[i])
+	}
+
+	return s
+}
+
+// Submit enqueues the task to the worker owning the key.
+// It blocks while the worker queue is full.
+func (s *Shards) Submit(key string, task func()) {
+	s.queues[s.index(key)] <- task
+}
+
+// Pending returns a number of queued tasks which are not picked up by workers yet
+func (s *Shards) Pending() int {
+	total := 0
+
+	for _, queue := range s.queues {
+		total += len(queue)
+	}
+
+	return total
+}
+
+// Close stops accepting tasks and waits until all submitted tasks are done.
+func (s *Shards) Close() {
+	for _, queue := range s.queues {
+		close(queue)
+	}
+
+	s.wg.Wait()
+}
+
+func (s *Shards) work(queue <-chan func()) {
+	defer s.wg.Done()
+
+	for task := range queue {
+		task()
+	}
+}
+
+func (s *Shards) index(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(len(s.queues)))
+}
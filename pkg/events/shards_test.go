@@ -0,0 +1,41 @@
+package events_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/blent/beagle/pkg/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardsOrderPerKey(t *testing.T) {
+	shards := events.NewShards(4, 10)
+	mu := &sync.Mutex{}
+	results := make(map[string][]int)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i%10)
+		num := i
+
+		shards.Submit(key, func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[key] = append(results[key], num)
+		})
+	}
+
+	// waits for all submitted tasks
+	shards.Close()
+
+	assert.Len(t, results, 10, "keys")
+
+	for key, nums := range results {
+		assert.Len(t, nums, 10, key)
+
+		for i := 1; i < len(nums); i++ {
+			assert.True(t, nums[i-1] < nums[i], key)
+		}
+	}
+}
@@ -20,7 +20,7 @@ func (history *Writer) Use(broker *notification.Broker) {
 		return
 	}
 
-	broker.AddEventListener(func(evt notification.Event) {
+	broker.Subscribe(func(evt notification.Event) {
 
 	})
 }
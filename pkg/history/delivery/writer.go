@@ -20,7 +20,7 @@ func (history *Writer) Use(sender *delivery.Sender) {
 		return
 	}
 
-	sender.AddEventListener(func(evt delivery.Event) {
+	sender.Subscribe(func(evt delivery.Event) {
 
 	})
 }
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+const namespace = "beagle"
+
+var (
+	requestBuckets  = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+	deliveryBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	queryBuckets    = []float64{0.0001, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.5, 1}
+)
+
+// Metrics collects application metrics and exposes them in the prometheus exposition format.
+// Counters are updated by events, values which are already kept by other services are read on scrape.
+type Metrics struct {
+	logger          *zap.Logger
+	registry        *prometheus.Registry
+	sources         *sources
+	found           *prometheus.CounterVec
+	lost            *prometheus.CounterVec
+	deliveries      *prometheus.CounterVec
+	deliveryLatency *prometheus.HistogramVec
+	queryLatency    *prometheus.HistogramVec
+	requests        *prometheus.CounterVec
+	requestLatency  *prometheus.HistogramVec
+}
+
+func New(logger *zap.Logger) *Metrics {
+	m := &Metrics{
+		logger:   logger,
+		registry: prometheus.NewRegistry(),
+		sources:  newSources(logger),
+		found: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "peripherals_found_total",
+			Help:      "Number of peripherals which came in range.",
+		}, []string{"kind", "registered"}),
+		lost: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "peripherals_lost_total",
+			Help:      "Number of peripherals which went out of range.",
+		}, []string{"kind", "registered"}),
+		deliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deliveries_total",
+			Help:      "Number of notification deliveries by endpoint and outcome.",
+		}, []string{"endpoint", "outcome"}),
+		deliveryLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "delivery_duration_seconds",
+			Help:      "Duration of notification delivery attempts by endpoint.",
+			Buckets:   deliveryBuckets,
+		}, []string{"endpoint"}),
+		queryLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_query_duration_seconds",
+			Help:      "Duration of storage operations.",
+			Buckets:   queryBuckets,
+		}, []string{"operation"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of handled http requests.",
+		}, []string{"method", "route", "status"}),
+		requestLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of http requests.",
+			Buckets:   requestBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.sources,
+		m.found,
+		m.lost,
+		m.deliveries,
+		m.deliveryLatency,
+		m.queryLatency,
+		m.requests,
+		m.requestLatency,
+	)
+
+	return m
+}
+
+// Handler returns an http handler serving the collected metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorLog: zap.NewStdLog(m.logger),
+	})
+}
+
+// Use counts found and lost peripherals and exposes the broker queue depth
+func (m *Metrics) Use(broker *notification.Broker) *Metrics {
+	if broker == nil {
+		return m
+	}
+
+	m.sources.queues["broker"] = broker
+
+	broker.Subscribe(func(evt notification.Event) {
+		labels := prometheus.Labels{
+			"kind":       evt.Peripheral.Kind(),
+			"registered": strconv.FormatBool(evt.Registered),
+		}
+
+		if evt.Name == notification.FOUND {
+			m.found.With(labels).Inc()
+		} else {
+			m.lost.With(labels).Inc()
+		}
+	})
+
+	return m
+}
+
+// UseSender counts delivery outcomes and exposes the delivery queue depth
+func (m *Metrics) UseSender(sender *delivery.Sender) *Metrics {
+	if sender == nil {
+		return m
+	}
+
+	m.sources.queues["delivery"] = sender
+
+	sender.Subscribe(func(evt delivery.Event) {
+		endpoint := ""
+
+		if evt.Subscriber != nil && evt.Subscriber.Endpoint != nil {
+			endpoint = evt.Subscriber.Endpoint.Name
+		}
+
+		m.deliveries.WithLabelValues(endpoint, evt.Outcome).Inc()
+
+		if evt.Duration > 0 {
+			m.deliveryLatency.WithLabelValues(endpoint).Observe(evt.Duration.Seconds())
+		}
+	})
+
+	return m
+}
+
+// UseTracker exposes numbers of received advertisements
+func (m *Metrics) UseTracker(tracker AdvertisementSource) *Metrics {
+	m.sources.advertisements = tracker
+
+	return m
+}
+
+// UseActivity exposes numbers of peripherals in range
+func (m *Metrics) UseActivity(activity ActivitySource) *Metrics {
+	m.sources.activity = activity
+
+	return m
+}
+
+// UseSystem exposes cpu, memory and storage usage
+func (m *Metrics) UseSystem(system SystemSource) *Metrics {
+	m.sources.system = system
+
+	return m
+}
+
+// ObserveQuery records a duration of a storage operation
+func (m *Metrics) ObserveQuery(operation string, duration time.Duration) {
+	m.queryLatency.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// ObserveRequest records a handled http request
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.requestLatency.WithLabelValues(method, route).Observe(duration.Seconds())
+}
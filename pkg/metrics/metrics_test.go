@@ -0,0 +1,88 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/metrics"
+	"github.com/blent/beagle/pkg/monitoring/system"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type (
+	trackerStub map[string]uint64
+
+	activityStub struct {
+		registered   int
+		unregistered int
+	}
+
+	systemStub struct{}
+)
+
+func (s trackerStub) Advertisements() map[string]uint64 {
+	return s
+}
+
+func (s *activityStub) Count() (int, int) {
+	return s.registered, s.unregistered
+}
+
+func (s *systemStub) GetStats() (*system.Stats, error) {
+	return &system.Stats{
+		Cpu: []float64{12.5},
+		Memory: &system.Memory{
+			Total:     1024,
+			Available: 512,
+			Used:      512,
+		},
+		Storage: []*system.Storage{
+			{Path: "/", Total: 2048, Available: 1024, Used: 1024},
+		},
+	}, nil
+}
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	res := httptest.NewRecorder()
+
+	m.Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !assert.Equal(t, http.StatusOK, res.Code) {
+		t.FailNow()
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestMetricsExposition(t *testing.T) {
+	m := metrics.New(zap.NewNop()).
+		UseTracker(trackerStub{"ibeacon": 7}).
+		UseActivity(&activityStub{registered: 2, unregistered: 3}).
+		UseSystem(&systemStub{})
+
+	m.ObserveRequest(http.MethodGet, "/api/registry/peripheral/:id", http.StatusOK, time.Millisecond*20)
+	m.ObserveQuery("get_peripheral", time.Millisecond)
+
+	body := scrape(t, m)
+
+	assert.Contains(t, body, `beagle_advertisements_received_total{kind="ibeacon"} 7`)
+	assert.Contains(t, body, `beagle_peripherals_tracked{registered="true"} 2`)
+	assert.Contains(t, body, `beagle_peripherals_tracked{registered="false"} 3`)
+	assert.Contains(t, body, `beagle_http_requests_total{method="GET",route="/api/registry/peripheral/:id",status="200"} 1`)
+	assert.Contains(t, body, `beagle_http_request_duration_seconds_count{method="GET",route="/api/registry/peripheral/:id"} 1`)
+	assert.Contains(t, body, `beagle_storage_query_duration_seconds_count{operation="get_peripheral"} 1`)
+	assert.Contains(t, body, `beagle_system_cpu_usage_percent{cpu="0"} 12.5`)
+	assert.Contains(t, body, `beagle_system_memory_bytes{state="available"} 512`)
+	assert.Contains(t, body, `beagle_system_storage_bytes{path="/",state="total"} 2048`)
+	assert.Contains(t, body, "go_goroutines")
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/blent/beagle/pkg/monitoring/system"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type (
+	AdvertisementSource interface {
+		Advertisements() map[string]uint64
+	}
+
+	ActivitySource interface {
+		Count() (registered int, unregistered int)
+	}
+
+	QueueSource interface {
+		QueueDepth() int
+	}
+
+	SystemSource interface {
+		GetStats() (*system.Stats, error)
+	}
+
+	// sources reads values kept by other services on every scrape
+	sources struct {
+		logger         *zap.Logger
+		advertisements AdvertisementSource
+		activity       ActivitySource
+		queues         map[string]QueueSource
+		system         SystemSource
+		descs          map[string]*prometheus.Desc
+	}
+)
+
+func newSources(logger *zap.Logger) *sources {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+	}
+
+	return &sources{
+		logger: logger,
+		queues: make(map[string]QueueSource),
+		descs: map[string]*prometheus.Desc{
+			"advertisements": desc("advertisements_received_total", "Number of received advertisements by peripheral kind.", "kind"),
+			"tracked":        desc("peripherals_tracked", "Number of peripherals in range.", "registered"),
+			"queue":          desc("queue_depth", "Number of events waiting in a queue.", "queue"),
+			"cpu":            desc("system_cpu_usage_percent", "Cpu usage.", "cpu"),
+			"memory":         desc("system_memory_bytes", "Memory usage.", "state"),
+			"storage":        desc("system_storage_bytes", "Storage usage by mount point.", "path", "state"),
+		},
+	}
+}
+
+func (s *sources) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range s.descs {
+		ch <- desc
+	}
+}
+
+func (s *sources) Collect(ch chan<- prometheus.Metric) {
+	if s.advertisements != nil {
+		for kind, count := range s.advertisements.Advertisements() {
+			ch <- prometheus.MustNewConstMetric(s.descs["advertisements"], prometheus.CounterValue, float64(count), kind)
+		}
+	}
+
+	if s.activity != nil {
+		registered, unregistered := s.activity.Count()
+
+		ch <- prometheus.MustNewConstMetric(s.descs["tracked"], prometheus.GaugeValue, float64(registered), "true")
+		ch <- prometheus.MustNewConstMetric(s.descs["tracked"], prometheus.GaugeValue, float64(unregistered), "false")
+	}
+
+	for name, queue := range s.queues {
+		ch <- prometheus.MustNewConstMetric(s.descs["queue"], prometheus.GaugeValue, float64(queue.QueueDepth()), name)
+	}
+
+	if s.system != nil {
+		s.collectSystem(ch)
+	}
+}
+
+func (s *sources) collectSystem(ch chan<- prometheus.Metric) {
+	stats, err := s.system.GetStats()
+
+	if err != nil {
+		s.logger.Error(
+			"Failed to retrieve system stats",
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	for idx, usage := range stats.Cpu {
+		ch <- prometheus.MustNewConstMetric(s.descs["cpu"], prometheus.GaugeValue, usage, strconv.Itoa(idx))
+	}
+
+	if stats.Memory != nil {
+		ch <- prometheus.MustNewConstMetric(s.descs["memory"], prometheus.GaugeValue, float64(stats.Memory.Total), "total")
+		ch <- prometheus.MustNewConstMetric(s.descs["memory"], prometheus.GaugeValue, float64(stats.Memory.Available), "available")
+		ch <- prometheus.MustNewConstMetric(s.descs["memory"], prometheus.GaugeValue, float64(stats.Memory.Used), "used")
+	}
+
+	for _, storage := range stats.Storage {
+		ch <- prometheus.MustNewConstMetric(s.descs["storage"], prometheus.GaugeValue, float64(storage.Total), storage.Path, "total")
+		ch <- prometheus.MustNewConstMetric(s.descs["storage"], prometheus.GaugeValue, float64(storage.Available), storage.Path, "available")
+		ch <- prometheus.MustNewConstMetric(s.descs["storage"], prometheus.GaugeValue, float64(storage.Used), storage.Path, "used")
+	}
+}
@@ -0,0 +1,127 @@
+package activity
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var ErrPairingInProgress = errors.New("pairing is already in progress")
+
+type (
+	// Sightings reports every advertisement of tracked peripherals
+	Sightings interface {
+		Watch(watcher tracking.Watcher) func()
+	}
+
+	// Pairing finds an unregistered iBeacon brought close to the gateway, so that it can be registered
+	// without knowing its uuid, major and minor numbers in advance
+	Pairing struct {
+		mu        *sync.Mutex
+		logger    *zap.Logger
+		activity  *Monitoring
+		sightings Sightings
+		isActive  bool
+	}
+)
+
+func NewPairing(logger *zap.Logger, activity *Monitoring, sightings Sightings) *Pairing {
+	return &Pairing{
+		mu:        &sync.Mutex{},
+		logger:    logger,
+		activity:  activity,
+		sightings: sightings,
+	}
+}
+
+// Claim watches advertisements during a given window and returns the unregistered iBeacon
+// with the strongest signal at immediate proximity, or nil if there was none.
+// Only one pairing runs at a time, others fail with ErrPairingInProgress.
+func (p *Pairing) Claim(ctx context.Context, window time.Duration) (peripherals.Peripheral, error) {
+	p.mu.Lock()
+
+	if p.isActive {
+		p.mu.Unlock()
+
+		return nil, ErrPairingInProgress
+	}
+
+	p.isActive = true
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.isActive = false
+		p.mu.Unlock()
+	}()
+
+	p.logger.Info("Started pairing", zap.Duration("window", window))
+
+	best := &strongest{mu: &sync.Mutex{}}
+
+	stop := p.sightings.Watch(func(peripheral peripherals.Peripheral) {
+		if peripheral.Kind() != peripherals.PERIPHERAL_IBEACON ||
+			peripheral.Proximity() != peripherals.PROXIMITY_IMMEDIATE {
+			return
+		}
+
+		// unregistered peripherals are known once their found events are processed
+		if !p.activity.IsUnregistered(peripheral.UniqueKey()) {
+			return
+		}
+
+		best.offer(peripheral)
+	})
+
+	defer stop()
+
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	claimed := best.get()
+
+	if claimed == nil {
+		p.logger.Info("Stopped pairing, no peripheral is claimed")
+	} else {
+		p.logger.Info(
+			"Stopped pairing",
+			zap.String("key", claimed.UniqueKey()),
+			zap.Float64("rssi", claimed.RSSI()),
+		)
+	}
+
+	return claimed, nil
+}
+
+// strongest keeps an advertisement with the highest rssi
+type strongest struct {
+	mu         *sync.Mutex
+	peripheral peripherals.Peripheral
+}
+
+func (s *strongest) offer(peripheral peripherals.Peripheral) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.peripheral == nil || peripheral.RSSI() > s.peripheral.RSSI() {
+		s.peripheral = peripheral
+	}
+}
+
+func (s *strongest) get() peripherals.Peripheral {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.peripheral
+}
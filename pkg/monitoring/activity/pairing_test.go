@@ -0,0 +1,164 @@
+package activity_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/monitoring/activity"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testUuid = "b9407f30f5f8466eaff925556b57fe6d"
+
+type (
+	nopSender struct{}
+
+	// minorRegistry registers iBeacons with the minor number 1
+	minorRegistry struct{}
+
+	// fakeSightings replays advertisements to watchers
+	fakeSightings struct {
+		mu       *sync.Mutex
+		watchers []tracking.Watcher
+		watched  chan struct{}
+	}
+)
+
+func (s *nopSender) Send(msg *notification.Message) error {
+	return nil
+}
+
+func (r *minorRegistry) FindTarget(peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	_, _, minor, err := peripherals.ParseIBeaconUniqueKey(peripheral.UniqueKey())
+
+	if err != nil || minor != 1 {
+		return nil, err
+	}
+
+	return &tracking.Peripheral{Id: 1, Key: peripheral.UniqueKey(), Name: "registered", Enabled: true}, nil
+}
+
+func (r *minorRegistry) FindSubscribers(targetId uint64, peripheral peripherals.Peripheral, events ...string) ([]*notification.Subscriber, error) {
+	return nil, nil
+}
+
+func (r *minorRegistry) FindGroups(targetId uint64, peripheral peripherals.Peripheral) ([]*tracking.Group, error) {
+	return nil, nil
+}
+
+func (r *minorRegistry) FindGroupSubscribers(groupId uint64, events ...string) ([]*notification.Subscriber, error) {
+	return nil, nil
+}
+
+func (s *fakeSightings) Watch(watcher tracking.Watcher) func() {
+	s.mu.Lock()
+	s.watchers = append(s.watchers, watcher)
+	s.mu.Unlock()
+
+	s.watched <- struct{}{}
+
+	return func() {
+		s.mu.Lock()
+		s.watchers = nil
+		s.mu.Unlock()
+	}
+}
+
+func (s *fakeSightings) advertise(peripheral peripherals.Peripheral) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, watcher := range s.watchers {
+		watcher(peripheral)
+	}
+}
+
+// createBeacon advertises with -59 dBm at one meter, so -40 dBm is immediate and -70 dBm is far
+func createBeacon(minor uint16, rssi float64) peripherals.Peripheral {
+	return peripherals.NewMockPeripheral(
+		peripherals.CreateIBeaconUniqueKey(testUuid, 1, minor),
+		peripherals.PERIPHERAL_IBEACON,
+		"",
+		nil,
+		-59,
+		rssi,
+		fmt.Sprintf("aa:bb:cc:dd:ee:%02x", minor),
+	)
+}
+
+func TestPairingClaim(t *testing.T) {
+	broker, err := notification.NewBroker(zap.NewNop(), &nopSender{}, &minorRegistry{})
+
+	assert.NoError(t, err)
+
+	monitoring := activity.New(zap.NewNop()).Use(broker)
+	found := make(chan peripherals.Peripheral, 10)
+
+	broker.Use(tracking.NewStream(found, make(chan peripherals.Peripheral), make(chan error)))
+
+	for minor := uint16(1); minor <= 4; minor++ {
+		found <- createBeacon(minor, -70)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+
+	for monitoring.Quantity() < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	sightings := &fakeSightings{mu: &sync.Mutex{}, watched: make(chan struct{}, 1)}
+	pairing := activity.NewPairing(zap.NewNop(), monitoring, sightings)
+
+	go func() {
+		<-sightings.watched
+
+		sightings.advertise(createBeacon(1, -35)) // registered
+		sightings.advertise(createBeacon(2, -44)) // unregistered
+		sightings.advertise(createBeacon(3, -40)) // unregistered and stronger
+		sightings.advertise(createBeacon(4, -70)) // far
+		sightings.advertise(createBeacon(5, -30)) // not found yet
+		sightings.advertise(createBeacon(2, -42)) // still weaker
+	}()
+
+	claimed, err := pairing.Claim(context.Background(), time.Millisecond*200)
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, claimed, "claimed peripheral") {
+		assert.Equal(t, createBeacon(3, -40).UniqueKey(), claimed.UniqueKey())
+	}
+
+	go func() {
+		<-sightings.watched
+	}()
+
+	claimed, err = pairing.Claim(context.Background(), time.Millisecond*50)
+
+	assert.NoError(t, err)
+	assert.Nil(t, claimed, "nothing is advertised")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		<-sightings.watched
+
+		_, err := pairing.Claim(context.Background(), time.Millisecond)
+
+		assert.Equal(t, activity.ErrPairingInProgress, err, "concurrent pairing")
+
+		cancel()
+	}()
+
+	_, err = pairing.Claim(ctx, time.Second*5)
+
+	assert.Equal(t, context.Canceled, err, "canceled pairing")
+
+	close(found)
+}
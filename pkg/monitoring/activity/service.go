@@ -28,6 +28,32 @@ func (s *Monitoring) Quantity() int {
 	return len(s.records)
 }
 
+// Count returns numbers of registered and unregistered peripherals in range
+func (s *Monitoring) Count() (registered int, unregistered int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, record := range s.records {
+		if record.Registered {
+			registered++
+		} else {
+			unregistered++
+		}
+	}
+
+	return registered, unregistered
+}
+
+// IsUnregistered returns true if a peripheral is in range and has no registration
+func (s *Monitoring) IsUnregistered(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[key]
+
+	return ok && !record.Registered
+}
+
 func (s *Monitoring) GetRecords(take, skip int) []*Record {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -71,7 +97,7 @@ func (s *Monitoring) Use(broker *notification.Broker) *Monitoring {
 		return s
 	}
 
-	broker.AddEventListener(func(evt notification.Event) {
+	broker.Subscribe(func(evt notification.Event) {
 		s.mu.Lock()
 		defer s.mu.Unlock()
 
@@ -0,0 +1,67 @@
+package notification
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+const (
+	// Found and lost events of the same peripheral are sent as they are
+	COALESCE_NONE = "none"
+	// Found and lost events of the same peripheral within a batch cancel each other
+	COALESCE_DROP = "drop"
+	// Found and lost events of the same peripheral within a batch are replaced by a single blip event
+	COALESCE_BLIP = "blip"
+
+	// MAX_BATCH_WINDOW is an hour in milliseconds
+	MAX_BATCH_WINDOW = 3600000
+	MAX_BATCH_SIZE   = 10000
+	// DEFAULT_BATCH_WINDOW is a second in milliseconds, it flushes batches stored without a window
+	DEFAULT_BATCH_WINDOW = 1000
+)
+
+type (
+	// Batch makes a subscriber receive events in arrays instead of one by one
+	Batch struct {
+		// Max time in milliseconds an event waits in a batch
+		Window uint64 `json:"window"`
+		// Max number of events in a batch
+		Size int `json:"size"`
+		// Coalescing mode of found and lost events of the same peripheral
+		Coalesce string `json:"coalesce,omitempty"`
+	}
+)
+
+func IsSupportedCoalesceMode(mode string) bool {
+	return mode == "" || mode == COALESCE_NONE || mode == COALESCE_DROP || mode == COALESCE_BLIP
+}
+
+func ParseBatch(src []byte) (*Batch, error) {
+	if len(src) == 0 {
+		return nil, nil
+	}
+
+	var batch *Batch
+
+	err := json.Unmarshal(src, &batch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+func (b *Batch) IsEnabled() bool {
+	return b != nil && (b.Window > 0 || b.Size > 1)
+}
+
+func (b Batch) Value() (driver.Value, error) {
+	j, err := json.Marshal(b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(string(j)), nil
+}
@@ -1,11 +1,13 @@
 package notification
 
 import (
+	"context"
 	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/events"
 	"github.com/blent/beagle/pkg/tracking"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
-	"reflect"
+	"sync"
 	"time"
 )
 
@@ -20,9 +22,24 @@ type (
 	EventListener func(evt Event)
 
 	Registry interface {
-		FindTarget(key string) (*tracking.Peripheral, error)
+		// FindTarget returns the most specific registration matching a peripheral
+		FindTarget(peripheral peripherals.Peripheral) (*tracking.Peripheral, error)
 
-		FindSubscribers(targetId uint64, events ...string) ([]*Subscriber, error)
+		// FindSubscribers returns subscribers notified about every event of a peripheral:
+		// its own ones and member subscribers of its groups, targetId is 0 for unregistered peripherals
+		FindSubscribers(targetId uint64, peripheral peripherals.Peripheral, events ...string) ([]*Subscriber, error)
+
+		// FindGroups returns enabled groups of a peripheral, targetId is 0 for unregistered ones
+		FindGroups(targetId uint64, peripheral peripherals.Peripheral) ([]*tracking.Group, error)
+
+		// FindGroupSubscribers returns presence subscribers of a group
+		FindGroupSubscribers(groupId uint64, events ...string) ([]*Subscriber, error)
+	}
+
+	// Registrar registers peripherals which have no registrations, e.g. by rules.
+	// It returns nil if a peripheral stays unregistered.
+	Registrar interface {
+		Register(peripheral peripherals.Peripheral) (*tracking.Peripheral, error)
 	}
 
 	MessageSender interface {
@@ -33,10 +50,20 @@ type (
 		logger    *zap.Logger
 		sender    MessageSender
 		registry  Registry
-		listeners []EventListener
+		listeners *events.Dispatcher
+		workers   *events.Shards
+		consumers *sync.WaitGroup
+		presence  *presence
+		registrar Registrar
 	}
 )
 
+const (
+	listenerQueueSize = 100
+	workerCount       = 16
+	workerQueueSize   = 100
+)
+
 func NewBroker(logger *zap.Logger, sender MessageSender, registry Registry) (*Broker, error) {
 	if logger == nil {
 		return nil, errors.Wrap(ErrMissedArg, "logger")
@@ -54,71 +81,75 @@ func NewBroker(logger *zap.Logger, sender MessageSender, registry Registry) (*Br
 		logger,
 		sender,
 		registry,
-		make([]EventListener, 0, 5),
+		events.NewDispatcher(logger.Named("listeners"), listenerQueueSize),
+		events.NewShards(workerCount, workerQueueSize),
+		&sync.WaitGroup{},
+		newPresence(),
+		nil,
 	}, nil
 }
 
-func (broker *Broker) Use(stream *tracking.Stream) {
-	go broker.doUse(stream)
+// UseRegistrar registers unregistered peripherals when they are found
+func (broker *Broker) UseRegistrar(registrar Registrar) *Broker {
+	broker.registrar = registrar
+
+	return broker
 }
 
-func (broker *Broker) AddEventListener(listener EventListener) {
-	if listener == nil {
-		return
-	}
+func (broker *Broker) Use(stream *tracking.Stream) {
+	broker.consumers.Add(1)
 
-	broker.listeners = append(broker.listeners, listener)
+	go broker.doUse(stream)
 }
 
-func (broker *Broker) RemoveEventListener(listener EventListener) bool {
+func (broker *Broker) Subscribe(listener EventListener) *events.Subscription {
 	if listener == nil {
-		return false
+		return nil
 	}
 
-	idx := -1
-	handlerPointer := reflect.ValueOf(listener).Pointer()
-
-	for i, element := range broker.listeners {
-		currentPointer := reflect.ValueOf(element).Pointer()
-
-		if currentPointer == handlerPointer {
-			idx = i
-		}
-	}
-
-	if idx < 0 {
-		return false
-	}
+	return broker.listeners.Subscribe(func(payload interface{}) {
+		listener(payload.(Event))
+	})
+}
 
-	broker.listeners = append(broker.listeners[:idx], broker.listeners[idx+1:]...)
+func (broker *Broker) ListenerStats() events.Stats {
+	return broker.listeners.Stats()
+}
 
-	return true
+// QueueDepth returns a number of events waiting for subscriber lookup
+func (broker *Broker) QueueDepth() int {
+	return broker.workers.Pending()
 }
 
+// doUse consumes the stream until it is closed
 func (broker *Broker) doUse(stream *tracking.Stream) {
-	streamIsClosed := false
+	defer broker.consumers.Done()
 
-	for {
-		if streamIsClosed {
-			broker.logger.Info("Stream is closed")
-			return
-		}
+	found := stream.Found()
+	lost := stream.Lost()
+	failures := stream.Error()
 
+	for found != nil || lost != nil {
 		select {
-		case peripheral, isOpen := <-stream.Found():
-			if isOpen {
-				broker.notify(FOUND, peripheral)
+		case peripheral, isOpen := <-found:
+			if !isOpen {
+				found = nil
+				continue
 			}
 
-			streamIsClosed = !isOpen
-		case peripheral, isOpen := <-stream.Lost():
-			if isOpen {
-				broker.notify(LOST, peripheral)
+			broker.notify(FOUND, peripheral)
+		case peripheral, isOpen := <-lost:
+			if !isOpen {
+				lost = nil
+				continue
 			}
 
-			streamIsClosed = !isOpen
-		case err, _ := <-stream.Error():
-			streamIsClosed = true
+			broker.notify(LOST, peripheral)
+		case err, isOpen := <-failures:
+			if !isOpen {
+				failures = nil
+				continue
+			}
 
 			broker.logger.Error(
 				"Error occurred during consuming the stream",
@@ -126,76 +157,222 @@ func (broker *Broker) doUse(stream *tracking.Stream) {
 			)
 		}
 	}
+
+	broker.logger.Info("Stream is closed")
 }
 
-func (broker *Broker) notify(eventName string, peripheral peripherals.Peripheral) {
+// Stop waits until all used streams are closed and all their events are processed and passed to listeners.
+// The broker can not be used after that.
+func (broker *Broker) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+
 	go func() {
-		key := peripheral.UniqueKey()
+		defer close(done)
 
-		if key == "" {
-			broker.logger.Error("Peripheral contains an empty key")
-			return
-		}
+		broker.consumers.Wait()
+		broker.workers.Close()
+		broker.listeners.Close()
+	}()
 
-		found, err := broker.registry.FindTarget(key)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-		evt := &Event{
-			Timestamp:  time.Now(),
-			Name:       eventName,
-			Peripheral: peripheral,
-			Registered: found != nil,
-		}
+// notify processes events of the same peripheral strictly in order,
+// while events of different peripherals are processed in parallel
+func (broker *Broker) notify(eventName string, peripheral peripherals.Peripheral) {
+	key := peripheral.UniqueKey()
 
-		if err != nil {
-			broker.logger.Error(
-				"Failed to retrieve a peripheral",
-				zap.String("key", key),
-				zap.Error(err),
-			)
+	if key == "" {
+		broker.logger.Error("Peripheral contains an empty key")
+		return
+	}
 
-			broker.emit(evt)
+	broker.workers.Submit(key, func() {
+		broker.process(eventName, peripheral)
+	})
+}
 
-			return
-		}
+func (broker *Broker) process(eventName string, peripheral peripherals.Peripheral) {
+	key := peripheral.UniqueKey()
+	found, err := broker.registry.FindTarget(peripheral)
+
+	if err == nil && found == nil && eventName == FOUND && broker.registrar != nil {
+		found = broker.register(peripheral)
+	}
+
+	evt := &Event{
+		Timestamp:  time.Now(),
+		Name:       eventName,
+		Peripheral: peripheral,
+		Registered: found != nil,
+	}
+
+	if err != nil {
+		broker.logger.Error(
+			"Failed to retrieve a peripheral",
+			zap.String("key", key),
+			zap.Error(err),
+		)
 
 		broker.emit(evt)
+		broker.leaveGroups(eventName, peripheral)
+
+		return
+	}
+
+	broker.emit(evt)
+
+	if found != nil && found.Enabled == false {
+		broker.logger.Info(
+			"Peripheral is disabled",
+			zap.String("key", key),
+		)
 
-		if found == nil {
+		broker.leaveGroups(eventName, peripheral)
+
+		return
+	}
+
+	if found == nil {
+		broker.logger.Info(
+			"Peripheral is not registered",
+			zap.String("key", key),
+		)
+	}
+
+	broker.notifySubscribers(eventName, found, peripheral)
+
+	broker.notifyGroups(eventName, found, peripheral)
+}
+
+// register returns nil if a peripheral is not registered, failures are only logged,
+// since the peripheral is still processed as an unregistered one
+func (broker *Broker) register(peripheral peripherals.Peripheral) *tracking.Peripheral {
+	found, err := broker.registrar.Register(peripheral)
+
+	if err != nil {
+		broker.logger.Error(
+			"Failed to register a peripheral",
+			zap.String("key", peripheral.UniqueKey()),
+			zap.Error(err),
+		)
+
+		return nil
+	}
+
+	if found != nil {
+		broker.logger.Info(
+			"Peripheral is registered by a rule",
+			zap.String("key", peripheral.UniqueKey()),
+			zap.String("name", found.Name),
+		)
+	}
+
+	return found
+}
+
+// notifySubscribers sends an event to subscribers of a peripheral and member subscribers of its groups,
+// unregistered peripherals are named by their keys
+func (broker *Broker) notifySubscribers(eventName string, found *tracking.Peripheral, peripheral peripherals.Peripheral) {
+	key := peripheral.UniqueKey()
+	targetId := uint64(0)
+	name := key
+
+	if found != nil {
+		targetId = found.Id
+		name = found.Name
+	}
+
+	subscribers, err := broker.registry.FindSubscribers(targetId, peripheral, eventName, "*")
+
+	if err != nil {
+		broker.logger.Error(
+			"Failed to retrieve subscribers",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	if len(subscribers) == 0 {
+		if found != nil {
 			broker.logger.Info(
-				"Peripheral is not registered",
+				"Peripheral does not have any enabled subscribers",
 				zap.String("key", key),
 			)
+		}
 
-			return
+		return
+	}
+
+	broker.sender.Send(NewMessage(eventName, name, peripheral, subscribers))
+}
+
+// notifyGroups sends FOUND to presence subscribers when the first member of a group arrives
+// and LOST when the last one leaves
+func (broker *Broker) notifyGroups(eventName string, found *tracking.Peripheral, peripheral peripherals.Peripheral) {
+	key := peripheral.UniqueKey()
+	var groups []*tracking.Group
+
+	if eventName == FOUND {
+		targetId := uint64(0)
+
+		if found != nil {
+			targetId = found.Id
 		}
 
-		if found.Enabled == false {
-			broker.logger.Info(
-				"Peripheral is disabled",
+		joined, err := broker.registry.FindGroups(targetId, peripheral)
+
+		if err != nil {
+			broker.logger.Error(
+				"Failed to retrieve groups",
 				zap.String("key", key),
+				zap.Error(err),
 			)
 
 			return
 		}
 
-		subscribers, err := broker.registry.FindSubscribers(found.Id, eventName, "*")
+		groups = broker.presence.arrive(key, joined)
+	} else {
+		groups = broker.presence.leave(key)
+	}
 
-		if subscribers == nil || len(subscribers) == 0 {
-			broker.logger.Info(
-				"Peripheral does not have any enabled subscribers",
-				zap.String("key", key),
+	for _, group := range groups {
+		subscribers, err := broker.registry.FindGroupSubscribers(group.Id, eventName, "*")
+
+		if err != nil {
+			broker.logger.Error(
+				"Failed to retrieve group subscribers",
+				zap.String("group", group.Name),
+				zap.Error(err),
 			)
-			return
+
+			continue
 		}
 
-		broker.sender.Send(NewMessage(eventName, found.Name, peripheral, subscribers))
-	}()
+		if len(subscribers) == 0 {
+			continue
+		}
+
+		broker.sender.Send(NewMessage(eventName, group.Name, peripheral, subscribers))
+	}
+}
+
+// leaveGroups lets a lost peripheral, which is not notified about otherwise, leave groups it is present in,
+// since a group would never lose its last member if it got disabled or its lookup failed
+func (broker *Broker) leaveGroups(eventName string, peripheral peripherals.Peripheral) {
+	if eventName == LOST {
+		broker.notifyGroups(eventName, nil, peripheral)
+	}
 }
 
 func (broker *Broker) emit(evt *Event) {
-	go func() {
-		for _, handler := range broker.listeners {
-			handler(*evt)
-		}
-	}()
+	broker.listeners.Publish(*evt)
 }
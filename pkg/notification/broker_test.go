@@ -0,0 +1,601 @@
+package notification_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/brianvoe/gofakeit"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type (
+	nopSender struct{}
+
+	nopRegistry struct{}
+
+	// scriptedRegistry delays the first lookup of every peripheral
+	// so that later events of the same peripheral would overtake it if processed concurrently
+	scriptedRegistry struct {
+		mu      *sync.Mutex
+		delay   time.Duration
+		seen    map[string]bool
+		lookups chan string
+	}
+
+	// groupRegistry puts registered peripherals "a" and "b" and unregistered "c" into one group,
+	// which has a member subscriber and a presence one
+	groupRegistry struct {
+		group *tracking.Group
+	}
+
+	// changingGroupRegistry disables or fails lookups of members of the group once they are marked
+	changingGroupRegistry struct {
+		groupRegistry
+		mu       *sync.Mutex
+		disabled map[string]bool
+		failing  map[string]bool
+	}
+
+	recordingSender struct {
+		mu       *sync.Mutex
+		messages map[string][]string
+	}
+
+	// subscribedRegistry has no registrations, but returns a subscriber for any registered peripheral
+	subscribedRegistry struct {
+		nopRegistry
+	}
+
+	// recordingRegistrar registers every peripheral and records their keys
+	recordingRegistrar struct {
+		keys chan string
+	}
+)
+
+func newScriptedRegistry(delay time.Duration) *scriptedRegistry {
+	return &scriptedRegistry{
+		mu:      &sync.Mutex{},
+		delay:   delay,
+		seen:    make(map[string]bool),
+		lookups: make(chan string, 100),
+	}
+}
+
+func (r *scriptedRegistry) FindTarget(peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	key := peripheral.UniqueKey()
+
+	r.mu.Lock()
+	first := !r.seen[key]
+	r.seen[key] = true
+	r.mu.Unlock()
+
+	r.lookups <- key
+
+	if first {
+		time.Sleep(r.delay)
+	}
+
+	return &tracking.Peripheral{Id: 1, Key: key, Name: key, Enabled: true}, nil
+}
+
+func (r *scriptedRegistry) FindSubscribers(targetId uint64, peripheral peripherals.Peripheral, events ...string) ([]*notification.Subscriber, error) {
+	return []*notification.Subscriber{{Id: targetId, Event: "*", Enabled: true}}, nil
+}
+
+func (r *scriptedRegistry) FindGroups(targetId uint64, peripheral peripherals.Peripheral) ([]*tracking.Group, error) {
+	return nil, nil
+}
+
+func (r *scriptedRegistry) FindGroupSubscribers(groupId uint64, events ...string) ([]*notification.Subscriber, error) {
+	return nil, nil
+}
+
+func (r *groupRegistry) FindTarget(peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	key := peripheral.UniqueKey()
+
+	if key == "c" {
+		return nil, nil
+	}
+
+	return &tracking.Peripheral{Id: uint64(key[0]), Key: key, Name: key, Enabled: true}, nil
+}
+
+func (r *groupRegistry) FindSubscribers(targetId uint64, peripheral peripherals.Peripheral, events ...string) ([]*notification.Subscriber, error) {
+	return []*notification.Subscriber{{
+		Id:      r.group.Id,
+		Event:   "*",
+		Enabled: true,
+		Mode:    notification.SUBSCRIBER_MODE_MEMBER,
+		GroupId: r.group.Id,
+	}}, nil
+}
+
+func (r *groupRegistry) FindGroups(targetId uint64, peripheral peripherals.Peripheral) ([]*tracking.Group, error) {
+	return []*tracking.Group{r.group}, nil
+}
+
+func (r *groupRegistry) FindGroupSubscribers(groupId uint64, events ...string) ([]*notification.Subscriber, error) {
+	return []*notification.Subscriber{{
+		Id:      groupId,
+		Event:   "*",
+		Enabled: true,
+		Mode:    notification.SUBSCRIBER_MODE_PRESENCE,
+		GroupId: groupId,
+	}}, nil
+}
+
+func (r *changingGroupRegistry) FindTarget(peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	key := peripheral.UniqueKey()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failing[key] {
+		return nil, errors.New("lookup failed")
+	}
+
+	return &tracking.Peripheral{Id: uint64(key[0]), Key: key, Name: key, Enabled: !r.disabled[key]}, nil
+}
+
+func (r *changingGroupRegistry) mark(states map[string]bool, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	states[key] = true
+}
+
+func newRecordingSender() *recordingSender {
+	return &recordingSender{
+		mu:       &sync.Mutex{},
+		messages: make(map[string][]string),
+	}
+}
+
+func (s *recordingSender) Send(msg *notification.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := msg.TargetName()
+	s.messages[key] = append(s.messages[key], msg.EventName())
+
+	return nil
+}
+
+func (s *recordingSender) Messages() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make(map[string][]string)
+
+	for key, names := range s.messages {
+		res[key] = append([]string{}, names...)
+	}
+
+	return res
+}
+
+func (s *nopSender) Send(msg *notification.Message) error {
+	return nil
+}
+
+func (r *nopRegistry) FindTarget(peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	return nil, nil
+}
+
+func (r *nopRegistry) FindSubscribers(targetId uint64, peripheral peripherals.Peripheral, events ...string) ([]*notification.Subscriber, error) {
+	return nil, nil
+}
+
+func (r *nopRegistry) FindGroups(targetId uint64, peripheral peripherals.Peripheral) ([]*tracking.Group, error) {
+	return nil, nil
+}
+
+func (r *nopRegistry) FindGroupSubscribers(groupId uint64, events ...string) ([]*notification.Subscriber, error) {
+	return nil, nil
+}
+
+func (r *subscribedRegistry) FindSubscribers(targetId uint64, peripheral peripherals.Peripheral, events ...string) ([]*notification.Subscriber, error) {
+	if targetId == 0 {
+		return nil, nil
+	}
+
+	return []*notification.Subscriber{{Id: targetId, Event: "*", Enabled: true}}, nil
+}
+
+func (r *recordingRegistrar) Register(peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	key := peripheral.UniqueKey()
+
+	r.keys <- key
+
+	return &tracking.Peripheral{Id: 1, Key: key, Name: key, Enabled: true}, nil
+}
+
+func createPeripheral() peripherals.Peripheral {
+	return createKeyedPeripheral(gofakeit.UUID())
+}
+
+func createKeyedPeripheral(key string) peripherals.Peripheral {
+	return peripherals.NewMockPeripheral(
+		key,
+		"mock",
+		gofakeit.BuzzWord(),
+		nil,
+		0,
+		0,
+		gofakeit.IPv4Address(),
+	)
+}
+
+func TestBrokerSubscribe(t *testing.T) {
+	broker, err := notification.NewBroker(zap.NewNop(), &nopSender{}, &nopRegistry{})
+
+	assert.NoError(t, err)
+
+	found := make(chan peripherals.Peripheral)
+	lost := make(chan peripherals.Peripheral)
+	stream := tracking.NewStream(found, lost, make(chan error))
+
+	broker.Use(stream)
+
+	max := 50
+	received := make(chan notification.Event, max)
+
+	broker.Subscribe(func(evt notification.Event) {
+		received <- evt
+	})
+
+	wg := &sync.WaitGroup{}
+
+	// listeners come and go while events are flowing
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				sub := broker.Subscribe(func(evt notification.Event) {})
+
+				broker.ListenerStats()
+
+				sub.Unsubscribe()
+			}
+		}()
+	}
+
+	for i := 0; i < max; i++ {
+		if i%2 == 0 {
+			found <- createPeripheral()
+		} else {
+			lost <- createPeripheral()
+		}
+	}
+
+	wg.Wait()
+
+	timeout := time.After(time.Second * 5)
+
+	for i := 0; i < max; i++ {
+		select {
+		case evt := <-received:
+			assert.False(t, evt.Registered, "not registered")
+		case <-timeout:
+			t.Fatalf("expected %d events, got %d", max, i)
+		}
+	}
+
+	stats := broker.ListenerStats()
+
+	assert.Equal(t, 1, stats.Listeners, "listeners")
+	assert.Equal(t, uint64(max), stats.Published, "published")
+
+	close(found)
+	close(lost)
+}
+
+func TestBrokerOrderPerPeripheral(t *testing.T) {
+	registry := newScriptedRegistry(time.Millisecond * 50)
+	sender := newRecordingSender()
+	broker, err := notification.NewBroker(zap.NewNop(), sender, registry)
+
+	assert.NoError(t, err)
+
+	found := make(chan peripherals.Peripheral)
+	lost := make(chan peripherals.Peripheral)
+
+	broker.Use(tracking.NewStream(found, lost, make(chan error)))
+
+	keys := []string{"a", "b", "c"}
+	script := []string{notification.FOUND, notification.LOST, notification.FOUND, notification.LOST}
+	total := len(keys) * len(script)
+	received := make(chan notification.Event, total)
+
+	broker.Subscribe(func(evt notification.Event) {
+		received <- evt
+	})
+
+	for _, name := range script {
+		for _, key := range keys {
+			if name == notification.FOUND {
+				found <- createKeyedPeripheral(key)
+			} else {
+				lost <- createKeyedPeripheral(key)
+			}
+		}
+	}
+
+	emitted := make(map[string][]string)
+	timeout := time.After(time.Second * 5)
+
+	for i := 0; i < total; i++ {
+		select {
+		case evt := <-received:
+			key := evt.Peripheral.UniqueKey()
+			emitted[key] = append(emitted[key], evt.Name)
+		case <-timeout:
+			t.Fatalf("expected %d events, got %d", total, i)
+		}
+	}
+
+	messages := sender.Messages()
+
+	for _, key := range keys {
+		assert.Equal(t, script, emitted[key], "listener events of "+key)
+		assert.Equal(t, script, messages[key], "sent messages of "+key)
+	}
+
+	close(found)
+	close(lost)
+}
+
+func TestBrokerParallelPeripherals(t *testing.T) {
+	registry := newScriptedRegistry(time.Second * 2)
+	broker, err := notification.NewBroker(zap.NewNop(), &nopSender{}, registry)
+
+	assert.NoError(t, err)
+
+	found := make(chan peripherals.Peripheral)
+
+	broker.Use(tracking.NewStream(found, make(chan peripherals.Peripheral), make(chan error)))
+
+	keys := make([]string, 0, 20)
+
+	for i := 0; i < cap(keys); i++ {
+		keys = append(keys, gofakeit.UUID())
+	}
+
+	for _, key := range keys {
+		found <- createKeyedPeripheral(key)
+	}
+
+	// every first lookup takes longer than the timeout, so a second lookup can only start in parallel
+	timeout := time.After(time.Second)
+	started := 0
+
+	for started < 2 {
+		select {
+		case <-registry.lookups:
+			started++
+		case <-timeout:
+			t.Fatalf("peripherals are not processed in parallel")
+		}
+	}
+
+	close(found)
+}
+
+func TestBrokerGroupPresence(t *testing.T) {
+	sender := newRecordingSender()
+	broker, err := notification.NewBroker(zap.NewNop(), sender, &groupRegistry{
+		group: &tracking.Group{Id: 1, Name: "zone", Enabled: true},
+	})
+
+	assert.NoError(t, err)
+
+	found := make(chan peripherals.Peripheral)
+	lost := make(chan peripherals.Peripheral)
+
+	broker.Use(tracking.NewStream(found, lost, make(chan error)))
+
+	received := make(chan notification.Event, 10)
+
+	broker.Subscribe(func(evt notification.Event) {
+		received <- evt
+	})
+
+	// waits for each event, since events of different peripherals are processed in parallel
+	send := func(ch chan peripherals.Peripheral, key string) {
+		ch <- createKeyedPeripheral(key)
+
+		select {
+		case <-received:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("event of %s is not processed", key)
+		}
+	}
+
+	// group messages are sent after events are emitted
+	messages := func(expected int) []string {
+		deadline := time.Now().Add(time.Second * 5)
+
+		for len(sender.Messages()["zone"]) < expected && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		return sender.Messages()["zone"]
+	}
+
+	send(found, "a")
+	assert.Equal(t, []string{notification.FOUND}, messages(1), "first member arrives")
+
+	send(found, "c")
+	send(found, "b")
+	send(lost, "a")
+	send(lost, "c")
+	send(lost, "b")
+	assert.Equal(t, []string{notification.FOUND, notification.LOST}, messages(2), "last member leaves")
+
+	send(found, "c")
+	assert.Equal(
+		t,
+		[]string{notification.FOUND, notification.LOST, notification.FOUND},
+		messages(3),
+		"unregistered member arrives",
+	)
+
+	close(found)
+	close(lost)
+}
+
+func TestBrokerGroupPresenceUnavailableMembers(t *testing.T) {
+	sender := newRecordingSender()
+	registry := &changingGroupRegistry{
+		groupRegistry: groupRegistry{
+			group: &tracking.Group{Id: 1, Name: "zone", Enabled: true},
+		},
+		mu:       &sync.Mutex{},
+		disabled: make(map[string]bool),
+		failing:  make(map[string]bool),
+	}
+
+	broker, err := notification.NewBroker(zap.NewNop(), sender, registry)
+
+	assert.NoError(t, err)
+
+	found := make(chan peripherals.Peripheral)
+	lost := make(chan peripherals.Peripheral)
+
+	broker.Use(tracking.NewStream(found, lost, make(chan error)))
+
+	received := make(chan notification.Event, 10)
+
+	broker.Subscribe(func(evt notification.Event) {
+		received <- evt
+	})
+
+	send := func(ch chan peripherals.Peripheral, key string) {
+		ch <- createKeyedPeripheral(key)
+
+		select {
+		case <-received:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("event of %s is not processed", key)
+		}
+	}
+
+	// a member gets disabled while it is present
+	send(found, "a")
+	registry.mark(registry.disabled, "a")
+	send(lost, "a")
+
+	// a lookup of a member fails while it is present
+	send(found, "b")
+	registry.mark(registry.failing, "b")
+	send(lost, "b")
+
+	close(found)
+	close(lost)
+
+	assert.NoError(t, broker.Stop(context.Background()))
+
+	assert.Equal(
+		t,
+		[]string{notification.FOUND, notification.LOST, notification.FOUND, notification.LOST},
+		sender.Messages()["zone"],
+		"the last member leaves anyway",
+	)
+}
+
+func TestBrokerGroupMembers(t *testing.T) {
+	sender := newRecordingSender()
+	broker, err := notification.NewBroker(zap.NewNop(), sender, &groupRegistry{
+		group: &tracking.Group{Id: 1, Name: "zone", Enabled: true},
+	})
+
+	assert.NoError(t, err)
+
+	found := make(chan peripherals.Peripheral)
+	lost := make(chan peripherals.Peripheral)
+
+	broker.Use(tracking.NewStream(found, lost, make(chan error)))
+
+	found <- createKeyedPeripheral("a")
+	found <- createKeyedPeripheral("b")
+	found <- createKeyedPeripheral("c")
+	lost <- createKeyedPeripheral("a")
+	lost <- createKeyedPeripheral("c")
+	lost <- createKeyedPeripheral("b")
+
+	close(found)
+	close(lost)
+
+	assert.NoError(t, broker.Stop(context.Background()))
+
+	messages := sender.Messages()
+	events := []string{notification.FOUND, notification.LOST}
+
+	assert.Equal(t, events, messages["a"], "every event of a registered member")
+	assert.Equal(t, events, messages["b"], "every event of another member")
+	assert.Equal(t, events, messages["c"], "every event of an unregistered member")
+}
+
+func TestBrokerRegistrar(t *testing.T) {
+	sender := newRecordingSender()
+	registrar := &recordingRegistrar{keys: make(chan string, 10)}
+	broker, err := notification.NewBroker(zap.NewNop(), sender, &subscribedRegistry{})
+
+	assert.NoError(t, err)
+
+	broker.UseRegistrar(registrar)
+
+	found := make(chan peripherals.Peripheral)
+	lost := make(chan peripherals.Peripheral)
+
+	broker.Use(tracking.NewStream(found, lost, make(chan error)))
+
+	received := make(chan notification.Event, 10)
+
+	broker.Subscribe(func(evt notification.Event) {
+		received <- evt
+	})
+
+	receive := func(key string) notification.Event {
+		select {
+		case evt := <-received:
+			return evt
+		case <-time.After(time.Second * 5):
+			t.Fatalf("event of %s is not processed", key)
+		}
+
+		return notification.Event{}
+	}
+
+	found <- createKeyedPeripheral("a")
+	assert.True(t, receive("a").Registered, "registered by the registrar")
+
+	lost <- createKeyedPeripheral("a")
+	assert.False(t, receive("a").Registered, "lost peripherals are not registered")
+
+	close(found)
+	close(lost)
+
+	assert.NoError(t, broker.Stop(context.Background()))
+	assert.Equal(t, []string{notification.FOUND}, sender.Messages()["a"], "subscribers of a registered peripheral")
+
+	close(registrar.keys)
+
+	keys := make([]string, 0, 1)
+
+	for key := range registrar.keys {
+		keys = append(keys, key)
+	}
+
+	assert.Equal(t, []string{"a"}, keys, "registered keys")
+}
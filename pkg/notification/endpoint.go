@@ -6,17 +6,28 @@ import (
 	"fmt"
 )
 
+const (
+	ENDPOINT_KIND_HTTP    = "http"
+	ENDPOINT_KIND_COMMAND = "command"
+)
+
 type (
 	Headers  map[string]string
 	Endpoint struct {
 		Id      uint64  `json:"id"`
 		Name    string  `json:"name"`
+		Kind    string  `json:"kind"`
 		Url     string  `json:"url"`
 		Method  string  `json:"method"`
 		Headers Headers `json:"headers"`
+		Policy  *Policy `json:"policy,omitempty"`
 	}
 )
 
+func IsSupportedEndpointKind(kind string) bool {
+	return kind == ENDPOINT_KIND_HTTP || kind == ENDPOINT_KIND_COMMAND
+}
+
 func (h Headers) Value() (driver.Value, error) {
 	j, err := json.Marshal(h)
 
@@ -32,9 +43,14 @@ func (h Headers) Scan(src interface{}) error {
 		return nil
 	}
 
-	value, ok := src.([]byte)
+	var value []byte
 
-	if !ok {
+	switch v := src.(type) {
+	case []byte:
+		value = v
+	case string:
+		value = []byte(v)
+	default:
 		return fmt.Errorf("headers field must be an array of bytes, got %T instead", src)
 	}
 
@@ -3,4 +3,6 @@ package notification
 const (
 	FOUND = "found"
 	LOST  = "lost"
+	// Found and lost of the same peripheral coalesced within a batch
+	BLIP = "blip"
 )
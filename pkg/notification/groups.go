@@ -0,0 +1,77 @@
+package notification
+
+import (
+	"github.com/blent/beagle/pkg/tracking"
+	"sync"
+)
+
+// presence keeps peripherals in range by groups they belong to.
+// A group becomes occupied when its first member arrives and empty when its last member leaves.
+type presence struct {
+	mu      *sync.Mutex
+	members map[uint64]map[string]bool
+	joined  map[string][]*tracking.Group
+}
+
+func newPresence() *presence {
+	return &presence{
+		mu:      &sync.Mutex{},
+		members: make(map[uint64]map[string]bool),
+		joined:  make(map[string][]*tracking.Group),
+	}
+}
+
+// arrive adds a peripheral to given groups and returns those it is the first member of
+func (p *presence) arrive(key string, groups []*tracking.Group) []*tracking.Group {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	occupied := make([]*tracking.Group, 0, len(groups))
+
+	for _, group := range groups {
+		members, exists := p.members[group.Id]
+
+		if !exists {
+			members = make(map[string]bool)
+			p.members[group.Id] = members
+		}
+
+		if members[key] {
+			continue
+		}
+
+		members[key] = true
+		p.joined[key] = append(p.joined[key], group)
+
+		if len(members) == 1 {
+			occupied = append(occupied, group)
+		}
+	}
+
+	return occupied
+}
+
+// leave removes a peripheral from all groups it has joined and returns those it was the last member of.
+// Groups are taken from the arrival, so membership changes made in between do not leave groups occupied forever.
+func (p *presence) leave(key string) []*tracking.Group {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	groups := p.joined[key]
+	emptied := make([]*tracking.Group, 0, len(groups))
+
+	delete(p.joined, key)
+
+	for _, group := range groups {
+		members := p.members[group.Id]
+
+		delete(members, key)
+
+		if len(members) == 0 {
+			delete(p.members, group.Id)
+			emptied = append(emptied, group)
+		}
+	}
+
+	return emptied
+}
@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+type (
+	// Policy overrides default delivery settings for a particular endpoint.
+	// Zero values fall back to the defaults.
+	Policy struct {
+		// Max deliveries per second
+		RateLimit float64 `json:"rateLimit,omitempty"`
+		// Max deliveries above the rate limit allowed at once
+		Burst int `json:"burst,omitempty"`
+		// Max deliveries being sent at the same time
+		MaxInFlight int `json:"maxInFlight,omitempty"`
+		// Request timeout in milliseconds
+		Timeout uint64 `json:"timeout,omitempty"`
+		// Consecutive failures after which the circuit breaker opens
+		BreakerThreshold int `json:"breakerThreshold,omitempty"`
+		// Time in milliseconds after which an open circuit breaker lets a probe delivery through
+		BreakerCooldown uint64 `json:"breakerCooldown,omitempty"`
+	}
+)
+
+func ParsePolicy(src []byte) (*Policy, error) {
+	if len(src) == 0 {
+		return nil, nil
+	}
+
+	var policy *Policy
+
+	err := json.Unmarshal(src, &policy)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func (p Policy) Value() (driver.Value, error) {
+	j, err := json.Marshal(p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(string(j)), nil
+}
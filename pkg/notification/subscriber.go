@@ -1,11 +1,40 @@
 package notification
 
+const (
+	// SUBSCRIBER_MODE_MEMBER notifies group subscribers about events of every member, it is the default mode
+	SUBSCRIBER_MODE_MEMBER = "member"
+	// SUBSCRIBER_MODE_PRESENCE notifies group subscribers when the first member arrives and the last one leaves
+	SUBSCRIBER_MODE_PRESENCE = "presence"
+)
+
 type (
+	// Subscriber belongs either to a peripheral or to a group.
+	// Subscribers of rules are templates copied to peripherals registered by them.
+	// Mode only applies to subscribers of groups.
 	Subscriber struct {
 		Id       uint64    `json:"id"`
 		Name     string    `json:"name"`
 		Event    string    `json:"event"`
 		Endpoint *Endpoint `json:"endpoint"`
 		Enabled  bool      `json:"enabled"`
+		Batch    *Batch    `json:"batch,omitempty"`
+		Mode     string    `json:"mode,omitempty"`
+		TargetId uint64    `json:"targetId,omitempty"`
+		GroupId  uint64    `json:"groupId,omitempty"`
+		RuleId   uint64    `json:"ruleId,omitempty"`
 	}
 )
+
+func IsSupportedSubscriberMode(mode string) bool {
+	switch mode {
+	case "", SUBSCRIBER_MODE_MEMBER, SUBSCRIBER_MODE_PRESENCE:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsPresence returns true if a group subscriber is only notified about arrivals and departures of the whole group
+func (s *Subscriber) IsPresence() bool {
+	return s.Mode == SUBSCRIBER_MODE_PRESENCE
+}
@@ -0,0 +1,103 @@
+package streaming
+
+import (
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"time"
+)
+
+const (
+	SOURCE_BROKER   = "broker"
+	SOURCE_DELIVERY = "delivery"
+)
+
+type (
+	Event struct {
+		Id         uint64          `json:"id"`
+		Source     string          `json:"source"`
+		Name       string          `json:"name"`
+		Timestamp  time.Time       `json:"timestamp"`
+		Key        string          `json:"key"`
+		Kind       string          `json:"kind"`
+		Registered bool            `json:"registered"`
+		Peripheral *PeripheralData `json:"peripheral,omitempty"`
+		Delivery   *DeliveryData   `json:"delivery,omitempty"`
+	}
+
+	PeripheralData struct {
+		LocalName string  `json:"localName,omitempty"`
+		Address   string  `json:"address,omitempty"`
+		Rssi      float64 `json:"rssi"`
+		Proximity string  `json:"proximity"`
+		Accuracy  float64 `json:"accuracy"`
+	}
+
+	DeliveryData struct {
+		Target     string `json:"target"`
+		Subscriber string `json:"subscriber"`
+		Endpoint   string `json:"endpoint"`
+		Delivered  bool   `json:"delivered"`
+		Outcome    string `json:"outcome"`
+		Error      string `json:"error,omitempty"`
+	}
+)
+
+func fromBrokerEvent(evt notification.Event) *Event {
+	res := &Event{
+		Source:     SOURCE_BROKER,
+		Name:       evt.Name,
+		Timestamp:  evt.Timestamp,
+		Registered: evt.Registered,
+	}
+
+	setPeripheral(res, evt.Peripheral)
+
+	return res
+}
+
+func fromDeliveryEvent(evt delivery.Event) *Event {
+	res := &Event{
+		Source:     SOURCE_DELIVERY,
+		Name:       evt.Name,
+		Timestamp:  evt.Timestamp,
+		Registered: true,
+		Delivery: &DeliveryData{
+			Target:    evt.TargetName,
+			Delivered: evt.Delivered,
+			Outcome:   evt.Outcome,
+		},
+	}
+
+	if evt.Subscriber != nil {
+		res.Delivery.Subscriber = evt.Subscriber.Name
+
+		if evt.Subscriber.Endpoint != nil {
+			res.Delivery.Endpoint = evt.Subscriber.Endpoint.Name
+		}
+	}
+
+	if evt.Error != nil {
+		res.Delivery.Error = evt.Error.Error()
+	}
+
+	setPeripheral(res, evt.Peripheral)
+
+	return res
+}
+
+func setPeripheral(evt *Event, peripheral peripherals.Peripheral) {
+	if peripheral == nil {
+		return
+	}
+
+	evt.Key = peripheral.UniqueKey()
+	evt.Kind = peripheral.Kind()
+	evt.Peripheral = &PeripheralData{
+		LocalName: peripheral.LocalName(),
+		Address:   peripheral.Address(),
+		Rssi:      peripheral.RSSI(),
+		Proximity: peripheral.Proximity(),
+		Accuracy:  peripheral.Accuracy(),
+	}
+}
@@ -0,0 +1,51 @@
+package streaming
+
+type Filter struct {
+	Sources    []string
+	Names      []string
+	Keys       []string
+	Kinds      []string
+	Registered *bool
+}
+
+func (f *Filter) Match(evt *Event) bool {
+	if f == nil {
+		return true
+	}
+
+	if !matchAny(f.Sources, evt.Source) {
+		return false
+	}
+
+	if !matchAny(f.Names, evt.Name) {
+		return false
+	}
+
+	if !matchAny(f.Keys, evt.Key) {
+		return false
+	}
+
+	if !matchAny(f.Kinds, evt.Kind) {
+		return false
+	}
+
+	if f.Registered != nil && *f.Registered != evt.Registered {
+		return false
+	}
+
+	return true
+}
+
+func matchAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,200 @@
+package streaming
+
+import (
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/notification"
+	"go.uber.org/zap"
+	"sync"
+)
+
+type (
+	Client struct {
+		filter  *Filter
+		events  chan *Event
+		dropped bool
+	}
+
+	Hub struct {
+		mu       *sync.Mutex
+		logger   *zap.Logger
+		settings *Settings
+		sequence uint64
+		history  []*Event
+		next     int
+		clients  map[*Client]struct{}
+		closed   bool
+	}
+)
+
+// Events returns a channel of matching events.
+// The channel is closed once the client is unsubscribed or dropped.
+func (c *Client) Events() <-chan *Event {
+	return c.events
+}
+
+// Dropped reports whether the client has been disconnected for not keeping up with the stream.
+func (c *Client) Dropped() bool {
+	return c.dropped
+}
+
+func New(logger *zap.Logger, settings *Settings) *Hub {
+	return &Hub{
+		mu:       &sync.Mutex{},
+		logger:   logger,
+		settings: settings,
+		history:  make([]*Event, 0, settings.History),
+		clients:  make(map[*Client]struct{}),
+	}
+}
+
+func (h *Hub) Use(broker *notification.Broker) *Hub {
+	if broker == nil {
+		return h
+	}
+
+	broker.Subscribe(func(evt notification.Event) {
+		h.Publish(fromBrokerEvent(evt))
+	})
+
+	return h
+}
+
+func (h *Hub) UseSender(sender *delivery.Sender) *Hub {
+	if sender == nil {
+		return h
+	}
+
+	sender.Subscribe(func(evt delivery.Event) {
+		h.Publish(fromDeliveryEvent(evt))
+	})
+
+	return h
+}
+
+// Subscribe registers a new client and replays up to the given number of the latest matching events.
+func (h *Hub) Subscribe(filter *Filter, replay int) *Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if replay < 0 || replay > h.settings.History {
+		replay = h.settings.History
+	}
+
+	client := &Client{
+		filter: filter,
+		events: make(chan *Event, h.settings.Buffer+replay),
+	}
+
+	if h.closed {
+		close(client.events)
+
+		return client
+	}
+
+	if replay > 0 {
+		matched := make([]*Event, 0, replay)
+
+		for _, evt := range h.recent() {
+			if filter.Match(evt) {
+				matched = append(matched, evt)
+			}
+		}
+
+		if len(matched) > replay {
+			matched = matched[len(matched)-replay:]
+		}
+
+		for _, evt := range matched {
+			client.events <- evt
+		}
+	}
+
+	h.clients[client] = struct{}{}
+
+	return client
+}
+
+func (h *Hub) Unsubscribe(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.detach(client)
+}
+
+func (h *Hub) Publish(evt *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sequence++
+	evt.Id = h.sequence
+
+	if h.settings.History > 0 {
+		if len(h.history) < h.settings.History {
+			h.history = append(h.history, evt)
+		} else {
+			h.history[h.next] = evt
+		}
+
+		h.next = (h.next + 1) % h.settings.History
+	}
+
+	for client := range h.clients {
+		if !client.filter.Match(evt) {
+			continue
+		}
+
+		select {
+		case client.events <- evt:
+		default:
+			h.logger.Warn("Dropping slow stream client")
+
+			client.dropped = true
+			h.detach(client)
+		}
+	}
+}
+
+func (h *Hub) Quantity() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.clients)
+}
+
+// Close disconnects all clients
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+
+	for client := range h.clients {
+		h.detach(client)
+	}
+}
+
+func (h *Hub) detach(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	delete(h.clients, client)
+	close(client.events)
+}
+
+// recent returns the history ordered from the oldest event to the newest one
+func (h *Hub) recent() []*Event {
+	if len(h.history) < h.settings.History {
+		return h.history
+	}
+
+	res := make([]*Event, 0, len(h.history))
+	res = append(res, h.history[h.next:]...)
+	res = append(res, h.history[:h.next]...)
+
+	return res
+}
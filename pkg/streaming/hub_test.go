@@ -0,0 +1,103 @@
+package streaming_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blent/beagle/pkg/streaming"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func createHub(history, buffer int) *streaming.Hub {
+	return streaming.New(zap.NewNop(), &streaming.Settings{
+		History: history,
+		Buffer:  buffer,
+	})
+}
+
+func publish(hub *streaming.Hub, name, key string) {
+	hub.Publish(&streaming.Event{
+		Source: streaming.SOURCE_BROKER,
+		Name:   name,
+		Key:    key,
+		Kind:   "ibeacon",
+	})
+}
+
+func receive(client *streaming.Client) []string {
+	res := make([]string, 0, 10)
+
+	for {
+		select {
+		case evt, ok := <-client.Events():
+			if !ok {
+				return res
+			}
+
+			res = append(res, evt.Key)
+		default:
+			return res
+		}
+	}
+}
+
+func TestHubFilter(t *testing.T) {
+	hub := createHub(10, 10)
+	registered := true
+	client := hub.Subscribe(&streaming.Filter{
+		Names:      []string{"found"},
+		Keys:       []string{"a", "b"},
+		Registered: &registered,
+	}, 0)
+
+	publish(hub, "found", "a")
+	publish(hub, "lost", "a")
+	publish(hub, "found", "c")
+	hub.Publish(&streaming.Event{Name: "found", Key: "b", Registered: true})
+
+	assert.Equal(t, []string{"b"}, receive(client))
+}
+
+func TestHubReplay(t *testing.T) {
+	hub := createHub(3, 10)
+
+	for i := 0; i < 5; i++ {
+		publish(hub, "found", fmt.Sprintf("%d", i))
+	}
+
+	assert.Equal(t, []string{"2", "3", "4"}, receive(hub.Subscribe(nil, -1)), "whole history")
+	assert.Equal(t, []string{"3", "4"}, receive(hub.Subscribe(nil, 2)), "latest events")
+	assert.Empty(t, receive(hub.Subscribe(nil, 0)), "no replay")
+	assert.Equal(
+		t,
+		[]string{"4"},
+		receive(hub.Subscribe(&streaming.Filter{Keys: []string{"0", "4"}}, -1)),
+		"filtered history",
+	)
+}
+
+func TestHubDropsSlowClient(t *testing.T) {
+	hub := createHub(0, 2)
+	slow := hub.Subscribe(nil, 0)
+	fast := hub.Subscribe(nil, 0)
+
+	publish(hub, "found", "a")
+	publish(hub, "found", "b")
+
+	assert.Equal(t, []string{"a", "b"}, receive(fast))
+
+	publish(hub, "found", "c")
+
+	assert.Equal(t, []string{"c"}, receive(fast))
+	assert.Equal(t, 1, hub.Quantity(), "slow client is dropped")
+	assert.Equal(t, []string{"a", "b"}, receive(slow), "pending events")
+
+	_, ok := <-slow.Events()
+
+	assert.False(t, ok, "closed")
+	assert.True(t, slow.Dropped(), "dropped")
+	assert.False(t, fast.Dropped(), "not dropped")
+
+	hub.Unsubscribe(slow)
+}
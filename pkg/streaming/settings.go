@@ -0,0 +1,6 @@
+package streaming
+
+type Settings struct {
+	History int `yaml:"history"`
+	Buffer  int `yaml:"buffer"`
+}
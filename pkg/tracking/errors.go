@@ -3,6 +3,7 @@ package tracking
 import "github.com/pkg/errors"
 
 var (
-	ErrStart = errors.New("tracker is already started")
-	ErrStop  = errors.New("tracker is already stopped")
+	ErrStart          = errors.New("tracker is already started")
+	ErrStop           = errors.New("tracker is already stopped")
+	ErrInvalidPattern = errors.New("invalid registration pattern")
 )
@@ -0,0 +1,16 @@
+package tracking
+
+// Group is a named set of peripherals.
+// Besides added members, it includes all iBeacons with a given uuid and, unless major is 0, a given major.
+type Group struct {
+	Id      uint64 `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Uuid    string `json:"uuid,omitempty"`
+	Major   uint16 `json:"major,omitempty"`
+}
+
+// IsWildcard returns true if the group includes iBeacons by their uuid
+func (group *Group) IsWildcard() bool {
+	return group.Uuid != ""
+}
@@ -0,0 +1,276 @@
+package tracking
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/pkg/errors"
+)
+
+const (
+	anyNumber     = "*"
+	addressPrefix = "mac:"
+)
+
+type (
+	// Range is an inclusive range of iBeacon major or minor numbers
+	Range struct {
+		Min uint16
+		Max uint16
+	}
+
+	// Pattern matches discovered peripherals against a registration.
+	// It either matches iBeacons by uuid and ranges of major and minor numbers,
+	// or any peripheral by a prefix of its address.
+	Pattern struct {
+		Uuid    string
+		Major   Range
+		Minor   Range
+		Address string
+	}
+)
+
+// AnyNumber matches all major or minor numbers
+var AnyNumber = Range{0, math.MaxUint16}
+
+func NewRange(min, max uint16) Range {
+	return Range{min, max}
+}
+
+func NewIBeaconPattern(uuid string, major, minor Range) (*Pattern, error) {
+	if len(uuid) != 32 {
+		return nil, errors.Wrapf(ErrInvalidPattern, "uuid length: %d", len(uuid))
+	}
+
+	if major.Min > major.Max || minor.Min > minor.Max {
+		return nil, errors.Wrap(ErrInvalidPattern, "range minimum is greater than maximum")
+	}
+
+	// a minor range is meaningless across different majors
+	if major.Min != major.Max && minor != AnyNumber {
+		return nil, errors.Wrap(ErrInvalidPattern, "minor numbers require a single major number")
+	}
+
+	return &Pattern{
+		Uuid:  strings.ToLower(uuid),
+		Major: major,
+		Minor: minor,
+	}, nil
+}
+
+// NewAddressPattern creates a pattern matching addresses starting with given bytes, e.g. "AA:BB:CC"
+func NewAddressPattern(prefix string) (*Pattern, error) {
+	normalized := normalizeAddress(prefix)
+
+	if normalized == "" || len(normalized)%2 != 0 || len(normalized) > 12 {
+		return nil, errors.Wrapf(ErrInvalidPattern, "address prefix: '%s'", prefix)
+	}
+
+	if _, err := hex.DecodeString(normalized); err != nil {
+		return nil, errors.Wrapf(ErrInvalidPattern, "address prefix: '%s'", prefix)
+	}
+
+	return &Pattern{Address: normalized}, nil
+}
+
+// ParsePattern parses a registration key.
+// iBeacon keys are "uuid:major:minor" where numbers can be "*" or "min-max" ranges,
+// address keys are "mac:" followed by an address prefix.
+func ParsePattern(key string) (*Pattern, error) {
+	if strings.HasPrefix(key, addressPrefix) {
+		return NewAddressPattern(strings.TrimPrefix(key, addressPrefix))
+	}
+
+	parts := strings.Split(key, ":")
+
+	if len(parts) != 3 {
+		return nil, errors.Wrapf(ErrInvalidPattern, "key: '%s'", key)
+	}
+
+	major, err := parseRange(parts[1])
+
+	if err != nil {
+		return nil, err
+	}
+
+	minor, err := parseRange(parts[2])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewIBeaconPattern(parts[0], major, minor)
+}
+
+// Key returns a unique key of a registration, exact patterns have keys of discovered iBeacons
+func (p *Pattern) Key() string {
+	if p.IsAddress() {
+		return addressPrefix + p.AddressPrefix()
+	}
+
+	return fmt.Sprintf("%s:%s:%s", p.Uuid, formatRange(p.Major), formatRange(p.Minor))
+}
+
+// AddressPrefix returns the address prefix formatted as bytes separated by colons
+func (p *Pattern) AddressPrefix() string {
+	bytes := make([]string, 0, len(p.Address)/2)
+
+	for i := 0; i+1 < len(p.Address); i += 2 {
+		bytes = append(bytes, p.Address[i:i+2])
+	}
+
+	return strings.Join(bytes, ":")
+}
+
+func (p *Pattern) IsAddress() bool {
+	return p.Address != ""
+}
+
+// IsExact returns true if the pattern matches a single iBeacon
+func (p *Pattern) IsExact() bool {
+	return !p.IsAddress() && p.Major.Min == p.Major.Max && p.Minor.Min == p.Minor.Max
+}
+
+func (p *Pattern) Matches(peripheral peripherals.Peripheral) bool {
+	if p.IsAddress() {
+		return strings.HasPrefix(normalizeAddress(peripheral.Address()), p.Address)
+	}
+
+	if peripheral.Kind() != peripherals.PERIPHERAL_IBEACON {
+		return false
+	}
+
+	uuid, major, minor, err := peripherals.ParseIBeaconUniqueKey(peripheral.UniqueKey())
+
+	if err != nil {
+		return false
+	}
+
+	return uuid == p.Uuid && p.Major.contains(major) && p.Minor.contains(minor)
+}
+
+// specificity returns a level and a number of matched peripherals within it.
+// From the most specific: exact iBeacons, minors of a single major, ranges of majors, uuids, address prefixes.
+func (p *Pattern) specificity() (int, uint64) {
+	if p.IsAddress() {
+		return 0, uint64(12 - len(p.Address))
+	}
+
+	size := p.Major.size() * p.Minor.size()
+
+	switch {
+	case p.IsExact():
+		return 4, size
+	case p.Major.Min == p.Major.Max:
+		return 3, size
+	case p.Major != AnyNumber:
+		return 2, size
+	default:
+		return 1, size
+	}
+}
+
+// MoreSpecific returns true if the pattern wins over another one matching the same peripheral
+func (p *Pattern) MoreSpecific(other *Pattern) bool {
+	level, size := p.specificity()
+	otherLevel, otherSize := other.specificity()
+
+	if level != otherLevel {
+		return level > otherLevel
+	}
+
+	return size < otherSize
+}
+
+// FindBestMatch returns the most specific registration matching a peripheral.
+// Registrations with equal specificity are resolved by the lowest id.
+func FindBestMatch(registrations []*Peripheral, peripheral peripherals.Peripheral) *Peripheral {
+	idx := findBest(len(registrations), func(i int) (string, uint64) {
+		return registrations[i].Key, registrations[i].Id
+	}, peripheral)
+
+	if idx < 0 {
+		return nil
+	}
+
+	return registrations[idx]
+}
+
+// findBest returns an index of the most specific key matching a peripheral or -1,
+// keys with equal specificity are resolved by the lowest id
+func findBest(size int, get func(i int) (string, uint64), peripheral peripherals.Peripheral) int {
+	best := -1
+	var bestId uint64
+	var bestPattern *Pattern
+
+	for i := 0; i < size; i++ {
+		key, id := get(i)
+		pattern, err := ParsePattern(key)
+
+		if err != nil || !pattern.Matches(peripheral) {
+			continue
+		}
+
+		if best < 0 ||
+			pattern.MoreSpecific(bestPattern) ||
+			(!bestPattern.MoreSpecific(pattern) && id < bestId) {
+			best = i
+			bestId = id
+			bestPattern = pattern
+		}
+	}
+
+	return best
+}
+
+func (r Range) contains(number uint16) bool {
+	return number >= r.Min && number <= r.Max
+}
+
+func (r Range) size() uint64 {
+	return uint64(r.Max-r.Min) + 1
+}
+
+func parseRange(str string) (Range, error) {
+	if str == anyNumber {
+		return AnyNumber, nil
+	}
+
+	bounds := strings.SplitN(str, "-", 2)
+	numbers := make([]uint16, 0, 2)
+
+	for _, bound := range bounds {
+		number, err := strconv.ParseUint(bound, 10, 16)
+
+		if err != nil {
+			return Range{}, errors.Wrapf(ErrInvalidPattern, "number: '%s'", str)
+		}
+
+		numbers = append(numbers, uint16(number))
+	}
+
+	if len(numbers) == 1 {
+		return Range{numbers[0], numbers[0]}, nil
+	}
+
+	return Range{numbers[0], numbers[1]}, nil
+}
+
+func formatRange(r Range) string {
+	switch {
+	case r == AnyNumber:
+		return anyNumber
+	case r.Min == r.Max:
+		return strconv.Itoa(int(r.Min))
+	default:
+		return fmt.Sprintf("%d-%d", r.Min, r.Max)
+	}
+}
+
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "").Replace(strings.TrimSpace(address)))
+}
@@ -0,0 +1,103 @@
+package tracking_test
+
+import (
+	"testing"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/stretchr/testify/assert"
+)
+
+const testUuid = "b9407f30f5f8466eaff925556b57fe6d"
+
+func createBeacon(major, minor uint16, address string) peripherals.Peripheral {
+	return peripherals.NewMockPeripheral(
+		peripherals.CreateIBeaconUniqueKey(testUuid, major, minor),
+		peripherals.PERIPHERAL_IBEACON,
+		"",
+		nil,
+		0,
+		0,
+		address,
+	)
+}
+
+func TestParsePattern(t *testing.T) {
+	valid := map[string]string{
+		testUuid + ":*:*":       testUuid + ":*:*",
+		testUuid + ":1:*":       testUuid + ":1:*",
+		testUuid + ":1-5:*":     testUuid + ":1-5:*",
+		testUuid + ":1:10-20":   testUuid + ":1:10-20",
+		testUuid + ":1:2":       testUuid + ":1:2",
+		"mac:AA-BB-CC":          "mac:aa:bb:cc",
+		"mac:aa:bb:cc:dd:ee:ff": "mac:aa:bb:cc:dd:ee:ff",
+	}
+
+	for key, expected := range valid {
+		pattern, err := tracking.ParsePattern(key)
+
+		if assert.NoError(t, err, key) {
+			assert.Equal(t, expected, pattern.Key(), key)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"test",
+		"abc:*:*",
+		testUuid + ":5-1:*",
+		testUuid + ":1-5:10",
+		testUuid + ":x:*",
+		"mac:",
+		"mac:abc",
+		"mac:zz",
+		"mac:aa:bb:cc:dd:ee:ff:00",
+	}
+
+	for _, key := range invalid {
+		_, err := tracking.ParsePattern(key)
+
+		assert.Error(t, err, key)
+	}
+}
+
+func TestFindBestMatch(t *testing.T) {
+	registrations := []*tracking.Peripheral{
+		{Id: 1, Key: "mac:aa"},
+		{Id: 2, Key: "mac:aa:bb"},
+		{Id: 3, Key: testUuid + ":*:*"},
+		{Id: 4, Key: testUuid + ":1-10:*"},
+		{Id: 5, Key: testUuid + ":1-3:*"},
+		{Id: 6, Key: testUuid + ":2:*"},
+		{Id: 7, Key: testUuid + ":2:1-5"},
+		{Id: 8, Key: testUuid + ":2:1-5"},
+		{Id: 9, Key: "invalid"},
+	}
+
+	cases := []struct {
+		peripheral peripherals.Peripheral
+		expected   uint64
+	}{
+		{createBeacon(2, 3, "aa:bb:cc:dd:ee:ff"), 7},
+		{createBeacon(2, 6, ""), 6},
+		{createBeacon(3, 1, ""), 5},
+		{createBeacon(7, 1, ""), 4},
+		{createBeacon(11, 1, ""), 3},
+		{peripherals.NewMockPeripheral("test", "mock", "", nil, 0, 0, "AA:BB:CC:DD:EE:FF"), 2},
+		{peripherals.NewMockPeripheral("test", "mock", "", nil, 0, 0, "aa:01:02:03:04:05"), 1},
+		{peripherals.NewMockPeripheral("test", "mock", "", nil, 0, 0, "bb:01:02:03:04:05"), 0},
+	}
+
+	for _, c := range cases {
+		found := tracking.FindBestMatch(registrations, c.peripheral)
+
+		if c.expected == 0 {
+			assert.Nil(t, found, c.peripheral.UniqueKey())
+			continue
+		}
+
+		if assert.NotNil(t, found, c.peripheral.UniqueKey()) {
+			assert.Equal(t, c.expected, found.Id, c.peripheral.UniqueKey())
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package tracking
 
+// Peripheral is a registration of a single peripheral or, if Pattern is set, of all peripherals matching its key
 type Peripheral struct {
 	Id      uint64 `json:"id"`
 	Key     string `json:"key"`
 	Name    string `json:"name"`
 	Kind    string `json:"kind"`
 	Enabled bool   `json:"enabled"`
+	Pattern bool   `json:"pattern"`
 }
@@ -0,0 +1,98 @@
+package tracking
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+)
+
+const (
+	// RULE_MODE_ACCEPT registers matched peripherals right away
+	RULE_MODE_ACCEPT = "accept"
+	// RULE_MODE_REVIEW queues matched peripherals until they are approved or rejected
+	RULE_MODE_REVIEW = "review"
+)
+
+const (
+	CANDIDATE_STATUS_PENDING    = "pending"
+	CANDIDATE_STATUS_REGISTERED = "registered"
+	CANDIDATE_STATUS_REJECTED   = "rejected"
+	CANDIDATE_STATUS_FAILED     = "failed"
+)
+
+type (
+	// Rule registers unregistered peripherals matching its key, which is a key of a pattern registration.
+	// Names of registrations are rendered from NameTemplate, see RenderName.
+	Rule struct {
+		Id                uint64 `json:"id"`
+		Name              string `json:"name"`
+		Enabled           bool   `json:"enabled"`
+		Key               string `json:"key"`
+		NameTemplate      string `json:"nameTemplate"`
+		Mode              string `json:"mode"`
+		PeripheralEnabled bool   `json:"peripheralEnabled"`
+	}
+
+	// Candidate is a peripheral matched by a rule, it is either pending a review or already decided.
+	// Candidates are kept as an audit trail, a peripheral with a candidate is not matched by rules again.
+	Candidate struct {
+		Id        uint64     `json:"id"`
+		RuleId    uint64     `json:"ruleId"`
+		Key       string     `json:"key"`
+		Kind      string     `json:"kind"`
+		Address   string     `json:"address,omitempty"`
+		Name      string     `json:"name"`
+		Status    string     `json:"status"`
+		TargetId  uint64     `json:"targetId,omitempty"`
+		Message   string     `json:"message,omitempty"`
+		Reviewer  string     `json:"reviewer,omitempty"`
+		CreatedAt time.Time  `json:"createdAt"`
+		DecidedAt *time.Time `json:"decidedAt,omitempty"`
+	}
+)
+
+// RenderName replaces {kind}, {key}, {address}, {uuid}, {major} and {minor} in the name template,
+// the last three are empty for peripherals other than iBeacons. An empty name falls back to the key.
+func (rule *Rule) RenderName(peripheral peripherals.Peripheral) string {
+	var uuid, major, minor string
+
+	if peripheral.Kind() == peripherals.PERIPHERAL_IBEACON {
+		id, majorNum, minorNum, err := peripherals.ParseIBeaconUniqueKey(peripheral.UniqueKey())
+
+		if err == nil {
+			uuid = id
+			major = strconv.Itoa(int(majorNum))
+			minor = strconv.Itoa(int(minorNum))
+		}
+	}
+
+	name := strings.TrimSpace(strings.NewReplacer(
+		"{kind}", peripheral.Kind(),
+		"{key}", peripheral.UniqueKey(),
+		"{address}", peripheral.Address(),
+		"{uuid}", uuid,
+		"{major}", major,
+		"{minor}", minor,
+	).Replace(rule.NameTemplate))
+
+	if name == "" {
+		return peripheral.UniqueKey()
+	}
+
+	return name
+}
+
+// FindBestRule returns the most specific rule matching a peripheral, the same way as FindBestMatch
+func FindBestRule(rules []*Rule, peripheral peripherals.Peripheral) *Rule {
+	idx := findBest(len(rules), func(i int) (string, uint64) {
+		return rules[i].Key, rules[i].Id
+	}, peripheral)
+
+	if idx < 0 {
+		return nil
+	}
+
+	return rules[idx]
+}
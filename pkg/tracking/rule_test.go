@@ -0,0 +1,39 @@
+package tracking_test
+
+import (
+	"testing"
+
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleRenderName(t *testing.T) {
+	beacon := createBeacon(1, 7, "aa:bb:cc:dd:ee:ff")
+
+	templates := map[string]string{
+		"badge-{minor}":          "badge-7",
+		"{uuid}/{major}/{minor}": testUuid + "/1/7",
+		"tag {address}":          "tag aa:bb:cc:dd:ee:ff",
+		"{kind}":                 "ibeacon",
+		"  ":                     beacon.UniqueKey(),
+	}
+
+	for template, expected := range templates {
+		rule := &tracking.Rule{NameTemplate: template}
+
+		assert.Equal(t, expected, rule.RenderName(beacon), template)
+	}
+}
+
+func TestFindBestRule(t *testing.T) {
+	rules := []*tracking.Rule{
+		{Id: 1, Key: testUuid + ":*:*"},
+		{Id: 2, Key: testUuid + ":1:*"},
+		{Id: 3, Key: "mac:aa:bb"},
+	}
+
+	assert.Equal(t, uint64(2), tracking.FindBestRule(rules, createBeacon(1, 7, "")).Id, "narrower uuid")
+	assert.Equal(t, uint64(1), tracking.FindBestRule(rules, createBeacon(2, 7, "")).Id, "uuid")
+	assert.Equal(t, uint64(3), tracking.FindBestRule(rules[2:], createBeacon(2, 7, "aa:bb:01")).Id, "address")
+	assert.Nil(t, tracking.FindBestRule(rules[2:], createBeacon(2, 7, "cc:dd:01")), "no match")
+}
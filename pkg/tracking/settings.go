@@ -3,8 +3,8 @@ package tracking
 import "time"
 
 type Settings struct {
-	Ttl       time.Duration
-	Heartbeat time.Duration
+	Ttl       time.Duration `yaml:"ttl"`
+	Heartbeat time.Duration `yaml:"heartbeat"`
 }
 
 func (s *Settings) Equals(other *Settings) bool {
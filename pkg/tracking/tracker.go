@@ -2,6 +2,7 @@ package tracking
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/blent/beagle/pkg/discovery"
@@ -15,30 +16,106 @@ const bufferSize = 500
 type (
 	TrackerError error
 
+	// Watcher receives every advertisement of tracked peripherals, not only found ones.
+	// It is called by the tracking loop, so it must not block.
+	Watcher func(peripheral peripherals.Peripheral)
+
 	Tracker struct {
-		logger    *zap.Logger
-		device    devices.Device
-		settings  *Settings
-		tracks    map[string]*Track
-		isRunning bool
+		mu          *sync.RWMutex
+		logger      *zap.Logger
+		device      devices.Device
+		settings    *Settings
+		updates     chan *Settings
+		tracks      map[string]*Track
+		received    map[string]uint64
+		watchers    map[uint64]Watcher
+		lastWatcher uint64
+		isRunning   bool
 	}
 )
 
 func NewTracker(logger *zap.Logger, device devices.Device, settings *Settings) *Tracker {
 	return &Tracker{
+		mu:        &sync.RWMutex{},
 		logger:    logger,
 		device:    device,
 		settings:  settings,
+		updates:   make(chan *Settings, 1),
 		tracks:    make(map[string]*Track),
+		received:  make(map[string]uint64),
+		watchers:  make(map[uint64]Watcher),
 		isRunning: false,
 	}
 }
 
 func (tracker *Tracker) IsRunning() bool {
+	tracker.mu.RLock()
+	defer tracker.mu.RUnlock()
+
 	return tracker.isRunning
 }
 
+// Advertisements returns numbers of received advertisements by peripheral kinds
+func (tracker *Tracker) Advertisements() map[string]uint64 {
+	tracker.mu.RLock()
+	defer tracker.mu.RUnlock()
+
+	result := make(map[string]uint64, len(tracker.received))
+
+	for kind, count := range tracker.received {
+		result[kind] = count
+	}
+
+	return result
+}
+
+// Watch adds a watcher of advertisements until the returned function is called
+func (tracker *Tracker) Watch(watcher Watcher) func() {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.lastWatcher++
+	id := tracker.lastWatcher
+	tracker.watchers[id] = watcher
+
+	return func() {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+
+		delete(tracker.watchers, id)
+	}
+}
+
+// SetSettings applies new settings without restarting the scanning
+func (tracker *Tracker) SetSettings(settings *Settings) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if tracker.settings.Equals(settings) {
+		return
+	}
+
+	tracker.settings = settings
+
+	if !tracker.isRunning {
+		return
+	}
+
+	// keeps only the latest update
+	select {
+	case <-tracker.updates:
+	default:
+	}
+
+	tracker.updates <- settings
+}
+
+// Track starts scanning until the context is done.
+// The returned stream is closed once scanning is stopped and all pending events are sent.
 func (tracker *Tracker) Track(ctx context.Context) (*Stream, error) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
 	if tracker.isRunning {
 		return nil, ErrStart
 	}
@@ -49,7 +126,7 @@ func (tracker *Tracker) Track(ctx context.Context) (*Stream, error) {
 
 	inFound := make(chan peripherals.Peripheral, bufferSize)
 	inLost := make(chan peripherals.Peripheral, bufferSize)
-	inError := make(chan error)
+	inError := make(chan error, 1)
 
 	output, err := tracker.device.Scan(ctx)
 
@@ -60,21 +137,37 @@ func (tracker *Tracker) Track(ctx context.Context) (*Stream, error) {
 	tracker.isRunning = true
 
 	go tracker.start(ctx, output, inFound, inLost, inError)
-	go tracker.stopOnDone(ctx, inFound, inLost, inError)
 
 	return NewStream(inFound, inLost, inError), nil
 }
 
+// start is the only writer of the output channels, so it closes them on exit
 func (tracker *Tracker) start(ctx context.Context, stream *discovery.Stream, inFound chan<- peripherals.Peripheral, inLost chan<- peripherals.Peripheral, inError chan<- error) {
 	tracker.logger.Info("Started tracking")
 
+	tracker.mu.RLock()
+	settings := tracker.settings
+	tracker.mu.RUnlock()
+
 	done := false
-	ticker := time.NewTicker(tracker.settings.Heartbeat)
+	ticker := time.NewTicker(settings.Heartbeat)
+
+	defer func() {
+		ticker.Stop()
+
+		tracker.mu.Lock()
+		tracker.isRunning = false
+		tracker.mu.Unlock()
+
+		close(inFound)
+		close(inLost)
+		close(inError)
+
+		tracker.logger.Info("Stopped tracking")
+	}()
 
 	for {
 		if done {
-			ticker.Stop()
-			tracker.logger.Info("Stopped tracking")
 			return
 		}
 
@@ -83,11 +176,30 @@ func (tracker *Tracker) start(ctx context.Context, stream *discovery.Stream, inF
 			done = true
 		case <-ticker.C:
 			tracker.heartbeat(inLost)
+		case update := <-tracker.updates:
+			if update.Heartbeat != settings.Heartbeat {
+				ticker.Stop()
+				ticker = time.NewTicker(update.Heartbeat)
+			}
+
+			if update.Ttl != settings.Ttl {
+				for _, record := range tracker.tracks {
+					record.ttl = update.Ttl
+				}
+			}
+
+			settings = update
+
+			tracker.logger.Info(
+				"Applied new settings",
+				zap.Duration("ttl", settings.Ttl),
+				zap.Duration("heartbeat", settings.Heartbeat),
+			)
 		case peripheral, isOpen := <-stream.Data():
 			done = !isOpen
 
 			if done == false {
-				tracker.push(peripheral, inFound)
+				tracker.push(peripheral, settings.Ttl, inFound)
 			}
 		case err, _ := <-stream.Error():
 			done = true
@@ -104,14 +216,6 @@ func (tracker *Tracker) start(ctx context.Context, stream *discovery.Stream, inF
 	}
 }
 
-func (tracker *Tracker) stopOnDone(ctx context.Context, inFound chan peripherals.Peripheral, inLost chan peripherals.Peripheral, inError chan error) {
-	<-ctx.Done()
-	tracker.isRunning = false
-	close(inFound)
-	close(inLost)
-	close(inError)
-}
-
 func (tracker *Tracker) heartbeat(inLost chan<- peripherals.Peripheral) {
 	if len(tracker.tracks) == 0 {
 		return
@@ -135,11 +239,20 @@ func (tracker *Tracker) heartbeat(inLost chan<- peripherals.Peripheral) {
 	tracker.tracks = active
 }
 
-func (tracker *Tracker) push(peripheral peripherals.Peripheral, inFound chan<- peripherals.Peripheral) {
+func (tracker *Tracker) push(peripheral peripherals.Peripheral, ttl time.Duration, inFound chan<- peripherals.Peripheral) {
 	if peripheral == nil {
 		return
 	}
 
+	tracker.mu.Lock()
+	tracker.received[peripheral.Kind()]++
+
+	for _, watcher := range tracker.watchers {
+		watcher(peripheral)
+	}
+
+	tracker.mu.Unlock()
+
 	key := peripheral.UniqueKey()
 
 	found, ok := tracker.tracks[key]
@@ -147,7 +260,7 @@ func (tracker *Tracker) push(peripheral peripherals.Peripheral, inFound chan<- p
 	if ok {
 		found.Update()
 	} else {
-		tracker.tracks[key] = NewTrack(peripheral, tracker.settings.Ttl)
+		tracker.tracks[key] = NewTrack(peripheral, ttl)
 		inFound <- peripheral
 
 		tracker.logger.Info(
@@ -0,0 +1,69 @@
+package tracking_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestTrackerWithSimulatedFleet(t *testing.T) {
+	device := devices.NewScenarioDevice(zap.NewNop(), &devices.Scenario{
+		Seed: 1,
+		Tick: time.Millisecond * 20,
+		Groups: []*devices.BeaconGroup{{
+			Kind:     peripherals.PERIPHERAL_IBEACON,
+			Count:    1000,
+			Uuid:     testUuid,
+			Power:    -59,
+			Interval: time.Millisecond * 100,
+			Jitter:   time.Millisecond * 20,
+			Near:     -45,
+			Far:      -90,
+			Step:     2,
+			Loss:     0.1,
+			Script: []*devices.Move{
+				{At: time.Millisecond * 500, Do: devices.MOVE_LEAVE},
+			},
+		}},
+	})
+
+	tracker := tracking.NewTracker(zap.NewNop(), device, &tracking.Settings{
+		Ttl:       time.Millisecond * 400,
+		Heartbeat: time.Millisecond * 50,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := tracker.Track(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(map[string]bool)
+	lost := make(map[string]bool)
+	timeout := time.After(time.Second * 10)
+
+	for len(lost) < 1000 {
+		select {
+		case peripheral := <-stream.Found():
+			found[peripheral.UniqueKey()] = true
+		case peripheral := <-stream.Lost():
+			lost[peripheral.UniqueKey()] = true
+		case err := <-stream.Error():
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("found %d and lost %d of 1000 peripherals", len(found), len(lost))
+		}
+	}
+
+	assert.Len(t, found, 1000, "all found")
+	assert.True(t, tracker.Advertisements()[peripherals.PERIPHERAL_IBEACON] >= 3000, "advertisements are counted")
+}
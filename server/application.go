@@ -2,13 +2,35 @@ package server
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
-type Application struct {
-	container *Container
-}
+const settingsPollInterval = time.Second * 2
+
+type (
+	// SettingsLoader creates settings from the same sources as on startup
+	SettingsLoader func() (*Settings, error)
+
+	Application struct {
+		container    *Container
+		loader       SettingsLoader
+		path         string
+		loaded       fileState
+		pollInterval time.Duration
+		reloads      chan struct{}
+	}
+
+	fileState struct {
+		modTime time.Time
+		size    int64
+	}
+)
 
 func New(settings *Settings) (*Application, error) {
 	container, err := NewContainer(settings)
@@ -17,17 +39,89 @@ func New(settings *Settings) (*Application, error) {
 		return nil, err
 	}
 
-	return &Application{container}, nil
+	return newApplication(container), nil
+}
+
+func newApplication(container *Container) *Application {
+	return &Application{
+		container:    container,
+		pollInterval: settingsPollInterval,
+		reloads:      make(chan struct{}, 1),
+	}
+}
+
+// WatchSettings makes the application reload settings on SIGHUP and whenever a given file changes.
+// Changes made after the call are detected, including those made while the application starts.
+// An empty path disables watching the file.
+func (app *Application) WatchSettings(path string, loader SettingsLoader) *Application {
+	app.path = path
+	app.loader = loader
+	app.loaded = statFile(path)
+
+	return app
 }
 
+// Reload requests the running application to reload its settings
+func (app *Application) Reload() {
+	select {
+	case app.reloads <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the application until SIGINT or SIGTERM is received
 func (app *Application) Run() error {
+	logger := app.container.GetLogger()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				logger.Info(
+					"Received a signal",
+					zap.String("signal", sig.String()),
+				)
+
+				if sig == syscall.SIGHUP {
+					app.Reload()
+
+					continue
+				}
+
+				stop()
+
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return app.run(ctx)
+}
+
+// RunContext runs the application until the context is done, it does not handle signals,
+// which lets the application be embedded and started in tests
+func (app *Application) RunContext(ctx context.Context) error {
+	return app.run(ctx)
+}
+
+// run runs the application until the context is done and then shuts it down
+func (app *Application) run(ctx context.Context) error {
 	var err error
 
 	logger := app.container.GetLogger()
 
 	logger.Info("Starting the application")
 
-	err = app.container.GetInitManager().Run(app.container.GetAllInitializers())
+	err = app.container.GetInitManager().Run()
 
 	if err != nil {
 		logger.Error(
@@ -35,12 +129,15 @@ func (app *Application) Run() error {
 			zap.Error(err),
 		)
 
+		app.shutdownInitializers()
+
 		return err
 	}
 
-	ctx, stop := context.WithCancel(context.Background())
-	defer stop()
-	stream, err := app.container.GetTracker().Track(ctx)
+	scanning, stopScanning := context.WithCancel(ctx)
+	defer stopScanning()
+
+	stream, err := app.container.GetTracker().Track(scanning)
 
 	if err != nil {
 		logger.Error(
@@ -48,29 +145,177 @@ func (app *Application) Run() error {
 			zap.Error(err),
 		)
 
+		app.shutdownInitializers()
+
 		return err
 	}
 
-	// Closes db connection
-	defer app.container.GetStorageProvider().Close()
-
 	app.container.GetEventBroker().Use(stream)
 
 	app.container.GetActivityWriter().Use(app.container.GetEventBroker())
 	app.container.GetActivityService().Use(app.container.GetEventBroker())
 
-	err = app.container.GetServer().Run(ctx)
+	failure := make(chan error, 1)
+
+	go func() {
+		failure <- app.container.GetServer().Run()
+	}()
+
+	if app.loader != nil && app.path != "" {
+		go app.watchFile(scanning)
+	}
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case err = <-failure:
+			if err != nil {
+				logger.Error(
+					"Failed to start the server",
+					zap.Error(err),
+				)
+			}
+
+			break loop
+		case <-app.reloads:
+			app.reload()
+		}
+	}
+
+	stopScanning()
+
+	if shutdownErr := app.shutdown(); err == nil {
+		err = shutdownErr
+	}
+
+	return err
+}
+
+func (app *Application) reload() {
+	logger := app.container.GetLogger()
+
+	if app.loader == nil {
+		logger.Warn("Settings reload is not supported")
+
+		return
+	}
+
+	settings, err := app.loader()
 
 	if err != nil {
 		logger.Error(
-			"Failed to start the server",
+			"Failed to reload settings, keeping the current ones",
 			zap.Error(err),
 		)
 
-		stop()
+		return
+	}
 
-		return err
+	app.container.ApplySettings(settings)
+
+	logger.Info("Settings are reloaded")
+}
+
+// watchFile requests a reload whenever the settings file is modified
+func (app *Application) watchFile(ctx context.Context) {
+	ticker := time.NewTicker(app.pollInterval)
+	defer ticker.Stop()
+
+	last := app.loaded
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := statFile(app.path)
+
+			if current != last {
+				last = current
+
+				app.container.GetLogger().Info(
+					"Settings file is changed",
+					zap.String("path", app.path),
+				)
+
+				app.Reload()
+			}
+		}
+	}
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return fileState{}
 	}
 
-	return nil
+	return fileState{info.ModTime(), info.Size()}
+}
+
+// shutdownInitializers releases resources of initializers when the application fails to start
+func (app *Application) shutdownInitializers() {
+	ctx, cancel := context.WithTimeout(context.Background(), app.container.GetSettings().ShutdownTimeout)
+	defer cancel()
+
+	app.container.GetInitManager().Shutdown(ctx)
+}
+
+// shutdown drains all pending events and deliveries, stops the server and shuts initializers down,
+// which closes the storage.
+// Scanning must be stopped before the call.
+func (app *Application) shutdown() error {
+	var err error
+
+	logger := app.container.GetLogger()
+
+	logger.Info("Shutting down the application")
+
+	ctx, cancel := context.WithTimeout(context.Background(), app.container.GetSettings().ShutdownTimeout)
+	defer cancel()
+
+	if stopErr := app.container.GetEventBroker().Stop(ctx); stopErr != nil {
+		logger.Error(
+			"Failed to drain the broker",
+			zap.Error(stopErr),
+		)
+
+		err = errors.Wrap(stopErr, "broker")
+	}
+
+	if stopErr := app.container.GetSender().Stop(ctx); stopErr != nil {
+		logger.Error(
+			"Failed to flush pending deliveries",
+			zap.Error(stopErr),
+		)
+
+		if err == nil {
+			err = errors.Wrap(stopErr, "sender")
+		}
+	}
+
+	app.container.GetStreamingHub().Close()
+
+	if stopErr := app.container.GetServer().Shutdown(ctx); stopErr != nil {
+		logger.Error(
+			"Failed to shut the server down",
+			zap.Error(stopErr),
+		)
+
+		if err == nil {
+			err = errors.Wrap(stopErr, "server")
+		}
+	}
+
+	// initializers log their own failures
+	if stopErr := app.container.GetInitManager().Shutdown(ctx); stopErr != nil && err == nil {
+		err = stopErr
+	}
+
+	logger.Info("Application is stopped")
+
+	return err
 }
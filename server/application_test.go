@@ -0,0 +1,332 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/blent/beagle/server/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testPeripheralUuid = "f7826da64fa24e988024bc5b71e0893e"
+
+func createApplication(t *testing.T, webhookUrl string, timeout time.Duration) (*Application, *devices.MockDevice, func()) {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewDefaultSettings()
+	settings.ShutdownTimeout = timeout
+	settings.Storage.ConnectionString = filepath.Join(dir, "database.db")
+	settings.Http.Port = 0
+	settings.Http.Static = nil
+
+	device := devices.NewMockDevice()
+	container, err := newContainer(settings, zap.NewNop(), device)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the schema is needed before the application runs all initializers
+	if err := container.GetInitManager().Get("storage").Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := storage.NewManager(zap.NewNop(), container.GetStorageProvider())
+
+	endpointId, err := manager.CreateEndpoint(&notification.Endpoint{
+		Name:   "webhook",
+		Url:    webhookUrl,
+		Method: http.MethodPost,
+		Kind:   notification.ENDPOINT_KIND_HTTP,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = manager.CreatePeripheral(&tracking.Peripheral{
+		Key:     peripherals.CreateIBeaconUniqueKey(testPeripheralUuid, 1, 1),
+		Name:    "test",
+		Kind:    peripherals.PERIPHERAL_IBEACON,
+		Enabled: true,
+	}, []*notification.Subscriber{{
+		Name:     "test",
+		Event:    notification.FOUND,
+		Enabled:  true,
+		Endpoint: &notification.Endpoint{Id: endpointId},
+	}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newApplication(container), device, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func emit(t *testing.T, device *devices.MockDevice) {
+	data, err := peripherals.CreateIBeaconData(testPeripheralUuid, 1, 1, -59)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peripheral, err := peripherals.NewPeripheral("test", data, -59, -60, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device.EmitPeripheral(peripheral)
+}
+
+func TestApplicationShutdownFlushesDeliveries(t *testing.T) {
+	started := make(chan struct{}, 1)
+	completed := make(chan struct{}, 1)
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(time.Millisecond * 300)
+		completed <- struct{}{}
+	}))
+	defer webhook.Close()
+
+	app, device, cleanup := createApplication(t, webhook.URL, time.Second*5)
+	defer cleanup()
+
+	ctx, stop := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+
+	go func() {
+		result <- app.run(ctx)
+	}()
+
+	emit(t, device)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second * 5):
+		t.Fatal("webhook is not called")
+	}
+
+	stop()
+
+	select {
+	case err := <-result:
+		assert.NoError(t, err, "shutdown error")
+	case <-time.After(time.Second * 5):
+		t.Fatal("application is not stopped")
+	}
+
+	select {
+	case <-completed:
+	default:
+		t.Fatal("in-flight delivery is abandoned")
+	}
+
+	assert.False(t, app.container.GetTracker().IsRunning(), "tracker is stopped")
+	assert.Error(t, app.container.GetStorageProvider().GetConnection().Ping(), "storage is closed")
+}
+
+func TestApplicationShutdownTimeout(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer webhook.Close()
+	defer close(release)
+
+	app, device, cleanup := createApplication(t, webhook.URL, time.Millisecond*200)
+	defer cleanup()
+
+	ctx, stop := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+
+	go func() {
+		result <- app.run(ctx)
+	}()
+
+	emit(t, device)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second * 5):
+		t.Fatal("webhook is not called")
+	}
+
+	stop()
+
+	select {
+	case err := <-result:
+		assert.Error(t, err, "shutdown must time out")
+		assert.Equal(t, context.DeadlineExceeded, errors.Cause(err), "cause")
+	case <-time.After(time.Second * 5):
+		t.Fatal("application is not stopped within the timeout")
+	}
+}
+
+func TestApplicationReloadsSettingsOnFileChange(t *testing.T) {
+	app, _, cleanup := createApplication(t, "http://localhost", time.Second*5)
+	defer cleanup()
+
+	path := filepath.Join(filepath.Dir(app.container.GetSettings().Storage.ConnectionString), "beagle.yml")
+
+	if err := ioutil.WriteFile(path, []byte("tracking:\n  ttl: 5s\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	current := app.container.GetSettings()
+	loaded := make(chan struct{}, 1)
+
+	app.WatchSettings(path, func() (*Settings, error) {
+		settings := NewDefaultSettings()
+		settings.Storage = current.Storage
+		settings.Http = current.Http
+
+		err := LoadSettingsFile(settings, path)
+
+		loaded <- struct{}{}
+
+		return settings, err
+	})
+	app.pollInterval = time.Millisecond * 20
+
+	ctx, stop := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+
+	go func() {
+		result <- app.run(ctx)
+	}()
+
+	// lets the watcher read the initial state
+	time.Sleep(time.Millisecond * 100)
+
+	if err := ioutil.WriteFile(path, []byte("tracking:\n  ttl: 30s\ndelivery:\n  policy:\n    burst: 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-loaded:
+	case <-time.After(time.Second * 5):
+		t.Fatal("settings are not reloaded")
+	}
+
+	stop()
+
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("application is not stopped")
+	}
+
+	settings := app.container.GetSettings()
+
+	assert.Equal(t, time.Second*30, settings.Tracking.Ttl)
+	assert.Equal(t, 3, settings.Delivery.Policy.Burst)
+	assert.Equal(t, current.Storage, settings.Storage)
+}
+
+func TestApplicationKeepsSettingsOnInvalidReload(t *testing.T) {
+	app, _, cleanup := createApplication(t, "http://localhost", time.Second*5)
+	defer cleanup()
+
+	current := app.container.GetSettings()
+	loaded := make(chan struct{}, 1)
+
+	app.WatchSettings("", func() (*Settings, error) {
+		loaded <- struct{}{}
+
+		return nil, errors.New("invalid settings")
+	})
+
+	ctx, stop := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+
+	go func() {
+		result <- app.run(ctx)
+	}()
+
+	app.Reload()
+
+	select {
+	case <-loaded:
+	case <-time.After(time.Second * 5):
+		t.Fatal("settings are not reloaded")
+	}
+
+	stop()
+
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("application is not stopped")
+	}
+
+	assert.Equal(t, current, app.container.GetSettings())
+}
+
+func TestContainerRoutesDependencies(t *testing.T) {
+	for _, authEnabled := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "beagle")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		settings := NewDefaultSettings()
+		settings.Storage.ConnectionString = filepath.Join(dir, "database.db")
+		settings.Http.Port = 0
+		settings.Http.Static = nil
+		settings.Auth.Enabled = authEnabled
+
+		container, err := newContainer(settings, zap.NewNop(), devices.NewMockDevice())
+
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+
+		initManager := container.GetInitManager()
+
+		assert.NoError(t, initManager.Run(), "initialization")
+
+		dependsOn := []string{"storage"}
+
+		if authEnabled {
+			dependsOn = append(dependsOn, "auth")
+		}
+
+		steps := make(map[string][]string)
+
+		for _, step := range initManager.Status() {
+			steps[step.Name] = step.DependsOn
+		}
+
+		assert.Equal(t, dependsOn, steps["routes"], "routes wait for the storage and auth")
+		assert.NoError(t, initManager.Shutdown(context.Background()), "shutdown")
+
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,209 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/auth"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func createAuthEngine(t *testing.T) (*gin.Engine, func()) {
+	manager, cleanup := createManager(t)
+
+	service := auth.NewService(zap.NewNop(), manager, &auth.Settings{
+		Enabled:    true,
+		SessionTtl: time.Hour,
+		Admin: &auth.AdminSettings{
+			Username: "admin",
+			Password: "admin-password",
+		},
+	})
+
+	if err := service.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(serverHttp.AuthMiddleware(zap.NewNop(), service, serverHttp.NewAccessPolicy("/api")))
+
+	routes.NewAuthRoute(path.Join("/api", "auth"), zap.NewNop(), service).Use(engine)
+	routes.NewUsersRoute("/api", zap.NewNop(), manager).Use(engine)
+
+	return engine, cleanup
+}
+
+func call(engine *gin.Engine, method, url, token string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, url, &payload)
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res := httptest.NewRecorder()
+	engine.ServeHTTP(res, req)
+
+	return res
+}
+
+func login(t *testing.T, engine *gin.Engine, username, password string) string {
+	res := call(engine, http.MethodPost, "/api/auth/login", "", map[string]string{
+		"username": username,
+		"password": password,
+	})
+
+	if !assert.Equal(t, http.StatusOK, res.Code, "login "+username) {
+		t.FailNow()
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, res.Header().Get("Set-Cookie"), "session cookie")
+
+	return body.Token
+}
+
+func TestUserManagement(t *testing.T) {
+	engine, cleanup := createAuthEngine(t)
+	defer cleanup()
+
+	res := call(engine, http.MethodPost, "/api/auth/login", "", map[string]string{
+		"username": "admin",
+		"password": "wrong-password",
+	})
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	admin := login(t, engine, "admin", "admin-password")
+
+	res = call(engine, http.MethodPost, "/api/user", admin, map[string]interface{}{
+		"username": "viewer",
+		"password": "viewer-password",
+		"role":     auth.ROLE_VIEWER,
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	viewerId := res.Body.String()
+
+	res = call(engine, http.MethodPost, "/api/user", admin, map[string]interface{}{
+		"username": "viewer",
+		"password": "viewer-password",
+		"role":     auth.ROLE_VIEWER,
+	})
+	assert.Equal(t, http.StatusConflict, res.Code, "duplicate username")
+
+	res = call(engine, http.MethodPost, "/api/user", admin, map[string]interface{}{
+		"username": "other",
+		"password": "other-password",
+		"role":     "root",
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "invalid role")
+
+	viewer := login(t, engine, "viewer", "viewer-password")
+
+	res = call(engine, http.MethodGet, "/api/auth/me", viewer, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"role":"viewer"`)
+	assert.NotContains(t, res.Body.String(), "password")
+
+	res = call(engine, http.MethodGet, "/api/users", viewer, nil)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	res = call(engine, http.MethodPost, fmt.Sprintf("/api/user/%s/key", viewerId), admin, map[string]string{
+		"name": "dashboard",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var created struct {
+		Token string `json:"token"`
+	}
+
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &created))
+
+	res = call(engine, http.MethodGet, "/api/auth/me", created.Token, nil)
+	assert.Equal(t, http.StatusOK, res.Code, "api key")
+
+	res = call(engine, http.MethodDelete, "/api/user/1", admin, nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "admins can not delete themselves")
+
+	res = call(engine, http.MethodDelete, "/api/user/"+viewerId, admin, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = call(engine, http.MethodGet, "/api/auth/me", created.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "api key is deleted with its user")
+
+	res = call(engine, http.MethodGet, "/api/auth/me", viewer, nil)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "sessions are deleted with its user")
+
+	res = call(engine, http.MethodPost, "/api/auth/logout", admin, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = call(engine, http.MethodGet, "/api/users", admin, nil)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "session is ended")
+}
+
+func TestUserUpdateEndsSessions(t *testing.T) {
+	engine, cleanup := createAuthEngine(t)
+	defer cleanup()
+
+	admin := login(t, engine, "admin", "admin-password")
+
+	res := call(engine, http.MethodPost, "/api/user", admin, map[string]interface{}{
+		"username": "operator",
+		"password": "operator-password",
+		"role":     auth.ROLE_ADMIN,
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	operatorId := res.Body.String()
+	operator := login(t, engine, "operator", "operator-password")
+
+	res = call(engine, http.MethodPut, "/api/user", admin, map[string]interface{}{
+		"id":       json.Number(operatorId),
+		"username": "operator",
+		"role":     auth.ROLE_VIEWER,
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = call(engine, http.MethodGet, "/api/auth/me", operator, nil)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "demotion ends sessions")
+
+	res = call(engine, http.MethodPut, "/api/user", admin, map[string]interface{}{
+		"id":       1,
+		"username": "admin",
+		"role":     auth.ROLE_VIEWER,
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code, "admins can not demote themselves")
+
+	operator = login(t, engine, "operator", "operator-password")
+
+	res = call(engine, http.MethodPost, "/api/user", operator, map[string]interface{}{
+		"username": "intruder",
+		"password": "intruder-password",
+		"role":     auth.ROLE_ADMIN,
+	})
+	assert.Equal(t, http.StatusForbidden, res.Code)
+}
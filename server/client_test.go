@@ -0,0 +1,180 @@
+package server_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blent/beagle/pkg/client"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/blent/beagle/server/http/openapi"
+	"github.com/blent/beagle/server/http/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func createRegistryEngine(t *testing.T) (*gin.Engine, func()) {
+	manager, cleanup := createManager(t)
+
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+
+	routes.NewPeripheralsRoute("/api/registry", zap.NewNop(), manager).Use(engine)
+	routes.NewEndpointsRoute("/api/registry", zap.NewNop(), manager, nil).Use(engine)
+	routes.NewGroupsRoute("/api/registry", zap.NewNop(), manager).Use(engine)
+	routes.NewSubscribersRoute("/api/registry", zap.NewNop(), manager).Use(engine)
+	routes.NewRulesRoute("/api/registry", zap.NewNop(), manager).Use(engine)
+
+	return engine, cleanup
+}
+
+func TestClient(t *testing.T) {
+	engine, cleanup := createRegistryEngine(t)
+	defer cleanup()
+
+	routes.NewOpenApiRoute(openapi.SpecRoute("/api"), openapi.NewSpec("test", "/api", "")).Use(engine)
+
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	c := client.New(server.URL + "/api")
+
+	spec, err := c.GetSpec()
+	assert.NoError(t, err)
+	assert.Equal(t, openapi.VERSION, spec["openapi"])
+
+	endpoint := &notification.Endpoint{
+		Name:   "hook",
+		Kind:   notification.ENDPOINT_KIND_HTTP,
+		Url:    "http://localhost/hook",
+		Method: "POST",
+	}
+
+	endpoint.Id, err = c.CreateEndpoint(endpoint)
+	assert.NoError(t, err)
+
+	duplicate := *endpoint
+	duplicate.Id = 0
+
+	_, err = c.CreateEndpoint(&duplicate)
+	assert.True(t, client.IsConflict(err), "duplicate endpoint")
+
+	peripheral := &client.Peripheral{
+		Kind:    "ibeacon",
+		Name:    "kitchen",
+		Enabled: true,
+		Uuid:    "f7826da64fa24e988024bc5b71e0893e",
+		Major:   1,
+		Minor:   2,
+		Subscribers: []*notification.Subscriber{
+			{Name: "found", Event: notification.FOUND, Enabled: true, Endpoint: &notification.Endpoint{Id: endpoint.Id}},
+		},
+	}
+
+	peripheral.Id, err = c.CreatePeripheral(peripheral)
+	assert.NoError(t, err)
+
+	page, err := c.FindPeripherals(&client.PeripheralQuery{Search: "kitch", Event: notification.FOUND})
+
+	if assert.NoError(t, err) && assert.Len(t, page.Items, 1) {
+		assert.Equal(t, peripheral.Id, page.Items[0].Id)
+		assert.Empty(t, page.Next)
+	}
+
+	saved, err := c.GetPeripheral(peripheral.Id)
+
+	if assert.NoError(t, err) && assert.Len(t, saved.Subscribers, 1) {
+		assert.Equal(t, "found", saved.Subscribers[0].Name)
+	}
+
+	id, err := c.CreateSubscriber(&notification.Subscriber{
+		Name:     "lost",
+		Event:    notification.LOST,
+		TargetId: peripheral.Id,
+		Endpoint: &notification.Endpoint{Id: endpoint.Id},
+	})
+	assert.NoError(t, err)
+
+	_, err = c.CreateSubscriber(&notification.Subscriber{Name: "lost", Event: notification.LOST})
+	assert.True(t, client.IsInvalid(err), "invalid subscriber")
+
+	subscribers, err := c.FindSubscribers(&client.SubscriberQuery{Events: []string{notification.LOST}})
+
+	if assert.NoError(t, err) && assert.Len(t, subscribers.Items, 1) {
+		assert.Equal(t, id, subscribers.Items[0].Id)
+		assert.False(t, subscribers.Items[0].Enabled)
+	}
+
+	assert.NoError(t, c.EnableSubscribers([]uint64{id}))
+
+	subscriber, err := c.GetSubscriber(id)
+
+	if assert.NoError(t, err) {
+		assert.True(t, subscriber.Enabled)
+	}
+
+	result, err := c.ImportPeripherals([]*client.ImportedPeripheral{
+		{Name: "hall", Uuid: "f7826da64fa24e988024bc5b71e0893e", Major: 2, Subscribers: []*client.ImportedSubscriber{
+			{Name: "found", Event: notification.FOUND, Endpoint: "missing"},
+		}},
+	}, true)
+	assert.True(t, client.IsInvalid(err), "unknown endpoint")
+	assert.Nil(t, result)
+
+	exported, err := c.ExportPeripherals()
+	assert.NoError(t, err)
+
+	result, err = c.ImportPeripheralsCsv(bytes.NewReader(exported), true)
+	assert.True(t, client.IsConflict(err), "import of existing peripherals")
+
+	ruleId, err := c.CreateRule(&client.Rule{
+		Name:         "badges",
+		Enabled:      true,
+		Mode:         tracking.RULE_MODE_REVIEW,
+		NameTemplate: "badge-{minor}",
+		Uuid:         "f7826da64fa24e988024bc5b71e0893e",
+		Major:        3,
+		Subscribers: []*notification.Subscriber{
+			{Name: "found", Event: notification.FOUND, Enabled: true, Endpoint: &notification.Endpoint{Id: endpoint.Id}},
+		},
+	})
+	assert.NoError(t, err)
+
+	rule, err := c.GetRule(ruleId)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint16(3), rule.Major)
+		assert.Len(t, rule.Subscribers, 1)
+	}
+
+	rules, err := c.FindRules(0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), rules.Quantity)
+
+	candidates, err := c.FindCandidates(&client.CandidateQuery{Status: tracking.CANDIDATE_STATUS_PENDING})
+	assert.NoError(t, err)
+	assert.Empty(t, candidates.Items)
+
+	_, err = c.ApproveCandidate(1, "")
+	assert.True(t, client.IsNotFound(err), "missing candidate")
+
+	templates, err := c.FindSubscribers(&client.SubscriberQuery{RuleId: ruleId})
+	assert.NoError(t, err)
+	assert.Len(t, templates.Items, 1, "subscribers of a rule")
+
+	linked, err := c.GetEndpointSubscribers(endpoint.Id)
+	assert.NoError(t, err)
+	assert.Len(t, linked, 3)
+
+	assert.NoError(t, c.DeleteEndpoint(endpoint.Id))
+
+	_, err = c.GetEndpoint(endpoint.Id)
+	assert.True(t, client.IsNotFound(err))
+
+	subscribers, err = c.FindSubscribers(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, subscribers.Items, "subscribers are deleted along with an endpoint")
+}
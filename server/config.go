@@ -0,0 +1,475 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/utils"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v2"
+)
+
+const EnvironmentPrefix = "BEAGLE"
+
+var (
+	ErrMissingSection           = errors.New("section must be present")
+	ErrInvalidName              = errors.New("name value must be non-empty string")
+	ErrInvalidShutdownTimeout   = errors.New("shutdown timeout value must be greater than 0")
+	ErrInvalidLogLevel          = errors.New("log level must be one of: debug, info, warn, error")
+	ErrInvalidPort              = errors.New("port value must be between 0 and 65535")
+	ErrInvalidApiRoute          = errors.New("api route must be non-empty string")
+	ErrRouteCollision           = errors.New("routes collision detected")
+	ErrStaticRoute              = errors.New("static route must be non-empty string")
+	ErrInvalidMetricsRoute      = errors.New("metrics route must be an absolute path")
+	ErrInvalidAddress           = errors.New("address must be an ip address or a host name without a port")
+	ErrInvalidCertificate       = errors.New("certificate file must exist unless a self-signed one is enabled")
+	ErrInvalidKey               = errors.New("key file must exist unless a self-signed certificate is enabled")
+	ErrInvalidClientCa          = errors.New("client ca file must exist")
+	ErrInvalidClientAuth        = errors.New("client auth must be one of: optional, required")
+	ErrInvalidSessionTtl        = errors.New("session ttl value must be greater than 0")
+	ErrInvalidAdminUsername     = errors.New("admin username must be non-empty string")
+	ErrInvalidStorageProvider   = errors.New("storage provider is not supported")
+	ErrInvalidStorageConnection = errors.New("storage connection value must be non-empty string")
+	ErrInvalidDeviceKind        = errors.New("device kind must be one of: ble, replay, simulated, none")
+	ErrInvalidHci               = errors.New("hci value must be greater than or equal to 0")
+	ErrInvalidReplayFile        = errors.New("replay file must exist")
+	ErrInvalidScenarioFile      = errors.New("scenario file must exist")
+	ErrInvalidSimulatedFleet    = errors.New("simulated peripherals value must be between 1 and 65535")
+	ErrInvalidSimulatedInterval = errors.New("simulated interval value must be greater than 0")
+	ErrInvalidTtlDuration       = errors.New("ttl value must be greater than 0")
+	ErrInvalidHeartbeatInterval = errors.New("heartbeat value must be greater than 0")
+	ErrInvalidRateLimit         = errors.New("rate limit value must be greater than or equal to 0")
+	ErrInvalidBurst             = errors.New("burst value must be greater than 0")
+	ErrInvalidMaxInFlight       = errors.New("max in-flight value must be greater than or equal to 0")
+	ErrInvalidDeliveryTimeout   = errors.New("delivery timeout value must be greater than or equal to 0")
+	ErrInvalidBreakerThreshold  = errors.New("breaker threshold value must be greater than or equal to 0")
+	ErrInvalidBreakerCooldown   = errors.New("breaker cooldown value must be greater than 0")
+	ErrInvalidCommandTimeout    = errors.New("command timeout value must be greater than 0")
+	ErrInvalidCommandLimit      = errors.New("command concurrency value must be greater than 0")
+	ErrInvalidStreamHistory     = errors.New("stream history value must be greater than or equal to 0")
+	ErrInvalidStreamBuffer      = errors.New("stream buffer value must be greater than 0")
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// SettingError points to a setting by its path in a settings file, e.g. "tracking.ttl"
+type SettingError struct {
+	Key string
+	Err error
+}
+
+func (e *SettingError) Error() string {
+	return fmt.Sprintf("invalid setting \"%s\": %s", e.Key, e.Err.Error())
+}
+
+func (e *SettingError) Cause() error {
+	return e.Err
+}
+
+// LoadSettingsFile reads a YAML file on top of given settings.
+// Missing keys keep their current values, unknown keys are rejected.
+func LoadSettingsFile(settings *Settings, path string) error {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.UnmarshalStrict(data, settings); err != nil {
+		return errors.Wrap(err, path)
+	}
+
+	return nil
+}
+
+// ApplyEnvironment overrides settings by environment variables.
+// Variable names are derived from settings file keys, e.g. "tracking.ttl" is BEAGLE_TRACKING_TTL
+// and "delivery.policy.rateLimit" is BEAGLE_DELIVERY_POLICY_RATE_LIMIT.
+func ApplyEnvironment(settings *Settings, lookup func(string) (string, bool)) error {
+	return applyEnvironment(reflect.ValueOf(settings).Elem(), EnvironmentPrefix, lookup)
+}
+
+func applyEnvironment(target reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	targetType := target.Type()
+
+	for i := 0; i < targetType.NumField(); i++ {
+		key := strings.Split(targetType.Field(i).Tag.Get("yaml"), ",")[0]
+
+		if key == "" || key == "-" {
+			continue
+		}
+
+		name := prefix + "_" + toEnvironmentName(key)
+		field := target.Field(i)
+
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+
+			if err := applyEnvironment(field.Elem(), name, lookup); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		value, ok := lookup(name)
+
+		if !ok {
+			continue
+		}
+
+		if err := setValue(field, strings.TrimSpace(value)); err != nil {
+			return errors.Wrap(err, name)
+		}
+	}
+
+	return nil
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		res, err := time.ParseDuration(value)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(int64(res))
+
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		res, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(res)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		res, err := strconv.ParseInt(value, 10, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(res)
+	case reflect.Float32, reflect.Float64:
+		res, err := strconv.ParseFloat(value, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetFloat(res)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+// toEnvironmentName converts camel case keys to upper snake case
+func toEnvironmentName(key string) string {
+	var res strings.Builder
+
+	for i, r := range key {
+		if i > 0 && unicode.IsUpper(r) {
+			res.WriteRune('_')
+		}
+
+		res.WriteRune(unicode.ToUpper(r))
+	}
+
+	return res.String()
+}
+
+// Validate checks settings and returns SettingError pointing to the first invalid one
+func (s *Settings) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return &SettingError{"name", ErrInvalidName}
+	}
+
+	if s.ShutdownTimeout <= 0 {
+		return &SettingError{"shutdownTimeout", ErrInvalidShutdownTimeout}
+	}
+
+	if s.Log == nil {
+		return &SettingError{"log", ErrMissingSection}
+	}
+
+	if _, err := ParseLogLevel(s.Log.Level); err != nil {
+		return &SettingError{"log.level", ErrInvalidLogLevel}
+	}
+
+	if err := s.validateHttp(); err != nil {
+		return err
+	}
+
+	if s.Auth == nil {
+		return &SettingError{"auth", ErrMissingSection}
+	}
+
+	if s.Auth.Enabled {
+		if s.Auth.SessionTtl <= 0 {
+			return &SettingError{"auth.sessionTtl", ErrInvalidSessionTtl}
+		}
+
+		if s.Auth.Admin == nil || strings.TrimSpace(s.Auth.Admin.Username) == "" {
+			return &SettingError{"auth.admin.username", ErrInvalidAdminUsername}
+		}
+	}
+
+	if s.Storage == nil {
+		return &SettingError{"storage", ErrMissingSection}
+	}
+
+	if s.Storage.Provider != "sqlite3" {
+		return &SettingError{"storage.provider", ErrInvalidStorageProvider}
+	}
+
+	if strings.TrimSpace(s.Storage.ConnectionString) == "" {
+		return &SettingError{"storage.connection", ErrInvalidStorageConnection}
+	}
+
+	if err := s.validateDevice(); err != nil {
+		return err
+	}
+
+	if s.Tracking == nil {
+		return &SettingError{"tracking", ErrMissingSection}
+	}
+
+	if s.Tracking.Ttl <= 0 {
+		return &SettingError{"tracking.ttl", ErrInvalidTtlDuration}
+	}
+
+	if s.Tracking.Heartbeat <= 0 {
+		return &SettingError{"tracking.heartbeat", ErrInvalidHeartbeatInterval}
+	}
+
+	if err := s.validateDelivery(); err != nil {
+		return err
+	}
+
+	if s.Streaming == nil {
+		return &SettingError{"streaming", ErrMissingSection}
+	}
+
+	if s.Streaming.History < 0 {
+		return &SettingError{"streaming.history", ErrInvalidStreamHistory}
+	}
+
+	if s.Streaming.Buffer <= 0 {
+		return &SettingError{"streaming.buffer", ErrInvalidStreamBuffer}
+	}
+
+	return nil
+}
+
+func (s *Settings) validateHttp() error {
+	if s.Http == nil {
+		return &SettingError{"http", ErrMissingSection}
+	}
+
+	if !s.Http.Enabled {
+		return nil
+	}
+
+	if s.Http.Port < 0 || s.Http.Port > 65535 {
+		return &SettingError{"http.port", ErrInvalidPort}
+	}
+
+	if strings.Contains(s.Http.Address, ":") && net.ParseIP(s.Http.Address) == nil {
+		return &SettingError{"http.address", ErrInvalidAddress}
+	}
+
+	if s.Http.Api == nil || strings.TrimSpace(s.Http.Api.Route) == "" {
+		return &SettingError{"http.api.route", ErrInvalidApiRoute}
+	}
+
+	if err := s.validateTls(); err != nil {
+		return err
+	}
+
+	if metrics := s.Http.Metrics; metrics != nil && metrics.Enabled {
+		if !strings.HasPrefix(metrics.Route, "/") || metrics.Route == "/" {
+			return &SettingError{"http.metrics.route", ErrInvalidMetricsRoute}
+		}
+
+		api := path.Join("/", s.Http.Api.Route)
+
+		if metrics.Route == api || strings.HasPrefix(metrics.Route, api+"/") {
+			return &SettingError{"http.metrics.route", ErrRouteCollision}
+		}
+	}
+
+	if s.Http.Static == nil || s.Http.Static.Directory == "" {
+		return nil
+	}
+
+	if s.Http.Static.Route == "" {
+		return &SettingError{"http.static.route", ErrStaticRoute}
+	}
+
+	if s.Http.Static.Route == s.Http.Api.Route {
+		return &SettingError{"http.static.route", ErrRouteCollision}
+	}
+
+	return nil
+}
+
+func (s *Settings) validateTls() error {
+	settings := s.Http.Tls
+
+	if settings == nil || !settings.Enabled {
+		return nil
+	}
+
+	if strings.TrimSpace(settings.Certificate) == "" || (!settings.SelfSigned && !utils.Exists(settings.Certificate)) {
+		return &SettingError{"http.tls.certificate", ErrInvalidCertificate}
+	}
+
+	if strings.TrimSpace(settings.Key) == "" || (!settings.SelfSigned && !utils.Exists(settings.Key)) {
+		return &SettingError{"http.tls.key", ErrInvalidKey}
+	}
+
+	if settings.ClientCa == "" {
+		return nil
+	}
+
+	if !utils.Exists(settings.ClientCa) {
+		return &SettingError{"http.tls.clientCa", ErrInvalidClientCa}
+	}
+
+	if !http.IsSupportedClientAuth(settings.ClientAuth) {
+		return &SettingError{"http.tls.clientAuth", ErrInvalidClientAuth}
+	}
+
+	return nil
+}
+
+func (s *Settings) validateDevice() error {
+	settings := s.Device
+
+	if settings == nil {
+		return &SettingError{"device", ErrMissingSection}
+	}
+
+	if !devices.IsSupportedKind(settings.Kind) {
+		return &SettingError{"device.kind", ErrInvalidDeviceKind}
+	}
+
+	switch settings.Kind {
+	case devices.DEVICE_KIND_BLE:
+		if settings.Hci < 0 {
+			return &SettingError{"device.hci", ErrInvalidHci}
+		}
+	case devices.DEVICE_KIND_REPLAY:
+		if strings.TrimSpace(settings.Replay) == "" || !utils.Exists(settings.Replay) {
+			return &SettingError{"device.replay", ErrInvalidReplayFile}
+		}
+	case devices.DEVICE_KIND_SIMULATED:
+		if settings.Simulated == nil {
+			return &SettingError{"device.simulated", ErrMissingSection}
+		}
+
+		if settings.Simulated.Scenario != "" {
+			if !utils.Exists(settings.Simulated.Scenario) {
+				return &SettingError{"device.simulated.scenario", ErrInvalidScenarioFile}
+			}
+
+			return nil
+		}
+
+		if settings.Simulated.Peripherals < 1 || settings.Simulated.Peripherals > 65535 {
+			return &SettingError{"device.simulated.peripherals", ErrInvalidSimulatedFleet}
+		}
+
+		if settings.Simulated.Interval <= 0 {
+			return &SettingError{"device.simulated.interval", ErrInvalidSimulatedInterval}
+		}
+	}
+
+	return nil
+}
+
+func (s *Settings) validateDelivery() error {
+	if s.Delivery == nil {
+		return &SettingError{"delivery", ErrMissingSection}
+	}
+
+	policy := s.Delivery.Policy
+
+	if policy == nil {
+		return &SettingError{"delivery.policy", ErrMissingSection}
+	}
+
+	if policy.RateLimit < 0 {
+		return &SettingError{"delivery.policy.rateLimit", ErrInvalidRateLimit}
+	}
+
+	if policy.Burst <= 0 {
+		return &SettingError{"delivery.policy.burst", ErrInvalidBurst}
+	}
+
+	if policy.MaxInFlight < 0 {
+		return &SettingError{"delivery.policy.maxInFlight", ErrInvalidMaxInFlight}
+	}
+
+	if policy.Timeout < 0 {
+		return &SettingError{"delivery.policy.timeout", ErrInvalidDeliveryTimeout}
+	}
+
+	if policy.BreakerThreshold < 0 {
+		return &SettingError{"delivery.policy.breakerThreshold", ErrInvalidBreakerThreshold}
+	}
+
+	if policy.BreakerCooldown <= 0 {
+		return &SettingError{"delivery.policy.breakerCooldown", ErrInvalidBreakerCooldown}
+	}
+
+	command := s.Delivery.Command
+
+	if command == nil {
+		return &SettingError{"delivery.command", ErrMissingSection}
+	}
+
+	if !command.Enabled {
+		return nil
+	}
+
+	if command.Timeout <= 0 {
+		return &SettingError{"delivery.command.timeout", ErrInvalidCommandTimeout}
+	}
+
+	if command.Concurrency <= 0 {
+		return &SettingError{"delivery.command.concurrency", ErrInvalidCommandLimit}
+	}
+
+	return nil
+}
+
+func ParseLogLevel(level string) (zapcore.Level, error) {
+	var res zapcore.Level
+
+	err := res.UnmarshalText([]byte(level))
+
+	return res, err
+}
@@ -0,0 +1,221 @@
+package server_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/server"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSettingsFile(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "beagle.yml")
+
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+
+		return value, ok
+	}
+}
+
+func TestLoadSettingsFile(t *testing.T) {
+	path, cleanup := writeSettingsFile(t, `
+name: office
+log:
+  level: debug
+http:
+  port: 9090
+tracking:
+  ttl: 30s
+delivery:
+  policy:
+    rateLimit: 2.5
+    breakerCooldown: 1m
+  command:
+    enabled: true
+`)
+	defer cleanup()
+
+	settings := server.NewDefaultSettings()
+
+	err := server.LoadSettingsFile(settings, path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "office", settings.Name)
+	assert.Equal(t, "debug", settings.Log.Level)
+	assert.Equal(t, 9090, settings.Http.Port)
+	assert.Equal(t, "/api", settings.Http.Api.Route, "missing keys keep defaults")
+	assert.Equal(t, 30*time.Second, settings.Tracking.Ttl)
+	assert.Equal(t, 5*time.Second, settings.Tracking.Heartbeat)
+	assert.Equal(t, 2.5, settings.Delivery.Policy.RateLimit)
+	assert.Equal(t, time.Minute, settings.Delivery.Policy.BreakerCooldown)
+	assert.True(t, settings.Delivery.Command.Enabled)
+	assert.Equal(t, 4, settings.Delivery.Command.Concurrency)
+	assert.NoError(t, settings.Validate())
+}
+
+func TestLoadSettingsFileUnknownKey(t *testing.T) {
+	path, cleanup := writeSettingsFile(t, `
+tracking:
+  tll: 30s
+`)
+	defer cleanup()
+
+	err := server.LoadSettingsFile(server.NewDefaultSettings(), path)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tll")
+}
+
+func TestLoadSettingsFileMissing(t *testing.T) {
+	err := server.LoadSettingsFile(server.NewDefaultSettings(), filepath.Join(os.TempDir(), "beagle-missing.yml"))
+
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	settings := server.NewDefaultSettings()
+
+	err := server.ApplyEnvironment(settings, lookupIn(map[string]string{
+		"BEAGLE_NAME":                          "garage",
+		"BEAGLE_SHUTDOWN_TIMEOUT":              "3s",
+		"BEAGLE_HTTP_ENABLED":                  "false",
+		"BEAGLE_STORAGE_CONNECTION":            "/tmp/beagle.db",
+		"BEAGLE_DEVICE_KIND":                   "simulated",
+		"BEAGLE_DEVICE_SIMULATED_PERIPHERALS":  "1000",
+		"BEAGLE_TRACKING_HEARTBEAT":            "1s",
+		"BEAGLE_DELIVERY_POLICY_RATE_LIMIT":    "10",
+		"BEAGLE_DELIVERY_POLICY_MAX_IN_FLIGHT": "2",
+		"BEAGLE_STREAMING_BUFFER":              " 8 ",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "garage", settings.Name)
+	assert.Equal(t, 3*time.Second, settings.ShutdownTimeout)
+	assert.False(t, settings.Http.Enabled)
+	assert.Equal(t, "/tmp/beagle.db", settings.Storage.ConnectionString)
+	assert.Equal(t, "simulated", settings.Device.Kind)
+	assert.Equal(t, 1000, settings.Device.Simulated.Peripherals)
+	assert.Equal(t, time.Second, settings.Tracking.Heartbeat)
+	assert.Equal(t, float64(10), settings.Delivery.Policy.RateLimit)
+	assert.Equal(t, 2, settings.Delivery.Policy.MaxInFlight)
+	assert.Equal(t, 8, settings.Streaming.Buffer)
+}
+
+func TestApplyEnvironmentInvalidValue(t *testing.T) {
+	err := server.ApplyEnvironment(server.NewDefaultSettings(), lookupIn(map[string]string{
+		"BEAGLE_TRACKING_TTL": "5",
+	}))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "BEAGLE_TRACKING_TTL")
+}
+
+func TestApplyEnvironmentCreatesSections(t *testing.T) {
+	settings := server.NewDefaultSettings()
+	settings.Tracking = nil
+
+	err := server.ApplyEnvironment(settings, lookupIn(map[string]string{
+		"BEAGLE_TRACKING_TTL": "5s",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, settings.Tracking.Ttl)
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		key    string
+		err    error
+		modify func(*server.Settings)
+	}{
+		{"name", server.ErrInvalidName, func(s *server.Settings) { s.Name = " " }},
+		{"shutdownTimeout", server.ErrInvalidShutdownTimeout, func(s *server.Settings) { s.ShutdownTimeout = 0 }},
+		{"log.level", server.ErrInvalidLogLevel, func(s *server.Settings) { s.Log.Level = "verbose" }},
+		{"http.port", server.ErrInvalidPort, func(s *server.Settings) { s.Http.Port = 70000 }},
+		{"http.static.route", server.ErrRouteCollision, func(s *server.Settings) {
+			s.Http.Static.Directory = "/var/www"
+			s.Http.Static.Route = "/api"
+		}},
+		{"http.metrics.route", server.ErrInvalidMetricsRoute, func(s *server.Settings) { s.Http.Metrics.Route = "metrics" }},
+		{"http.metrics.route", server.ErrRouteCollision, func(s *server.Settings) { s.Http.Metrics.Route = "/api/metrics" }},
+		{"http.address", server.ErrInvalidAddress, func(s *server.Settings) { s.Http.Address = "gateway:8080" }},
+		{"http.tls.certificate", server.ErrInvalidCertificate, func(s *server.Settings) {
+			s.Http.Tls.Enabled = true
+			s.Http.Tls.SelfSigned = false
+			s.Http.Tls.Certificate = filepath.Join(os.TempDir(), "beagle-missing.pem")
+		}},
+		{"http.tls.clientCa", server.ErrInvalidClientCa, func(s *server.Settings) {
+			s.Http.Tls.Enabled = true
+			s.Http.Tls.ClientCa = filepath.Join(os.TempDir(), "beagle-missing.pem")
+		}},
+		{"auth.sessionTtl", server.ErrInvalidSessionTtl, func(s *server.Settings) { s.Auth.SessionTtl = 0 }},
+		{"storage", server.ErrMissingSection, func(s *server.Settings) { s.Storage = nil }},
+		{"storage.connection", server.ErrInvalidStorageConnection, func(s *server.Settings) { s.Storage.ConnectionString = "" }},
+		{"device.kind", server.ErrInvalidDeviceKind, func(s *server.Settings) { s.Device.Kind = "usb" }},
+		{"device.hci", server.ErrInvalidHci, func(s *server.Settings) { s.Device.Hci = -1 }},
+		{"device.replay", server.ErrInvalidReplayFile, func(s *server.Settings) {
+			s.Device.Kind = "replay"
+			s.Device.Replay = "/nonexistent/replay.jsonl"
+		}},
+		{"device.simulated.peripherals", server.ErrInvalidSimulatedFleet, func(s *server.Settings) {
+			s.Device.Kind = "simulated"
+			s.Device.Simulated.Peripherals = 0
+		}},
+		{"device.simulated.scenario", server.ErrInvalidScenarioFile, func(s *server.Settings) {
+			s.Device.Kind = "simulated"
+			s.Device.Simulated.Scenario = "/nonexistent/fleet.yml"
+		}},
+		{"tracking.ttl", server.ErrInvalidTtlDuration, func(s *server.Settings) { s.Tracking.Ttl = 0 }},
+		{"tracking.heartbeat", server.ErrInvalidHeartbeatInterval, func(s *server.Settings) { s.Tracking.Heartbeat = -time.Second }},
+		{"delivery.policy.burst", server.ErrInvalidBurst, func(s *server.Settings) { s.Delivery.Policy.Burst = 0 }},
+		{"delivery.command.concurrency", server.ErrInvalidCommandLimit, func(s *server.Settings) {
+			s.Delivery.Command.Enabled = true
+			s.Delivery.Command.Concurrency = 0
+		}},
+		{"streaming.buffer", server.ErrInvalidStreamBuffer, func(s *server.Settings) { s.Streaming.Buffer = 0 }},
+	}
+
+	for _, c := range cases {
+		settings := server.NewDefaultSettings()
+
+		c.modify(settings)
+
+		err := settings.Validate()
+
+		if assert.Error(t, err, c.key) {
+			assert.Equal(t, c.err, errors.Cause(err), c.key)
+			assert.Contains(t, err.Error(), "\""+c.key+"\"")
+		}
+	}
+
+	assert.NoError(t, server.NewDefaultSettings().Validate())
+}
+
+func TestValidateDisabledHttp(t *testing.T) {
+	settings := server.NewDefaultSettings()
+	settings.Http.Enabled = false
+	settings.Http.Port = -1
+
+	assert.NoError(t, settings.Validate())
+}
@@ -1,14 +1,18 @@
 package server
 
 import (
+	"github.com/blent/beagle/pkg/auth"
 	"github.com/blent/beagle/pkg/delivery"
 	"github.com/blent/beagle/pkg/discovery/devices"
 	"github.com/blent/beagle/pkg/history/activity"
+	"github.com/blent/beagle/pkg/metrics"
 	activityMonitor "github.com/blent/beagle/pkg/monitoring/activity"
 	systemMonitor "github.com/blent/beagle/pkg/monitoring/system"
 	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/streaming"
 	"github.com/blent/beagle/pkg/tracking"
 	"github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/openapi"
 	"github.com/blent/beagle/server/http/routes"
 	"github.com/blent/beagle/server/initialization"
 	"github.com/blent/beagle/server/initialization/initializers"
@@ -17,25 +21,41 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"path"
+	"reflect"
+	"time"
 )
 
+// initTimeout limits initializers which wait for the storage
+const initTimeout = time.Second * 30
+
 type Container struct {
 	settings        *Settings
 	logger          *zap.Logger
+	level           *zap.AtomicLevel
 	initManager     *initialization.InitManager
-	initializers    map[string]initialization.Initializer
 	tracker         *tracking.Tracker
 	eventBroker     *notification.Broker
 	storageProvider storage.Provider
 	activityService *activityMonitor.Monitoring
 	activityWriter  *activity.Writer
+	sender          *delivery.Sender
+	authService     *auth.Service
+	streamingHub    *streaming.Hub
 	server          *http.Server
 }
 
+// NewContainer creates the device selected by settings and wires all services around it
 func NewContainer(settings *Settings) (*Container, error) {
-	var err error
+	level, err := ParseLogLevel(settings.Log.Level)
+
+	if err != nil {
+		return nil, err
+	}
 
-	logger, err := zap.NewProduction(zap.Fields(
+	config := zap.NewProductionConfig()
+	config.Level = zap.NewAtomicLevelAt(level)
+
+	logger, err := config.Build(zap.Fields(
 		zap.String("app", settings.Name),
 		zap.String("version", settings.Version),
 	))
@@ -45,12 +65,27 @@ func NewContainer(settings *Settings) (*Container, error) {
 	}
 
 	// Core
-	device, err := devices.NewDevice(logger.Named("device"))
+	device, err := devices.New(logger.Named("device"), settings.Device)
+
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := newContainer(settings, logger, device)
 
 	if err != nil {
 		return nil, err
 	}
 
+	container.level = &config.Level
+
+	return container, nil
+}
+
+// newContainer wires services around a given device, so that any device can be injected
+func newContainer(settings *Settings, logger *zap.Logger, device devices.Device) (*Container, error) {
+	var err error
+
 	tracker := tracking.NewTracker(logger.Named("tracker"), device, settings.Tracking)
 
 	// Storage
@@ -63,10 +98,11 @@ func NewContainer(settings *Settings) (*Container, error) {
 	storageManager := storage.NewManager(logger.Named("storage"), storageProvider)
 
 	// Init
-	initManager := initialization.NewInitManager(logger.Named("initialization"))
-	inits := map[string]initialization.Initializer{
-		"storage": initializers.NewDatabaseInitializer(logger.Named("initialization:database"), storageProvider),
-	}
+	initManager := initialization.NewInitManager(logger.Named("initialization")).Add(&initialization.Step{
+		Name:        "storage",
+		Initializer: initializers.NewDatabaseInitializer(logger.Named("initialization:database"), storageProvider),
+		Timeout:     initTimeout,
+	})
 
 	// Writer
 	activityWriter := activity.New(logger.Named("activity:writer"))
@@ -84,30 +120,53 @@ func NewContainer(settings *Settings) (*Container, error) {
 		return nil, err
 	}
 
+	cachingRegistry := NewCachingRegistry(registry).Use(storageManager)
+
+	sender := delivery.New(
+		logger.Named("sender"),
+		delivery.NewHttpTransport(logger.Named("transport")),
+	).SetPolicy(settings.Delivery.Policy)
+
+	if settings.Delivery.Command.Enabled {
+		sender.SetCommandTransport(
+			delivery.NewExecTransport(logger.Named("transport:command"), settings.Delivery.Command),
+		)
+	}
+
 	eventBroker, err := notification.NewBroker(
 		logger.Named("broker"),
-		delivery.New(
-			logger.Named("sender"),
-			delivery.NewHttpTransport(logger.Named("transport")),
-		),
-		registry,
+		sender,
+		cachingRegistry,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	eventBroker.UseRegistrar(NewRuleRegistrar(storageManager))
+
+	authService := auth.NewService(logger.Named("auth"), storageManager, settings.Auth)
+
 	// Http
-	var webServer *http.Server
+	webServer := http.NewServer(logger.Named("server"), settings.Http)
+
+	var eventsHub *streaming.Hub
 
 	if settings.Http.Enabled {
-		webServer = http.NewServer(logger.Named("server"), settings.Http)
+		systemService := systemMonitor.New(logger.Named("service:monitoring:system"))
 
 		monitoringRoute := routes.NewMonitoringRoute(
 			path.Join(settings.Http.Api.Route, "monitoring"),
 			logger.Named("route:monitoring"),
 			activityService,
-			systemMonitor.New(logger.Named("service:monitoring:system")),
+			systemService,
+			sender,
+			map[string]routes.ListenerStats{
+				"broker":   eventBroker,
+				"delivery": sender,
+			},
+			cachingRegistry,
+			initManager,
 		)
 
 		peripheralsRoute := routes.NewPeripheralsRoute(
@@ -116,17 +175,116 @@ func NewContainer(settings *Settings) (*Container, error) {
 			storageManager,
 		)
 
+		groupsRoute := routes.NewGroupsRoute(
+			path.Join(settings.Http.Api.Route, "registry"),
+			logger.Named("route:groups"),
+			storageManager,
+		)
+
 		endpointsRoute := routes.NewEndpointsRoute(
 			path.Join(settings.Http.Api.Route, "registry"),
 			logger.Named("route:endpoints"),
 			storageManager,
+			sender,
 		)
 
-		inits["routes"] = initializers.NewRoutesInitializer(
-			logger.Named("initialization:routes"),
-			webServer,
-			[]http.Route{monitoringRoute, peripheralsRoute, endpointsRoute},
+		subscribersRoute := routes.NewSubscribersRoute(
+			path.Join(settings.Http.Api.Route, "registry"),
+			logger.Named("route:subscribers"),
+			storageManager,
 		)
+
+		rulesRoute := routes.NewRulesRoute(
+			path.Join(settings.Http.Api.Route, "registry"),
+			logger.Named("route:rules"),
+			storageManager,
+		)
+
+		claimRoute := routes.NewClaimRoute(
+			path.Join(settings.Http.Api.Route, "registry"),
+			logger.Named("route:claim"),
+			activityMonitor.NewPairing(logger.Named("activity:pairing"), activityService, tracker),
+		)
+
+		eventsHub = streaming.New(logger.Named("streaming"), settings.Streaming).
+			Use(eventBroker).
+			UseSender(sender)
+
+		eventsRoute := routes.NewEventsRoute(
+			path.Join(settings.Http.Api.Route, "events"),
+			logger.Named("route:events"),
+			eventsHub,
+		)
+
+		routeList := []http.Route{monitoringRoute, peripheralsRoute, groupsRoute, endpointsRoute, subscribersRoute, rulesRoute, claimRoute, eventsRoute}
+		protected := make([]string, 0, 1)
+		metricsRoute := ""
+		// routes serve the storage and sessions, so the server starts only after them
+		routesDependsOn := []string{"storage"}
+
+		if settings.Http.Metrics != nil && settings.Http.Metrics.Enabled {
+			metricsRoute = settings.Http.Metrics.Route
+
+			collector := metrics.New(logger.Named("metrics")).
+				Use(eventBroker).
+				UseSender(sender).
+				UseTracker(tracker).
+				UseActivity(activityService).
+				UseSystem(systemService)
+
+			storageManager.OnQuery(collector.ObserveQuery)
+			webServer.OnRequest(collector.ObserveRequest)
+
+			routeList = append(routeList, routes.NewMetricsRoute(settings.Http.Metrics.Route, collector))
+			protected = append(protected, settings.Http.Metrics.Route)
+		}
+
+		if settings.Auth.Enabled {
+			webServer.UseAuth(authService, http.NewAccessPolicy(settings.Http.Api.Route, protected...))
+
+			initManager.Add(&initialization.Step{
+				Name:        "auth",
+				Initializer: initializers.NewAuthInitializer(logger.Named("initialization:auth"), authService),
+				DependsOn:   []string{"storage"},
+				Timeout:     initTimeout,
+			})
+
+			routesDependsOn = append(routesDependsOn, "auth")
+
+			routeList = append(
+				routeList,
+				routes.NewAuthRoute(
+					path.Join(settings.Http.Api.Route, "auth"),
+					logger.Named("route:auth"),
+					authService,
+				),
+				routes.NewUsersRoute(
+					settings.Http.Api.Route,
+					logger.Named("route:users"),
+					storageManager,
+				),
+			)
+		} else {
+			logger.Warn("Authentication is disabled, the api is open to everyone")
+		}
+
+		routeList = append(
+			routeList,
+			routes.NewOpenApiRoute(
+				openapi.SpecRoute(settings.Http.Api.Route),
+				openapi.NewSpec(settings.Version, settings.Http.Api.Route, metricsRoute),
+			),
+		)
+
+		initManager.Add(&initialization.Step{
+			Name: "routes",
+			Initializer: initializers.NewRoutesInitializer(
+				logger.Named("initialization:routes"),
+				webServer,
+				routeList,
+			),
+			DependsOn: routesDependsOn,
+		})
 	}
 
 	if err != nil {
@@ -136,17 +294,50 @@ func NewContainer(settings *Settings) (*Container, error) {
 	return &Container{
 		settings,
 		logger,
+		nil,
 		initManager,
-		inits,
 		tracker,
 		eventBroker,
 		storageProvider,
 		activityService,
 		activityWriter,
+		sender,
+		authService,
+		eventsHub,
 		webServer,
 	}, nil
 }
 
+func restartRequired(current, next *Settings) []string {
+	res := make([]string, 0, 4)
+
+	if next.Name != current.Name {
+		res = append(res, "name")
+	}
+
+	if !reflect.DeepEqual(next.Http, current.Http) {
+		res = append(res, "http")
+	}
+
+	if !reflect.DeepEqual(next.Auth, current.Auth) {
+		res = append(res, "auth")
+	}
+
+	if *next.Storage != *current.Storage {
+		res = append(res, "storage")
+	}
+
+	if !reflect.DeepEqual(next.Device, current.Device) {
+		res = append(res, "device")
+	}
+
+	if *next.Streaming != *current.Streaming {
+		res = append(res, "streaming")
+	}
+
+	return res
+}
+
 func createStorageProvider(settings *storage.Settings) (storage.Provider, error) {
 	switch settings.Provider {
 	case "sqlite3":
@@ -156,6 +347,59 @@ func createStorageProvider(settings *storage.Settings) (storage.Provider, error)
 	}
 }
 
+func (c *Container) GetSettings() *Settings {
+	return c.settings
+}
+
+// ApplySettings applies tracking, delivery and logging settings at runtime.
+// Changes of other settings take effect only after restart.
+func (c *Container) ApplySettings(next *Settings) {
+	current := c.settings
+
+	for _, key := range restartRequired(current, next) {
+		c.logger.Warn(
+			"Setting change requires restart",
+			zap.String("setting", key),
+		)
+	}
+
+	if c.level != nil && next.Log.Level != current.Log.Level {
+		level, _ := ParseLogLevel(next.Log.Level)
+
+		c.level.SetLevel(level)
+	}
+
+	c.tracker.SetSettings(next.Tracking)
+
+	if *next.Delivery.Policy != *current.Delivery.Policy {
+		c.sender.SetPolicy(next.Delivery.Policy)
+	}
+
+	if *next.Delivery.Command != *current.Delivery.Command {
+		if next.Delivery.Command.Enabled {
+			c.sender.SetCommandTransport(
+				delivery.NewExecTransport(c.logger.Named("transport:command"), next.Delivery.Command),
+			)
+		} else {
+			c.sender.SetCommandTransport(nil)
+		}
+	}
+
+	c.settings = &Settings{
+		Version:         current.Version,
+		Name:            current.Name,
+		ShutdownTimeout: next.ShutdownTimeout,
+		Log:             next.Log,
+		Http:            current.Http,
+		Auth:            current.Auth,
+		Storage:         current.Storage,
+		Device:          current.Device,
+		Tracking:        next.Tracking,
+		Delivery:        next.Delivery,
+		Streaming:       current.Streaming,
+	}
+}
+
 func (c *Container) GetLogger() *zap.Logger {
 	return c.logger
 }
@@ -164,10 +408,6 @@ func (c *Container) GetInitManager() *initialization.InitManager {
 	return c.initManager
 }
 
-func (c *Container) GetAllInitializers() map[string]initialization.Initializer {
-	return c.initializers
-}
-
 func (c *Container) GetEventBroker() *notification.Broker {
 	return c.eventBroker
 }
@@ -188,6 +428,18 @@ func (c *Container) GetTracker() *tracking.Tracker {
 	return c.tracker
 }
 
+func (c *Container) GetSender() *delivery.Sender {
+	return c.sender
+}
+
+func (c *Container) GetAuthService() *auth.Service {
+	return c.authService
+}
+
+func (c *Container) GetStreamingHub() *streaming.Hub {
+	return c.streamingHub
+}
+
 func (c *Container) GetServer() *http.Server {
 	return c.server
 }
@@ -0,0 +1,170 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/client"
+	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/blent/beagle/server"
+	"github.com/blent/beagle/server/initialization"
+	"github.com/blent/beagle/server/initialization/initializers"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/storage/providers/sqlite"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// seedStorage registers a simulated iBeacon before the application starts,
+// so that its first advertisement is already delivered
+func seedStorage(t *testing.T, connection, webhookUrl string) {
+	provider, err := sqlite.NewSQLiteProvider(connection)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	init := initializers.NewDatabaseInitializer(zap.NewNop(), provider)
+	defer init.Shutdown(context.Background())
+
+	if err := init.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := storage.NewManager(zap.NewNop(), provider)
+
+	endpointId, err := manager.CreateEndpoint(&notification.Endpoint{
+		Name:   "webhook",
+		Url:    webhookUrl,
+		Method: http.MethodPost,
+		Kind:   notification.ENDPOINT_KIND_HTTP,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = manager.CreatePeripheral(&tracking.Peripheral{
+		Key:     peripherals.CreateIBeaconUniqueKey(devices.SIMULATED_UUID, 1, 2),
+		Name:    "kitchen",
+		Kind:    peripherals.PERIPHERAL_IBEACON,
+		Enabled: true,
+	}, []*notification.Subscriber{{
+		Name:     "found",
+		Event:    notification.FOUND,
+		Enabled:  true,
+		Endpoint: &notification.Endpoint{Id: endpointId},
+	}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplicationWithSimulatedDevice(t *testing.T) {
+	received := make(chan map[string]interface{}, 10)
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+
+		json.NewDecoder(r.Body).Decode(&body)
+
+		received <- body
+	}))
+	defer webhook.Close()
+
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	settings := server.NewDefaultSettings()
+	settings.ShutdownTimeout = time.Second * 5
+	settings.Log.Level = "error"
+	settings.Storage.ConnectionString = filepath.Join(dir, "database.db")
+	settings.Http.Address = "127.0.0.1"
+	settings.Http.Port = freePort(t)
+	settings.Http.Static = nil
+	settings.Auth.Enabled = false
+	settings.Device.Kind = devices.DEVICE_KIND_SIMULATED
+	settings.Device.Simulated.Peripherals = 5
+	settings.Device.Simulated.Interval = time.Millisecond * 100
+
+	assert.NoError(t, settings.Validate())
+
+	seedStorage(t, settings.Storage.ConnectionString, webhook.URL)
+
+	app, err := server.New(settings)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+
+	go func() {
+		result <- app.RunContext(ctx)
+	}()
+
+	select {
+	case body := <-received:
+		assert.Equal(t, "kitchen", body["name"])
+	case <-time.After(time.Second * 10):
+		t.Fatal("webhook is not called")
+	}
+
+	c := client.New("http://127.0.0.1:" + strconv.Itoa(settings.Http.Port) + "/api")
+
+	var steps []*client.StartupStep
+
+	for attempt := 0; attempt < 50; attempt++ {
+		if steps, err = c.GetStartupStatus(); err == nil {
+			break
+		}
+
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	if assert.NoError(t, err) {
+		for _, step := range steps {
+			assert.Equal(t, initialization.STEP_STATE_DONE, step.State, step.Name)
+		}
+	}
+
+	stop()
+
+	select {
+	case err := <-result:
+		assert.NoError(t, err, "shutdown error")
+	case <-time.After(time.Second * 10):
+		t.Fatal("application is not stopped")
+	}
+}
@@ -0,0 +1,180 @@
+package http
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/blent/beagle/pkg/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	SESSION_COOKIE = "beagle_session"
+	API_KEY_HEADER = "X-Api-Key"
+
+	userContextKey = "user"
+)
+
+var ErrForbidden = errors.New("insufficient role")
+
+type (
+	Authenticator interface {
+		Authenticate(token string) (*auth.User, error)
+	}
+
+	// CertificateAuthenticator authenticates machine clients by their verified tls certificates
+	CertificateAuthenticator interface {
+		AuthenticateCertificate(commonName string) (*auth.User, error)
+	}
+
+	// AccessPolicy returns a role required for a request, empty role means public access
+	AccessPolicy func(method, path string) string
+)
+
+// NewAccessPolicy protects api routes and given extra routes only, so static files stay public.
+// Reads require the viewer role, writes and user management require the admin role.
+// Extra routes, like metrics, require the viewer role. Login and the api description are public.
+func NewAccessPolicy(apiRoute string, extra ...string) AccessPolicy {
+	api := path.Join("/", apiRoute)
+	login := path.Join(api, "auth", "login")
+	spec := path.Join(api, "openapi.json")
+	session := path.Join(api, "auth")
+	users := []string{
+		path.Join(api, "users"),
+		path.Join(api, "user"),
+	}
+
+	return func(method, url string) string {
+		for _, route := range extra {
+			if url == route {
+				return auth.ROLE_VIEWER
+			}
+		}
+
+		if !isUnder(url, api) || url == login || url == spec {
+			return ""
+		}
+
+		if isUnder(url, session) {
+			return auth.ROLE_VIEWER
+		}
+
+		for _, prefix := range users {
+			if isUnder(url, prefix) {
+				return auth.ROLE_ADMIN
+			}
+		}
+
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return auth.ROLE_VIEWER
+		default:
+			return auth.ROLE_ADMIN
+		}
+	}
+}
+
+func AuthMiddleware(logger *zap.Logger, authenticator Authenticator, policy AccessPolicy) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		role := policy(ctx.Request.Method, ctx.Request.URL.Path)
+
+		if role == "" {
+			ctx.Next()
+			return
+		}
+
+		user, err := authenticate(authenticator, ctx.Request)
+
+		if err != nil {
+			if err == auth.ErrUnauthenticated {
+				AbortWithError(ctx, http.StatusUnauthorized, err)
+				return
+			}
+
+			logger.Error(
+				"Failed to authenticate a request",
+				zap.String("path", ctx.Request.URL.Path),
+				zap.Error(err),
+			)
+
+			AbortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !user.Allows(role) {
+			logger.Warn(
+				"Access denied",
+				zap.String("user", user.Username),
+				zap.String("method", ctx.Request.Method),
+				zap.String("path", ctx.Request.URL.Path),
+			)
+
+			AbortWithError(ctx, http.StatusForbidden, ErrForbidden)
+			return
+		}
+
+		ctx.Set(userContextKey, user)
+		ctx.Next()
+	}
+}
+
+// authenticate prefers tokens, so a person can use a device with a client certificate
+func authenticate(authenticator Authenticator, req *http.Request) (*auth.User, error) {
+	token := GetToken(req)
+
+	if token != "" {
+		return authenticator.Authenticate(token)
+	}
+
+	certificates, ok := authenticator.(CertificateAuthenticator)
+
+	if !ok || req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	return certificates.AuthenticateCertificate(req.TLS.VerifiedChains[0][0].Subject.CommonName)
+}
+
+// GetToken returns a session token or an api key passed by a bearer authorization header,
+// an api key header or a session cookie
+func GetToken(req *http.Request) string {
+	header := req.Header.Get("Authorization")
+
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+
+	if key := req.Header.Get(API_KEY_HEADER); key != "" {
+		return strings.TrimSpace(key)
+	}
+
+	if cookie, err := req.Cookie(SESSION_COOKIE); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+// GetUser returns an authenticated user, nil if a route is public or auth is disabled
+func GetUser(ctx *gin.Context) *auth.User {
+	value, ok := ctx.Get(userContextKey)
+
+	if !ok {
+		return nil
+	}
+
+	user, _ := value.(*auth.User)
+
+	return user
+}
+
+func isUnder(url, prefix string) bool {
+	if prefix == "/" {
+		return true
+	}
+
+	return url == prefix || strings.HasPrefix(url, prefix+"/")
+}
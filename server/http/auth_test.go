@@ -0,0 +1,132 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blent/beagle/pkg/auth"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type tokenAuthenticator map[string]*auth.User
+
+func (a tokenAuthenticator) Authenticate(token string) (*auth.User, error) {
+	user, ok := a[token]
+
+	if !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	return user, nil
+}
+
+func createEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	authenticator := tokenAuthenticator{
+		"admin-token":  {Id: 1, Username: "admin", Role: auth.ROLE_ADMIN, Enabled: true},
+		"viewer-token": {Id: 2, Username: "viewer", Role: auth.ROLE_VIEWER, Enabled: true},
+	}
+
+	engine := gin.New()
+	engine.Use(serverHttp.AuthMiddleware(zap.NewNop(), authenticator, serverHttp.NewAccessPolicy("/api", "/metrics")))
+
+	handler := func(ctx *gin.Context) {
+		if user := serverHttp.GetUser(ctx); user != nil {
+			ctx.String(http.StatusOK, user.Username)
+			return
+		}
+
+		ctx.String(http.StatusOK, "anonymous")
+	}
+
+	engine.GET("/index.html", handler)
+	engine.POST("/api/auth/login", handler)
+	engine.GET("/api/openapi.json", handler)
+	engine.GET("/api/auth/me", handler)
+	engine.GET("/api/registry/peripherals", handler)
+	engine.POST("/api/registry/peripheral", handler)
+	engine.GET("/api/users", handler)
+	engine.GET("/metrics", handler)
+
+	return engine
+}
+
+func request(engine *gin.Engine, method, url string, prepare func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, nil)
+
+	if prepare != nil {
+		prepare(req)
+	}
+
+	res := httptest.NewRecorder()
+	engine.ServeHTTP(res, req)
+
+	return res
+}
+
+func bearer(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func TestAuthMiddlewareRoles(t *testing.T) {
+	engine := createEngine()
+
+	cases := []struct {
+		method string
+		url    string
+		token  string
+		status int
+	}{
+		{http.MethodGet, "/index.html", "", http.StatusOK},
+		{http.MethodPost, "/api/auth/login", "", http.StatusOK},
+		{http.MethodGet, "/api/openapi.json", "", http.StatusOK},
+		{http.MethodGet, "/api/auth/me", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/auth/me", "viewer-token", http.StatusOK},
+		{http.MethodGet, "/api/registry/peripherals", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/registry/peripherals", "unknown", http.StatusUnauthorized},
+		{http.MethodGet, "/api/registry/peripherals", "viewer-token", http.StatusOK},
+		{http.MethodPost, "/api/registry/peripheral", "viewer-token", http.StatusForbidden},
+		{http.MethodPost, "/api/registry/peripheral", "admin-token", http.StatusOK},
+		{http.MethodGet, "/api/users", "viewer-token", http.StatusForbidden},
+		{http.MethodGet, "/api/users", "admin-token", http.StatusOK},
+		{http.MethodGet, "/metrics", "", http.StatusUnauthorized},
+		{http.MethodGet, "/metrics", "viewer-token", http.StatusOK},
+	}
+
+	for _, c := range cases {
+		var prepare func(*http.Request)
+
+		if c.token != "" {
+			prepare = bearer(c.token)
+		}
+
+		res := request(engine, c.method, c.url, prepare)
+
+		assert.Equal(t, c.status, res.Code, c.method+" "+c.url+" "+c.token)
+	}
+}
+
+func TestAuthMiddlewareCredentials(t *testing.T) {
+	engine := createEngine()
+
+	res := request(engine, http.MethodGet, "/api/auth/me", func(req *http.Request) {
+		req.Header.Set(serverHttp.API_KEY_HEADER, "viewer-token")
+	})
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "viewer", res.Body.String())
+
+	res = request(engine, http.MethodGet, "/api/auth/me", func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: serverHttp.SESSION_COOKIE, Value: "admin-token"})
+	})
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "admin", res.Body.String())
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/blent/beagle/server/http/validation"
+	"github.com/blent/beagle/server/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// ErrorResponse is a body of all failed api requests
+type ErrorResponse struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  validation.Errors `json:"fields,omitempty"`
+}
+
+var ErrRouteNotFound = errors.New("route not found")
+
+// AbortWithError responds with a status and an error envelope.
+// Messages of server errors are not exposed, the status text is used instead.
+func AbortWithError(ctx *gin.Context, status int, err error) {
+	res := &ErrorResponse{
+		Code:    toErrorCode(status),
+		Message: http.StatusText(status),
+	}
+
+	if err != nil {
+		ctx.Error(err)
+
+		if status < http.StatusInternalServerError {
+			res.Message = err.Error()
+		}
+
+		if fields, ok := errors.Cause(err).(validation.Errors); ok {
+			res.Message = "invalid fields"
+			res.Fields = fields
+		}
+	}
+
+	ctx.AbortWithStatusJSON(status, res)
+}
+
+// AbortWithCause responds with a status matching the cause of an error
+func AbortWithCause(ctx *gin.Context, err error) {
+	AbortWithError(ctx, StatusOf(err), err)
+}
+
+// BindJSON decodes a request body and responds with 400 if it is malformed
+func BindJSON(ctx *gin.Context, obj interface{}) bool {
+	if err := ctx.ShouldBindJSON(obj); err != nil {
+		AbortWithError(ctx, http.StatusBadRequest, errors.Wrap(err, "invalid body"))
+		return false
+	}
+
+	return true
+}
+
+func StatusOf(err error) int {
+	cause := errors.Cause(err)
+
+	if _, ok := cause.(validation.Errors); ok {
+		return http.StatusUnprocessableEntity
+	}
+
+	switch cause {
+	case storage.ErrNotFound:
+		return http.StatusNotFound
+	case storage.ErrConflict:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// toErrorCode turns a status text into a code, e.g. "not_found"
+func toErrorCode(status int) string {
+	text := http.StatusText(status)
+
+	if text == "" {
+		return "error"
+	}
+
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
@@ -7,7 +7,10 @@ import (
 	"time"
 )
 
-func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
+// RequestObserver receives a completed request, the path is the one of the request
+type RequestObserver func(method, path string, status int, duration time.Duration)
+
+func LoggerMiddleware(logger *zap.Logger, observers ...RequestObserver) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// Start timer
 		start := time.Now()
@@ -22,13 +25,19 @@ func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 
 		ctx.Next()
 
+		elapsed := time.Since(start)
+
+		for _, observer := range observers {
+			observer(method, path, ctx.Writer.Status(), elapsed)
+		}
+
 		logger.Info(
 			"Completed",
 			zap.String("path", path),
 			zap.String("method", method),
 			zap.Int("status", ctx.Writer.Status()),
 			zap.String("status-text", http.StatusText(ctx.Writer.Status())),
-			zap.Duration("time", time.Since(start)),
+			zap.Duration("time", elapsed),
 		)
 	}
 }
@@ -0,0 +1,216 @@
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+const VERSION = "3.0.3"
+
+// Public allows anonymous access to an operation
+var Public = []SecurityRequired{{}}
+
+type (
+	// Document is a subset of OpenAPI 3 used to describe the api
+	Document struct {
+		OpenApi    string              `json:"openapi"`
+		Info       *Info               `json:"info"`
+		Paths      map[string]PathItem `json:"paths"`
+		Components *Components         `json:"components"`
+		Security   []SecurityRequired  `json:"security,omitempty"`
+	}
+
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description,omitempty"`
+		Version     string `json:"version"`
+	}
+
+	// PathItem maps lower case http methods to operations
+	PathItem map[string]*Operation
+
+	Operation struct {
+		OperationId string               `json:"operationId"`
+		Summary     string               `json:"summary"`
+		Description string               `json:"description,omitempty"`
+		Tags        []string             `json:"tags,omitempty"`
+		Parameters  []*Parameter         `json:"parameters,omitempty"`
+		RequestBody *RequestBody         `json:"requestBody,omitempty"`
+		Responses   map[string]*Response `json:"responses"`
+		Security    []SecurityRequired   `json:"security,omitempty"`
+	}
+
+	Parameter struct {
+		Name        string  `json:"name"`
+		In          string  `json:"in"`
+		Description string  `json:"description,omitempty"`
+		Required    bool    `json:"required,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	RequestBody struct {
+		Required bool                  `json:"required"`
+		Content  map[string]*MediaType `json:"content"`
+	}
+
+	Response struct {
+		Description string                `json:"description"`
+		Content     map[string]*MediaType `json:"content,omitempty"`
+	}
+
+	MediaType struct {
+		Schema *Schema `json:"schema"`
+	}
+
+	Schema struct {
+		Ref                  string             `json:"$ref,omitempty"`
+		Type                 string             `json:"type,omitempty"`
+		Format               string             `json:"format,omitempty"`
+		Description          string             `json:"description,omitempty"`
+		Enum                 []string           `json:"enum,omitempty"`
+		Items                *Schema            `json:"items,omitempty"`
+		Properties           map[string]*Schema `json:"properties,omitempty"`
+		AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+		Required             []string           `json:"required,omitempty"`
+		Nullable             bool               `json:"nullable,omitempty"`
+	}
+
+	Components struct {
+		Schemas         map[string]*Schema         `json:"schemas"`
+		SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+	}
+
+	SecurityScheme struct {
+		Type   string `json:"type"`
+		Scheme string `json:"scheme,omitempty"`
+		In     string `json:"in,omitempty"`
+		Name   string `json:"name,omitempty"`
+	}
+
+	// SecurityRequired maps names of security schemes to scopes
+	SecurityRequired map[string][]string
+)
+
+func NewDocument(info *Info) *Document {
+	return &Document{
+		OpenApi: VERSION,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: &Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+	}
+}
+
+// Add describes an operation of a route given in the gin syntax, e.g. /user/:id
+func (doc *Document) Add(method, route string, operation *Operation) *Document {
+	url := ToPath(route)
+	item, ok := doc.Paths[url]
+
+	if !ok {
+		item = make(PathItem)
+		doc.Paths[url] = item
+	}
+
+	item[strings.ToLower(method)] = operation
+
+	return doc
+}
+
+// Routes returns sorted "METHOD /path" pairs of all described operations
+func (doc *Document) Routes() []string {
+	routes := make([]string, 0, len(doc.Paths)*2)
+
+	for url, item := range doc.Paths {
+		for method := range item {
+			routes = append(routes, strings.ToUpper(method)+" "+url)
+		}
+	}
+
+	sort.Strings(routes)
+
+	return routes
+}
+
+// ToPath replaces gin parameters like :id by OpenAPI ones like {id}
+func ToPath(route string) string {
+	segments := strings.Split(route, "/")
+
+	for idx, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[idx] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// Ref points to a schema in components
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// MapOf describes an object with arbitrary keys
+func MapOf(values *Schema) *Schema {
+	return &Schema{Type: "object", AdditionalProperties: values}
+}
+
+func String(description string) *Schema {
+	return &Schema{Type: "string", Description: description}
+}
+
+func Enum(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
+
+func Integer(description string) *Schema {
+	return &Schema{Type: "integer", Format: "int64", Description: description}
+}
+
+func Number(description string) *Schema {
+	return &Schema{Type: "number", Format: "double", Description: description}
+}
+
+func Boolean(description string) *Schema {
+	return &Schema{Type: "boolean", Description: description}
+}
+
+func DateTime(description string) *Schema {
+	return &Schema{Type: "string", Format: "date-time", Description: description}
+}
+
+// JSON describes a content of a request or a response
+func JSON(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{
+		"application/json": {Schema: schema},
+	}
+}
+
+// Text describes a plain text content
+func Text(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{
+		"text/plain": {Schema: schema},
+	}
+}
+
+// Body describes a required json body
+func Body(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: JSON(schema)}
+}
+
+func PathParam(name, description string) *Parameter {
+	return &Parameter{Name: name, In: "path", Description: description, Required: true, Schema: Integer("")}
+}
+
+func QueryParam(name, description string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
@@ -0,0 +1,914 @@
+package openapi
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/blent/beagle/server/storage"
+)
+
+const (
+	TAG_PERIPHERALS = "peripherals"
+	TAG_GROUPS      = "groups"
+	TAG_ENDPOINTS   = "endpoints"
+	TAG_SUBSCRIBERS = "subscribers"
+	TAG_RULES       = "rules"
+	TAG_MONITORING  = "monitoring"
+	TAG_EVENTS      = "events"
+	TAG_AUTH        = "auth"
+	TAG_USERS       = "users"
+	TAG_META        = "meta"
+)
+
+// NewSpec describes all routes of the api mounted at apiRoute.
+// The metrics route is described only if metricsRoute is not empty.
+func NewSpec(version, apiRoute, metricsRoute string) *Document {
+	doc := NewDocument(&Info{
+		Title:       "Beagle",
+		Description: "REST API of beagle. Auth and users routes exist only when authentication is enabled.",
+		Version:     version,
+	})
+
+	api := path.Join("/", apiRoute)
+
+	addSchemas(doc)
+	addSecurity(doc)
+	addRegistry(doc, path.Join(api, "registry"))
+	addMonitoring(doc, path.Join(api, "monitoring"))
+	addEvents(doc, path.Join(api, "events"))
+	addAuth(doc, path.Join(api, "auth"))
+	addUsers(doc, api)
+
+	doc.Add(http.MethodGet, SpecRoute(apiRoute), &Operation{
+		OperationId: "getSpec",
+		Summary:     "Returns this document",
+		Tags:        []string{TAG_META},
+		Responses:   responses(ok(JSON(Object(nil)))),
+		Security:    Public,
+	})
+
+	if metricsRoute != "" {
+		doc.Add(http.MethodGet, path.Join("/", metricsRoute), &Operation{
+			OperationId: "getMetrics",
+			Summary:     "Returns metrics in the Prometheus text format",
+			Tags:        []string{TAG_META},
+			Responses:   responses(ok(Text(String(""))), failed(http.StatusUnauthorized)),
+		})
+	}
+
+	return doc
+}
+
+// SpecRoute returns a route the document is served at
+func SpecRoute(apiRoute string) string {
+	return path.Join("/", apiRoute, "openapi.json")
+}
+
+func addSchemas(doc *Document) {
+	schemas := doc.Components.Schemas
+
+	schemas["Error"] = Object(map[string]*Schema{
+		"code":    String("Snake case status text, e.g. not_found"),
+		"message": String(""),
+		"fields": ArrayOf(Object(map[string]*Schema{
+			"field":   String("A path of an invalid field, e.g. subscribers[0].endpoint"),
+			"message": String(""),
+		})),
+	}, "code", "message")
+
+	schemas["Ids"] = ArrayOf(Integer(""))
+
+	schemas["Policy"] = Object(map[string]*Schema{
+		"rateLimit":        Number("Max deliveries per second"),
+		"burst":            Integer("Max deliveries above the rate limit allowed at once"),
+		"maxInFlight":      Integer("Max deliveries being sent at the same time"),
+		"timeout":          Integer("Request timeout in milliseconds"),
+		"breakerThreshold": Integer("Consecutive failures after which the circuit breaker opens"),
+		"breakerCooldown":  Integer("Time in milliseconds after which an open circuit breaker lets a probe delivery through"),
+	})
+
+	schemas["Endpoint"] = Object(map[string]*Schema{
+		"id":      Integer(""),
+		"name":    String(""),
+		"kind":    Enum("http", "command"),
+		"url":     String("An http url or an absolute path of a command"),
+		"method":  String(""),
+		"headers": MapOf(String("")),
+		"policy":  Ref("Policy"),
+	}, "name", "url")
+
+	schemas["Batch"] = Object(map[string]*Schema{
+		"window":   Integer("Max time in milliseconds an event waits in a batch, an hour at most, required when size is greater than 1"),
+		"size":     Integer("Max number of events in a batch, from 0 to 10000"),
+		"coalesce": Enum("none", "drop", "blip"),
+	})
+
+	schemas["Subscriber"] = Object(map[string]*Schema{
+		"id":       Integer(""),
+		"name":     String(""),
+		"event":    Enum("found", "lost"),
+		"enabled":  Boolean(""),
+		"endpoint": Object(map[string]*Schema{"id": Integer("")}, "id"),
+		"batch":    Ref("Batch"),
+		"mode":     Enum("member", "presence"),
+		"targetId": Integer("An id of an owning peripheral"),
+		"groupId":  Integer("An id of an owning group"),
+		"ruleId":   Integer("An id of an owning rule, which copies the subscriber to registered peripherals"),
+	}, "name", "event", "endpoint")
+
+	schemas["Peripheral"] = Object(map[string]*Schema{
+		"id":      Integer(""),
+		"key":     String(""),
+		"name":    String(""),
+		"kind":    String(""),
+		"enabled": Boolean(""),
+		"pattern": Boolean("True if the registration matches many peripherals"),
+	})
+
+	schemas["PeripheralDetails"] = Object(map[string]*Schema{
+		"id":          Integer(""),
+		"kind":        Enum("ibeacon"),
+		"name":        String(""),
+		"enabled":     Boolean(""),
+		"pattern":     Boolean("Must be true to omit numbers, to use ranges or an address prefix"),
+		"uuid":        String(""),
+		"major":       Integer(""),
+		"majorMax":    Integer("Turns major into an inclusive range"),
+		"minor":       Integer(""),
+		"minorMax":    Integer("Turns minor into an inclusive range"),
+		"address":     String("A prefix of addresses of matched peripherals"),
+		"subscribers": ArrayOf(Ref("Subscriber")),
+	}, "kind", "name")
+
+	schemas["ImportedPeripheral"] = Object(map[string]*Schema{
+		"kind":     Enum("ibeacon"),
+		"name":     String(""),
+		"enabled":  Boolean("True if omitted"),
+		"uuid":     String(""),
+		"major":    Integer(""),
+		"majorMax": Integer("Turns major into an inclusive range"),
+		"minor":    Integer(""),
+		"minorMax": Integer("Turns minor into an inclusive range"),
+		"address":  String("A prefix of addresses of matched peripherals"),
+		"subscribers": ArrayOf(Object(map[string]*Schema{
+			"name":     String(""),
+			"event":    Enum("found", "lost"),
+			"endpoint": String("A name of an endpoint"),
+			"enabled":  Boolean("True if omitted"),
+			"batch":    Ref("Batch"),
+		}, "event", "endpoint")),
+	}, "name")
+
+	schemas["Group"] = Object(map[string]*Schema{
+		"id":      Integer(""),
+		"name":    String(""),
+		"enabled": Boolean(""),
+		"uuid":    String("Includes all iBeacons with the uuid"),
+		"major":   Integer("Narrows the uuid down to a major"),
+	})
+
+	schemas["GroupDetails"] = Object(map[string]*Schema{
+		"id":          Integer(""),
+		"name":        String(""),
+		"enabled":     Boolean(""),
+		"uuid":        String(""),
+		"major":       Integer(""),
+		"members":     ArrayOf(Integer("")),
+		"subscribers": ArrayOf(Ref("Subscriber")),
+	}, "name")
+
+	schemas["Rule"] = Object(map[string]*Schema{
+		"id":                Integer(""),
+		"name":              String(""),
+		"enabled":           Boolean(""),
+		"key":               String("A key of a pattern matching unregistered peripherals"),
+		"nameTemplate":      String(""),
+		"mode":              Enum("accept", "review"),
+		"peripheralEnabled": Boolean(""),
+	})
+
+	schemas["RuleDetails"] = Object(map[string]*Schema{
+		"id":      Integer(""),
+		"name":    String(""),
+		"enabled": Boolean(""),
+		"nameTemplate": String("A name of registered peripherals, " +
+			"{kind}, {key}, {address}, {uuid}, {major} and {minor} are replaced by values of a peripheral"),
+		"mode":              Enum("accept", "review"),
+		"peripheralEnabled": Boolean("A status of registered peripherals"),
+		"uuid":              String(""),
+		"major":             Integer(""),
+		"majorMax":          Integer("Turns major into an inclusive range"),
+		"minor":             Integer(""),
+		"minorMax":          Integer("Turns minor into an inclusive range"),
+		"address":           String("A prefix of addresses of matched peripherals"),
+		"subscribers":       ArrayOf(Ref("Subscriber")),
+	}, "name", "nameTemplate", "mode")
+
+	schemas["Candidate"] = Object(map[string]*Schema{
+		"id":        Integer(""),
+		"ruleId":    Integer(""),
+		"key":       String(""),
+		"kind":      String(""),
+		"address":   String(""),
+		"name":      String("A rendered name of the peripheral"),
+		"status":    Enum("pending", "registered", "rejected", "failed"),
+		"targetId":  Integer("An id of a registered peripheral"),
+		"message":   String("A reason of a failed registration"),
+		"reviewer":  String(""),
+		"createdAt": DateTime(""),
+		"decidedAt": DateTime(""),
+	})
+
+	schemas["TestResult"] = Object(map[string]*Schema{
+		"request": Object(map[string]*Schema{
+			"method":  String(""),
+			"url":     String(""),
+			"headers": MapOf(String("")),
+			"body":    String(""),
+		}),
+		"sent":    Boolean(""),
+		"status":  Integer(""),
+		"latency": Number("Milliseconds"),
+		"error":   String(""),
+	})
+
+	schemas["User"] = Object(map[string]*Schema{
+		"id":        Integer(""),
+		"username":  String(""),
+		"role":      Enum("admin", "viewer"),
+		"enabled":   Boolean(""),
+		"createdAt": DateTime(""),
+	})
+
+	schemas["UserInput"] = Object(map[string]*Schema{
+		"id":       Integer("Required by updates"),
+		"username": String(""),
+		"password": String("An empty password keeps the current one on updates"),
+		"role":     Enum("admin", "viewer"),
+		"enabled":  Boolean("True by default"),
+	}, "username", "role")
+
+	schemas["ApiKey"] = Object(map[string]*Schema{
+		"id":        Integer(""),
+		"name":      String(""),
+		"prefix":    String(""),
+		"userId":    Integer(""),
+		"createdAt": DateTime(""),
+	})
+
+	schemas["ActivityRecord"] = Object(map[string]*Schema{
+		"key":        String(""),
+		"kind":       String(""),
+		"proximity":  String(""),
+		"registered": Boolean(""),
+		"time":       DateTime(""),
+	})
+
+	schemas["SystemStats"] = Object(map[string]*Schema{
+		"os":       String(""),
+		"kernel":   String(""),
+		"platform": String(""),
+		"hostname": String(""),
+		"arch":     String(""),
+		"cpu":      ArrayOf(Number("")),
+		"memory": Object(map[string]*Schema{
+			"total":       Integer(""),
+			"available":   Integer(""),
+			"used":        Integer(""),
+			"usedPercent": Number(""),
+		}),
+		"storage": ArrayOf(Object(map[string]*Schema{
+			"total":       Integer(""),
+			"available":   Integer(""),
+			"used":        Integer(""),
+			"usedPercent": Number(""),
+			"path":        String(""),
+			"fstype":      String(""),
+		})),
+	})
+
+	schemas["GateState"] = Object(map[string]*Schema{
+		"endpointId": Integer(""),
+		"endpoint":   String(""),
+		"state":      Enum("closed", "open", "half-open"),
+		"failures":   Integer(""),
+		"inFlight":   Integer(""),
+		"openedAt":   DateTime(""),
+	})
+
+	schemas["ListenerStats"] = Object(map[string]*Schema{
+		"listeners": Integer(""),
+		"published": Integer(""),
+		"dropped":   Integer(""),
+	})
+
+	schemas["StartupStep"] = Object(map[string]*Schema{
+		"name":      String(""),
+		"state":     Enum("pending", "running", "done", "failed", "skipped", "stopped"),
+		"optional":  Boolean("A failed optional step does not stop the startup, but its dependents are skipped"),
+		"dependsOn": ArrayOf(String("")),
+		"startedAt": DateTime(""),
+		"duration":  Number("Milliseconds"),
+		"error":     String(""),
+	})
+
+	schemas["CacheStats"] = Object(map[string]*Schema{
+		"targets":       Integer(""),
+		"subscribers":   Integer(""),
+		"groups":        Integer(""),
+		"hits":          Integer(""),
+		"misses":        Integer(""),
+		"invalidations": Integer(""),
+	})
+}
+
+func addSecurity(doc *Document) {
+	doc.Components.SecuritySchemes["bearer"] = &SecurityScheme{Type: "http", Scheme: "bearer"}
+	doc.Components.SecuritySchemes["apiKey"] = &SecurityScheme{Type: "apiKey", In: "header", Name: "X-Api-Key"}
+	doc.Components.SecuritySchemes["session"] = &SecurityScheme{Type: "apiKey", In: "cookie", Name: "beagle_session"}
+
+	doc.Security = []SecurityRequired{
+		{"bearer": {}},
+		{"apiKey": {}},
+		{"session": {}},
+	}
+}
+
+func addRegistry(doc *Document, base string) {
+	listParams := []*Parameter{
+		QueryParam("take", "Page size", Integer("")),
+		QueryParam("skip", "Number of skipped items", Integer("")),
+		QueryParam("order", "", Enum("asc", "desc")),
+		QueryParam("cursor", "A cursor of the next page returned by a previous page", String("")),
+	}
+
+	withList := func(fields []string, params ...*Parameter) []*Parameter {
+		params = append(params, listParams...)
+
+		return append(params, QueryParam("sort", "", Enum(fields...)))
+	}
+
+	addEntity(doc, base, entity{
+		singular: "peripheral",
+		plural:   "peripherals",
+		tag:      TAG_PERIPHERALS,
+		item:     "Peripheral",
+		details:  "PeripheralDetails",
+		params: withList(
+			storage.PeripheralSortFields,
+			QueryParam("search", "A part of a name or a key", String("")),
+			QueryParam("kind", "", String("")),
+			QueryParam("enabled", "", Boolean("")),
+			QueryParam("uuid", "", String("")),
+			QueryParam("major", "", Integer("")),
+			QueryParam("event", "Has subscribers of the event", String("")),
+		),
+		paged: true,
+	})
+
+	addEntity(doc, base, entity{
+		singular: "group",
+		plural:   "groups",
+		tag:      TAG_GROUPS,
+		item:     "Group",
+		details:  "GroupDetails",
+		params: []*Parameter{
+			QueryParam("take", "Page size", Integer("")),
+			QueryParam("skip", "Number of skipped items", Integer("")),
+		},
+	})
+
+	addEntity(doc, base, entity{
+		singular: "endpoint",
+		plural:   "endpoints",
+		tag:      TAG_ENDPOINTS,
+		item:     "Endpoint",
+		details:  "Endpoint",
+		params: withList(
+			storage.EndpointSortFields,
+			QueryParam("name", "* is a wildcard", String("")),
+			QueryParam("search", "A part of a name or an url", String("")),
+			QueryParam("kind", "", Enum("http", "command")),
+		),
+		paged: true,
+	})
+
+	addEntity(doc, base, entity{
+		singular: "subscriber",
+		plural:   "subscribers",
+		tag:      TAG_SUBSCRIBERS,
+		item:     "Subscriber",
+		details:  "Subscriber",
+		params: withList(
+			storage.SubscriberSortFields,
+			QueryParam("search", "A part of a name", String("")),
+			QueryParam("event", "Can be repeated", String("")),
+			QueryParam("enabled", "", Boolean("")),
+			QueryParam("peripheral", "An id of an owning peripheral", Integer("")),
+			QueryParam("group", "An id of an owning group", Integer("")),
+			QueryParam("endpoint", "", Integer("")),
+			QueryParam("rule", "An id of an owning rule", Integer("")),
+		),
+		paged: true,
+	})
+
+	addEntity(doc, base, entity{
+		singular: "rule",
+		plural:   "rules",
+		tag:      TAG_RULES,
+		item:     "Rule",
+		details:  "RuleDetails",
+		params: []*Parameter{
+			QueryParam("take", "Page size", Integer("")),
+			QueryParam("skip", "Number of skipped items", Integer("")),
+			QueryParam("enabled", "", Boolean("")),
+		},
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, "claim"), &Operation{
+		OperationId: "claimPeripheral",
+		Summary:     "Waits for an unregistered iBeacon brought close to the gateway",
+		Description: "Responds after the whole pairing window with the unregistered iBeacon which had the strongest signal " +
+			"at immediate proximity. Its peripheral can be passed to createPeripheral as is. Only one pairing runs at a time.",
+		Tags: []string{TAG_PERIPHERALS},
+		RequestBody: &RequestBody{
+			Content: JSON(Object(map[string]*Schema{
+				"seconds": Integer("A length of the pairing window, 10 by default and 60 at most"),
+				"name":    String("A name of the claimed peripheral"),
+			})),
+		},
+		Responses: responses(
+			ok(JSON(Object(map[string]*Schema{
+				"peripheral": Ref("PeripheralDetails"),
+				"address":    String(""),
+				"rssi":       Number(""),
+				"accuracy":   Number("Estimated distance in meters"),
+			}, "peripheral"))),
+			failed(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity),
+		),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "candidates"), &Operation{
+		OperationId: "findCandidates",
+		Summary:     "Returns peripherals matched by rules, newest first",
+		Description: "Pending candidates of rules in the review mode wait for an approval, " +
+			"decided ones are kept as an audit trail.",
+		Tags: []string{TAG_RULES},
+		Parameters: []*Parameter{
+			QueryParam("take", "Page size", Integer("")),
+			QueryParam("skip", "Number of skipped items", Integer("")),
+			QueryParam("status", "", Enum("pending", "registered", "rejected", "failed")),
+			QueryParam("rule", "An id of a rule", Integer("")),
+		},
+		Responses: responses(
+			ok(JSON(Object(map[string]*Schema{
+				"items":    ArrayOf(Ref("Candidate")),
+				"quantity": Integer("Total quantity of matched items"),
+			}, "items", "quantity"))),
+			failed(http.StatusBadRequest),
+		),
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, "candidate", ":id", "approve"), &Operation{
+		OperationId: "approveCandidate",
+		Summary:     "Registers a pending or failed candidate",
+		Tags:        []string{TAG_RULES},
+		Parameters:  []*Parameter{PathParam("id", "")},
+		RequestBody: &RequestBody{
+			Content: JSON(Object(map[string]*Schema{
+				"name": String("Replaces the rendered name"),
+			})),
+		},
+		Responses: responses(
+			ok(JSON(Ref("Candidate"))),
+			failed(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+		),
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, "candidate", ":id", "reject"), &Operation{
+		OperationId: "rejectCandidate",
+		Summary:     "Rejects a pending or failed candidate",
+		Tags:        []string{TAG_RULES},
+		Parameters:  []*Parameter{PathParam("id", "")},
+		Responses: responses(
+			ok(JSON(Ref("Candidate"))),
+			failed(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+		),
+	})
+
+	doc.Add(http.MethodDelete, path.Join(base, "candidate", ":id"), &Operation{
+		OperationId: "deleteCandidate",
+		Summary:     "Deletes a candidate, so that rules match its peripheral again",
+		Tags:        []string{TAG_RULES},
+		Parameters:  []*Parameter{PathParam("id", "")},
+		Responses:   responses(ok(nil), failed(http.StatusBadRequest, http.StatusNotFound)),
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, "peripherals", "import"), &Operation{
+		OperationId: "importPeripherals",
+		Summary:     "Creates many peripherals with their subscribers, either all of them or none",
+		Description: "A csv needs a header with columns of ImportedPeripheral, subscribers are given " +
+			"by a json array or by event:endpoint pairs separated by ';'. Errors of fields refer to rows[index], " +
+			"which counts rows after the header.",
+		Tags:       []string{TAG_PERIPHERALS},
+		Parameters: []*Parameter{QueryParam("dryRun", "Only validates rows", Boolean(""))},
+		RequestBody: &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				"application/json": {Schema: ArrayOf(Ref("ImportedPeripheral"))},
+				"text/csv":         {Schema: String("")},
+			},
+		},
+		Responses: responses(
+			ok(JSON(Object(map[string]*Schema{
+				"quantity": Integer("A number of imported peripherals"),
+				"ids":      &Schema{Type: "array", Items: Integer(""), Nullable: true, Description: "Omitted by dry runs"},
+			}, "quantity"))),
+			failed(http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity),
+		),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "peripherals", "export"), &Operation{
+		OperationId: "exportPeripherals",
+		Summary:     "Returns all peripherals as a csv, which can be imported back",
+		Tags:        []string{TAG_PERIPHERALS},
+		Responses: responses(
+			ok(map[string]*MediaType{"text/csv": {Schema: String("")}}),
+			failed(),
+		),
+	})
+
+	testParams := []*Parameter{
+		QueryParam("event", "", Enum("found", "lost")),
+		QueryParam("dryRun", "Only renders a request", Boolean("")),
+	}
+
+	doc.Add(http.MethodGet, path.Join(base, "endpoint", ":id", "subscribers"), &Operation{
+		OperationId: "getEndpointSubscribers",
+		Summary:     "Returns all subscribers pointing at an endpoint, they are deleted along with it",
+		Tags:        []string{TAG_ENDPOINTS},
+		Parameters:  []*Parameter{PathParam("id", "")},
+		Responses:   responses(ok(JSON(ArrayOf(Ref("Subscriber")))), failed(http.StatusBadRequest, http.StatusNotFound)),
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, "endpoint", ":id", "test"), &Operation{
+		OperationId: "testEndpoint",
+		Summary:     "Sends a synthetic event to an endpoint",
+		Tags:        []string{TAG_ENDPOINTS},
+		Parameters:  append([]*Parameter{PathParam("id", "")}, testParams...),
+		Responses:   responses(ok(JSON(Ref("TestResult"))), failed(http.StatusBadRequest, http.StatusNotFound)),
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, "endpoints", "test"), &Operation{
+		OperationId: "testUnsavedEndpoint",
+		Summary:     "Sends a synthetic event to an unsaved endpoint",
+		Tags:        []string{TAG_ENDPOINTS},
+		Parameters:  testParams,
+		RequestBody: Body(Ref("Endpoint")),
+		Responses:   responses(ok(JSON(Ref("TestResult"))), failed(http.StatusBadRequest, http.StatusUnprocessableEntity)),
+	})
+
+	for _, status := range []string{"enable", "disable"} {
+		doc.Add(http.MethodPost, path.Join(base, "subscribers", status), &Operation{
+			OperationId: status + "Subscribers",
+			Summary:     "Changes a status of many subscribers by ids",
+			Tags:        []string{TAG_SUBSCRIBERS},
+			RequestBody: Body(Ref("Ids")),
+			Responses:   responses(ok(nil), failed(http.StatusBadRequest, http.StatusNotFound)),
+		})
+	}
+}
+
+type entity struct {
+	singular string
+	plural   string
+	tag      string
+	item     string
+	details  string
+	params   []*Parameter
+	paged    bool
+}
+
+// addEntity describes the common crud routes of a registry entity
+func addEntity(doc *Document, base string, e entity) {
+	id := []*Parameter{PathParam("id", "")}
+	name := strings.Title(e.singular)
+	names := strings.Title(e.plural)
+
+	page := Object(map[string]*Schema{
+		"items":    ArrayOf(Ref(e.item)),
+		"quantity": Integer("Total quantity of matched items"),
+	}, "items", "quantity")
+
+	if e.paged {
+		page.Properties["next"] = &Schema{Type: "string", Description: "A cursor of the next page", Nullable: true}
+	}
+
+	doc.Add(http.MethodGet, path.Join(base, e.plural), &Operation{
+		OperationId: "find" + names,
+		Summary:     "Returns a list of " + e.plural,
+		Tags:        []string{e.tag},
+		Parameters:  e.params,
+		Responses:   responses(ok(JSON(page)), failed(http.StatusBadRequest)),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, e.singular, ":id"), &Operation{
+		OperationId: "get" + name,
+		Summary:     "Returns a " + e.singular + " by id",
+		Tags:        []string{e.tag},
+		Parameters:  id,
+		Responses:   responses(ok(JSON(Ref(e.details))), failed(http.StatusBadRequest, http.StatusNotFound)),
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, e.singular), &Operation{
+		OperationId: "create" + name,
+		Summary:     "Creates a new " + e.singular,
+		Tags:        []string{e.tag},
+		RequestBody: Body(Ref(e.details)),
+		Responses: responses(
+			created(),
+			failed(http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity),
+		),
+	})
+
+	doc.Add(http.MethodPut, path.Join(base, e.singular), &Operation{
+		OperationId: "update" + name,
+		Summary:     "Updates a " + e.singular + " by an id given in the body",
+		Tags:        []string{e.tag},
+		RequestBody: Body(Ref(e.details)),
+		Responses: responses(
+			ok(nil),
+			failed(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity),
+		),
+	})
+
+	doc.Add(http.MethodDelete, path.Join(base, e.singular, ":id"), &Operation{
+		OperationId: "delete" + name,
+		Summary:     "Deletes a " + e.singular + " by id",
+		Tags:        []string{e.tag},
+		Parameters:  id,
+		Responses:   responses(ok(nil), failed(http.StatusBadRequest)),
+	})
+
+	doc.Add(http.MethodDelete, path.Join(base, e.plural), &Operation{
+		OperationId: "delete" + names,
+		Summary:     "Deletes many " + e.plural + " by ids",
+		Tags:        []string{e.tag},
+		RequestBody: Body(Ref("Ids")),
+		Responses:   responses(ok(nil), failed(http.StatusBadRequest)),
+	})
+}
+
+func addMonitoring(doc *Document, base string) {
+	list := func(item *Schema) *Schema {
+		return Object(map[string]*Schema{
+			"items":    ArrayOf(item),
+			"quantity": Integer(""),
+		}, "items", "quantity")
+	}
+
+	doc.Add(http.MethodGet, path.Join(base, "activity"), &Operation{
+		OperationId: "getActivity",
+		Summary:     "Returns active peripherals, registered and not registered",
+		Tags:        []string{TAG_MONITORING},
+		Parameters: []*Parameter{
+			QueryParam("take", "", Integer("")),
+			QueryParam("skip", "", Integer("")),
+		},
+		Responses: responses(ok(JSON(list(Ref("ActivityRecord")))), failed(http.StatusBadRequest)),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "system"), &Operation{
+		OperationId: "getSystemStats",
+		Summary:     "Returns stats of the host",
+		Tags:        []string{TAG_MONITORING},
+		Responses:   responses(ok(JSON(Ref("SystemStats")))),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "delivery"), &Operation{
+		OperationId: "getDeliveryStates",
+		Summary:     "Returns delivery states of endpoints",
+		Tags:        []string{TAG_MONITORING},
+		Responses:   responses(ok(JSON(list(Ref("GateState"))))),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "listeners"), &Operation{
+		OperationId: "getListenerStats",
+		Summary:     "Returns stats of internal event listeners by their source",
+		Tags:        []string{TAG_MONITORING},
+		Responses:   responses(ok(JSON(MapOf(Ref("ListenerStats"))))),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "registry"), &Operation{
+		OperationId: "getRegistryStats",
+		Summary:     "Returns stats of the registry lookup cache",
+		Tags:        []string{TAG_MONITORING},
+		Responses:   responses(ok(JSON(Ref("CacheStats")))),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "startup"), &Operation{
+		OperationId: "getStartupStatus",
+		Summary:     "Returns initialization steps in the order they run",
+		Tags:        []string{TAG_MONITORING},
+		Responses:   responses(ok(JSON(list(Ref("StartupStep"))))),
+	})
+}
+
+func addEvents(doc *Document, base string) {
+	params := []*Parameter{
+		QueryParam("source", "Can be repeated", String("")),
+		QueryParam("name", "Can be repeated", String("")),
+		QueryParam("key", "Can be repeated", String("")),
+		QueryParam("kind", "Can be repeated", String("")),
+		QueryParam("registered", "", Boolean("")),
+		QueryParam("replay", "Number of replayed events, the whole history by default", Integer("")),
+	}
+
+	doc.Add(http.MethodGet, path.Join(base, "stream"), &Operation{
+		OperationId: "streamEvents",
+		Summary:     "Streams events as Server-Sent Events",
+		Tags:        []string{TAG_EVENTS},
+		Parameters:  params,
+		Responses: responses(
+			ok(map[string]*MediaType{"text/event-stream": {Schema: String("")}}),
+			failed(http.StatusBadRequest),
+		),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "ws"), &Operation{
+		OperationId: "socketEvents",
+		Summary:     "Streams events over a WebSocket",
+		Tags:        []string{TAG_EVENTS},
+		Parameters:  params,
+		Responses: responses(
+			response(http.StatusSwitchingProtocols, nil),
+			failed(http.StatusBadRequest),
+		),
+	})
+}
+
+func addAuth(doc *Document, base string) {
+	doc.Add(http.MethodPost, path.Join(base, "login"), &Operation{
+		OperationId: "login",
+		Summary:     "Starts a new session",
+		Tags:        []string{TAG_AUTH},
+		RequestBody: Body(Object(map[string]*Schema{
+			"username": String(""),
+			"password": String(""),
+		}, "username", "password")),
+		Responses: responses(
+			ok(JSON(Object(map[string]*Schema{
+				"token":     String(""),
+				"expiresAt": DateTime(""),
+				"user":      Ref("User"),
+			}))),
+			failed(http.StatusBadRequest, http.StatusUnauthorized),
+		),
+		Security: Public,
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, "logout"), &Operation{
+		OperationId: "logout",
+		Summary:     "Ends the current session",
+		Tags:        []string{TAG_AUTH},
+		Responses:   responses(ok(nil), failed(http.StatusBadRequest, http.StatusUnauthorized)),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "me"), &Operation{
+		OperationId: "me",
+		Summary:     "Returns the current user",
+		Tags:        []string{TAG_AUTH},
+		Responses:   responses(ok(JSON(Ref("User"))), failed(http.StatusUnauthorized)),
+	})
+}
+
+func addUsers(doc *Document, base string) {
+	id := []*Parameter{PathParam("id", "")}
+	denied := []int{http.StatusUnauthorized, http.StatusForbidden}
+
+	doc.Add(http.MethodGet, path.Join(base, "users"), &Operation{
+		OperationId: "findUsers",
+		Summary:     "Returns a list of users",
+		Tags:        []string{TAG_USERS},
+		Parameters: []*Parameter{
+			QueryParam("take", "", Integer("")),
+			QueryParam("skip", "", Integer("")),
+		},
+		Responses: responses(
+			ok(JSON(Object(map[string]*Schema{
+				"items":    ArrayOf(Ref("User")),
+				"quantity": Integer(""),
+			}, "items", "quantity"))),
+			failed(append(denied, http.StatusBadRequest)...),
+		),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "user", ":id"), &Operation{
+		OperationId: "getUser",
+		Summary:     "Returns a user by id",
+		Tags:        []string{TAG_USERS},
+		Parameters:  id,
+		Responses:   responses(ok(JSON(Ref("User"))), failed(append(denied, http.StatusNotFound)...)),
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, "user"), &Operation{
+		OperationId: "createUser",
+		Summary:     "Creates a new user",
+		Tags:        []string{TAG_USERS},
+		RequestBody: Body(Ref("UserInput")),
+		Responses: responses(
+			created(),
+			failed(append(denied, http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity)...),
+		),
+	})
+
+	doc.Add(http.MethodPut, path.Join(base, "user"), &Operation{
+		OperationId: "updateUser",
+		Summary:     "Updates a user by an id given in the body",
+		Tags:        []string{TAG_USERS},
+		RequestBody: Body(Ref("UserInput")),
+		Responses: responses(
+			ok(nil),
+			failed(append(denied, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity)...),
+		),
+	})
+
+	doc.Add(http.MethodDelete, path.Join(base, "user", ":id"), &Operation{
+		OperationId: "deleteUser",
+		Summary:     "Deletes a user by id",
+		Tags:        []string{TAG_USERS},
+		Parameters:  id,
+		Responses:   responses(ok(nil), failed(append(denied, http.StatusBadRequest)...)),
+	})
+
+	doc.Add(http.MethodGet, path.Join(base, "user", ":id", "keys"), &Operation{
+		OperationId: "findApiKeys",
+		Summary:     "Returns api keys of a user",
+		Tags:        []string{TAG_USERS},
+		Parameters:  id,
+		Responses:   responses(ok(JSON(ArrayOf(Ref("ApiKey")))), failed(append(denied, http.StatusNotFound)...)),
+	})
+
+	doc.Add(http.MethodPost, path.Join(base, "user", ":id", "key"), &Operation{
+		OperationId: "createApiKey",
+		Summary:     "Creates a new api key of a user, the token is returned only once",
+		Tags:        []string{TAG_USERS},
+		Parameters:  id,
+		RequestBody: Body(Object(map[string]*Schema{"name": String("")}, "name")),
+		Responses: responses(
+			ok(JSON(Object(map[string]*Schema{
+				"token": String(""),
+				"key":   Ref("ApiKey"),
+			}))),
+			failed(append(denied, http.StatusNotFound, http.StatusUnprocessableEntity)...),
+		),
+	})
+
+	doc.Add(http.MethodDelete, path.Join(base, "user", ":id", "key", ":key"), &Operation{
+		OperationId: "deleteApiKey",
+		Summary:     "Deletes an api key of a user",
+		Tags:        []string{TAG_USERS},
+		Parameters:  []*Parameter{PathParam("id", ""), PathParam("key", "")},
+		Responses:   responses(ok(nil), failed(append(denied, http.StatusNotFound)...)),
+	})
+}
+
+func responses(groups ...map[string]*Response) map[string]*Response {
+	res := make(map[string]*Response)
+
+	for _, group := range groups {
+		for status, response := range group {
+			res[status] = response
+		}
+	}
+
+	return res
+}
+
+func response(status int, content map[string]*MediaType) map[string]*Response {
+	return map[string]*Response{
+		strconv.Itoa(status): {Description: http.StatusText(status), Content: content},
+	}
+}
+
+func ok(content map[string]*MediaType) map[string]*Response {
+	return response(http.StatusOK, content)
+}
+
+// created describes a response with an id of a new entity
+func created() map[string]*Response {
+	return ok(Text(Integer("An id of a new entity")))
+}
+
+// failed describes error responses, internal errors are possible everywhere
+func failed(statuses ...int) map[string]*Response {
+	res := response(http.StatusInternalServerError, JSON(Ref("Error")))
+
+	for _, status := range statuses {
+		res[strconv.Itoa(status)] = response(status, JSON(Ref("Error")))[strconv.Itoa(status)]
+	}
+
+	return res
+}
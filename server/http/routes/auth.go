@@ -0,0 +1,123 @@
+package routes
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/blent/beagle/pkg/auth"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type (
+	SessionService interface {
+		Login(username, password string) (string, *auth.Session, *auth.User, error)
+		Logout(token string) error
+	}
+
+	AuthRoute struct {
+		baseUrl string
+		logger  *zap.Logger
+		service SessionService
+	}
+
+	credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+)
+
+func NewAuthRoute(baseUrl string, logger *zap.Logger, service SessionService) *AuthRoute {
+	return &AuthRoute{baseUrl, logger, service}
+}
+
+func (rt *AuthRoute) Use(routes gin.IRoutes) {
+	// Start a new session
+	routes.POST(path.Join("/", rt.baseUrl, "login"), rt.login)
+
+	// End the current session
+	routes.POST(path.Join("/", rt.baseUrl, "logout"), rt.logout)
+
+	// Get the current user
+	routes.GET(path.Join("/", rt.baseUrl, "me"), rt.me)
+}
+
+func (rt *AuthRoute) login(ctx *gin.Context) {
+	var input credentials
+
+	if !serverHttp.BindJSON(ctx, &input) {
+		return
+	}
+
+	token, session, user, err := rt.service.Login(input.Username, input.Password)
+
+	if err != nil {
+		if err == auth.ErrInvalidCredentials {
+			rt.logger.Warn(
+				"Failed login attempt",
+				zap.String("username", input.Username),
+				zap.String("address", ctx.ClientIP()),
+			)
+			serverHttp.AbortWithError(ctx, http.StatusUnauthorized, err)
+			return
+		}
+
+		rt.logger.Error("Failed to log in", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     serverHttp.SESSION_COOKIE,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   ctx.Request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"token":     token,
+		"expiresAt": session.ExpiresAt,
+		"user":      user,
+	})
+}
+
+func (rt *AuthRoute) logout(ctx *gin.Context) {
+	token := serverHttp.GetToken(ctx.Request)
+
+	if token == "" {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed token"))
+		return
+	}
+
+	if err := rt.service.Logout(token); err != nil {
+		rt.logger.Error("Failed to log out", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     serverHttp.SESSION_COOKIE,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *AuthRoute) me(ctx *gin.Context) {
+	user := serverHttp.GetUser(ctx)
+
+	if user == nil {
+		serverHttp.AbortWithError(ctx, http.StatusUnauthorized, nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/monitoring/activity"
+	"github.com/blent/beagle/pkg/notification"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/validation"
+	"github.com/blent/beagle/server/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultClaimSeconds = 10
+	maxClaimSeconds     = 60
+)
+
+var ErrClaimRouteNotFound = errors.Wrap(storage.ErrNotFound, "no unregistered iBeacon at immediate proximity")
+
+type (
+	Claimer interface {
+		Claim(ctx context.Context, window time.Duration) (peripherals.Peripheral, error)
+	}
+
+	// ClaimRequest sets a length of a pairing window and a name of a claimed peripheral
+	ClaimRequest struct {
+		Seconds uint64 `json:"seconds"`
+		Name    string `json:"name"`
+	}
+
+	// ClaimDto contains a claimed peripheral ready to be registered and its last advertisement
+	ClaimDto struct {
+		Peripheral *Dto    `json:"peripheral"`
+		Address    string  `json:"address"`
+		Rssi       float64 `json:"rssi"`
+		Accuracy   float64 `json:"accuracy"`
+	}
+
+	ClaimRoute struct {
+		baseUrl string
+		logger  *zap.Logger
+		claimer Claimer
+	}
+)
+
+func NewClaimRoute(baseUrl string, logger *zap.Logger, claimer Claimer) *ClaimRoute {
+	return &ClaimRoute{baseUrl, logger, claimer}
+}
+
+func (rt *ClaimRoute) Use(routes gin.IRoutes) {
+	// Wait for an unregistered iBeacon brought close to the gateway
+	routes.POST(path.Join("/", rt.baseUrl, "claim"), rt.claim)
+}
+
+// claim responds after the whole pairing window, so that the strongest signal wins
+func (rt *ClaimRoute) claim(ctx *gin.Context) {
+	var req ClaimRequest
+
+	if ctx.Request.ContentLength != 0 && !serverHttp.BindJSON(ctx, &req) {
+		return
+	}
+
+	if req.Seconds == 0 {
+		req.Seconds = defaultClaimSeconds
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+
+	v := validation.New()
+
+	v.Check("seconds", req.Seconds <= maxClaimSeconds, "must not be greater than 60")
+
+	if err := v.Err(); err != nil {
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	claimed, err := rt.claimer.Claim(ctx.Request.Context(), time.Duration(req.Seconds)*time.Second)
+
+	if err == activity.ErrPairingInProgress {
+		serverHttp.AbortWithError(ctx, http.StatusConflict, err)
+		return
+	}
+
+	// the client is gone
+	if err == context.Canceled {
+		return
+	}
+
+	if err != nil {
+		rt.logger.Error("Failed to claim a peripheral", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	if claimed == nil {
+		serverHttp.AbortWithCause(ctx, ErrClaimRouteNotFound)
+		return
+	}
+
+	uuid, major, minor, err := peripherals.ParseIBeaconUniqueKey(claimed.UniqueKey())
+
+	if err != nil {
+		rt.logger.Error("Failed to parse a claimed peripheral", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &ClaimDto{
+		Peripheral: &Dto{
+			Kind:        claimed.Kind(),
+			Name:        req.Name,
+			Enabled:     true,
+			Uuid:        uuid,
+			Major:       major,
+			Minor:       minor,
+			Subscribers: []*notification.Subscriber{},
+		},
+		Address:  claimed.Address(),
+		Rssi:     claimed.RSSI(),
+		Accuracy: claimed.Accuracy(),
+	})
+}
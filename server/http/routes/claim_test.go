@@ -0,0 +1,81 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/monitoring/activity"
+	"github.com/blent/beagle/server/http/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type claimerFunc func(ctx context.Context, window time.Duration) (peripherals.Peripheral, error)
+
+func (fn claimerFunc) Claim(ctx context.Context, window time.Duration) (peripherals.Peripheral, error) {
+	return fn(ctx, window)
+}
+
+func TestClaimRoute(t *testing.T) {
+	uuid := "b9407f30f5f8466eaff925556b57fe6d"
+	var claimed peripherals.Peripheral
+	var claimErr error
+	var windows []time.Duration
+
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+
+	routes.NewClaimRoute("/api/registry", zap.NewNop(), claimerFunc(func(ctx context.Context, window time.Duration) (peripherals.Peripheral, error) {
+		windows = append(windows, window)
+
+		return claimed, claimErr
+	})).Use(engine)
+
+	res := call(engine, http.MethodPost, "/api/registry/claim", "", map[string]interface{}{"seconds": 61})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "too long window")
+	assert.Equal(t, []string{"seconds"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodPost, "/api/registry/claim", "", nil)
+	assert.Equal(t, http.StatusNotFound, res.Code, "nothing is claimed")
+
+	claimErr = activity.ErrPairingInProgress
+
+	res = call(engine, http.MethodPost, "/api/registry/claim", "", nil)
+	assert.Equal(t, http.StatusConflict, res.Code, "concurrent pairing")
+
+	claimed = peripherals.NewMockPeripheral(
+		peripherals.CreateIBeaconUniqueKey(uuid, 1, 7),
+		peripherals.PERIPHERAL_IBEACON,
+		"",
+		nil,
+		-59,
+		-40,
+		"aa:bb:cc:dd:ee:ff",
+	)
+	claimErr = nil
+
+	res = call(engine, http.MethodPost, "/api/registry/claim", "", map[string]interface{}{"seconds": 5, "name": " desk "})
+	assert.Equal(t, http.StatusOK, res.Code, "claimed")
+
+	var body routes.ClaimDto
+
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+
+	if assert.NotNil(t, body.Peripheral) {
+		assert.Equal(t, "desk", body.Peripheral.Name)
+		assert.Equal(t, uuid, body.Peripheral.Uuid)
+		assert.Equal(t, uint16(1), body.Peripheral.Major)
+		assert.Equal(t, uint16(7), body.Peripheral.Minor)
+		assert.True(t, body.Peripheral.Enabled)
+	}
+
+	assert.Equal(t, "aa:bb:cc:dd:ee:ff", body.Address)
+	assert.Equal(t, float64(-40), body.Rssi)
+	assert.Equal(t, []time.Duration{10 * time.Second, 10 * time.Second, 5 * time.Second}, windows, "windows")
+}
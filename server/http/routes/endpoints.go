@@ -1,28 +1,37 @@
 package routes
 
 import (
+	"github.com/blent/beagle/pkg/delivery"
 	"github.com/blent/beagle/pkg/notification"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/validation"
 	"github.com/blent/beagle/server/storage"
-	"github.com/blent/beagle/server/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"net/http"
 	"path"
+	"path/filepath"
+	"strings"
 )
 
-var (
-	ErrEndpointsRouteInvalidEndpoint = errors.New("invalid endpoint")
-)
+var ErrEndpointsRouteNotFound = errors.Wrap(storage.ErrNotFound, "endpoint")
 
-type EndpointsRoute struct {
-	baseUrl string
-	logger  *zap.Logger
-	storage *storage.Manager
-}
+type (
+	EndpointTester interface {
+		Test(eventName string, endpoint *notification.Endpoint, dryRun bool) (*delivery.TestResult, error)
+	}
 
-func NewEndpointsRoute(baseUrl string, logger *zap.Logger, storage *storage.Manager) *EndpointsRoute {
-	return &EndpointsRoute{baseUrl, logger, storage}
+	EndpointsRoute struct {
+		baseUrl string
+		logger  *zap.Logger
+		storage *storage.Manager
+		tester  EndpointTester
+	}
+)
+
+func NewEndpointsRoute(baseUrl string, logger *zap.Logger, storage *storage.Manager, tester EndpointTester) *EndpointsRoute {
+	return &EndpointsRoute{baseUrl, logger, storage, tester}
 }
 
 func (rt *EndpointsRoute) Use(routes gin.IRoutes) {
@@ -35,6 +44,9 @@ func (rt *EndpointsRoute) Use(routes gin.IRoutes) {
 	// Get single endpoint by id
 	routes.GET(path.Join("/", rt.baseUrl, singular, ":id"), rt.getEndpoint)
 
+	// Get subscribers pointing at endpoint, they are removed along with it
+	routes.GET(path.Join("/", rt.baseUrl, singular, ":id", "subscribers"), rt.getEndpointSubscribers)
+
 	// Create new endpoint
 	routes.POST(path.Join("/", rt.baseUrl, singular), rt.createEndpoint)
 
@@ -46,68 +58,103 @@ func (rt *EndpointsRoute) Use(routes gin.IRoutes) {
 
 	// Delete existing endpoints by id
 	routes.DELETE(path.Join("/", rt.baseUrl, plural), rt.deleteEndpoints)
+
+	// Send a test event to existing endpoint by id
+	routes.POST(path.Join("/", rt.baseUrl, singular, ":id", "test"), rt.testEndpoint)
+
+	// Send a test event to unsaved endpoint
+	routes.POST(path.Join("/", rt.baseUrl, plural, "test"), rt.testUnsavedEndpoint)
 }
 
 func (rt *EndpointsRoute) findEndpoints(ctx *gin.Context) {
-	take, err := utils.StringToUint64(ctx.Query("take"))
+	pagination, sorting, ok := parsePage(ctx, storage.EndpointSortFields)
 
-	if err != nil {
-		rt.logger.Error("failed to parse parameter: take")
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("invalid parameter: take"))
+	if !ok {
 		return
 	}
 
-	skip, err := utils.StringToUint64(ctx.Query("skip"))
+	endpoints, quantity, next, err := rt.storage.FindEndpoints(&storage.EndpointQuery{
+		Pagination: pagination,
+		EndpointFilter: &storage.EndpointFilter{
+			Name:   ctx.Query("name"),
+			Search: strings.TrimSpace(ctx.Query("search")),
+			Kind:   ctx.Query("kind"),
+		},
+		Sorting: sorting,
+	})
 
 	if err != nil {
-		rt.logger.Error("failed to parse parameter: skip")
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("invalid parameter: skip"))
+		rt.logger.Error("failed to find endpoints", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
-	name := ctx.Query("name")
+	respondWithPage(ctx, endpoints, quantity, next)
+}
 
-	endpoints, quantity, err := rt.storage.FindEndpoints(storage.NewEndpointQuery(take, skip, name))
+func (rt *EndpointsRoute) getEndpoint(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	endpoint, err := rt.storage.GetEndpoint(id)
 
 	if err != nil {
-		rt.logger.Error("failed to find endpoints", zap.Error(err))
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		rt.logger.Error(
+			"Failed to retrieve endpoint",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"items":    endpoints,
-		"quantity": quantity,
-	})
+	if endpoint == nil {
+		serverHttp.AbortWithCause(ctx, ErrEndpointsRouteNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, endpoint)
 }
 
-func (rt *EndpointsRoute) getEndpoint(ctx *gin.Context) {
-	id, err := utils.StringToUint64(ctx.Params.ByName("id"))
+func (rt *EndpointsRoute) getEndpointSubscribers(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
 
-	if err != nil {
-		rt.logger.Error("Failed to parse endpoint id", zap.Error(err))
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id"))
+	if !ok {
 		return
 	}
 
 	endpoint, err := rt.storage.GetEndpoint(id)
 
+	if err == nil && endpoint == nil {
+		err = ErrEndpointsRouteNotFound
+	}
+
 	if err != nil {
 		rt.logger.Error(
 			"Failed to retrieve endpoint",
 			zap.Uint64("id", id),
 			zap.Error(err),
 		)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
-	if endpoint == nil {
-		ctx.AbortWithStatus(http.StatusOK)
+	subscribers, err := rt.storage.GetEndpointSubscribers(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve endpoint subscribers",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, endpoint)
+	ctx.JSON(http.StatusOK, subscribers)
 }
 
 func (rt *EndpointsRoute) createEndpoint(ctx *gin.Context) {
@@ -121,7 +168,7 @@ func (rt *EndpointsRoute) createEndpoint(ctx *gin.Context) {
 
 	if err != nil {
 		rt.logger.Error("Failed to create a new endpoint", zap.Error(err))
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
@@ -136,8 +183,7 @@ func (rt *EndpointsRoute) updateEndpoint(ctx *gin.Context) {
 	}
 
 	if endpoint.Id == 0 {
-		rt.logger.Error("Missed endpoint id")
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id"))
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id"))
 		return
 	}
 
@@ -149,7 +195,7 @@ func (rt *EndpointsRoute) updateEndpoint(ctx *gin.Context) {
 			zap.Uint64("id", endpoint.Id),
 			zap.Error(err),
 		)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
@@ -157,15 +203,13 @@ func (rt *EndpointsRoute) updateEndpoint(ctx *gin.Context) {
 }
 
 func (rt *EndpointsRoute) deleteEndpoint(ctx *gin.Context) {
-	id, err := utils.StringToUint64(ctx.Params.ByName("id"))
+	id, ok := parseId(ctx, "id")
 
-	if err != nil {
-		rt.logger.Error("Failed to parse an endpoint id", zap.Error(err))
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id"))
+	if !ok {
 		return
 	}
 
-	err = rt.storage.DeleteEndpoint(id)
+	err := rt.storage.DeleteEndpoint(id)
 
 	if err != nil {
 		rt.logger.Error(
@@ -173,7 +217,7 @@ func (rt *EndpointsRoute) deleteEndpoint(ctx *gin.Context) {
 			zap.Uint64("id", id),
 			zap.Error(err),
 		)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
@@ -183,15 +227,16 @@ func (rt *EndpointsRoute) deleteEndpoint(ctx *gin.Context) {
 func (rt *EndpointsRoute) deleteEndpoints(ctx *gin.Context) {
 	var ids []uint64
 
-	err := ctx.BindJSON(&ids)
+	if !serverHttp.BindJSON(ctx, &ids) {
+		return
+	}
 
-	if err != nil {
-		rt.logger.Error("Failed to parse an array of endpoint ids", zap.Error(err))
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id(s)"))
+	if len(ids) == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id(s)"))
 		return
 	}
 
-	err = rt.storage.DeleteEndpoints(ids)
+	err := rt.storage.DeleteEndpoints(ids)
 
 	if err != nil {
 		rt.logger.Error(
@@ -199,24 +244,106 @@ func (rt *EndpointsRoute) deleteEndpoints(ctx *gin.Context) {
 			zap.Uint64s("ids", ids),
 			zap.Error(err),
 		)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
 	ctx.AbortWithStatus(http.StatusOK)
 }
 
-func (rt *EndpointsRoute) deserializeEndpoint(ctx *gin.Context) (*notification.Endpoint, bool) {
-	var endpoint *notification.Endpoint
+func (rt *EndpointsRoute) testEndpoint(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
 
-	err := ctx.BindJSON(&endpoint)
+	if !ok {
+		return
+	}
+
+	endpoint, err := rt.storage.GetEndpoint(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve endpoint",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	if endpoint == nil {
+		serverHttp.AbortWithCause(ctx, ErrEndpointsRouteNotFound)
+		return
+	}
+
+	rt.test(ctx, endpoint)
+}
+
+func (rt *EndpointsRoute) testUnsavedEndpoint(ctx *gin.Context) {
+	endpoint, ok := rt.deserializeEndpoint(ctx)
+
+	if !ok {
+		return
+	}
+
+	rt.test(ctx, endpoint)
+}
+
+func (rt *EndpointsRoute) test(ctx *gin.Context, endpoint *notification.Endpoint) {
+	eventName := ctx.DefaultQuery("event", notification.FOUND)
+	dryRun := ctx.Query("dryRun") == "true" || ctx.Query("dryRun") == "1"
+
+	if eventName != notification.FOUND && eventName != notification.LOST {
+		rt.logger.Error("Invalid test event name", zap.String("event", eventName))
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("invalid parameter: event"))
+		return
+	}
+
+	result, err := rt.tester.Test(eventName, endpoint, dryRun)
 
 	if err != nil {
-		rt.logger.Error("Failed to deserialize endpoint", zap.Error(err))
-		ctx.AbortWithError(http.StatusBadRequest, ErrEndpointsRouteInvalidEndpoint)
+		rt.logger.Error(
+			"Failed to test endpoint",
+			zap.String("endpoint", endpoint.Name),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// deserializeEndpoint responds with 400 for a malformed body and 422 for invalid fields
+func (rt *EndpointsRoute) deserializeEndpoint(ctx *gin.Context) (*notification.Endpoint, bool) {
+	var endpoint notification.Endpoint
+
+	if !serverHttp.BindJSON(ctx, &endpoint) {
+		return nil, false
+	}
+
+	endpoint.Name = strings.TrimSpace(endpoint.Name)
+	endpoint.Url = strings.TrimSpace(endpoint.Url)
+
+	if endpoint.Kind == "" {
+		endpoint.Kind = notification.ENDPOINT_KIND_HTTP
+	}
+
+	v := validation.New()
+
+	v.Required("name", endpoint.Name)
+	v.OneOf("kind", endpoint.Kind, notification.ENDPOINT_KIND_HTTP, notification.ENDPOINT_KIND_COMMAND)
+	v.Required("url", endpoint.Url)
+
+	if endpoint.Kind == notification.ENDPOINT_KIND_COMMAND {
+		v.Check("url", filepath.IsAbs(endpoint.Url), "must be an absolute path of a command")
+	}
+
+	if err := v.Err(); err != nil {
+		rt.logger.Error("Invalid endpoint", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
 
 		return nil, false
 	}
 
-	return endpoint, true
+	return &endpoint, true
 }
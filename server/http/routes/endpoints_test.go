@@ -0,0 +1,44 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointsRouteErrors(t *testing.T) {
+	engine, cleanup := createRegistryEngine(t)
+	defer cleanup()
+
+	res := call(engine, http.MethodGet, "/api/registry/endpoint/1", "", nil)
+	assert.Equal(t, http.StatusNotFound, res.Code, "missing endpoint")
+
+	res = call(engine, http.MethodPost, "/api/registry/endpoint", "", map[string]interface{}{
+		"kind": "command",
+		"url":  "notify.sh",
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "invalid fields")
+	assert.ElementsMatch(t, []string{"name", "url"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodPost, "/api/registry/endpoint", "", map[string]interface{}{
+		"kind": "mail",
+		"name": "mail",
+		"url":  "user@localhost",
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "unsupported kind")
+	assert.Equal(t, []string{"kind"}, fieldNames(decodeError(t, res)))
+
+	endpoint := map[string]interface{}{
+		"name":   "hook",
+		"url":    "http://localhost/hook",
+		"method": "POST",
+	}
+
+	res = call(engine, http.MethodPost, "/api/registry/endpoint", "", endpoint)
+	assert.Equal(t, http.StatusOK, res.Code, "valid endpoint")
+
+	res = call(engine, http.MethodPost, "/api/registry/endpoint", "", endpoint)
+	assert.Equal(t, http.StatusConflict, res.Code, "duplicate name")
+	assert.Equal(t, "conflict", decodeError(t, res).Code)
+}
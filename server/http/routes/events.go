@@ -0,0 +1,216 @@
+package routes
+
+import (
+	"github.com/blent/beagle/pkg/streaming"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	eventsKeepAlive    = time.Second * 15
+	eventsWriteTimeout = time.Second * 10
+)
+
+type EventsRoute struct {
+	baseUrl  string
+	logger   *zap.Logger
+	hub      *streaming.Hub
+	upgrader *websocket.Upgrader
+}
+
+func NewEventsRoute(baseUrl string, logger *zap.Logger, hub *streaming.Hub) *EventsRoute {
+	return &EventsRoute{
+		baseUrl: baseUrl,
+		logger:  logger,
+		hub:     hub,
+		upgrader: &websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+}
+
+func (rt *EventsRoute) Use(routes gin.IRoutes) {
+	// Server-Sent Events
+	routes.GET(path.Join("/", rt.baseUrl, "stream"), rt.stream)
+
+	// WebSocket
+	routes.GET(path.Join("/", rt.baseUrl, "ws"), rt.socket)
+}
+
+func (rt *EventsRoute) stream(ctx *gin.Context) {
+	filter, replay, err := rt.parseQuery(ctx)
+
+	if err != nil {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	client := rt.hub.Subscribe(filter, replay)
+	defer rt.hub.Unsubscribe(client)
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case evt, ok := <-client.Events():
+			if !ok {
+				return false
+			}
+
+			ctx.Render(-1, sse.Event{
+				Event: evt.Name,
+				Id:    strconv.FormatUint(evt.Id, 10),
+				Data:  evt,
+			})
+
+			return true
+		case <-ticker.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+
+			return err == nil
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (rt *EventsRoute) socket(ctx *gin.Context) {
+	filter, replay, err := rt.parseQuery(ctx)
+
+	if err != nil {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	conn, err := rt.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to upgrade the connection",
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	defer conn.Close()
+
+	client := rt.hub.Subscribe(filter, replay)
+	defer rt.hub.Unsubscribe(client)
+
+	// the stream is one-way, reading only detects a closed connection
+	closed := make(chan struct{})
+
+	go func() {
+		defer close(closed)
+
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case evt, ok := <-client.Events():
+			if !ok {
+				message := "stream closed"
+
+				if client.Dropped() {
+					message = "client is too slow"
+				}
+
+				conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, message),
+					time.Now().Add(eventsWriteTimeout),
+				)
+
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+
+			if err := conn.WriteJSON(evt); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventsWriteTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (rt *EventsRoute) parseQuery(ctx *gin.Context) (*streaming.Filter, int, error) {
+	filter := &streaming.Filter{
+		Sources: queryList(ctx, "source"),
+		Names:   queryList(ctx, "name"),
+		Keys:    queryList(ctx, "key"),
+		Kinds:   queryList(ctx, "kind"),
+	}
+
+	if value, ok := ctx.GetQuery("registered"); ok {
+		registered, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return nil, 0, errors.New("invalid parameter: registered")
+		}
+
+		filter.Registered = &registered
+	}
+
+	// replays the whole history by default
+	replay := -1
+
+	if value, ok := ctx.GetQuery("replay"); ok {
+		num, err := strconv.Atoi(value)
+
+		if err != nil || num < 0 {
+			return nil, 0, errors.New("invalid parameter: replay")
+		}
+
+		replay = num
+	}
+
+	return filter, replay, nil
+}
+
+func queryList(ctx *gin.Context, name string) []string {
+	res := make([]string, 0, 5)
+
+	for _, value := range ctx.QueryArray(name) {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+
+			if item != "" {
+				res = append(res, item)
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,256 @@
+package routes
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/validation"
+	"github.com/blent/beagle/server/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var ErrGroupsRouteNotFound = errors.Wrap(storage.ErrNotFound, "group")
+
+type (
+	GroupDto struct {
+		Id          uint64                     `json:"id"`
+		Name        string                     `json:"name"`
+		Enabled     bool                       `json:"enabled"`
+		Uuid        string                     `json:"uuid,omitempty"`
+		Major       uint16                     `json:"major,omitempty"`
+		Members     []uint64                   `json:"members"`
+		Subscribers []*notification.Subscriber `json:"subscribers"`
+	}
+
+	GroupsRoute struct {
+		baseUrl string
+		logger  *zap.Logger
+		storage *storage.Manager
+	}
+)
+
+func NewGroupsRoute(baseUrl string, logger *zap.Logger, storage *storage.Manager) *GroupsRoute {
+	return &GroupsRoute{
+		baseUrl,
+		logger,
+		storage,
+	}
+}
+
+func (rt *GroupsRoute) Use(routes gin.IRoutes) {
+	singular := "group"
+	plural := "groups"
+
+	// Get multiple groups
+	routes.GET(path.Join("/", rt.baseUrl, plural), rt.findGroups)
+
+	// Get single group by id
+	routes.GET(path.Join("/", rt.baseUrl, singular, ":id"), rt.getGroup)
+
+	// Create new group
+	routes.POST(path.Join("/", rt.baseUrl, singular), rt.createGroup)
+
+	// Update existing group by id
+	routes.PUT(path.Join("/", rt.baseUrl, singular), rt.updateGroup)
+
+	// Delete existing group by id
+	routes.DELETE(path.Join("/", rt.baseUrl, singular, ":id"), rt.deleteGroup)
+
+	// Delete multiple groups by id
+	routes.DELETE(path.Join("/", rt.baseUrl, plural), rt.deleteGroups)
+}
+
+func (rt *GroupsRoute) findGroups(ctx *gin.Context) {
+	take, skip, ok := parsePagination(ctx)
+
+	if !ok {
+		return
+	}
+
+	groups, quantity, err := rt.storage.FindGroups(storage.NewGroupQuery(take, skip, storage.PERIPHERAL_STATUS_ANY))
+
+	if err != nil {
+		rt.logger.Error("failed to find groups", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"items":    groups,
+		"quantity": quantity,
+	})
+}
+
+func (rt *GroupsRoute) getGroup(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	group, members, subscribers, err := rt.storage.GetGroupWithDetails(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve group",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	if group == nil {
+		serverHttp.AbortWithCause(ctx, ErrGroupsRouteNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &GroupDto{
+		Id:          group.Id,
+		Name:        group.Name,
+		Enabled:     group.Enabled,
+		Uuid:        group.Uuid,
+		Major:       group.Major,
+		Members:     members,
+		Subscribers: subscribers,
+	})
+}
+
+func (rt *GroupsRoute) createGroup(ctx *gin.Context) {
+	dto, ok := rt.deserializeGroup(ctx)
+
+	if !ok {
+		return
+	}
+
+	id, err := rt.storage.CreateGroup(rt.toGroup(dto), dto.Members, dto.Subscribers)
+
+	if err != nil {
+		rt.logger.Error("Failed to create new group", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "%d", id)
+}
+
+func (rt *GroupsRoute) updateGroup(ctx *gin.Context) {
+	dto, ok := rt.deserializeGroup(ctx)
+
+	if !ok {
+		return
+	}
+
+	if dto.Id == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id"))
+		return
+	}
+
+	err := rt.storage.UpdateGroup(rt.toGroup(dto), dto.Members, dto.Subscribers)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to update group",
+			zap.Uint64("id", dto.Id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *GroupsRoute) deleteGroup(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	err := rt.storage.DeleteGroup(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to delete group",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *GroupsRoute) deleteGroups(ctx *gin.Context) {
+	var ids []uint64
+
+	if !serverHttp.BindJSON(ctx, &ids) {
+		return
+	}
+
+	if len(ids) == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id(s)"))
+		return
+	}
+
+	err := rt.storage.DeleteGroups(ids)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to delete groups",
+			zap.Uint64s("ids", ids),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+// deserializeGroup responds with 400 for a malformed body and 422 for invalid fields
+func (rt *GroupsRoute) deserializeGroup(ctx *gin.Context) (*GroupDto, bool) {
+	var dto GroupDto
+
+	if !serverHttp.BindJSON(ctx, &dto) {
+		return nil, false
+	}
+
+	// uuids of discovered iBeacons are lower case hex strings
+	dto.Name = strings.TrimSpace(dto.Name)
+	dto.Uuid = strings.ToLower(strings.TrimSpace(dto.Uuid))
+
+	v := validation.New()
+
+	v.Required("name", dto.Name)
+	v.Length("uuid", dto.Uuid, 32)
+	v.Check("major", dto.Major == 0 || dto.Uuid != "", "requires uuid")
+
+	validateSubscribers(v, dto.Subscribers, true)
+
+	if err := v.Err(); err != nil {
+		rt.logger.Error("Invalid group", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+
+		return nil, false
+	}
+
+	return &dto, true
+}
+
+func (rt *GroupsRoute) toGroup(dto *GroupDto) *tracking.Group {
+	return &tracking.Group{
+		Id:      dto.Id,
+		Name:    dto.Name,
+		Enabled: dto.Enabled,
+		Uuid:    dto.Uuid,
+		Major:   dto.Major,
+	}
+}
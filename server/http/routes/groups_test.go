@@ -0,0 +1,38 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupsRouteErrors(t *testing.T) {
+	engine, cleanup := createRegistryEngine(t)
+	defer cleanup()
+
+	res := call(engine, http.MethodGet, "/api/registry/group/1", "", nil)
+	assert.Equal(t, http.StatusNotFound, res.Code, "missing group")
+
+	res = call(engine, http.MethodPost, "/api/registry/group", "", map[string]interface{}{
+		"name":  "office",
+		"major": 1,
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "major without uuid")
+	assert.Equal(t, []string{"major"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodPost, "/api/registry/group", "", map[string]interface{}{
+		"name": "office",
+		"subscribers": []map[string]interface{}{{
+			"name":     "office",
+			"event":    "found",
+			"mode":     "sometimes",
+			"endpoint": map[string]interface{}{"id": 1},
+		}},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "unsupported mode")
+	assert.Equal(t, []string{"subscribers[0].mode"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodDelete, "/api/registry/groups", "", []uint64{})
+	assert.Equal(t, http.StatusBadRequest, res.Code, "no ids")
+}
@@ -0,0 +1,199 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/blent/beagle/pkg/notification"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/validation"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// parseId responds with 400 if a path parameter is not a positive number
+func parseId(ctx *gin.Context, name string) (uint64, bool) {
+	id, err := utils.StringToUint64(ctx.Params.ByName(name))
+
+	if err != nil || id == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.Errorf("invalid parameter: %s", name))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// parsePagination responds with 400 if take or skip query parameters are not numbers
+func parsePagination(ctx *gin.Context) (uint64, uint64, bool) {
+	take, err := utils.StringToUint64(ctx.Query("take"))
+
+	if err != nil {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("invalid parameter: take"))
+		return 0, 0, false
+	}
+
+	skip, err := utils.StringToUint64(ctx.Query("skip"))
+
+	if err != nil {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("invalid parameter: skip"))
+		return 0, 0, false
+	}
+
+	return take, skip, true
+}
+
+// parsePage parses take, skip, cursor, sort and order query parameters and responds with 400 if they are invalid.
+// A cursor is returned with a previous page and is valid only with the same sort field.
+func parsePage(ctx *gin.Context, fields []string) (*storage.Pagination, *storage.Sorting, bool) {
+	take, skip, ok := parsePagination(ctx)
+
+	if !ok {
+		return nil, nil, false
+	}
+
+	sorting := storage.NewSorting(ctx.DefaultQuery("sort", storage.SORT_ID), false)
+
+	if !isOneOf(sorting.Field, fields) {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("invalid parameter: sort"))
+		return nil, nil, false
+	}
+
+	switch ctx.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		sorting.Descending = true
+	default:
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("invalid parameter: order"))
+		return nil, nil, false
+	}
+
+	pagination := storage.NewPagination(take, skip)
+
+	if value := ctx.Query("cursor"); value != "" {
+		cursor, err := storage.ParseCursor(value)
+
+		if err != nil || cursor.Field != sorting.Field {
+			serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("invalid parameter: cursor"))
+			return nil, nil, false
+		}
+
+		pagination.After = cursor
+	}
+
+	return pagination, sorting, true
+}
+
+// parseStatus parses an optional "enabled" query parameter
+func parseStatus(ctx *gin.Context) (string, bool) {
+	value, ok := ctx.GetQuery("enabled")
+
+	if !ok || value == "" {
+		return storage.PERIPHERAL_STATUS_ANY, true
+	}
+
+	enabled, err := strconv.ParseBool(value)
+
+	if err != nil {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("invalid parameter: enabled"))
+		return "", false
+	}
+
+	if enabled {
+		return storage.PERIPHERAL_STATUS_ENABLED, true
+	}
+
+	return storage.PERIPHERAL_STATUS_DISABLED, true
+}
+
+// parseOptionalId parses an optional id query parameter, zero means any
+func parseOptionalId(ctx *gin.Context, name string) (uint64, bool) {
+	id, err := utils.StringToUint64(ctx.Query(name))
+
+	if err != nil {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.Errorf("invalid parameter: %s", name))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// respondWithPage responds with items of a page, a total quantity and a cursor of the next page if there is one
+func respondWithPage(ctx *gin.Context, items interface{}, quantity uint64, next *storage.Cursor) {
+	res := gin.H{
+		"items":    items,
+		"quantity": quantity,
+		"next":     nil,
+	}
+
+	if next != nil {
+		res["next"] = next.String()
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func isOneOf(value string, allowed []string) bool {
+	for _, option := range allowed {
+		if value == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateSubscribers checks subscribers of peripherals, groups and rules, only those of groups have modes
+func validateSubscribers(v *validation.Validator, subscribers []*notification.Subscriber, grouped bool) {
+	for idx, subscriber := range subscribers {
+		prefix := fmt.Sprintf("subscribers[%d].", idx)
+
+		if subscriber == nil {
+			v.Add(prefix+"subscriber", "is required")
+			continue
+		}
+
+		validateSubscriber(v, prefix, subscriber, grouped)
+	}
+}
+
+// validateSubscriber checks a subscriber, names of fields start with a prefix
+func validateSubscriber(v *validation.Validator, prefix string, subscriber *notification.Subscriber, grouped bool) {
+	v.Required(prefix+"event", subscriber.Event)
+	v.Check(prefix+"endpoint", subscriber.Endpoint != nil && subscriber.Endpoint.Id > 0, "is required")
+
+	if grouped {
+		v.Check(
+			prefix+"mode",
+			notification.IsSupportedSubscriberMode(subscriber.Mode),
+			fmt.Sprintf("unsupported mode: '%s'", subscriber.Mode),
+		)
+	} else {
+		v.Check(prefix+"mode", subscriber.Mode == "", "is only supported by subscribers of groups")
+	}
+
+	if subscriber.Batch != nil {
+		v.Check(
+			prefix+"batch.window",
+			subscriber.Batch.Window <= notification.MAX_BATCH_WINDOW,
+			fmt.Sprintf("must not be greater than %d", notification.MAX_BATCH_WINDOW),
+		)
+		v.Check(
+			prefix+"batch.window",
+			subscriber.Batch.Window > 0 || subscriber.Batch.Size <= 1,
+			"is required when batch.size is greater than 1",
+		)
+		v.Check(
+			prefix+"batch.size",
+			subscriber.Batch.Size >= 0 && subscriber.Batch.Size <= notification.MAX_BATCH_SIZE,
+			fmt.Sprintf("must be between 0 and %d", notification.MAX_BATCH_SIZE),
+		)
+		v.Check(
+			prefix+"batch.coalesce",
+			notification.IsSupportedCoalesceMode(subscriber.Batch.Coalesce),
+			fmt.Sprintf("unsupported coalesce mode: '%s'", subscriber.Batch.Coalesce),
+		)
+	}
+}
@@ -0,0 +1,138 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/routes"
+	"github.com/blent/beagle/server/initialization/initializers"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/storage/providers/sqlite"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func createManager(t *testing.T) (*storage.Manager, func()) {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := sqlite.NewSQLiteProvider(filepath.Join(dir, "database.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := initializers.NewDatabaseInitializer(zap.NewNop(), provider).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	return storage.NewManager(zap.NewNop(), provider), func() {
+		provider.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func createRegistryEngine(t *testing.T) (*gin.Engine, func()) {
+	manager, cleanup := createManager(t)
+
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+
+	routes.NewPeripheralsRoute("/api/registry", zap.NewNop(), manager).Use(engine)
+	routes.NewEndpointsRoute("/api/registry", zap.NewNop(), manager, nil).Use(engine)
+	routes.NewGroupsRoute("/api/registry", zap.NewNop(), manager).Use(engine)
+	routes.NewSubscribersRoute("/api/registry", zap.NewNop(), manager).Use(engine)
+	routes.NewRulesRoute("/api/registry", zap.NewNop(), manager).Use(engine)
+
+	return engine, cleanup
+}
+
+func call(engine *gin.Engine, method, url, token string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, url, &payload)
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res := httptest.NewRecorder()
+	engine.ServeHTTP(res, req)
+
+	return res
+}
+
+func createTestPeripheral(key string) peripherals.Peripheral {
+	return peripherals.NewMockPeripheral(key, peripherals.PERIPHERAL_IBEACON, "", nil, 0, 0, "")
+}
+
+func decodeError(t *testing.T, res *httptest.ResponseRecorder) *serverHttp.ErrorResponse {
+	var body serverHttp.ErrorResponse
+
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid error response '%s': %s", res.Body.String(), err)
+	}
+
+	return &body
+}
+
+func fieldNames(body *serverHttp.ErrorResponse) []string {
+	names := make([]string, 0, len(body.Fields))
+
+	for _, field := range body.Fields {
+		names = append(names, field.Field)
+	}
+
+	return names
+}
+
+type page struct {
+	Items []struct {
+		Id       uint64 `json:"id"`
+		Name     string `json:"name"`
+		TargetId uint64 `json:"targetId"`
+	} `json:"items"`
+	Quantity uint64  `json:"quantity"`
+	Next     *string `json:"next"`
+}
+
+func decodePage(t *testing.T, res *httptest.ResponseRecorder) *page {
+	if !assert.Equal(t, http.StatusOK, res.Code, res.Body.String()) {
+		t.FailNow()
+	}
+
+	var body page
+
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	return &body
+}
+
+func (p *page) names() []string {
+	names := make([]string, 0, len(p.Items))
+
+	for _, item := range p.Items {
+		names = append(names, item.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	MetricsHandler interface {
+		Handler() http.Handler
+	}
+
+	MetricsRoute struct {
+		url     string
+		metrics MetricsHandler
+	}
+)
+
+func NewMetricsRoute(url string, metrics MetricsHandler) *MetricsRoute {
+	return &MetricsRoute{url, metrics}
+}
+
+func (rt *MetricsRoute) Use(routes gin.IRoutes) {
+	routes.GET(rt.url, gin.WrapH(rt.metrics.Handler()))
+}
@@ -1,42 +1,57 @@
 package routes
 
 import (
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/events"
 	"github.com/blent/beagle/pkg/monitoring/activity"
 	"github.com/blent/beagle/pkg/monitoring/system"
-	"github.com/blent/beagle/server/utils"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/initialization"
+	"github.com/blent/beagle/server/storage"
 	"github.com/gin-gonic/gin"
-	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"net/http"
 	"path"
 )
 
-type MonitoringRoute struct {
-	baseUrl  string
-	logger   *zap.Logger
-	activity *activity.Monitoring
-	system   *system.Monitoring
-}
+type (
+	DeliveryStates interface {
+		EndpointStates() []*delivery.GateState
+	}
+
+	ListenerStats interface {
+		ListenerStats() events.Stats
+	}
+
+	RegistryCache interface {
+		CacheStats() *storage.CacheStats
+	}
+
+	StartupStatus interface {
+		Status() []*initialization.StepStatus
+	}
 
-func NewMonitoringRoute(baseUrl string, logger *zap.Logger, activity *activity.Monitoring, system *system.Monitoring) *MonitoringRoute {
-	return &MonitoringRoute{baseUrl, logger, activity, system}
+	MonitoringRoute struct {
+		baseUrl   string
+		logger    *zap.Logger
+		activity  *activity.Monitoring
+		system    *system.Monitoring
+		delivery  DeliveryStates
+		listeners map[string]ListenerStats
+		registry  RegistryCache
+		startup   StartupStatus
+	}
+)
+
+func NewMonitoringRoute(baseUrl string, logger *zap.Logger, activity *activity.Monitoring, system *system.Monitoring, delivery DeliveryStates, listeners map[string]ListenerStats, registry RegistryCache, startup StartupStatus) *MonitoringRoute {
+	return &MonitoringRoute{baseUrl, logger, activity, system, delivery, listeners, registry, startup}
 }
 
 func (rt *MonitoringRoute) Use(routes gin.IRoutes) {
 	routes.GET(path.Join("/", rt.baseUrl, "activity"), func(ctx *gin.Context) {
-		take, err := utils.StringToUint64(ctx.Query("take"))
+		take, skip, ok := parsePagination(ctx)
 
-		if err != nil {
-			rt.logger.Error("failed to parse parameter: take")
-			ctx.AbortWithError(http.StatusBadRequest, errors.New("invalid parameter: take"))
-			return
-		}
-
-		skip, err := utils.StringToUint64(ctx.Query("skip"))
-
-		if err != nil {
-			rt.logger.Error("failed to parse parameter: skip")
-			ctx.AbortWithError(http.StatusBadRequest, errors.New("invalid parameter: skip"))
+		if !ok {
 			return
 		}
 
@@ -55,11 +70,43 @@ func (rt *MonitoringRoute) Use(routes gin.IRoutes) {
 				zap.Error(err),
 			)
 
-			ctx.AbortWithStatus(http.StatusInternalServerError)
+			serverHttp.AbortWithCause(ctx, err)
 
 			return
 		}
 
 		ctx.JSON(http.StatusOK, stats)
 	})
+
+	routes.GET(path.Join("/", rt.baseUrl, "delivery"), func(ctx *gin.Context) {
+		states := rt.delivery.EndpointStates()
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"items":    states,
+			"quantity": len(states),
+		})
+	})
+
+	routes.GET(path.Join("/", rt.baseUrl, "listeners"), func(ctx *gin.Context) {
+		stats := make(map[string]events.Stats)
+
+		for name, source := range rt.listeners {
+			stats[name] = source.ListenerStats()
+		}
+
+		ctx.JSON(http.StatusOK, stats)
+	})
+
+	routes.GET(path.Join("/", rt.baseUrl, "registry"), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, rt.registry.CacheStats())
+	})
+
+	routes.GET(path.Join("/", rt.baseUrl, "startup"), func(ctx *gin.Context) {
+		steps := rt.startup.Status()
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"items":    steps,
+			"quantity": len(steps),
+		})
+	})
 }
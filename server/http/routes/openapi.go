@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/blent/beagle/server/http/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+type OpenApiRoute struct {
+	url string
+	doc *openapi.Document
+}
+
+func NewOpenApiRoute(url string, doc *openapi.Document) *OpenApiRoute {
+	return &OpenApiRoute{url, doc}
+}
+
+func (rt *OpenApiRoute) Use(routes gin.IRoutes) {
+	routes.GET(rt.url, func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, rt.doc)
+	})
+}
@@ -4,31 +4,41 @@ import (
 	"github.com/blent/beagle/pkg/discovery/peripherals"
 	"github.com/blent/beagle/pkg/notification"
 	"github.com/blent/beagle/pkg/tracking"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/validation"
 	"github.com/blent/beagle/server/storage"
-	"github.com/blent/beagle/server/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 )
 
 var (
 	ErrPeripheralsRouteInvalidModel = errors.New("invalid peripheral")
+	ErrPeripheralsRouteNotFound     = errors.Wrap(storage.ErrNotFound, "peripheral")
 )
 
 type (
 	// We make one big generic DTO for all types of Peripherals
-	// Just to make deserialization more simple and fast
+	// Just to make deserialization more simple and fast.
+	// Pattern registrations must be asked for explicitly by Pattern, omitted major and minor numbers
+	// of them match any, MajorMax and MinorMax turn them into ranges.
+	// Address registers all peripherals with a given address prefix instead.
 	Dto struct {
 		Id          uint64                     `json:"id"`
-		Kind        string                     `json:"kind" binding:"required"`
-		Name        string                     `json:"name" binding:"required"`
+		Kind        string                     `json:"kind"`
+		Name        string                     `json:"name"`
 		Enabled     bool                       `json:"enabled"`
-		Uuid        string                     `json:"uuid, omitempty"`
-		Major       uint16                     `json:"major, omitempty"`
-		Minor       uint16                     `json:"minor, omitempty"`
+		Pattern     bool                       `json:"pattern"`
+		Uuid        string                     `json:"uuid,omitempty"`
+		Major       uint16                     `json:"major,omitempty"`
+		MajorMax    uint16                     `json:"majorMax,omitempty"`
+		Minor       uint16                     `json:"minor,omitempty"`
+		MinorMax    uint16                     `json:"minorMax,omitempty"`
+		Address     string                     `json:"address,omitempty"`
 		Subscribers []*notification.Subscriber `json:"subscribers"`
 	}
 
@@ -68,45 +78,71 @@ func (rt *PeripheralsRoute) Use(routes gin.IRoutes) {
 
 	// Delete multiple peripherals by id
 	routes.DELETE(path.Join("/", rt.baseUrl, plural), rt.deletePeripherals)
+
+	// Create multiple peripherals from csv or json, all or none
+	routes.POST(path.Join("/", rt.baseUrl, plural, "import"), rt.importPeripherals)
+
+	// Export all peripherals as csv
+	routes.GET(path.Join("/", rt.baseUrl, plural, "export"), rt.exportPeripherals)
 }
 
 func (rt *PeripheralsRoute) findPeripherals(ctx *gin.Context) {
-	take, err := utils.StringToUint64(ctx.Query("take"))
+	pagination, sorting, ok := parsePage(ctx, storage.PeripheralSortFields)
 
-	if err != nil {
-		rt.logger.Error("failed to parse parameter: take")
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("invalid parameter: take"))
+	if !ok {
 		return
 	}
 
-	skip, err := utils.StringToUint64(ctx.Query("skip"))
+	filter, ok := rt.parseFilter(ctx)
 
-	if err != nil {
-		rt.logger.Error("failed to parse parameter: skip")
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("invalid parameter: skip"))
+	if !ok {
 		return
 	}
 
-	targets, quantity, err := rt.storage.FindPeripherals(storage.NewTargetQuery(take, skip, storage.PERIPHERAL_STATUS_ANY))
+	targets, quantity, next, err := rt.storage.FindPeripherals(&storage.PeripheralQuery{
+		Pagination:       pagination,
+		PeripheralFilter: filter,
+		Sorting:          sorting,
+	})
 
 	if err != nil {
 		rt.logger.Error("failed to find peripherals", zap.Error(err))
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"items":    targets,
-		"quantity": quantity,
-	})
+	respondWithPage(ctx, targets, quantity, next)
 }
 
-func (rt *PeripheralsRoute) getPeripheral(ctx *gin.Context) {
-	id, err := utils.StringToUint64(ctx.Params.ByName("id"))
+// parseFilter responds with 400 if filter query parameters are invalid
+func (rt *PeripheralsRoute) parseFilter(ctx *gin.Context) (*storage.PeripheralFilter, bool) {
+	status, ok := parseStatus(ctx)
+
+	if !ok {
+		return nil, false
+	}
+
+	major, err := strconv.ParseUint(ctx.DefaultQuery("major", "0"), 10, 16)
 
 	if err != nil {
-		rt.logger.Error("Failed to parse peripheral id", zap.Error(err))
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id"))
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("invalid parameter: major"))
+		return nil, false
+	}
+
+	return &storage.PeripheralFilter{
+		Status: status,
+		Search: strings.TrimSpace(ctx.Query("search")),
+		Kind:   ctx.Query("kind"),
+		Uuid:   strings.TrimSpace(ctx.Query("uuid")),
+		Major:  uint16(major),
+		Event:  ctx.Query("event"),
+	}, true
+}
+
+func (rt *PeripheralsRoute) getPeripheral(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
 		return
 	}
 
@@ -118,19 +154,19 @@ func (rt *PeripheralsRoute) getPeripheral(ctx *gin.Context) {
 			zap.Uint64("id", id),
 			zap.Error(err),
 		)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
 	if target == nil {
-		ctx.AbortWithStatus(http.StatusOK)
+		serverHttp.AbortWithCause(ctx, ErrPeripheralsRouteNotFound)
 		return
 	}
 
 	dto, err := rt.serializePeripheral(target, subscribers)
 
 	if err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, ErrPeripheralsRouteInvalidModel)
+		serverHttp.AbortWithError(ctx, http.StatusInternalServerError, ErrPeripheralsRouteInvalidModel)
 		return
 	}
 
@@ -138,10 +174,9 @@ func (rt *PeripheralsRoute) getPeripheral(ctx *gin.Context) {
 }
 
 func (rt *PeripheralsRoute) createPeripheral(ctx *gin.Context) {
-	target, subscribers, err := rt.deserializePeripheral(ctx)
+	target, subscribers, ok := rt.deserializePeripheral(ctx)
 
-	if err != nil {
-		ctx.AbortWithStatus(http.StatusBadRequest)
+	if !ok {
 		return
 	}
 
@@ -149,7 +184,7 @@ func (rt *PeripheralsRoute) createPeripheral(ctx *gin.Context) {
 
 	if err != nil {
 		rt.logger.Error("Failed to create new peripheral", zap.Error(err))
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
@@ -157,28 +192,26 @@ func (rt *PeripheralsRoute) createPeripheral(ctx *gin.Context) {
 }
 
 func (rt *PeripheralsRoute) updatePeripheral(ctx *gin.Context) {
-	target, subscribers, err := rt.deserializePeripheral(ctx)
+	target, subscribers, ok := rt.deserializePeripheral(ctx)
 
-	if err != nil {
-		ctx.AbortWithStatus(http.StatusBadRequest)
+	if !ok {
 		return
 	}
 
-	if target.Id < 0 || target.Id == 0 {
-		rt.logger.Error("Missed peripheral id")
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id"))
+	if target.Id == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id"))
 		return
 	}
 
-	err = rt.storage.UpdatePeripheral(target, subscribers)
+	err := rt.storage.UpdatePeripheral(target, subscribers)
 
 	if err != nil {
 		rt.logger.Error(
 			"Failed to update peripheral",
 			zap.Uint64("id", target.Id),
-			zap.String("error", err.Error()),
+			zap.Error(err),
 		)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
@@ -186,15 +219,13 @@ func (rt *PeripheralsRoute) updatePeripheral(ctx *gin.Context) {
 }
 
 func (rt *PeripheralsRoute) deletePeripheral(ctx *gin.Context) {
-	id, err := utils.StringToUint64(ctx.Params.ByName("id"))
+	id, ok := parseId(ctx, "id")
 
-	if err != nil {
-		rt.logger.Error("Failed to parse peripheral id", zap.Error(err))
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id"))
+	if !ok {
 		return
 	}
 
-	err = rt.storage.DeletePeripheral(id)
+	err := rt.storage.DeletePeripheral(id)
 
 	if err != nil {
 		rt.logger.Error(
@@ -202,7 +233,7 @@ func (rt *PeripheralsRoute) deletePeripheral(ctx *gin.Context) {
 			zap.Uint64("id", id),
 			zap.Error(err),
 		)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
@@ -212,15 +243,16 @@ func (rt *PeripheralsRoute) deletePeripheral(ctx *gin.Context) {
 func (rt *PeripheralsRoute) deletePeripherals(ctx *gin.Context) {
 	var ids []uint64
 
-	err := ctx.BindJSON(&ids)
+	if !serverHttp.BindJSON(ctx, &ids) {
+		return
+	}
 
-	if err != nil {
-		rt.logger.Error("Failed to parse an array of peripheral ids", zap.Error(err))
-		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id(s)"))
+	if len(ids) == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id(s)"))
 		return
 	}
 
-	err = rt.storage.DeletePeripherals(ids)
+	err := rt.storage.DeletePeripherals(ids)
 
 	if err != nil {
 		rt.logger.Error(
@@ -228,7 +260,7 @@ func (rt *PeripheralsRoute) deletePeripherals(ctx *gin.Context) {
 			zap.Uint64s("ids", ids),
 			zap.Error(err),
 		)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+		serverHttp.AbortWithCause(ctx, err)
 		return
 	}
 
@@ -243,11 +275,27 @@ func (rt *PeripheralsRoute) serializePeripheral(target *tracking.Peripheral, sub
 		Kind:        target.Kind,
 		Name:        target.Name,
 		Enabled:     target.Enabled,
+		Pattern:     target.Pattern,
 		Subscribers: subscribers,
 	}
 
-	switch target.Kind {
-	case peripherals.PERIPHERAL_IBEACON:
+	switch {
+	case target.Kind == peripherals.PERIPHERAL_IBEACON && target.Pattern:
+		pattern, err := tracking.ParsePattern(target.Key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if pattern.IsAddress() {
+			dto.Address = pattern.AddressPrefix()
+			break
+		}
+
+		dto.Uuid = pattern.Uuid
+		dto.Major, dto.MajorMax = fromRange(pattern.Major)
+		dto.Minor, dto.MinorMax = fromRange(pattern.Minor)
+	case target.Kind == peripherals.PERIPHERAL_IBEACON:
 		uuid, major, minor, err := peripherals.ParseIBeaconUniqueKey(target.Key)
 
 		if err != nil {
@@ -270,55 +318,144 @@ func (rt *PeripheralsRoute) serializePeripheral(target *tracking.Peripheral, sub
 	return dto, nil
 }
 
-func (rt *PeripheralsRoute) deserializePeripheral(ctx *gin.Context) (*tracking.Peripheral, []*notification.Subscriber, error) {
-	var err error
+// deserializePeripheral responds with 400 for a malformed body and 422 for invalid fields
+func (rt *PeripheralsRoute) deserializePeripheral(ctx *gin.Context) (*tracking.Peripheral, []*notification.Subscriber, bool) {
 	var dto Dto
 
-	err = ctx.BindJSON(&dto)
+	if !serverHttp.BindJSON(ctx, &dto) {
+		return nil, nil, false
+	}
+
+	peripheral, err := rt.toPeripheral(&dto)
 
 	if err != nil {
-		rt.logger.Error("Failed to deserialize peripheral", zap.Error(err))
+		rt.logger.Error("Invalid peripheral", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
 
-		return nil, nil, err
+		return nil, nil, false
 	}
 
-	var key string
+	return peripheral, dto.Subscribers, true
+}
 
-	switch dto.Kind {
-	case peripherals.PERIPHERAL_IBEACON:
-		dto.Uuid = strings.TrimSpace(dto.Uuid)
+// toPeripheral validates a registration and returns validation.Errors if it is invalid
+func (rt *PeripheralsRoute) toPeripheral(dto *Dto) (*tracking.Peripheral, error) {
+	v := validation.New()
 
-		if len(dto.Uuid) != 32 {
-			err = errors.Errorf("invalid uuid length: %d", len(dto.Uuid))
-			break
-		}
+	v.Required("name", dto.Name)
+	v.OneOf("kind", dto.Kind, peripherals.PERIPHERAL_IBEACON)
 
-		if dto.Major == 0 {
-			err = errors.Errorf("invalid major number: %d", dto.Major)
-			break
-		}
+	var pattern *tracking.Pattern
 
-		if dto.Minor == 0 {
-			err = errors.Errorf("invalid minor number: %d", dto.Minor)
-			break
+	if !v.Failed("kind") {
+		if !dto.Pattern {
+			validateExact(v, dto)
 		}
 
-		key = peripherals.CreateIBeaconUniqueKey(dto.Uuid, dto.Major, dto.Minor)
-	default:
-		err = errors.Errorf("unsupported peripheral kind: '%s'", dto.Kind)
+		pattern = toPattern(v, dto)
 	}
 
-	if err != nil {
-		return nil, nil, err
+	validateSubscribers(v, dto.Subscribers, false)
+
+	if err := v.Err(); err != nil {
+		return nil, err
 	}
 
 	peripheral := &tracking.Peripheral{
 		Id:      dto.Id,
-		Key:     key,
-		Name:    dto.Name,
+		Key:     pattern.Key(),
+		Name:    strings.TrimSpace(dto.Name),
 		Kind:    dto.Kind,
 		Enabled: dto.Enabled,
+		Pattern: !pattern.IsExact(),
+	}
+
+	if pattern.IsExact() {
+		peripheral.Key = peripherals.CreateIBeaconUniqueKey(dto.Uuid, dto.Major, dto.Minor)
+	}
+
+	return peripheral, nil
+}
+
+// validateExact keeps registrations which are not marked as patterns from matching many peripherals
+func validateExact(v *validation.Validator, dto *Dto) {
+	if dto.Address != "" || dto.MajorMax > 0 || dto.MinorMax > 0 {
+		v.Add("pattern", "must be true for an address prefix or ranges")
+		return
+	}
+
+	v.Check("major", dto.Major > 0, "is required unless pattern is true")
+	v.Check("minor", dto.Minor > 0, "is required unless pattern is true")
+}
+
+// toPattern validates iBeacon numbers or an address prefix of a registration
+func toPattern(v *validation.Validator, dto *Dto) *tracking.Pattern {
+	dto.Uuid = strings.TrimSpace(dto.Uuid)
+	dto.Address = strings.TrimSpace(dto.Address)
+
+	if dto.Address != "" {
+		v.Check("address", dto.Uuid == "" && dto.Major == 0 && dto.Minor == 0, "can not be combined with uuid, major or minor numbers")
+
+		if v.Failed("address") {
+			return nil
+		}
+
+		pattern, err := tracking.NewAddressPattern(dto.Address)
+
+		v.Error("address", err)
+
+		return pattern
+	}
+
+	v.Required("uuid", dto.Uuid)
+	v.Length("uuid", dto.Uuid, 32)
+	v.Check("major", dto.Major > 0 || (dto.MajorMax == 0 && dto.Minor == 0), "is required with a range or a minor number")
+	v.Check("minor", dto.Minor > 0 || dto.MinorMax == 0, "is required with a range")
+
+	major, err := toRange(dto.Major, dto.MajorMax)
+
+	v.Error("majorMax", err)
+
+	minor, err := toRange(dto.Minor, dto.MinorMax)
+
+	v.Error("minorMax", err)
+
+	if v.Err() != nil {
+		return nil
+	}
+
+	pattern, err := tracking.NewIBeaconPattern(dto.Uuid, major, minor)
+
+	v.Error("minor", err)
+
+	return pattern
+}
+
+// toRange treats a zero number as any and a zero maximum as a single number
+func toRange(number, max uint16) (tracking.Range, error) {
+	if number == 0 {
+		return tracking.AnyNumber, nil
+	}
+
+	if max == 0 {
+		return tracking.NewRange(number, number), nil
+	}
+
+	if max < number {
+		return tracking.Range{}, errors.Errorf("must not be less than %d", number)
+	}
+
+	return tracking.NewRange(number, max), nil
+}
+
+func fromRange(r tracking.Range) (uint16, uint16) {
+	if r == tracking.AnyNumber {
+		return 0, 0
+	}
+
+	if r.Min == r.Max {
+		return r.Min, 0
 	}
 
-	return peripheral, dto.Subscribers, nil
+	return r.Min, r.Max
 }
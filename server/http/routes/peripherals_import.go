@@ -0,0 +1,492 @@
+package routes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/validation"
+	"github.com/blent/beagle/server/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	importMaxRows      = 10000
+	importCsvMediaType = "text/csv"
+)
+
+// importColumns are columns of csv imports and exports, all but name are optional in imports
+var importColumns = []string{
+	"kind",
+	"name",
+	"pattern",
+	"uuid",
+	"major",
+	"majorMax",
+	"minor",
+	"minorMax",
+	"address",
+	"enabled",
+	"subscribers",
+}
+
+type (
+	// importRow is a peripheral of an import, subscribers reference endpoints by name.
+	// Omitted kind is iBeacon, omitted enabled is true.
+	importRow struct {
+		Kind        string                 `json:"kind"`
+		Name        string                 `json:"name"`
+		Pattern     bool                   `json:"pattern,omitempty"`
+		Uuid        string                 `json:"uuid,omitempty"`
+		Major       uint16                 `json:"major,omitempty"`
+		MajorMax    uint16                 `json:"majorMax,omitempty"`
+		Minor       uint16                 `json:"minor,omitempty"`
+		MinorMax    uint16                 `json:"minorMax,omitempty"`
+		Address     string                 `json:"address,omitempty"`
+		Enabled     *bool                  `json:"enabled,omitempty"`
+		Subscribers []*importSubscriberRow `json:"subscribers,omitempty"`
+	}
+
+	importSubscriberRow struct {
+		Name     string              `json:"name"`
+		Event    string              `json:"event"`
+		Endpoint string              `json:"endpoint"`
+		Enabled  *bool               `json:"enabled,omitempty"`
+		Batch    *notification.Batch `json:"batch,omitempty"`
+	}
+)
+
+// importPeripherals creates peripherals given by a csv or a json array all at once.
+// Invalid rows are reported with 422 and nothing is created, dryRun only validates rows.
+func (rt *PeripheralsRoute) importPeripherals(ctx *gin.Context) {
+	dryRun := ctx.Query("dryRun") == "true" || ctx.Query("dryRun") == "1"
+	v := validation.New()
+
+	var rows []*importRow
+	var err error
+
+	if ctx.ContentType() == importCsvMediaType {
+		rows, err = readCsvRows(ctx.Request.Body, v)
+	} else {
+		err = json.NewDecoder(ctx.Request.Body).Decode(&rows)
+	}
+
+	if err == nil && len(rows) == 0 {
+		err = errors.New("no rows")
+	}
+
+	if err == nil && len(rows) > importMaxRows {
+		err = errors.Errorf("more than %d rows", importMaxRows)
+	}
+
+	if err != nil {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.Wrap(err, "invalid body"))
+		return
+	}
+
+	endpoints, err := rt.endpointIds()
+
+	if err != nil {
+		rt.logger.Error("Failed to find endpoints", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	entries := rt.toEntries(v, rows, endpoints)
+
+	if err := v.Err(); err != nil {
+		rt.logger.Error("Invalid import", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ids, err := rt.storage.ImportPeripherals(entries, dryRun)
+
+	if err != nil {
+		if failed, ok := err.(*storage.ImportError); ok {
+			err = errors.Wrapf(failed.Err, "rows[%d]", failed.Index)
+		}
+
+		rt.logger.Error("Failed to import peripherals", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	if dryRun {
+		ids = nil
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"quantity": len(entries),
+		"ids":      ids,
+	})
+}
+
+// exportPeripherals responds with a csv of all peripherals which can be imported back
+func (rt *PeripheralsRoute) exportPeripherals(ctx *gin.Context) {
+	entries, err := rt.storage.ExportPeripherals()
+
+	if err != nil {
+		rt.logger.Error("Failed to export peripherals", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	records := make([][]string, 0, len(entries)+1)
+	records = append(records, importColumns)
+
+	for _, entry := range entries {
+		dto, err := rt.serializePeripheral(entry.Peripheral, nil)
+
+		if err != nil {
+			serverHttp.AbortWithError(ctx, http.StatusInternalServerError, ErrPeripheralsRouteInvalidModel)
+			return
+		}
+
+		record, err := toCsvRecord(dto, entry.Subscribers)
+
+		if err != nil {
+			serverHttp.AbortWithCause(ctx, err)
+			return
+		}
+
+		records = append(records, record)
+	}
+
+	ctx.Header("Content-Type", importCsvMediaType+"; charset=utf-8")
+	ctx.Header("Content-Disposition", `attachment; filename="peripherals.csv"`)
+	ctx.Status(http.StatusOK)
+
+	if err := csv.NewWriter(ctx.Writer).WriteAll(records); err != nil {
+		rt.logger.Error("Failed to write peripherals", zap.Error(err))
+	}
+}
+
+// endpointIds maps names of all endpoints to their ids
+func (rt *PeripheralsRoute) endpointIds() (map[string]uint64, error) {
+	endpoints, _, _, err := rt.storage.FindEndpoints(&storage.EndpointQuery{
+		Pagination: storage.NewPagination(0, 0),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]uint64, len(endpoints))
+
+	for _, endpoint := range endpoints {
+		ids[endpoint.Name] = endpoint.Id
+	}
+
+	return ids, nil
+}
+
+// toEntries validates rows like single peripherals, errors are reported as fields of rows[index]
+func (rt *PeripheralsRoute) toEntries(v *validation.Validator, rows []*importRow, endpoints map[string]uint64) []*storage.PeripheralEntry {
+	entries := make([]*storage.PeripheralEntry, 0, len(rows))
+	names := make(map[string]int, len(rows))
+	keys := make(map[string]int, len(rows))
+
+	for idx, row := range rows {
+		prefix := fmt.Sprintf("rows[%d].", idx)
+
+		if row == nil {
+			v.Add(prefix+"name", "is required")
+			continue
+		}
+
+		dto := row.toDto(v, prefix, endpoints)
+		peripheral, err := rt.toPeripheral(dto)
+
+		v.Nest(prefix, err)
+
+		if err != nil {
+			continue
+		}
+
+		if other, exists := names[peripheral.Name]; exists {
+			v.Add(prefix+"name", fmt.Sprintf("is already used by rows[%d]", other))
+		}
+
+		if other, exists := keys[peripheral.Key]; exists {
+			v.Add(prefix+"key", fmt.Sprintf("matches the same peripherals as rows[%d]", other))
+		}
+
+		names[peripheral.Name] = idx
+		keys[peripheral.Key] = idx
+
+		entries = append(entries, &storage.PeripheralEntry{
+			Peripheral:  peripheral,
+			Subscribers: dto.Subscribers,
+		})
+	}
+
+	return entries
+}
+
+// toDto resolves endpoints of subscribers by their names
+func (row *importRow) toDto(v *validation.Validator, prefix string, endpoints map[string]uint64) *Dto {
+	dto := &Dto{
+		Kind:        strings.TrimSpace(row.Kind),
+		Name:        row.Name,
+		Enabled:     row.Enabled == nil || *row.Enabled,
+		Pattern:     row.Pattern,
+		Uuid:        row.Uuid,
+		Major:       row.Major,
+		MajorMax:    row.MajorMax,
+		Minor:       row.Minor,
+		MinorMax:    row.MinorMax,
+		Address:     row.Address,
+		Subscribers: make([]*notification.Subscriber, 0, len(row.Subscribers)),
+	}
+
+	if dto.Kind == "" {
+		dto.Kind = peripherals.PERIPHERAL_IBEACON
+	}
+
+	for idx, subscriber := range row.Subscribers {
+		field := fmt.Sprintf("%ssubscribers[%d].endpoint", prefix, idx)
+
+		if subscriber == nil {
+			dto.Subscribers = append(dto.Subscribers, nil)
+			continue
+		}
+
+		id, exists := endpoints[strings.TrimSpace(subscriber.Endpoint)]
+
+		v.Check(field, exists || subscriber.Endpoint == "", "does not exist")
+
+		dto.Subscribers = append(dto.Subscribers, &notification.Subscriber{
+			Name:     strings.TrimSpace(subscriber.Name),
+			Event:    strings.TrimSpace(subscriber.Event),
+			Enabled:  subscriber.Enabled == nil || *subscriber.Enabled,
+			Batch:    subscriber.Batch,
+			Endpoint: &notification.Endpoint{Id: id},
+		})
+	}
+
+	return dto
+}
+
+// readCsvRows reads rows of a csv with a header, invalid values are added to a validator.
+// Subscribers are given either by a json array or by a list of "event:endpoint" separated by ";".
+func readCsvRows(body io.Reader, v *validation.Validator) ([]*importRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err == io.EOF {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, column := range header {
+		header[idx] = strings.TrimSpace(column)
+
+		if !isOneOf(header[idx], importColumns) {
+			return nil, errors.Errorf("unknown column: '%s'", column)
+		}
+	}
+
+	if !isOneOf("name", header) {
+		return nil, errors.New("missed column: 'name'")
+	}
+
+	rows := make([]*importRow, 0, 100)
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			return rows, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rows) == importMaxRows {
+			return nil, errors.Errorf("more than %d rows", importMaxRows)
+		}
+
+		prefix := fmt.Sprintf("rows[%d].", len(rows))
+		row := &importRow{}
+
+		for idx, value := range record {
+			if err := row.set(header[idx], strings.TrimSpace(value)); err != nil {
+				v.Add(prefix+header[idx], err.Error())
+			}
+		}
+
+		rows = append(rows, row)
+	}
+}
+
+func (row *importRow) set(column, value string) error {
+	var err error
+
+	switch column {
+	case "kind":
+		row.Kind = value
+	case "name":
+		row.Name = value
+	case "pattern":
+		row.Pattern, err = parseBool(value)
+	case "uuid":
+		row.Uuid = value
+	case "address":
+		row.Address = value
+	case "major":
+		row.Major, err = parseNumber(value)
+	case "majorMax":
+		row.MajorMax, err = parseNumber(value)
+	case "minor":
+		row.Minor, err = parseNumber(value)
+	case "minorMax":
+		row.MinorMax, err = parseNumber(value)
+	case "enabled":
+		row.Enabled, err = parseOptionalBool(value)
+	case "subscribers":
+		row.Subscribers, err = parseSubscriberRows(value)
+	}
+
+	return err
+}
+
+func parseNumber(value string) (uint16, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	num, err := strconv.ParseUint(value, 10, 16)
+
+	if err != nil {
+		return 0, errors.New("must be a number from 0 to 65535")
+	}
+
+	return uint16(num), nil
+}
+
+func parseBool(value string) (bool, error) {
+	res, err := parseOptionalBool(value)
+
+	if err != nil || res == nil {
+		return false, err
+	}
+
+	return *res, nil
+}
+
+func parseOptionalBool(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	res, err := strconv.ParseBool(value)
+
+	if err != nil {
+		return nil, errors.New("must be true or false")
+	}
+
+	return &res, nil
+}
+
+// parseSubscriberRows parses a json array or "event:endpoint" pairs separated by ";",
+// subscribers given by pairs are enabled and named after their events
+func parseSubscriberRows(value string) ([]*importSubscriberRow, error) {
+	var subscribers []*importSubscriberRow
+
+	if value == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &subscribers); err != nil {
+			return nil, errors.New("must be a json array or event:endpoint pairs separated by ';'")
+		}
+
+		return subscribers, nil
+	}
+
+	for _, pair := range strings.Split(value, ";") {
+		parts := strings.SplitN(pair, ":", 2)
+
+		if len(parts) != 2 {
+			return nil, errors.Errorf("must be a json array or event:endpoint pairs separated by ';', got '%s'", pair)
+		}
+
+		event := strings.TrimSpace(parts[0])
+
+		subscribers = append(subscribers, &importSubscriberRow{
+			Name:     event,
+			Event:    event,
+			Endpoint: strings.TrimSpace(parts[1]),
+		})
+	}
+
+	return subscribers, nil
+}
+
+// toCsvRecord writes subscribers as a json array, so that their names, statuses and batches are kept
+func toCsvRecord(dto *Dto, subscribers []*notification.Subscriber) ([]string, error) {
+	cell := ""
+
+	if len(subscribers) > 0 {
+		rows := make([]*importSubscriberRow, 0, len(subscribers))
+
+		for _, subscriber := range subscribers {
+			enabled := subscriber.Enabled
+
+			rows = append(rows, &importSubscriberRow{
+				Name:     subscriber.Name,
+				Event:    subscriber.Event,
+				Endpoint: subscriber.Endpoint.Name,
+				Enabled:  &enabled,
+				Batch:    subscriber.Batch,
+			})
+		}
+
+		data, err := json.Marshal(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		cell = string(data)
+	}
+
+	return []string{
+		dto.Kind,
+		dto.Name,
+		strconv.FormatBool(dto.Pattern),
+		dto.Uuid,
+		formatNumber(dto.Major),
+		formatNumber(dto.MajorMax),
+		formatNumber(dto.Minor),
+		formatNumber(dto.MinorMax),
+		dto.Address,
+		strconv.FormatBool(dto.Enabled),
+		cell,
+	}, nil
+}
+
+func formatNumber(num uint16) string {
+	if num == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(int(num))
+}
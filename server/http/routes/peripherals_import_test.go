@@ -0,0 +1,110 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func callCsv(engine *gin.Engine, url, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+
+	res := httptest.NewRecorder()
+	engine.ServeHTTP(res, req)
+
+	return res
+}
+
+func TestPeripheralsImport(t *testing.T) {
+	engine, cleanup := createRegistryEngine(t)
+	defer cleanup()
+
+	res := call(engine, http.MethodPost, "/api/registry/endpoint", "", map[string]interface{}{
+		"name":   "hook",
+		"url":    "http://localhost/hook",
+		"method": "POST",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = callCsv(engine, "/api/registry/peripherals/import", "name,color\nkitchen,red\n")
+	assert.Equal(t, http.StatusBadRequest, res.Code, "unknown column")
+
+	res = callCsv(engine, "/api/registry/peripherals/import", "name,uuid\n")
+	assert.Equal(t, http.StatusBadRequest, res.Code, "no rows")
+
+	invalid := "name,uuid,major,minor,enabled,subscribers\n" +
+		"kitchen,f7826da64fa24e988024bc5b71e0893e,1,1,true,found:hook\n" +
+		"hall,abc,1,2,maybe,lost:missing\n" +
+		"hall,f7826da64fa24e988024bc5b71e0893e,1,70000,,\n" +
+		"kitchen,f7826da64fa24e988024bc5b71e0893e,1,3,,\n"
+
+	res = callCsv(engine, "/api/registry/peripherals/import", invalid)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "invalid rows")
+	assert.ElementsMatch(t, []string{
+		"rows[1].uuid",
+		"rows[1].enabled",
+		"rows[1].subscribers[0].endpoint",
+		"rows[2].minor",
+		"rows[3].name",
+	}, fieldNames(decodeError(t, res)))
+
+	valid := "kind,name,pattern,uuid,major,minor,enabled,subscribers\n" +
+		"ibeacon,kitchen,,f7826da64fa24e988024bc5b71e0893e,1,1,true,found:hook;lost:hook\n" +
+		`ibeacon,hall,false,f7826da64fa24e988024bc5b71e0893e,1,2,false,"[{""name"":""arrival"",""event"":""found"",""endpoint"":""hook"",""enabled"":false}]"` + "\n" +
+		"ibeacon,floor,true,f7826da64fa24e988024bc5b71e0893e,2,,,\n"
+
+	res = callCsv(engine, "/api/registry/peripherals/import?dryRun=true", valid)
+	assert.Equal(t, http.StatusOK, res.Code, "dry run")
+	assert.JSONEq(t, `{"quantity":3,"ids":null}`, res.Body.String())
+	assert.Empty(t, decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals", "", nil)).names(), "dry run changes nothing")
+
+	res = callCsv(engine, "/api/registry/peripherals/import", valid)
+	assert.Equal(t, http.StatusOK, res.Code, "import")
+	assert.ElementsMatch(t, []string{"kitchen", "hall", "floor"}, decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals", "", nil)).names())
+
+	res = call(engine, http.MethodGet, "/api/registry/subscribers", "", nil)
+	assert.ElementsMatch(t, []string{"found", "lost", "arrival"}, decodePage(t, res).names())
+
+	res = call(engine, http.MethodPost, "/api/registry/peripherals/import", "", []map[string]interface{}{
+		{"name": "porch", "uuid": "f7826da64fa24e988024bc5b71e0893e", "major": 3, "minor": 1},
+		{"name": "kitchen", "uuid": "f7826da64fa24e988024bc5b71e0893e", "major": 3, "minor": 2},
+	})
+	assert.Equal(t, http.StatusConflict, res.Code, "existing name")
+	assert.Contains(t, decodeError(t, res).Message, "rows[1]")
+	assert.Len(t, decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals", "", nil)).names(), 3, "nothing is created on failure")
+
+	res = call(engine, http.MethodPost, "/api/registry/peripherals/import", "", []map[string]interface{}{
+		{"name": "porch", "pattern": true, "address": "aa:bb", "subscribers": []map[string]interface{}{
+			{"name": "found", "event": "found", "endpoint": "hook"},
+		}},
+	})
+	assert.Equal(t, http.StatusOK, res.Code, "json import")
+
+	res = call(engine, http.MethodGet, "/api/registry/peripherals/export", "", nil)
+	assert.Equal(t, http.StatusOK, res.Code, "export")
+	assert.Contains(t, res.Header().Get("Content-Type"), "text/csv")
+
+	exported := res.Body.String()
+	assert.True(t, strings.HasPrefix(exported, "kind,name,pattern,uuid,major,majorMax,minor,minorMax,address,enabled,subscribers\n"))
+
+	other, cleanupOther := createRegistryEngine(t)
+	defer cleanupOther()
+
+	res = call(other, http.MethodPost, "/api/registry/endpoint", "", map[string]interface{}{
+		"name":   "hook",
+		"url":    "http://localhost/hook",
+		"method": "POST",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = callCsv(other, "/api/registry/peripherals/import", exported)
+	assert.Equal(t, http.StatusOK, res.Code, "import of an export: %s", res.Body.String())
+
+	res = call(other, http.MethodGet, "/api/registry/peripherals/export", "", nil)
+	assert.Equal(t, exported, res.Body.String(), "export of an import")
+}
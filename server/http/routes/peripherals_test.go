@@ -0,0 +1,183 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeripheralsRouteErrors(t *testing.T) {
+	engine, cleanup := createRegistryEngine(t)
+	defer cleanup()
+
+	res := call(engine, http.MethodGet, "/api/registry/peripheral/1", "", nil)
+	assert.Equal(t, http.StatusNotFound, res.Code, "missing peripheral")
+	assert.Equal(t, "not_found", decodeError(t, res).Code)
+
+	res = call(engine, http.MethodGet, "/api/registry/peripheral/first", "", nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "invalid id")
+	assert.Equal(t, "invalid parameter: id", decodeError(t, res).Message)
+
+	res = call(engine, http.MethodGet, "/api/registry/peripherals?take=many", "", nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "invalid take")
+
+	res = call(engine, http.MethodPost, "/api/registry/peripheral", "", nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "empty body")
+	assert.Equal(t, "bad_request", decodeError(t, res).Code)
+
+	res = call(engine, http.MethodPost, "/api/registry/peripheral", "", map[string]interface{}{
+		"kind": "ibeacon",
+		"uuid": "abc",
+		"subscribers": []map[string]interface{}{
+			{"event": "found"},
+		},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "invalid fields")
+
+	body := decodeError(t, res)
+	assert.Equal(t, "unprocessable_entity", body.Code)
+	assert.ElementsMatch(t, []string{"name", "uuid", "major", "minor", "subscribers[0].endpoint"}, fieldNames(body))
+
+	peripheral := map[string]interface{}{
+		"kind":  "ibeacon",
+		"name":  "kitchen",
+		"uuid":  "f7826da64fa24e988024bc5b71e0893e",
+		"major": 1,
+		"minor": 2,
+	}
+
+	res = call(engine, http.MethodPost, "/api/registry/peripheral", "", peripheral)
+	assert.Equal(t, http.StatusOK, res.Code, "valid peripheral")
+
+	wildcard := map[string]interface{}{
+		"kind":  "ibeacon",
+		"name":  "fleet",
+		"uuid":  "f7826da64fa24e988024bc5b71e0893e",
+		"major": 1,
+	}
+
+	res = call(engine, http.MethodPost, "/api/registry/peripheral", "", wildcard)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "omitted minor of an exact registration")
+	assert.Equal(t, []string{"minor"}, fieldNames(decodeError(t, res)))
+
+	wildcard["address"] = "aa:bb"
+	delete(wildcard, "uuid")
+	delete(wildcard, "major")
+
+	res = call(engine, http.MethodPost, "/api/registry/peripheral", "", wildcard)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "address prefix of an exact registration")
+	assert.Equal(t, []string{"pattern"}, fieldNames(decodeError(t, res)))
+
+	wildcard["pattern"] = true
+
+	res = call(engine, http.MethodPost, "/api/registry/peripheral", "", wildcard)
+	assert.Equal(t, http.StatusOK, res.Code, "pattern registration")
+
+	peripheral["minor"] = 3
+
+	res = call(engine, http.MethodPost, "/api/registry/peripheral", "", peripheral)
+	assert.Equal(t, http.StatusConflict, res.Code, "duplicate name")
+	assert.Contains(t, decodeError(t, res).Message, "name")
+
+	peripheral["id"] = 100
+	peripheral["name"] = "hall"
+
+	res = call(engine, http.MethodPut, "/api/registry/peripheral", "", peripheral)
+	assert.Equal(t, http.StatusNotFound, res.Code, "update of missing peripheral")
+}
+
+func TestRegistryRouteSearch(t *testing.T) {
+	engine, cleanup := createRegistryEngine(t)
+	defer cleanup()
+
+	res := call(engine, http.MethodPost, "/api/registry/endpoint", "", map[string]interface{}{
+		"name":   "hook",
+		"url":    "http://localhost/hook",
+		"method": "POST",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	endpointId := json.Number(res.Body.String())
+	uuid := "f7826da64fa24e988024bc5b71e0893e"
+
+	for idx, name := range []string{"kitchen", "hall", "bedroom", "kitchen_2"} {
+		peripheral := map[string]interface{}{
+			"kind":    "ibeacon",
+			"name":    name,
+			"enabled": idx != 1,
+			"uuid":    uuid,
+			"major":   idx%2 + 1,
+			"minor":   idx + 1,
+		}
+
+		if idx == 0 {
+			peripheral["subscribers"] = []map[string]interface{}{
+				{"name": "lost", "event": "lost", "enabled": true, "endpoint": map[string]interface{}{"id": endpointId}},
+			}
+		}
+
+		res = call(engine, http.MethodPost, "/api/registry/peripheral", "", peripheral)
+		assert.Equal(t, http.StatusOK, res.Code, name)
+	}
+
+	body := decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals?search=kitchen", "", nil))
+	assert.Equal(t, []string{"kitchen", "kitchen_2"}, body.names(), "search")
+	assert.Equal(t, uint64(2), body.Quantity)
+	assert.Nil(t, body.Next, "all items are returned")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals?search=n_", "", nil))
+	assert.Equal(t, []string{"kitchen_2"}, body.names(), "wildcards are escaped")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals?enabled=false", "", nil))
+	assert.Equal(t, []string{"hall"}, body.names(), "status")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals?uuid="+uuid+"&major=2", "", nil))
+	assert.Equal(t, []string{"hall", "kitchen_2"}, body.names(), "uuid and major")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals?event=lost", "", nil))
+	assert.Equal(t, []string{"kitchen"}, body.names(), "event")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals?sort=name&order=desc", "", nil))
+	assert.Equal(t, []string{"kitchen_2", "kitchen", "hall", "bedroom"}, body.names(), "sorting")
+
+	names := make([]string, 0, 4)
+	url := "/api/registry/peripherals?sort=name&take=3"
+
+	for i := 0; i < 3; i++ {
+		body = decodePage(t, call(engine, http.MethodGet, url, "", nil))
+		assert.Equal(t, uint64(4), body.Quantity, "quantity does not depend on a page")
+
+		names = append(names, body.names()...)
+
+		if body.Next == nil {
+			break
+		}
+
+		url = "/api/registry/peripherals?sort=name&take=3&cursor=" + *body.Next
+	}
+
+	assert.Equal(t, []string{"bedroom", "hall", "kitchen", "kitchen_2"}, names, "cursor pagination")
+
+	res = call(engine, http.MethodGet, "/api/registry/peripherals?sort=uuid", "", nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "unsupported sort field")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/peripherals?take=1", "", nil))
+
+	res = call(engine, http.MethodGet, "/api/registry/peripherals?sort=key&cursor="+*body.Next, "", nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "cursor of another sort field")
+
+	res = call(engine, http.MethodGet, "/api/registry/peripherals?cursor=garbage", "", nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "invalid cursor")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/endpoints?search=localhost&sort=url", "", nil))
+	assert.Equal(t, []string{"hook"}, body.names(), "endpoints")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/subscribers?endpoint="+endpointId.String()+"&event=lost", "", nil))
+	assert.Equal(t, []string{"lost"}, body.names(), "subscribers")
+	assert.NotZero(t, body.Items[0].TargetId, "subscriber owner")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/subscribers?event=found", "", nil))
+	assert.Empty(t, body.Items)
+}
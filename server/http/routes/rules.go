@@ -0,0 +1,455 @@
+package routes
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/validation"
+	"github.com/blent/beagle/server/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var candidateStatuses = []string{
+	tracking.CANDIDATE_STATUS_PENDING,
+	tracking.CANDIDATE_STATUS_REGISTERED,
+	tracking.CANDIDATE_STATUS_REJECTED,
+	tracking.CANDIDATE_STATUS_FAILED,
+}
+
+type (
+	// RuleDto describes matched peripherals the same way as Dto describes pattern registrations.
+	// Subscribers are copied to every peripheral registered by the rule.
+	RuleDto struct {
+		Id                uint64                     `json:"id"`
+		Name              string                     `json:"name"`
+		Enabled           bool                       `json:"enabled"`
+		Mode              string                     `json:"mode"`
+		NameTemplate      string                     `json:"nameTemplate"`
+		PeripheralEnabled bool                       `json:"peripheralEnabled"`
+		Uuid              string                     `json:"uuid,omitempty"`
+		Major             uint16                     `json:"major,omitempty"`
+		MajorMax          uint16                     `json:"majorMax,omitempty"`
+		Minor             uint16                     `json:"minor,omitempty"`
+		MinorMax          uint16                     `json:"minorMax,omitempty"`
+		Address           string                     `json:"address,omitempty"`
+		Subscribers       []*notification.Subscriber `json:"subscribers"`
+	}
+
+	ApprovalDto struct {
+		Name string `json:"name"`
+	}
+
+	RulesRoute struct {
+		baseUrl string
+		logger  *zap.Logger
+		storage *storage.Manager
+	}
+)
+
+func NewRulesRoute(baseUrl string, logger *zap.Logger, storage *storage.Manager) *RulesRoute {
+	return &RulesRoute{
+		baseUrl,
+		logger,
+		storage,
+	}
+}
+
+func (rt *RulesRoute) Use(routes gin.IRoutes) {
+	singular := "rule"
+	plural := "rules"
+
+	// Get multiple rules
+	routes.GET(path.Join("/", rt.baseUrl, plural), rt.findRules)
+
+	// Get single rule by id
+	routes.GET(path.Join("/", rt.baseUrl, singular, ":id"), rt.getRule)
+
+	// Create new rule
+	routes.POST(path.Join("/", rt.baseUrl, singular), rt.createRule)
+
+	// Update existing rule by id
+	routes.PUT(path.Join("/", rt.baseUrl, singular), rt.updateRule)
+
+	// Delete existing rule by id
+	routes.DELETE(path.Join("/", rt.baseUrl, singular, ":id"), rt.deleteRule)
+
+	// Delete multiple rules by id
+	routes.DELETE(path.Join("/", rt.baseUrl, plural), rt.deleteRules)
+
+	// Get candidates matched by rules, the review queue and the audit trail
+	routes.GET(path.Join("/", rt.baseUrl, "candidates"), rt.findCandidates)
+
+	// Register a pending or failed candidate
+	routes.POST(path.Join("/", rt.baseUrl, "candidate", ":id", "approve"), rt.approveCandidate)
+
+	// Reject a pending or failed candidate
+	routes.POST(path.Join("/", rt.baseUrl, "candidate", ":id", "reject"), rt.rejectCandidate)
+
+	// Delete a candidate, so that its peripheral is matched by rules again
+	routes.DELETE(path.Join("/", rt.baseUrl, "candidate", ":id"), rt.deleteCandidate)
+}
+
+func (rt *RulesRoute) findRules(ctx *gin.Context) {
+	take, skip, ok := parsePagination(ctx)
+
+	if !ok {
+		return
+	}
+
+	status, ok := parseStatus(ctx)
+
+	if !ok {
+		return
+	}
+
+	rules, quantity, err := rt.storage.FindRules(&storage.RuleQuery{
+		Pagination: storage.NewPagination(take, skip),
+		RuleFilter: &storage.RuleFilter{Status: status},
+	})
+
+	if err != nil {
+		rt.logger.Error("failed to find rules", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"items":    rules,
+		"quantity": quantity,
+	})
+}
+
+func (rt *RulesRoute) getRule(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	rule, subscribers, err := rt.storage.GetRuleWithSubscribers(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve rule",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	if rule == nil {
+		serverHttp.AbortWithCause(ctx, storage.ErrRuleNotFound)
+		return
+	}
+
+	dto, err := rt.serializeRule(rule, subscribers)
+
+	if err != nil {
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto)
+}
+
+func (rt *RulesRoute) createRule(ctx *gin.Context) {
+	rule, subscribers, ok := rt.deserializeRule(ctx)
+
+	if !ok {
+		return
+	}
+
+	id, err := rt.storage.CreateRule(rule, subscribers)
+
+	if err != nil {
+		rt.logger.Error("Failed to create new rule", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "%d", id)
+}
+
+func (rt *RulesRoute) updateRule(ctx *gin.Context) {
+	rule, subscribers, ok := rt.deserializeRule(ctx)
+
+	if !ok {
+		return
+	}
+
+	if rule.Id == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id"))
+		return
+	}
+
+	err := rt.storage.UpdateRule(rule, subscribers)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to update rule",
+			zap.Uint64("id", rule.Id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *RulesRoute) deleteRule(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	err := rt.storage.DeleteRules([]uint64{id})
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to delete rule",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *RulesRoute) deleteRules(ctx *gin.Context) {
+	var ids []uint64
+
+	if !serverHttp.BindJSON(ctx, &ids) {
+		return
+	}
+
+	if len(ids) == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id(s)"))
+		return
+	}
+
+	err := rt.storage.DeleteRules(ids)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to delete rules",
+			zap.Uint64s("ids", ids),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *RulesRoute) findCandidates(ctx *gin.Context) {
+	take, skip, ok := parsePagination(ctx)
+
+	if !ok {
+		return
+	}
+
+	status := ctx.Query("status")
+
+	if status != "" && !isOneOf(status, candidateStatuses) {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("invalid parameter: status"))
+		return
+	}
+
+	ruleId, ok := parseOptionalId(ctx, "rule")
+
+	if !ok {
+		return
+	}
+
+	candidates, quantity, err := rt.storage.FindCandidates(&storage.CandidateQuery{
+		Pagination:      storage.NewPagination(take, skip),
+		CandidateFilter: &storage.CandidateFilter{Status: status, RuleId: ruleId},
+	})
+
+	if err != nil {
+		rt.logger.Error("failed to find candidates", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"items":    candidates,
+		"quantity": quantity,
+	})
+}
+
+// approveCandidate accepts an optional body with a name replacing the rendered one
+func (rt *RulesRoute) approveCandidate(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	var dto ApprovalDto
+
+	if ctx.Request.ContentLength != 0 && !serverHttp.BindJSON(ctx, &dto) {
+		return
+	}
+
+	candidate, err := rt.storage.ApproveCandidate(id, strings.TrimSpace(dto.Name), rt.reviewer(ctx))
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to approve candidate",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, candidate)
+}
+
+func (rt *RulesRoute) rejectCandidate(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	candidate, err := rt.storage.RejectCandidate(id, rt.reviewer(ctx))
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to reject candidate",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, candidate)
+}
+
+func (rt *RulesRoute) deleteCandidate(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	err := rt.storage.DeleteCandidate(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to delete candidate",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+// reviewer is empty when authentication is disabled
+func (rt *RulesRoute) reviewer(ctx *gin.Context) string {
+	user := serverHttp.GetUser(ctx)
+
+	if user == nil {
+		return ""
+	}
+
+	return user.Username
+}
+
+func (rt *RulesRoute) serializeRule(rule *tracking.Rule, subscribers []*notification.Subscriber) (*RuleDto, error) {
+	pattern, err := tracking.ParsePattern(rule.Key)
+
+	if err != nil {
+		rt.logger.Error("Failed to serialize rule", zap.Error(err))
+
+		return nil, err
+	}
+
+	dto := &RuleDto{
+		Id:                rule.Id,
+		Name:              rule.Name,
+		Enabled:           rule.Enabled,
+		Mode:              rule.Mode,
+		NameTemplate:      rule.NameTemplate,
+		PeripheralEnabled: rule.PeripheralEnabled,
+		Subscribers:       subscribers,
+	}
+
+	if pattern.IsAddress() {
+		dto.Address = pattern.AddressPrefix()
+
+		return dto, nil
+	}
+
+	dto.Uuid = pattern.Uuid
+	dto.Major, dto.MajorMax = fromRange(pattern.Major)
+	dto.Minor, dto.MinorMax = fromRange(pattern.Minor)
+
+	return dto, nil
+}
+
+// deserializeRule responds with 400 for a malformed body and 422 for invalid fields
+func (rt *RulesRoute) deserializeRule(ctx *gin.Context) (*tracking.Rule, []*notification.Subscriber, bool) {
+	var dto RuleDto
+
+	if !serverHttp.BindJSON(ctx, &dto) {
+		return nil, nil, false
+	}
+
+	dto.Name = strings.TrimSpace(dto.Name)
+	dto.NameTemplate = strings.TrimSpace(dto.NameTemplate)
+
+	v := validation.New()
+
+	v.Required("name", dto.Name)
+	v.Required("nameTemplate", dto.NameTemplate)
+	v.OneOf("mode", dto.Mode, tracking.RULE_MODE_ACCEPT, tracking.RULE_MODE_REVIEW)
+
+	pattern := toPattern(v, &Dto{
+		Uuid:     dto.Uuid,
+		Major:    dto.Major,
+		MajorMax: dto.MajorMax,
+		Minor:    dto.Minor,
+		MinorMax: dto.MinorMax,
+		Address:  dto.Address,
+	})
+
+	validateSubscribers(v, dto.Subscribers, false)
+
+	if err := v.Err(); err != nil {
+		rt.logger.Error("Invalid rule", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+
+		return nil, nil, false
+	}
+
+	rule := &tracking.Rule{
+		Id:                dto.Id,
+		Name:              dto.Name,
+		Enabled:           dto.Enabled,
+		Key:               pattern.Key(),
+		NameTemplate:      dto.NameTemplate,
+		Mode:              dto.Mode,
+		PeripheralEnabled: dto.PeripheralEnabled,
+	}
+
+	return rule, dto.Subscribers, true
+}
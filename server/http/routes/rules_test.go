@@ -0,0 +1,140 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/blent/beagle/server"
+	"github.com/blent/beagle/server/http/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRulesRoute(t *testing.T) {
+	manager, cleanup := createManager(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+
+	routes.NewEndpointsRoute("/api/registry", zap.NewNop(), manager, nil).Use(engine)
+	routes.NewRulesRoute("/api/registry", zap.NewNop(), manager).Use(engine)
+
+	res := call(engine, http.MethodPost, "/api/registry/rule", "", map[string]interface{}{
+		"name":     "badges",
+		"mode":     "auto",
+		"minorMax": 5,
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "invalid rule")
+	assert.ElementsMatch(t, []string{"nameTemplate", "mode", "uuid", "minor"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodPost, "/api/registry/endpoint", "", map[string]interface{}{
+		"name":   "hook",
+		"url":    "http://localhost/hook",
+		"method": "POST",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	endpointId := json.Number(res.Body.String())
+	uuid := "b9407f30f5f8466eaff925556b57fe6d"
+
+	res = call(engine, http.MethodPost, "/api/registry/rule", "", map[string]interface{}{
+		"name":         "badges",
+		"enabled":      true,
+		"mode":         "review",
+		"nameTemplate": "badge-{minor}",
+		"uuid":         uuid,
+		"major":        1,
+		"subscribers": []map[string]interface{}{{
+			"name":     "arrival",
+			"event":    "found",
+			"enabled":  true,
+			"endpoint": map[string]interface{}{"id": endpointId},
+		}},
+	})
+	assert.Equal(t, http.StatusOK, res.Code, "rule")
+
+	ruleId := res.Body.String()
+
+	res = call(engine, http.MethodGet, "/api/registry/rule/"+ruleId, "", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var rule routes.RuleDto
+
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &rule))
+	assert.Equal(t, uuid, rule.Uuid)
+	assert.Equal(t, uint16(1), rule.Major)
+	assert.Len(t, rule.Subscribers, 1, "subscribers")
+
+	for minor := uint16(1); minor <= 2; minor++ {
+		target, err := server.NewRuleRegistrar(manager).Register(
+			createTestPeripheral(peripherals.CreateIBeaconUniqueKey(uuid, 1, minor)),
+		)
+
+		assert.NoError(t, err)
+		assert.Nil(t, target, "pending")
+	}
+
+	res = call(engine, http.MethodGet, "/api/registry/candidates?status=pending", "", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	candidates := decodePage(t, res)
+
+	assert.Equal(t, []string{"badge-2", "badge-1"}, candidates.names(), "newest first")
+
+	ids := []uint64{candidates.Items[0].Id, candidates.Items[1].Id}
+
+	res = call(engine, http.MethodPost, fmt.Sprintf("/api/registry/candidate/%d/approve", ids[1]), "", map[string]string{
+		"name": "front desk",
+	})
+	assert.Equal(t, http.StatusOK, res.Code, "approve")
+
+	var candidate tracking.Candidate
+
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &candidate))
+	assert.Equal(t, tracking.CANDIDATE_STATUS_REGISTERED, candidate.Status)
+	assert.Equal(t, "front desk", candidate.Name)
+
+	target, subscribers, err := manager.GetPeripheralWithSubscribers(candidate.TargetId)
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, target, "registered peripheral") {
+		assert.Equal(t, "front desk", target.Name)
+		assert.Len(t, subscribers, 1, "copied subscribers")
+	}
+
+	res = call(engine, http.MethodPost, fmt.Sprintf("/api/registry/candidate/%d/reject", ids[1]), "", nil)
+	assert.Equal(t, http.StatusConflict, res.Code, "already decided")
+
+	res = call(engine, http.MethodPost, fmt.Sprintf("/api/registry/candidate/%d/reject", ids[0]), "", nil)
+	assert.Equal(t, http.StatusOK, res.Code, "reject")
+
+	res = call(engine, http.MethodGet, "/api/registry/candidates?status=pending", "", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Empty(t, decodePage(t, res).names(), "review queue")
+
+	res = call(engine, http.MethodGet, "/api/registry/candidates?status=unknown", "", nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "invalid status")
+
+	res = call(engine, http.MethodDelete, "/api/registry/rule/"+ruleId, "", nil)
+	assert.Equal(t, http.StatusOK, res.Code, "delete")
+
+	res = call(engine, http.MethodGet, "/api/registry/candidates", "", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Len(t, decodePage(t, res).names(), 2, "audit trail")
+
+	res = call(engine, http.MethodDelete, fmt.Sprintf("/api/registry/candidate/%d", ids[0]), "", nil)
+	assert.Equal(t, http.StatusOK, res.Code, "delete candidate")
+
+	res = call(engine, http.MethodDelete, fmt.Sprintf("/api/registry/candidate/%d", ids[0]), "", nil)
+	assert.Equal(t, http.StatusNotFound, res.Code, "deleted candidate")
+}
+
+// claimerFunc claims peripherals by a function
@@ -0,0 +1,348 @@
+package routes
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/blent/beagle/pkg/notification"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/validation"
+	"github.com/blent/beagle/server/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type SubscribersRoute struct {
+	baseUrl string
+	logger  *zap.Logger
+	storage *storage.Manager
+}
+
+func NewSubscribersRoute(baseUrl string, logger *zap.Logger, storage *storage.Manager) *SubscribersRoute {
+	return &SubscribersRoute{baseUrl, logger, storage}
+}
+
+func (rt *SubscribersRoute) Use(routes gin.IRoutes) {
+	singular := "subscriber"
+	plural := "subscribers"
+
+	// Get multiple subscribers of peripherals and groups, subscribers of rules are only listed by a rule
+	routes.GET(path.Join("/", rt.baseUrl, plural), rt.findSubscribers)
+
+	// Get single subscriber by id
+	routes.GET(path.Join("/", rt.baseUrl, singular, ":id"), rt.getSubscriber)
+
+	// Create new subscriber of a peripheral or a group
+	routes.POST(path.Join("/", rt.baseUrl, singular), rt.createSubscriber)
+
+	// Update existing subscriber by id
+	routes.PUT(path.Join("/", rt.baseUrl, singular), rt.updateSubscriber)
+
+	// Delete existing subscriber by id
+	routes.DELETE(path.Join("/", rt.baseUrl, singular, ":id"), rt.deleteSubscriber)
+
+	// Delete multiple subscribers by id
+	routes.DELETE(path.Join("/", rt.baseUrl, plural), rt.deleteSubscribers)
+
+	// Enable multiple subscribers by id
+	routes.POST(path.Join("/", rt.baseUrl, plural, "enable"), rt.setEnabled(true))
+
+	// Disable multiple subscribers by id
+	routes.POST(path.Join("/", rt.baseUrl, plural, "disable"), rt.setEnabled(false))
+}
+
+func (rt *SubscribersRoute) findSubscribers(ctx *gin.Context) {
+	pagination, sorting, ok := parsePage(ctx, storage.SubscriberSortFields)
+
+	if !ok {
+		return
+	}
+
+	filter, ok := rt.parseFilter(ctx)
+
+	if !ok {
+		return
+	}
+
+	subscribers, quantity, next, err := rt.storage.FindSubscribers(&storage.SubscriberQuery{
+		Pagination:       pagination,
+		SubscriberFilter: filter,
+		Sorting:          sorting,
+	})
+
+	if err != nil {
+		rt.logger.Error("failed to find subscribers", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	respondWithPage(ctx, subscribers, quantity, next)
+}
+
+func (rt *SubscribersRoute) getSubscriber(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	subscriber, err := rt.storage.GetSubscriber(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve subscriber",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	if subscriber == nil {
+		serverHttp.AbortWithCause(ctx, storage.ErrSubscriberNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, subscriber)
+}
+
+func (rt *SubscribersRoute) createSubscriber(ctx *gin.Context) {
+	subscriber, ok := rt.deserializeSubscriber(ctx, true)
+
+	if !ok {
+		return
+	}
+
+	id, err := rt.storage.CreateSubscriber(subscriber)
+
+	if err != nil {
+		rt.logger.Error("Failed to create new subscriber", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "%d", id)
+}
+
+func (rt *SubscribersRoute) updateSubscriber(ctx *gin.Context) {
+	subscriber, ok := rt.deserializeSubscriber(ctx, false)
+
+	if !ok {
+		return
+	}
+
+	err := rt.storage.UpdateSubscriber(subscriber)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to update subscriber",
+			zap.Uint64("id", subscriber.Id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *SubscribersRoute) deleteSubscriber(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	err := rt.storage.DeleteSubscriber(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to delete subscriber",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *SubscribersRoute) deleteSubscribers(ctx *gin.Context) {
+	ids, ok := rt.bindIds(ctx)
+
+	if !ok {
+		return
+	}
+
+	err := rt.storage.DeleteSubscribers(ids)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to delete subscribers",
+			zap.Uint64s("ids", ids),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *SubscribersRoute) setEnabled(enabled bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ids, ok := rt.bindIds(ctx)
+
+		if !ok {
+			return
+		}
+
+		err := rt.storage.SetSubscribersEnabled(ids, enabled)
+
+		if err != nil {
+			rt.logger.Error(
+				"Failed to change status of subscribers",
+				zap.Uint64s("ids", ids),
+				zap.Bool("enabled", enabled),
+				zap.Error(err),
+			)
+			serverHttp.AbortWithCause(ctx, err)
+			return
+		}
+
+		ctx.AbortWithStatus(http.StatusOK)
+	}
+}
+
+// parseFilter responds with 400 if filter query parameters are invalid
+func (rt *SubscribersRoute) parseFilter(ctx *gin.Context) (*storage.SubscriberFilter, bool) {
+	status, ok := parseStatus(ctx)
+
+	if !ok {
+		return nil, false
+	}
+
+	filter := &storage.SubscriberFilter{
+		Status: status,
+		Search: strings.TrimSpace(ctx.Query("search")),
+		Events: queryList(ctx, "event"),
+	}
+
+	if filter.TargetId, ok = parseOptionalId(ctx, "peripheral"); !ok {
+		return nil, false
+	}
+
+	if filter.GroupId, ok = parseOptionalId(ctx, "group"); !ok {
+		return nil, false
+	}
+
+	if filter.EndpointId, ok = parseOptionalId(ctx, "endpoint"); !ok {
+		return nil, false
+	}
+
+	if filter.RuleId, ok = parseOptionalId(ctx, "rule"); !ok {
+		return nil, false
+	}
+
+	return filter, true
+}
+
+// bindIds responds with 400 unless a body is a non-empty array of ids
+func (rt *SubscribersRoute) bindIds(ctx *gin.Context) ([]uint64, bool) {
+	var ids []uint64
+
+	if !serverHttp.BindJSON(ctx, &ids) {
+		return nil, false
+	}
+
+	if len(ids) == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id(s)"))
+		return nil, false
+	}
+
+	return ids, true
+}
+
+// deserializeSubscriber responds with 400 for a malformed body and 422 for invalid fields.
+// New subscribers must belong either to an existing peripheral or to an existing group,
+// owners of existing subscribers can not be changed.
+func (rt *SubscribersRoute) deserializeSubscriber(ctx *gin.Context, isNew bool) (*notification.Subscriber, bool) {
+	var subscriber notification.Subscriber
+
+	if !serverHttp.BindJSON(ctx, &subscriber) {
+		return nil, false
+	}
+
+	if !isNew && subscriber.Id == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id"))
+		return nil, false
+	}
+
+	subscriber.Name = strings.TrimSpace(subscriber.Name)
+
+	v := validation.New()
+
+	validateSubscriber(v, "", &subscriber, subscriber.GroupId > 0)
+
+	if isNew {
+		v.Check("targetId", (subscriber.TargetId == 0) != (subscriber.GroupId == 0), "either targetId or groupId is required")
+	}
+
+	if err := rt.checkReferences(v, &subscriber, isNew); err != nil {
+		rt.logger.Error("Failed to check subscriber references", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+
+		return nil, false
+	}
+
+	if err := v.Err(); err != nil {
+		rt.logger.Error("Invalid subscriber", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+
+		return nil, false
+	}
+
+	return &subscriber, true
+}
+
+// checkReferences adds errors of an endpoint and an owner which do not exist
+func (rt *SubscribersRoute) checkReferences(v *validation.Validator, subscriber *notification.Subscriber, isNew bool) error {
+	if !v.Failed("endpoint") {
+		endpoint, err := rt.storage.GetEndpoint(subscriber.Endpoint.Id)
+
+		if err != nil {
+			return err
+		}
+
+		v.Check("endpoint", endpoint != nil, "does not exist")
+	}
+
+	if !isNew || v.Failed("targetId") {
+		return nil
+	}
+
+	if subscriber.TargetId > 0 {
+		target, err := rt.storage.GetPeripheral(subscriber.TargetId)
+
+		if err != nil {
+			return err
+		}
+
+		v.Check("targetId", target != nil, "does not exist")
+
+		return nil
+	}
+
+	group, err := rt.storage.GetGroup(subscriber.GroupId)
+
+	if err != nil {
+		return err
+	}
+
+	v.Check("groupId", group != nil, "does not exist")
+
+	return nil
+}
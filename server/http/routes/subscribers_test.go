@@ -0,0 +1,173 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribersRoute(t *testing.T) {
+	engine, cleanup := createRegistryEngine(t)
+	defer cleanup()
+
+	res := call(engine, http.MethodPost, "/api/registry/endpoint", "", map[string]interface{}{
+		"name":   "hook",
+		"url":    "http://localhost/hook",
+		"method": "POST",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	endpointId := json.Number(res.Body.String())
+	targets := make([]json.Number, 0, 2)
+
+	for idx, name := range []string{"kitchen", "hall"} {
+		res = call(engine, http.MethodPost, "/api/registry/peripheral", "", map[string]interface{}{
+			"kind":    "ibeacon",
+			"name":    name,
+			"enabled": true,
+			"uuid":    "f7826da64fa24e988024bc5b71e0893e",
+			"major":   1,
+			"minor":   idx + 1,
+		})
+		assert.Equal(t, http.StatusOK, res.Code, name)
+
+		targets = append(targets, json.Number(res.Body.String()))
+	}
+
+	res = call(engine, http.MethodPost, "/api/registry/subscriber", "", map[string]interface{}{
+		"name":     "found",
+		"event":    "found",
+		"endpoint": map[string]interface{}{"id": endpointId},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "missed owner")
+	assert.Equal(t, []string{"targetId"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodPost, "/api/registry/subscriber", "", map[string]interface{}{
+		"name":     "found",
+		"event":    "found",
+		"targetId": targets[0],
+		"endpoint": map[string]interface{}{"id": endpointId},
+		"batch":    map[string]interface{}{"window": 100, "size": -1},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "negative batch size")
+	assert.Equal(t, []string{"batch.size"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodPost, "/api/registry/subscriber", "", map[string]interface{}{
+		"name":     "found",
+		"event":    "found",
+		"targetId": targets[0],
+		"endpoint": map[string]interface{}{"id": endpointId},
+		"batch":    map[string]interface{}{"window": 100000000, "size": 10},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "too long batch window")
+	assert.Equal(t, []string{"batch.window"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodPost, "/api/registry/subscriber", "", map[string]interface{}{
+		"name":     "found",
+		"event":    "found",
+		"targetId": targets[0],
+		"endpoint": map[string]interface{}{"id": endpointId},
+		"batch":    map[string]interface{}{"window": 0, "size": 10},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "batch size without a window")
+	assert.Equal(t, []string{"batch.window"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodPost, "/api/registry/subscriber", "", map[string]interface{}{
+		"name":     "found",
+		"event":    "found",
+		"targetId": targets[0],
+		"mode":     "presence",
+		"endpoint": map[string]interface{}{"id": endpointId},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "mode of a peripheral subscriber")
+	assert.Equal(t, []string{"mode"}, fieldNames(decodeError(t, res)))
+
+	res = call(engine, http.MethodPost, "/api/registry/subscriber", "", map[string]interface{}{
+		"name":     "found",
+		"event":    "found",
+		"targetId": 100,
+		"endpoint": map[string]interface{}{"id": 100},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "missing references")
+	assert.ElementsMatch(t, []string{"targetId", "endpoint"}, fieldNames(decodeError(t, res)))
+
+	ids := make([]json.Number, 0, 2)
+
+	for _, target := range targets {
+		res = call(engine, http.MethodPost, "/api/registry/subscriber", "", map[string]interface{}{
+			"name":     "found",
+			"event":    "found",
+			"enabled":  true,
+			"targetId": target,
+			"endpoint": map[string]interface{}{"id": endpointId},
+		})
+		assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+		ids = append(ids, json.Number(res.Body.String()))
+	}
+
+	res = call(engine, http.MethodGet, "/api/registry/subscriber/"+ids[0].String(), "", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"targetId":`+targets[0].String())
+
+	res = call(engine, http.MethodPut, "/api/registry/subscriber", "", map[string]interface{}{
+		"id":       ids[0],
+		"name":     "lost",
+		"event":    "lost",
+		"enabled":  true,
+		"targetId": targets[1],
+		"endpoint": map[string]interface{}{"id": endpointId},
+	})
+	assert.Equal(t, http.StatusOK, res.Code, "update")
+
+	body := decodePage(t, call(engine, http.MethodGet, "/api/registry/subscribers?peripheral="+targets[0].String(), "", nil))
+	assert.Equal(t, []string{"lost"}, body.names(), "owner is kept")
+
+	peripheral := call(engine, http.MethodGet, "/api/registry/peripheral/"+targets[1].String(), "", nil)
+	assert.NotContains(t, peripheral.Body.String(), `"lost"`, "subscribers of other peripherals are untouched")
+
+	res = call(engine, http.MethodPut, "/api/registry/subscriber", "", map[string]interface{}{
+		"id":       100,
+		"name":     "lost",
+		"event":    "lost",
+		"endpoint": map[string]interface{}{"id": endpointId},
+	})
+	assert.Equal(t, http.StatusNotFound, res.Code, "update of missing subscriber")
+
+	res = call(engine, http.MethodPost, "/api/registry/subscribers/disable", "", ids)
+	assert.Equal(t, http.StatusOK, res.Code, "bulk disable")
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/subscribers?enabled=true", "", nil))
+	assert.Empty(t, body.Items, "all subscribers are disabled")
+
+	res = call(engine, http.MethodPost, "/api/registry/subscribers/enable", "", []uint64{100})
+	assert.Equal(t, http.StatusNotFound, res.Code, "bulk enable of missing subscribers")
+
+	res = call(engine, http.MethodGet, "/api/registry/endpoint/"+endpointId.String()+"/subscribers", "", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var subscribers []map[string]interface{}
+
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &subscribers))
+	assert.Len(t, subscribers, 2, "reverse lookup")
+
+	res = call(engine, http.MethodDelete, "/api/registry/peripheral/"+targets[0].String(), "", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/subscribers", "", nil))
+	assert.Equal(t, []string{"found"}, body.names(), "subscribers are removed along with a peripheral")
+
+	res = call(engine, http.MethodDelete, "/api/registry/endpoint/"+endpointId.String(), "", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	body = decodePage(t, call(engine, http.MethodGet, "/api/registry/subscribers", "", nil))
+	assert.Empty(t, body.Items, "subscribers are removed along with an endpoint")
+
+	res = call(engine, http.MethodGet, "/api/registry/endpoint/"+endpointId.String()+"/subscribers", "", nil)
+	assert.Equal(t, http.StatusNotFound, res.Code, "missing endpoint")
+
+	res = call(engine, http.MethodDelete, "/api/registry/subscribers", "", []uint64{})
+	assert.Equal(t, http.StatusBadRequest, res.Code, "no ids")
+}
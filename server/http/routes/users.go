@@ -0,0 +1,415 @@
+package routes
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/blent/beagle/pkg/auth"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/blent/beagle/server/http/validation"
+	"github.com/blent/beagle/server/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrUsersRouteNotFound    = errors.Wrap(storage.ErrNotFound, "user")
+	ErrUsersRouteKeyNotFound = errors.Wrap(storage.ErrNotFound, "api key")
+	ErrUsersRouteDuplicate   = errors.Wrap(storage.ErrConflict, "username is already taken")
+	ErrUsersRouteSelfLockout = errors.New("admins can not delete, disable or demote themselves")
+)
+
+type (
+	UsersRoute struct {
+		baseUrl string
+		logger  *zap.Logger
+		storage *storage.Manager
+	}
+
+	userInput struct {
+		Id       uint64 `json:"id"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+		Enabled  *bool  `json:"enabled"`
+	}
+
+	apiKeyInput struct {
+		Name string `json:"name"`
+	}
+)
+
+func NewUsersRoute(baseUrl string, logger *zap.Logger, storage *storage.Manager) *UsersRoute {
+	return &UsersRoute{baseUrl, logger, storage}
+}
+
+func (rt *UsersRoute) Use(routes gin.IRoutes) {
+	singular := "user"
+	plural := "users"
+
+	// Get multiple users
+	routes.GET(path.Join("/", rt.baseUrl, plural), rt.findUsers)
+
+	// Get single user by id
+	routes.GET(path.Join("/", rt.baseUrl, singular, ":id"), rt.getUser)
+
+	// Create new user
+	routes.POST(path.Join("/", rt.baseUrl, singular), rt.createUser)
+
+	// Update existing user, an empty password keeps the current one
+	routes.PUT(path.Join("/", rt.baseUrl, singular), rt.updateUser)
+
+	// Delete existing user by id
+	routes.DELETE(path.Join("/", rt.baseUrl, singular, ":id"), rt.deleteUser)
+
+	// Get api keys of a user
+	routes.GET(path.Join("/", rt.baseUrl, singular, ":id", "keys"), rt.findApiKeys)
+
+	// Create new api key for a user, the key is returned only once
+	routes.POST(path.Join("/", rt.baseUrl, singular, ":id", "key"), rt.createApiKey)
+
+	// Delete api key of a user
+	routes.DELETE(path.Join("/", rt.baseUrl, singular, ":id", "key", ":key"), rt.deleteApiKey)
+}
+
+func (rt *UsersRoute) findUsers(ctx *gin.Context) {
+	take, skip, ok := parsePagination(ctx)
+
+	if !ok {
+		return
+	}
+
+	users, quantity, err := rt.storage.FindUsers(storage.NewPagination(take, skip))
+
+	if err != nil {
+		rt.logger.Error("Failed to find users", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"items":    users,
+		"quantity": quantity,
+	})
+}
+
+func (rt *UsersRoute) getUser(ctx *gin.Context) {
+	user, ok := rt.findUser(ctx)
+
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+func (rt *UsersRoute) createUser(ctx *gin.Context) {
+	var input userInput
+
+	if !serverHttp.BindJSON(ctx, &input) {
+		return
+	}
+
+	user := &auth.User{
+		Username:  strings.TrimSpace(input.Username),
+		Role:      input.Role,
+		Enabled:   input.Enabled == nil || *input.Enabled,
+		CreatedAt: time.Now(),
+	}
+
+	if !rt.validate(ctx, user, 0) {
+		return
+	}
+
+	hash, err := auth.HashPassword(input.Password)
+
+	if err != nil {
+		serverHttp.AbortWithCause(ctx, validation.New().Error("password", err).Err())
+		return
+	}
+
+	user.PasswordHash = hash
+
+	id, err := rt.storage.CreateUser(user)
+
+	if err != nil {
+		rt.logger.Error("Failed to create a new user", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "%d", id)
+}
+
+func (rt *UsersRoute) updateUser(ctx *gin.Context) {
+	var input userInput
+
+	if !serverHttp.BindJSON(ctx, &input) {
+		return
+	}
+
+	if input.Id == 0 {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, errors.New("missed id"))
+		return
+	}
+
+	user, err := rt.storage.GetUser(input.Id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve user",
+			zap.Uint64("id", input.Id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	if user == nil {
+		serverHttp.AbortWithCause(ctx, ErrUsersRouteNotFound)
+		return
+	}
+
+	role := user.Role
+	enabled := user.Enabled
+
+	user.Username = strings.TrimSpace(input.Username)
+	user.Role = input.Role
+
+	if input.Enabled != nil {
+		user.Enabled = *input.Enabled
+	}
+
+	if !rt.validate(ctx, user, user.Id) {
+		return
+	}
+
+	if rt.isSelf(ctx, user.Id) && (user.Role != auth.ROLE_ADMIN || !user.Enabled) {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, ErrUsersRouteSelfLockout)
+		return
+	}
+
+	if input.Password != "" {
+		user.PasswordHash, err = auth.HashPassword(input.Password)
+
+		if err != nil {
+			serverHttp.AbortWithCause(ctx, validation.New().Error("password", err).Err())
+			return
+		}
+	}
+
+	// sessions started with old credentials or privileges are ended
+	endSessions := input.Password != "" || user.Role != role || user.Enabled != enabled
+
+	if err := rt.storage.UpdateUser(user, endSessions); err != nil {
+		rt.logger.Error(
+			"Failed to update user",
+			zap.Uint64("id", user.Id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *UsersRoute) deleteUser(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	if rt.isSelf(ctx, id) {
+		serverHttp.AbortWithError(ctx, http.StatusBadRequest, ErrUsersRouteSelfLockout)
+		return
+	}
+
+	if err := rt.storage.DeleteUser(id); err != nil {
+		rt.logger.Error(
+			"Failed to delete user",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *UsersRoute) findApiKeys(ctx *gin.Context) {
+	user, ok := rt.findUser(ctx)
+
+	if !ok {
+		return
+	}
+
+	keys, err := rt.storage.FindApiKeys(user.Id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to find api keys",
+			zap.Uint64("user", user.Id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, keys)
+}
+
+func (rt *UsersRoute) createApiKey(ctx *gin.Context) {
+	user, ok := rt.findUser(ctx)
+
+	if !ok {
+		return
+	}
+
+	var input apiKeyInput
+
+	if !serverHttp.BindJSON(ctx, &input) {
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+
+	if err := validation.New().Required("name", name).Err(); err != nil {
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	token, key, err := auth.NewApiKey(user.Id, name)
+
+	if err != nil {
+		rt.logger.Error("Failed to generate an api key", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	key.CreatedAt = time.Now()
+	key.Id, err = rt.storage.CreateApiKey(key)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to create a new api key",
+			zap.Uint64("user", user.Id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"key":   key,
+	})
+}
+
+func (rt *UsersRoute) deleteApiKey(ctx *gin.Context) {
+	userId, ok := parseId(ctx, "id")
+
+	if !ok {
+		return
+	}
+
+	id, ok := parseId(ctx, "key")
+
+	if !ok {
+		return
+	}
+
+	key, err := rt.storage.GetApiKey(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve api key",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	if key == nil || key.UserId != userId {
+		serverHttp.AbortWithCause(ctx, ErrUsersRouteKeyNotFound)
+		return
+	}
+
+	if err := rt.storage.DeleteApiKey(id); err != nil {
+		rt.logger.Error(
+			"Failed to delete api key",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusOK)
+}
+
+func (rt *UsersRoute) findUser(ctx *gin.Context) (*auth.User, bool) {
+	id, ok := parseId(ctx, "id")
+
+	if !ok {
+		return nil, false
+	}
+
+	user, err := rt.storage.GetUser(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve user",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		serverHttp.AbortWithCause(ctx, err)
+		return nil, false
+	}
+
+	if user == nil {
+		serverHttp.AbortWithCause(ctx, ErrUsersRouteNotFound)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (rt *UsersRoute) validate(ctx *gin.Context, user *auth.User, id uint64) bool {
+	v := validation.New()
+
+	v.Required("username", user.Username)
+	v.OneOf("role", user.Role, auth.ROLE_ADMIN, auth.ROLE_VIEWER)
+
+	if err := v.Err(); err != nil {
+		serverHttp.AbortWithCause(ctx, err)
+		return false
+	}
+
+	existing, err := rt.storage.GetUserByName(user.Username)
+
+	if err != nil {
+		rt.logger.Error("Failed to retrieve user", zap.Error(err))
+		serverHttp.AbortWithCause(ctx, err)
+		return false
+	}
+
+	if existing != nil && existing.Id != id {
+		serverHttp.AbortWithCause(ctx, ErrUsersRouteDuplicate)
+		return false
+	}
+
+	return true
+}
+
+func (rt *UsersRoute) isSelf(ctx *gin.Context, id uint64) bool {
+	current := serverHttp.GetUser(ctx)
+
+	return current != nil && current.Id == id
+}
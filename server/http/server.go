@@ -2,10 +2,14 @@ package http
 
 import (
 	"context"
-	"fmt"
+	"net"
 	"net/http"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
@@ -19,23 +23,60 @@ type (
 	}
 
 	Server struct {
-		logger   *zap.Logger
-		engine   *gin.Engine
-		settings *Settings
+		logger    *zap.Logger
+		engine    *gin.Engine
+		settings  *Settings
+		mu        *sync.Mutex
+		server    *http.Server
+		address   string
+		closed    bool
+		done      chan struct{}
+		observers []RequestObserver
 	}
 )
 
+const (
+	staticRouteLabel    = "static"
+	unmatchedRouteLabel = "unmatched"
+)
+
 func NewServer(logger *zap.Logger, settings *Settings) *Server {
+	server := &Server{logger, nil, settings, &sync.Mutex{}, nil, "", false, make(chan struct{}), nil}
+
 	if !settings.Enabled {
-		return &Server{logger, nil, settings}
+		return server
 	}
 
 	gin.SetMode(gin.ReleaseMode)
-	engine := gin.New()
-	engine.Use(gin.Recovery())
-	engine.Use(LoggerMiddleware(logger))
+	server.engine = gin.New()
+	server.engine.Use(gin.Recovery())
+	server.engine.Use(LoggerMiddleware(logger, server.observe))
+
+	return server
+}
+
+// OnRequest registers an observer of completed requests.
+// Paths are reduced to routes, so that ids and static files do not produce unique values.
+func (server *Server) OnRequest(observer RequestObserver) *Server {
+	if observer == nil {
+		return server
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.observers = append(server.observers, observer)
+
+	return server
+}
 
-	return &Server{logger, engine, settings}
+// UseAuth protects routes added after the call
+func (server *Server) UseAuth(authenticator Authenticator, policy AccessPolicy) *Server {
+	if server.engine != nil {
+		server.engine.Use(AuthMiddleware(server.logger.Named("auth"), authenticator, policy))
+	}
+
+	return server
 }
 
 func (server *Server) AddRoute(route Route) *Server {
@@ -50,15 +91,29 @@ func (server *Server) AddRoute(route Route) *Server {
 	return server
 }
 
-func (server *Server) Run(ctx context.Context) error {
+// Routes returns added routes, nil if the server is disabled
+func (server *Server) Routes() gin.RoutesInfo {
+	if server.engine == nil {
+		return nil
+	}
+
+	return server.engine.Routes()
+}
+
+// Run serves requests until the server is shut down
+func (server *Server) Run() error {
 	if server.engine == nil {
 		server.logger.Info("Server is disabled")
-		<-ctx.Done()
+		<-server.done
 		return nil
 	}
 
-	if server.settings.Static != nil {
-		dir, err := filepath.Abs(server.settings.Static.Directory)
+	var dir string
+
+	if server.settings.Static != nil && server.settings.Static.Directory != "" {
+		var err error
+
+		dir, err = filepath.Abs(server.settings.Static.Directory)
 
 		if err != nil {
 			return err
@@ -68,16 +123,178 @@ func (server *Server) Run(ctx context.Context) error {
 			server.settings.Static.Route,
 			static.LocalFile(dir, true),
 		))
+	}
+
+	server.engine.NoRoute(func(ctx *gin.Context) {
+		if dir == "" || (server.settings.Api != nil && strings.HasPrefix(ctx.Request.URL.Path, server.settings.Api.Route)) {
+			AbortWithError(ctx, http.StatusNotFound, ErrRouteNotFound)
+			return
+		}
+
+		ctx.File(filepath.Join(dir, "index.html"))
+	})
+
+	address, err := resolveAddress(server.settings)
+
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:    address,
+		Handler: server.engine,
+	}
+
+	tlsSettings := server.settings.Tls
+	secure := tlsSettings != nil && tlsSettings.Enabled
+
+	if secure {
+		if err := server.prepareTls(srv); err != nil {
+			return err
+		}
+	}
+
+	server.mu.Lock()
+
+	if server.closed {
+		server.mu.Unlock()
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+
+	if err != nil {
+		server.mu.Unlock()
+		return err
+	}
+
+	server.server = srv
+	server.address = listener.Addr().String()
+	server.mu.Unlock()
+
+	server.logger.Info(
+		"Server is listening",
+		zap.String("address", listener.Addr().String()),
+		zap.Bool("tls", secure),
+	)
+
+	if secure {
+		err = srv.ServeTLS(listener, tlsSettings.Certificate, tlsSettings.Key)
+	} else {
+		err = srv.Serve(listener)
+	}
+
+	if err == http.ErrServerClosed {
+		<-server.done
+		return nil
+	}
+
+	return err
+}
+
+// Address returns an address the server listens on, empty until the server is started
+func (server *Server) Address() string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.address
+}
+
+func (server *Server) observe(method, url string, status int, duration time.Duration) {
+	server.mu.Lock()
+	observers := server.observers
+	server.mu.Unlock()
+
+	if len(observers) == 0 {
+		return
+	}
+
+	route := toRoute(server.settings, url, status)
 
-		server.engine.NoRoute(func(ctx *gin.Context) {
-			if strings.HasPrefix(ctx.Request.URL.Path, server.settings.Api.Route) {
-				ctx.AbortWithError(http.StatusNotFound, errors.New("Route not found"))
-				return
-			}
+	for _, observer := range observers {
+		observer(method, route, status, duration)
+	}
+}
+
+func (server *Server) prepareTls(srv *http.Server) error {
+	settings := server.settings.Tls
+
+	if settings.SelfSigned {
+		hosts := LocalHosts()
+
+		if server.settings.Address != "" {
+			hosts = append([]string{server.settings.Address}, hosts...)
+		}
+
+		generated, err := EnsureCertificate(settings.Certificate, settings.Key, hosts)
+
+		if err != nil {
+			return errors.Wrap(err, "self-signed certificate")
+		}
+
+		if generated {
+			server.logger.Info(
+				"Generated a self-signed certificate",
+				zap.String("certificate", settings.Certificate),
+				zap.Strings("hosts", hosts),
+			)
+		}
+	}
 
-			ctx.File(filepath.Join(dir, "index.html"))
-		})
+	config, err := createTlsConfig(settings)
+
+	if err != nil {
+		return err
+	}
+
+	srv.TLSConfig = config
+
+	return nil
+}
+
+// Shutdown stops accepting new connections and waits for active requests to complete
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.mu.Lock()
+
+	if server.closed {
+		server.mu.Unlock()
+		return nil
+	}
+
+	server.closed = true
+	srv := server.server
+	server.mu.Unlock()
+
+	defer close(server.done)
+
+	if srv == nil {
+		return nil
+	}
+
+	return srv.Shutdown(ctx)
+}
+
+// toRoute replaces numeric segments of api paths by a placeholder
+func toRoute(settings *Settings, url string, status int) string {
+	if status == http.StatusNotFound {
+		return unmatchedRouteLabel
+	}
+
+	if settings.Metrics != nil && url == settings.Metrics.Route {
+		return url
+	}
+
+	if settings.Api == nil || !isUnder(url, path.Join("/", settings.Api.Route)) {
+		return staticRouteLabel
+	}
+
+	segments := strings.Split(url, "/")
+
+	for idx, segment := range segments {
+		if _, err := strconv.ParseUint(segment, 10, 64); err == nil {
+			segments[idx] = ":id"
+		}
 	}
 
-	return server.engine.Run(fmt.Sprintf(":%d", server.settings.Port))
+	return strings.Join(segments, "/")
 }
@@ -0,0 +1,81 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type peripheralRoute struct{}
+
+func (rt *peripheralRoute) Use(routes gin.IRoutes) {
+	routes.GET("/api/registry/peripheral/:id", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, ctx.Param("id"))
+	})
+}
+
+func TestServerObservesRequests(t *testing.T) {
+	settings := &serverHttp.Settings{
+		Address: "127.0.0.1",
+		Port:    0,
+		Enabled: true,
+		Api:     &serverHttp.ApiSettings{Route: "/api"},
+		Static:  &serverHttp.StaticSettings{},
+		Metrics: &serverHttp.MetricsSettings{Enabled: true, Route: "/metrics"},
+	}
+
+	mu := &sync.Mutex{}
+	routes := make([]string, 0, 2)
+
+	server := serverHttp.NewServer(zap.NewNop(), settings).
+		OnRequest(func(method, route string, status int, duration time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			routes = append(routes, method+" "+route)
+		}).
+		AddRoute(&peripheralRoute{})
+
+	result := make(chan error, 1)
+
+	go func() {
+		result <- server.Run()
+	}()
+
+	defer func() {
+		server.Shutdown(context.Background())
+
+		assert.NoError(t, <-result)
+	}()
+
+	for i := 0; server.Address() == "" && i < 100; i++ {
+		time.Sleep(time.Millisecond * 20)
+	}
+
+	if server.Address() == "" {
+		t.Fatal("server is not started")
+	}
+
+	for _, url := range []string{"/api/registry/peripheral/42", "/wp-login.php"} {
+		res, err := http.Get("http://" + server.Address() + url)
+
+		if assert.NoError(t, err) {
+			res.Body.Close()
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{
+		"GET /api/registry/peripheral/:id",
+		"GET unmatched",
+	}, routes, "ids and unknown paths do not produce unique routes")
+}
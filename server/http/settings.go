@@ -1,20 +1,50 @@
 package http
 
+const (
+	CLIENT_AUTH_OPTIONAL = "optional"
+	CLIENT_AUTH_REQUIRED = "required"
+)
+
 type (
 	Settings struct {
-		Port     int
-		Headless bool
-		Enabled  bool
-		Api      *ApiSettings
-		Static   *StaticSettings
+		Address   string           `yaml:"address"`
+		Interface string           `yaml:"interface"`
+		Port      int              `yaml:"port"`
+		Headless  bool             `yaml:"-"`
+		Enabled   bool             `yaml:"enabled"`
+		Api       *ApiSettings     `yaml:"api"`
+		Static    *StaticSettings  `yaml:"static"`
+		Tls       *TlsSettings     `yaml:"tls"`
+		Metrics   *MetricsSettings `yaml:"metrics"`
 	}
 
 	ApiSettings struct {
-		Route string
+		Route string `yaml:"route"`
 	}
 
 	StaticSettings struct {
-		Route     string
-		Directory string
+		Route     string `yaml:"route"`
+		Directory string `yaml:"directory"`
+	}
+
+	MetricsSettings struct {
+		Enabled bool   `yaml:"enabled"`
+		Route   string `yaml:"route"`
+	}
+
+	// TlsSettings describes https.
+	// With SelfSigned, a certificate is generated when the files do not exist.
+	// With ClientCa, client certificates are verified against it.
+	TlsSettings struct {
+		Enabled     bool   `yaml:"enabled"`
+		Certificate string `yaml:"certificate"`
+		Key         string `yaml:"key"`
+		SelfSigned  bool   `yaml:"selfSigned"`
+		ClientCa    string `yaml:"clientCa"`
+		ClientAuth  string `yaml:"clientAuth"`
 	}
 )
+
+func IsSupportedClientAuth(mode string) bool {
+	return mode == CLIENT_AUTH_OPTIONAL || mode == CLIENT_AUTH_REQUIRED
+}
@@ -0,0 +1,210 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/blent/beagle/server/utils"
+	"github.com/pkg/errors"
+)
+
+const certificateValidity = time.Hour * 24 * 365 * 10
+
+var ErrInvalidClientCa = errors.New("no certificates found in client ca file")
+
+// EnsureCertificate generates a self-signed certificate for given hosts unless both files exist.
+// It returns true if the certificate is generated.
+func EnsureCertificate(certFile, keyFile string, hosts []string) (bool, error) {
+	if utils.Exists(certFile) && utils.Exists(keyFile) {
+		return false, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return false, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Beagle"},
+			CommonName:   hosts[0],
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		return false, err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		return false, err
+	}
+
+	if err := writePem(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return false, err
+	}
+
+	if err := writePem(keyFile, "EC PRIVATE KEY", keyDer, 0600); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// LocalHosts returns names and addresses the device is reachable by
+func LocalHosts() []string {
+	hosts := make([]string, 0, 5)
+
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+
+	hosts = append(hosts, "localhost")
+
+	addresses, err := net.InterfaceAddrs()
+
+	if err != nil {
+		return append(hosts, "127.0.0.1", "::1")
+	}
+
+	for _, address := range addresses {
+		if network, ok := address.(*net.IPNet); ok {
+			hosts = append(hosts, network.IP.String())
+		}
+	}
+
+	return hosts
+}
+
+func createTlsConfig(settings *TlsSettings) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if settings.ClientCa == "" {
+		return config, nil
+	}
+
+	data, err := ioutil.ReadFile(settings.ClientCa)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.Wrap(ErrInvalidClientCa, settings.ClientCa)
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	if settings.ClientAuth == CLIENT_AUTH_OPTIONAL {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, nil
+}
+
+// resolveAddress returns a listen address, an interface takes precedence over an address
+func resolveAddress(settings *Settings) (string, error) {
+	host := settings.Address
+
+	if settings.Interface != "" {
+		iface, err := net.InterfaceByName(settings.Interface)
+
+		if err != nil {
+			return "", errors.Wrap(err, settings.Interface)
+		}
+
+		addresses, err := iface.Addrs()
+
+		if err != nil {
+			return "", errors.Wrap(err, settings.Interface)
+		}
+
+		host = ""
+
+		for _, address := range addresses {
+			network, ok := address.(*net.IPNet)
+
+			if !ok {
+				continue
+			}
+
+			// prefers IPv4
+			if host == "" || network.IP.To4() != nil {
+				host = network.IP.String()
+			}
+
+			if network.IP.To4() != nil {
+				break
+			}
+		}
+
+		if host == "" {
+			return "", fmt.Errorf("%s: interface has no addresses", settings.Interface)
+		}
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(settings.Port)), nil
+}
+
+func writePem(path, kind string, data []byte, mode os.FileMode) error {
+	if err := utils.EnsureDirectory(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+
+	if err != nil {
+		return err
+	}
+
+	if err := pem.Encode(file, &pem.Block{Type: kind, Bytes: data}); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,242 @@
+package http_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/pkg/auth"
+	serverHttp "github.com/blent/beagle/server/http"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type (
+	pingRoute struct{}
+
+	certificateAuthenticator struct {
+		tokenAuthenticator
+	}
+)
+
+func (rt *pingRoute) Use(routes gin.IRoutes) {
+	routes.GET("/api/ping", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, serverHttp.GetUser(ctx).Username)
+	})
+}
+
+func (a *certificateAuthenticator) AuthenticateCertificate(commonName string) (*auth.User, error) {
+	if commonName != "machine" {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	return &auth.User{Id: 3, Username: commonName, Role: auth.ROLE_VIEWER, Enabled: true}, nil
+}
+
+func createTempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func readCertificate(t *testing.T, path string) *x509.Certificate {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		t.Fatal("no pem block")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// createClientCertificate returns a ca pem and a client certificate signed by the ca
+func createClientCertificate(t *testing.T, commonName string) ([]byte, tls.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	caDer, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	clientDer, err := x509.CreateCertificate(rand.Reader, client, ca, &clientKey.PublicKey, caKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer}), tls.Certificate{
+		Certificate: [][]byte{clientDer},
+		PrivateKey:  clientKey,
+	}
+}
+
+func TestEnsureCertificate(t *testing.T) {
+	dir, cleanup := createTempDir(t)
+	defer cleanup()
+
+	certFile := filepath.Join(dir, "tls", "certificate.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+
+	generated, err := serverHttp.EnsureCertificate(certFile, keyFile, []string{"gateway", "127.0.0.1"})
+
+	assert.NoError(t, err)
+	assert.True(t, generated)
+
+	cert := readCertificate(t, certFile)
+
+	assert.NoError(t, cert.VerifyHostname("gateway"))
+	assert.NoError(t, cert.VerifyHostname("127.0.0.1"))
+	assert.True(t, cert.NotAfter.After(time.Now().Add(time.Hour*24*365)))
+
+	info, err := os.Stat(keyFile)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "private key is readable by the owner only")
+	}
+
+	generated, err = serverHttp.EnsureCertificate(certFile, keyFile, []string{"other"})
+
+	assert.NoError(t, err)
+	assert.False(t, generated, "existing certificate is kept")
+	assert.Equal(t, cert.SerialNumber, readCertificate(t, certFile).SerialNumber)
+}
+
+func TestServerMutualTls(t *testing.T) {
+	dir, cleanup := createTempDir(t)
+	defer cleanup()
+
+	caPem, clientCert := createClientCertificate(t, "machine")
+	caFile := filepath.Join(dir, "ca.pem")
+
+	if err := ioutil.WriteFile(caFile, caPem, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := &serverHttp.Settings{
+		Address: "127.0.0.1",
+		Port:    0,
+		Enabled: true,
+		Api:     &serverHttp.ApiSettings{Route: "/api"},
+		Static:  &serverHttp.StaticSettings{},
+		Tls: &serverHttp.TlsSettings{
+			Enabled:     true,
+			Certificate: filepath.Join(dir, "certificate.pem"),
+			Key:         filepath.Join(dir, "key.pem"),
+			SelfSigned:  true,
+			ClientCa:    caFile,
+			ClientAuth:  serverHttp.CLIENT_AUTH_REQUIRED,
+		},
+	}
+
+	server := serverHttp.NewServer(zap.NewNop(), settings).
+		UseAuth(&certificateAuthenticator{}, serverHttp.NewAccessPolicy("/api")).
+		AddRoute(&pingRoute{})
+
+	result := make(chan error, 1)
+
+	go func() {
+		result <- server.Run()
+	}()
+
+	defer func() {
+		server.Shutdown(context.Background())
+
+		assert.NoError(t, <-result)
+	}()
+
+	for i := 0; server.Address() == "" && i < 100; i++ {
+		time.Sleep(time.Millisecond * 20)
+	}
+
+	if server.Address() == "" {
+		t.Fatal("server is not started")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(readCertificate(t, settings.Tls.Certificate))
+
+	url := "https://" + server.Address() + "/api/ping"
+
+	anonymous := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}}
+
+	_, err := anonymous.Get(url)
+	assert.Error(t, err, "client certificate is required")
+
+	machine := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}},
+	}}
+
+	res, err := machine.Get(url)
+
+	if assert.NoError(t, err) {
+		defer res.Body.Close()
+
+		body, _ := ioutil.ReadAll(res.Body)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "machine", string(body), "authenticated by the certificate")
+	}
+}
@@ -0,0 +1,124 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// FieldError describes an invalid field of a request
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+
+	// Errors are returned by a validator as a single error
+	Errors []*FieldError
+
+	// Validator collects errors of request fields, only the first error of each field is kept
+	Validator struct {
+		errors Errors
+	}
+)
+
+func New() *Validator {
+	return &Validator{}
+}
+
+func (errs Errors) Error() string {
+	messages := make([]string, 0, len(errs))
+
+	for _, err := range errs {
+		messages = append(messages, fmt.Sprintf("%s: %s", err.Field, err.Message))
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Required checks that a trimmed value is not empty
+func (v *Validator) Required(field, value string) *Validator {
+	return v.Check(field, strings.TrimSpace(value) != "", "is required")
+}
+
+// Length checks the exact length of a non-empty value
+func (v *Validator) Length(field, value string, length int) *Validator {
+	return v.Check(field, value == "" || len(value) == length, fmt.Sprintf("must be %d characters long", length))
+}
+
+// OneOf checks that a value is one of allowed ones
+func (v *Validator) OneOf(field, value string, allowed ...string) *Validator {
+	for _, option := range allowed {
+		if value == option {
+			return v
+		}
+	}
+
+	return v.Add(field, fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", ")))
+}
+
+// Check adds a message unless a condition holds
+func (v *Validator) Check(field string, ok bool, message string) *Validator {
+	if ok {
+		return v
+	}
+
+	return v.Add(field, message)
+}
+
+// Error adds an error unless it is nil
+func (v *Validator) Error(field string, err error) *Validator {
+	if err == nil {
+		return v
+	}
+
+	return v.Add(field, err.Error())
+}
+
+func (v *Validator) Add(field, message string) *Validator {
+	if v.Failed(field) {
+		return v
+	}
+
+	v.errors = append(v.errors, &FieldError{field, message})
+
+	return v
+}
+
+// Nest adds errors of a nested object, names of its fields start with a prefix
+func (v *Validator) Nest(prefix string, err error) *Validator {
+	if err == nil {
+		return v
+	}
+
+	errs, ok := err.(Errors)
+
+	if !ok {
+		return v.Add(strings.TrimSuffix(prefix, "."), err.Error())
+	}
+
+	for _, field := range errs {
+		v.Add(prefix+field.Field, field.Message)
+	}
+
+	return v
+}
+
+// Failed returns true if a field already has an error
+func (v *Validator) Failed(field string) bool {
+	for _, err := range v.errors {
+		if err.Field == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Err returns collected errors or nil if all fields are valid
+func (v *Validator) Err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+
+	return v.errors
+}
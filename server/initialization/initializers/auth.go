@@ -0,0 +1,20 @@
+package initializers
+
+import (
+	"github.com/blent/beagle/pkg/auth"
+	"go.uber.org/zap"
+)
+
+type AuthInitializer struct {
+	logger  *zap.Logger
+	service *auth.Service
+}
+
+func NewAuthInitializer(logger *zap.Logger, service *auth.Service) *AuthInitializer {
+	return &AuthInitializer{logger, service}
+}
+
+// Run creates an admin if there are no users yet, it needs the storage to be initialized
+func (init *AuthInitializer) Run() error {
+	return init.service.Bootstrap()
+}
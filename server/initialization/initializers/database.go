@@ -1,6 +1,7 @@
 package initializers
 
 import (
+	"context"
 	"fmt"
 	"github.com/blent/beagle/server/storage"
 	"go.uber.org/zap"
@@ -62,3 +63,8 @@ func (init *DatabaseInitializer) Run() error {
 
 	return nil
 }
+
+// Shutdown closes the storage
+func (init *DatabaseInitializer) Shutdown(ctx context.Context) error {
+	return init.provider.Close()
+}
@@ -1,38 +1,457 @@
 package initialization
 
 import (
-	"fmt"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
+const (
+	STEP_STATE_PENDING = "pending"
+	STEP_STATE_RUNNING = "running"
+	STEP_STATE_DONE    = "done"
+	STEP_STATE_FAILED  = "failed"
+	STEP_STATE_SKIPPED = "skipped"
+	STEP_STATE_STOPPED = "stopped"
+)
+
+var (
+	ErrCycle             = errors.New("dependency cycle")
+	ErrUnknownDependency = errors.New("unknown dependency")
+	ErrDuplicateStep     = errors.New("duplicate step")
+	ErrTimeout           = errors.New("timed out")
+	ErrDependencyFailed  = errors.New("dependency failed")
+	ErrAlreadyRun        = errors.New("initializers are already run")
+)
+
 type (
 	Initializer interface {
 		Run() error
 	}
 
+	// Shutdowner is implemented by initializers which need to release resources,
+	// they are shut down in the reverse order of initialization
+	Shutdowner interface {
+		Shutdown(ctx context.Context) error
+	}
+
+	// Step is a named initializer which runs after all initializers it depends on.
+	// A failed optional step does not fail the initialization, but its dependents are skipped.
+	// A zero timeout means no timeout, a step which timed out keeps running in the background
+	// and is shut down if it succeeds later.
+	Step struct {
+		Name        string
+		Initializer Initializer
+		DependsOn   []string
+		Timeout     time.Duration
+		Optional    bool
+	}
+
+	StepStatus struct {
+		Name      string     `json:"name"`
+		State     string     `json:"state"`
+		Optional  bool       `json:"optional"`
+		DependsOn []string   `json:"dependsOn"`
+		StartedAt *time.Time `json:"startedAt,omitempty"`
+		// Duration is in milliseconds
+		Duration float64 `json:"duration"`
+		Error    string  `json:"error,omitempty"`
+		// LateState is a state a timed out step has finished with in the background
+		LateState string `json:"lateState,omitempty"`
+	}
+
 	InitManager struct {
-		logger *zap.Logger
+		mu       *sync.RWMutex
+		logger   *zap.Logger
+		steps    []*Step
+		statuses map[string]*StepStatus
+		order    []*Step
+		late     map[string]chan struct{}
+		isRun    bool
 	}
 )
 
 func NewInitManager(logger *zap.Logger) *InitManager {
 	return &InitManager{
-		logger: logger,
+		mu:       &sync.RWMutex{},
+		logger:   logger,
+		steps:    make([]*Step, 0, 4),
+		statuses: make(map[string]*StepStatus),
+		late:     make(map[string]chan struct{}),
+	}
+}
+
+// Add registers a step, steps without dependencies between them run in the order they are added
+func (manager *InitManager) Add(step *Step) *InitManager {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.steps = append(manager.steps, step)
+
+	return manager
+}
+
+// Get returns an initializer of a step by name or nil if there is none
+func (manager *InitManager) Get(name string) Initializer {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	for _, step := range manager.steps {
+		if step.Name == name {
+			return step.Initializer
+		}
 	}
+
+	return nil
 }
 
-func (manager *InitManager) Run(initializers map[string]Initializer) error {
+// Run runs all steps in the order of their dependencies and stops at the first failed required step.
+// Steps which succeeded before a failure still need to be shut down.
+func (manager *InitManager) Run() error {
+	manager.mu.Lock()
+
+	if manager.isRun {
+		manager.mu.Unlock()
+
+		return ErrAlreadyRun
+	}
+
+	order, err := sortSteps(manager.steps)
+
+	if err != nil {
+		manager.mu.Unlock()
+		manager.logger.Error("Invalid initializers", zap.Error(err))
+
+		return err
+	}
+
+	manager.isRun = true
+	manager.order = order
+
+	for _, step := range order {
+		manager.statuses[step.Name] = &StepStatus{
+			Name:      step.Name,
+			State:     STEP_STATE_PENDING,
+			Optional:  step.Optional,
+			DependsOn: append([]string{}, step.DependsOn...),
+		}
+	}
+
+	manager.mu.Unlock()
+
+	for _, step := range order {
+		if failed := manager.failedDependency(step); failed != "" {
+			err = errors.Wrapf(ErrDependencyFailed, "%s depends on %s", step.Name, failed)
+
+			manager.finish(step.Name, STEP_STATE_SKIPPED, time.Now(), err)
+			manager.logger.Warn("Skipped initializer", zap.String("name", step.Name), zap.Error(err))
+
+			if step.Optional {
+				continue
+			}
+
+			manager.skipPending()
+
+			return err
+		}
+
+		started := time.Now()
+
+		manager.start(step.Name, started)
+
+		err = manager.run(step, started)
+
+		if err == nil {
+			manager.finish(step.Name, STEP_STATE_DONE, started, nil)
+			manager.logger.Info(
+				"Initializer is done",
+				zap.String("name", step.Name),
+				zap.Duration("duration", time.Since(started)),
+			)
+
+			continue
+		}
+
+		manager.finish(step.Name, STEP_STATE_FAILED, started, err)
+
+		if step.Optional {
+			manager.logger.Warn("Optional initializer failed", zap.String("name", step.Name), zap.Error(err))
+
+			continue
+		}
+
+		manager.logger.Error("Initializer failed", zap.String("name", step.Name), zap.Error(err))
+		manager.skipPending()
+
+		return errors.Wrap(err, step.Name)
+	}
+
+	return nil
+}
+
+// Shutdown shuts down succeeded steps in the reverse order and returns the first error.
+// Steps which timed out and are still running are waited for until the context is done,
+// so that resources they acquire late are released as well.
+func (manager *InitManager) Shutdown(ctx context.Context) error {
+	manager.mu.RLock()
+	order := manager.order
+	manager.mu.RUnlock()
+
 	var err error
 
-	for name, init := range initializers {
-		initError := init.Run()
+	for idx := len(order) - 1; idx >= 0; idx-- {
+		step := order[idx]
+
+		if waitErr := manager.waitLate(ctx, step.Name); waitErr != nil {
+			manager.logger.Error("Initializer is still running", zap.String("name", step.Name), zap.Error(waitErr))
 
-		if initError != nil {
-			err = fmt.Errorf("Error occured during %s initializer: %s", name, initError.Error())
-			manager.logger.Error(err.Error())
-			break
+			if err == nil {
+				err = errors.Wrap(waitErr, step.Name)
+			}
+
+			continue
+		}
+
+		if !manager.isDone(step.Name) {
+			continue
+		}
+
+		shutdowner, ok := step.Initializer.(Shutdowner)
+
+		if !ok {
+			continue
+		}
+
+		stepErr := shutdowner.Shutdown(ctx)
+
+		manager.mu.Lock()
+		manager.statuses[step.Name].State = STEP_STATE_STOPPED
+
+		if stepErr != nil {
+			manager.statuses[step.Name].Error = stepErr.Error()
+		}
+
+		manager.mu.Unlock()
+
+		if stepErr != nil {
+			manager.logger.Error("Failed to shut down initializer", zap.String("name", step.Name), zap.Error(stepErr))
+
+			if err == nil {
+				err = errors.Wrap(stepErr, step.Name)
+			}
 		}
 	}
 
 	return err
 }
+
+// Status returns states of all steps in the order they run, it is empty until Run is called
+func (manager *InitManager) Status() []*StepStatus {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	res := make([]*StepStatus, 0, len(manager.order))
+
+	for _, step := range manager.order {
+		status := *manager.statuses[step.Name]
+		res = append(res, &status)
+	}
+
+	return res
+}
+
+// isDone returns true if a step has succeeded in time or later in the background
+func (manager *InitManager) isDone(name string) bool {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	status := manager.statuses[name]
+
+	return status.State == STEP_STATE_DONE || (status.State == STEP_STATE_FAILED && status.LateState == STEP_STATE_DONE)
+}
+
+// waitLate waits until a timed out step finishes in the background
+func (manager *InitManager) waitLate(ctx context.Context, name string) error {
+	manager.mu.RLock()
+	finished, exists := manager.late[name]
+	manager.mu.RUnlock()
+
+	if !exists {
+		return nil
+	}
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// failedDependency returns a name of a dependency which did not succeed
+func (manager *InitManager) failedDependency(step *Step) string {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	for _, name := range step.DependsOn {
+		if manager.statuses[name].State != STEP_STATE_DONE {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func (manager *InitManager) start(name string, started time.Time) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	status := manager.statuses[name]
+	status.State = STEP_STATE_RUNNING
+	status.StartedAt = &started
+}
+
+func (manager *InitManager) finish(name, state string, started time.Time, err error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	status := manager.statuses[name]
+	status.State = state
+	status.Duration = float64(time.Since(started)) / float64(time.Millisecond)
+
+	if err != nil {
+		status.Error = err.Error()
+	}
+}
+
+func (manager *InitManager) skipPending() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for _, status := range manager.statuses {
+		if status.State == STEP_STATE_PENDING {
+			status.State = STEP_STATE_SKIPPED
+		}
+	}
+}
+
+// run waits for an initializer until its timeout, after that its result is recorded as a late state
+func (manager *InitManager) run(step *Step, started time.Time) error {
+	if step.Timeout <= 0 {
+		return step.Initializer.Run()
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- step.Initializer.Run()
+	}()
+
+	timer := time.NewTimer(step.Timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		finished := make(chan struct{})
+
+		manager.mu.Lock()
+		manager.late[step.Name] = finished
+		manager.mu.Unlock()
+
+		go manager.finishLate(step.Name, started, done, finished)
+
+		return errors.Wrapf(ErrTimeout, "after %s", step.Timeout)
+	}
+}
+
+func (manager *InitManager) finishLate(name string, started time.Time, done <-chan error, finished chan<- struct{}) {
+	defer close(finished)
+
+	err := <-done
+	state := STEP_STATE_DONE
+
+	if err != nil {
+		state = STEP_STATE_FAILED
+	}
+
+	manager.mu.Lock()
+	manager.statuses[name].LateState = state
+	manager.mu.Unlock()
+
+	manager.logger.Warn(
+		"Timed out initializer has finished",
+		zap.String("name", name),
+		zap.String("state", state),
+		zap.Duration("duration", time.Since(started)),
+		zap.Error(err),
+	)
+}
+
+// sortSteps orders steps topologically, keeping the order of independent steps
+func sortSteps(steps []*Step) ([]*Step, error) {
+	byName := make(map[string]*Step, len(steps))
+
+	for _, step := range steps {
+		if _, exists := byName[step.Name]; exists {
+			return nil, errors.Wrap(ErrDuplicateStep, step.Name)
+		}
+
+		byName[step.Name] = step
+	}
+
+	for _, step := range steps {
+		for _, name := range step.DependsOn {
+			if _, exists := byName[name]; !exists {
+				return nil, errors.Wrapf(ErrUnknownDependency, "%s depends on %s", step.Name, name)
+			}
+		}
+	}
+
+	order := make([]*Step, 0, len(steps))
+	visited := make(map[string]bool, len(steps))
+	path := make([]string, 0, len(steps))
+
+	var visit func(step *Step) error
+
+	visit = func(step *Step) error {
+		for idx, name := range path {
+			if name == step.Name {
+				cycle := append(append([]string{}, path[idx:]...), step.Name)
+
+				return errors.Wrap(ErrCycle, strings.Join(cycle, " -> "))
+			}
+		}
+
+		if visited[step.Name] {
+			return nil
+		}
+
+		path = append(path, step.Name)
+
+		for _, name := range step.DependsOn {
+			if err := visit(byName[name]); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		visited[step.Name] = true
+		order = append(order, step)
+
+		return nil
+	}
+
+	for _, step := range steps {
+		if err := visit(step); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
@@ -0,0 +1,228 @@
+package initialization_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blent/beagle/server/initialization"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type (
+	journal struct {
+		mu      *sync.Mutex
+		entries []string
+	}
+
+	// recordingInitializer writes its runs and shutdowns to a journal
+	recordingInitializer struct {
+		name    string
+		journal *journal
+		err     error
+		delay   time.Duration
+	}
+)
+
+func newJournal() *journal {
+	return &journal{mu: &sync.Mutex{}}
+}
+
+func (j *journal) write(entry string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = append(j.entries, entry)
+}
+
+func (j *journal) read() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]string{}, j.entries...)
+}
+
+func (init *recordingInitializer) Run() error {
+	time.Sleep(init.delay)
+
+	init.journal.write("run " + init.name)
+
+	return init.err
+}
+
+func (init *recordingInitializer) Shutdown(ctx context.Context) error {
+	init.journal.write("shutdown " + init.name)
+
+	return nil
+}
+
+func step(j *journal, name string, dependsOn ...string) *initialization.Step {
+	return &initialization.Step{
+		Name:        name,
+		Initializer: &recordingInitializer{name: name, journal: j},
+		DependsOn:   dependsOn,
+	}
+}
+
+func states(manager *initialization.InitManager) map[string]string {
+	res := make(map[string]string)
+
+	for _, status := range manager.Status() {
+		res[status.Name] = status.State
+	}
+
+	return res
+}
+
+func TestInitManagerOrder(t *testing.T) {
+	j := newJournal()
+
+	manager := initialization.NewInitManager(zap.NewNop()).
+		Add(step(j, "routes", "auth")).
+		Add(step(j, "auth", "migrations")).
+		Add(step(j, "cache", "storage")).
+		Add(step(j, "migrations", "storage")).
+		Add(step(j, "storage"))
+
+	assert.Empty(t, manager.Status(), "not run")
+	assert.NoError(t, manager.Run())
+	assert.Equal(t, initialization.ErrAlreadyRun, manager.Run())
+
+	assert.Equal(t, []string{
+		"run storage",
+		"run migrations",
+		"run auth",
+		"run routes",
+		"run cache",
+	}, j.read(), "dependencies first, otherwise in the order of adding")
+
+	for _, status := range manager.Status() {
+		assert.Equal(t, initialization.STEP_STATE_DONE, status.State, status.Name)
+		assert.NotNil(t, status.StartedAt, status.Name)
+	}
+
+	assert.NoError(t, manager.Shutdown(context.Background()))
+
+	assert.Equal(t, []string{
+		"shutdown cache",
+		"shutdown routes",
+		"shutdown auth",
+		"shutdown migrations",
+		"shutdown storage",
+	}, j.read()[5:], "reverse order")
+
+	assert.Equal(t, initialization.STEP_STATE_STOPPED, states(manager)["storage"])
+}
+
+func TestInitManagerInvalidSteps(t *testing.T) {
+	j := newJournal()
+
+	err := initialization.NewInitManager(zap.NewNop()).
+		Add(step(j, "storage")).
+		Add(step(j, "a", "storage", "c")).
+		Add(step(j, "b", "a")).
+		Add(step(j, "c", "b")).
+		Run()
+
+	assert.True(t, errors.Cause(err) == initialization.ErrCycle, "cycle")
+	assert.Contains(t, err.Error(), "a -> c -> b -> a")
+
+	err = initialization.NewInitManager(zap.NewNop()).
+		Add(step(j, "a", "missing")).
+		Run()
+
+	assert.True(t, errors.Cause(err) == initialization.ErrUnknownDependency, "unknown dependency")
+
+	err = initialization.NewInitManager(zap.NewNop()).
+		Add(step(j, "a")).
+		Add(step(j, "a")).
+		Run()
+
+	assert.True(t, errors.Cause(err) == initialization.ErrDuplicateStep, "duplicate")
+	assert.Empty(t, j.read(), "nothing is run")
+}
+
+func TestInitManagerFailures(t *testing.T) {
+	j := newJournal()
+
+	warmUp := step(j, "warm-up", "storage")
+	warmUp.Optional = true
+	warmUp.Initializer.(*recordingInitializer).err = errors.New("unavailable")
+
+	stats := step(j, "stats", "warm-up")
+	stats.Optional = true
+
+	migrations := step(j, "migrations", "storage")
+	migrations.Timeout = time.Millisecond * 10
+	migrations.Initializer.(*recordingInitializer).delay = time.Millisecond * 100
+
+	manager := initialization.NewInitManager(zap.NewNop()).
+		Add(step(j, "storage")).
+		Add(warmUp).
+		Add(stats).
+		Add(migrations).
+		Add(step(j, "routes", "migrations")).
+		Add(step(j, "auth"))
+
+	err := manager.Run()
+
+	assert.True(t, errors.Cause(err) == initialization.ErrTimeout, "timeout")
+	assert.Contains(t, err.Error(), "migrations")
+
+	assert.Equal(t, map[string]string{
+		"storage":    initialization.STEP_STATE_DONE,
+		"warm-up":    initialization.STEP_STATE_FAILED,
+		"stats":      initialization.STEP_STATE_SKIPPED,
+		"migrations": initialization.STEP_STATE_FAILED,
+		"routes":     initialization.STEP_STATE_SKIPPED,
+		"auth":       initialization.STEP_STATE_SKIPPED,
+	}, states(manager))
+
+	assert.NoError(t, manager.Shutdown(context.Background()))
+	assert.Equal(
+		t,
+		[]string{"run storage", "run warm-up", "run migrations", "shutdown migrations", "shutdown storage"},
+		j.read(),
+		"succeeded steps are shut down, including those which succeeded late",
+	)
+
+	for _, status := range manager.Status() {
+		if status.Name == "migrations" {
+			assert.Equal(t, initialization.STEP_STATE_STOPPED, status.State)
+			assert.Equal(t, initialization.STEP_STATE_DONE, status.LateState)
+		}
+	}
+}
+
+func TestInitManagerLateSteps(t *testing.T) {
+	j := newJournal()
+
+	storage := step(j, "storage")
+	storage.Timeout = time.Millisecond * 10
+	storage.Initializer.(*recordingInitializer).delay = time.Millisecond * 300
+
+	manager := initialization.NewInitManager(zap.NewNop()).Add(storage)
+
+	err := manager.Run()
+
+	assert.True(t, errors.Cause(err) == initialization.ErrTimeout, "timeout")
+	assert.Empty(t, manager.Status()[0].LateState, "still running")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	err = manager.Shutdown(ctx)
+
+	assert.True(t, errors.Cause(err) == context.DeadlineExceeded, "still running at shutdown")
+	assert.Empty(t, j.read(), "running steps are not shut down")
+
+	assert.NoError(t, manager.Shutdown(context.Background()), "waits for running steps")
+	assert.Equal(t, []string{"run storage", "shutdown storage"}, j.read())
+	assert.Equal(t, initialization.STEP_STATE_DONE, manager.Status()[0].LateState)
+
+	assert.NoError(t, manager.Shutdown(context.Background()))
+	assert.Len(t, j.read(), 2, "stopped steps are shut down once")
+}
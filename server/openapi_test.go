@@ -0,0 +1,161 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/server/http/openapi"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var pathParamRegExp = regexp.MustCompile(`{(\w+)}`)
+
+func TestOpenApiSpecMatchesRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	// all optional routes are enabled by default
+	settings := NewDefaultSettings()
+	settings.Storage.ConnectionString = filepath.Join(dir, "database.db")
+
+	container, err := newContainer(settings, zap.NewNop(), devices.NewMockDevice())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := container.GetInitManager().Get("routes").Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := make([]string, 0, 64)
+
+	for _, route := range container.GetServer().Routes() {
+		routes = append(routes, route.Method+" "+openapi.ToPath(route.Path))
+	}
+
+	spec := openapi.NewSpec("test", settings.Http.Api.Route, settings.Http.Metrics.Route)
+
+	described := spec.Routes()
+
+	assert.Empty(t, difference(routes, described), "routes missed in the spec")
+	assert.Empty(t, difference(described, routes), "spec operations without routes")
+
+	operations := make(map[string]string)
+
+	for url, item := range spec.Paths {
+		params := pathParamRegExp.FindAllStringSubmatch(url, -1)
+
+		for method, operation := range item {
+			route := strings.ToUpper(method) + " " + url
+
+			if other, ok := operations[operation.OperationId]; ok {
+				t.Errorf("%s: operation id '%s' is used by %s", route, operation.OperationId, other)
+			}
+
+			operations[operation.OperationId] = route
+
+			for _, param := range params {
+				assert.True(t, hasPathParam(operation, param[1]), "%s: parameter '%s' is not described", route, param[1])
+			}
+
+			assert.NotEmpty(t, operation.Responses, "%s: responses are described", route)
+		}
+	}
+
+	for _, ref := range collectRefs(spec) {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+
+		_, ok := spec.Components.Schemas[name]
+		assert.True(t, ok, "schema '%s' is described", name)
+	}
+}
+
+// difference returns items of a missed in b
+func difference(a, b []string) []string {
+	set := make(map[string]bool, len(b))
+
+	for _, item := range b {
+		set[item] = true
+	}
+
+	res := make([]string, 0, len(a))
+
+	for _, item := range a {
+		if !set[item] {
+			res = append(res, item)
+		}
+	}
+
+	return res
+}
+
+func hasPathParam(operation *openapi.Operation, name string) bool {
+	for _, param := range operation.Parameters {
+		if param.In == "path" && param.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func collectRefs(spec *openapi.Document) []string {
+	refs := make([]string, 0, 64)
+
+	var collect func(schema *openapi.Schema)
+
+	collect = func(schema *openapi.Schema) {
+		if schema == nil {
+			return
+		}
+
+		if schema.Ref != "" {
+			refs = append(refs, schema.Ref)
+		}
+
+		collect(schema.Items)
+		collect(schema.AdditionalProperties)
+
+		for _, property := range schema.Properties {
+			collect(property)
+		}
+	}
+
+	for _, schema := range spec.Components.Schemas {
+		collect(schema)
+	}
+
+	for _, item := range spec.Paths {
+		for _, operation := range item {
+			for _, param := range operation.Parameters {
+				collect(param.Schema)
+			}
+
+			if operation.RequestBody != nil {
+				for _, media := range operation.RequestBody.Content {
+					collect(media.Schema)
+				}
+			}
+
+			for _, response := range operation.Responses {
+				for _, media := range response.Content {
+					collect(media.Schema)
+				}
+			}
+		}
+	}
+
+	return refs
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/blent/beagle/server/storage"
+)
+
+// RuleRegistrar registers unregistered peripherals by enabled rules or queues them for a review.
+// Rules and keys of peripherals which are not matched or already have candidates are cached
+// until the next mutation made through storage.Manager.
+type RuleRegistrar struct {
+	mu         *sync.RWMutex
+	db         *storage.Manager
+	generation uint64
+	rules      []*tracking.Rule
+	skipped    map[string]bool
+}
+
+func NewRuleRegistrar(db *storage.Manager) *RuleRegistrar {
+	r := &RuleRegistrar{
+		mu:      &sync.RWMutex{},
+		db:      db,
+		skipped: make(map[string]bool),
+	}
+
+	db.OnChange(func(change storage.Change) {
+		r.Invalidate()
+	})
+
+	return r
+}
+
+func (r *RuleRegistrar) Register(peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	key := peripheral.UniqueKey()
+
+	r.mu.RLock()
+	skipped := r.skipped[key]
+	rules := r.rules
+	generation := r.generation
+	r.mu.RUnlock()
+
+	if skipped {
+		return nil, nil
+	}
+
+	if rules == nil {
+		loaded, err := r.db.GetEnabledRules()
+
+		if err != nil {
+			return nil, err
+		}
+
+		rules = loaded
+
+		r.mu.Lock()
+
+		if generation == r.generation {
+			r.rules = rules
+		}
+
+		r.mu.Unlock()
+	}
+
+	rule := tracking.FindBestRule(rules, peripheral)
+
+	if rule == nil {
+		r.skip(key, generation)
+
+		return nil, nil
+	}
+
+	candidate, err := r.db.GetCandidateByKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate != nil {
+		r.skip(key, generation)
+
+		return nil, nil
+	}
+
+	return r.db.ApplyRule(rule, peripheral)
+}
+
+// Invalidate drops cached rules and skipped keys
+func (r *RuleRegistrar) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	r.rules = nil
+	r.skipped = make(map[string]bool)
+}
+
+// skip caches a key unless the cache has been invalidated since a lookup started
+func (r *RuleRegistrar) skip(key string, generation uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if generation != r.generation {
+		return
+	}
+
+	if len(r.skipped) >= registryCacheSize {
+		r.skipped = make(map[string]bool)
+	}
+
+	r.skipped[key] = true
+}
@@ -1,9 +1,14 @@
 package server
 
 import (
+	"fmt"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
 	"github.com/blent/beagle/pkg/notification"
 	"github.com/blent/beagle/pkg/tracking"
 	"github.com/blent/beagle/server/storage"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type Registry struct {
@@ -14,14 +19,330 @@ func NewRegistry(db *storage.Manager) (*Registry, error) {
 	return &Registry{db}, nil
 }
 
-func (r *Registry) FindTarget(key string) (*tracking.Peripheral, error) {
-	return r.db.GetPeripheralByKey(key)
+// FindTarget returns a registration of a peripheral by its key, or the most specific pattern registration matching it
+func (r *Registry) FindTarget(peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	target, err := r.db.GetPeripheralByKey(peripheral.UniqueKey())
+
+	if err != nil || target != nil {
+		return target, err
+	}
+
+	patterns, err := r.db.GetPeripheralPatterns()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tracking.FindBestMatch(patterns, peripheral), nil
 }
 
-func (r *Registry) FindSubscribers(targetId uint64, events ...string) ([]*notification.Subscriber, error) {
-	return r.db.GetPeripheralSubscribersByEvent(
-		targetId,
+// FindSubscribers returns subscribers of a registered peripheral and member subscribers of groups it belongs to,
+// including groups matching uuid and major of unregistered iBeacons
+func (r *Registry) FindSubscribers(targetId uint64, peripheral peripherals.Peripheral, events ...string) ([]*notification.Subscriber, error) {
+	subscribers := make([]*notification.Subscriber, 0, 5)
+
+	if targetId > 0 {
+		found, err := r.db.GetPeripheralSubscribersByEvent(
+			targetId,
+			events,
+			storage.PERIPHERAL_STATUS_ENABLED,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		subscribers = append(subscribers, found...)
+	}
+
+	groups, err := r.FindGroups(targetId, peripheral)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		found, err := r.db.GetGroupSubscribersByEvent(
+			group.Id,
+			events,
+			storage.PERIPHERAL_STATUS_ENABLED,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		subscribers = append(subscribers, filterSubscribers(found, false)...)
+	}
+
+	return subscribers, nil
+}
+
+// FindGroups returns enabled groups a peripheral is added to, and for iBeacons, groups matching their uuid and major
+func (r *Registry) FindGroups(targetId uint64, peripheral peripherals.Peripheral) ([]*tracking.Group, error) {
+	filter := &storage.MemberFilter{
+		TargetId: targetId,
+		Status:   storage.PERIPHERAL_STATUS_ENABLED,
+	}
+
+	if peripheral.Kind() == peripherals.PERIPHERAL_IBEACON {
+		uuid, major, _, err := peripherals.ParseIBeaconUniqueKey(peripheral.UniqueKey())
+
+		if err == nil {
+			filter.Uuid = uuid
+			filter.Major = major
+		}
+	}
+
+	return r.db.GetPeripheralGroups(filter)
+}
+
+// FindGroupSubscribers returns presence subscribers of a group, member ones are returned by FindSubscribers
+func (r *Registry) FindGroupSubscribers(groupId uint64, events ...string) ([]*notification.Subscriber, error) {
+	subscribers, err := r.db.GetGroupSubscribersByEvent(
+		groupId,
 		events,
 		storage.PERIPHERAL_STATUS_ENABLED,
 	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return filterSubscribers(subscribers, true), nil
+}
+
+// filterSubscribers returns group subscribers which are in the presence mode or not
+func filterSubscribers(subscribers []*notification.Subscriber, presence bool) []*notification.Subscriber {
+	res := make([]*notification.Subscriber, 0, len(subscribers))
+
+	for _, subscriber := range subscribers {
+		if subscriber.IsPresence() == presence {
+			res = append(res, subscriber)
+		}
+	}
+
+	return res
+}
+
+// max number of cached lookups of each kind, the cache is reset once it is reached
+const registryCacheSize = 10000
+
+// CachingRegistry caches peripherals by key, including unregistered ones, their groups and subscribers.
+// The cache is invalidated by mutations made through storage.Manager.
+type CachingRegistry struct {
+	mu            *sync.RWMutex
+	source        notification.Registry
+	generation    uint64
+	targets       map[string]*tracking.Peripheral
+	subscribers   map[string][]*notification.Subscriber
+	groups        map[string][]*tracking.Group
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+}
+
+func NewCachingRegistry(source notification.Registry) *CachingRegistry {
+	return &CachingRegistry{
+		mu:          &sync.RWMutex{},
+		source:      source,
+		targets:     make(map[string]*tracking.Peripheral),
+		subscribers: make(map[string][]*notification.Subscriber),
+		groups:      make(map[string][]*tracking.Group),
+	}
+}
+
+func (r *CachingRegistry) Use(db *storage.Manager) *CachingRegistry {
+	if db == nil {
+		return r
+	}
+
+	db.OnChange(func(change storage.Change) {
+		// candidates are a review queue, lookups do not depend on them
+		if change.Entity == storage.ENTITY_CANDIDATE {
+			return
+		}
+
+		r.Invalidate()
+	})
+
+	return r
+}
+
+func (r *CachingRegistry) FindTarget(peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	// address patterns may match the same key differently, since addresses of some peripherals are random
+	key := peripheral.UniqueKey() + "@" + peripheral.Address()
+
+	r.mu.RLock()
+	target, found := r.targets[key]
+	generation := r.generation
+	r.mu.RUnlock()
+
+	if found {
+		atomic.AddUint64(&r.hits, 1)
+
+		return target, nil
+	}
+
+	atomic.AddUint64(&r.misses, 1)
+
+	target, err := r.source.FindTarget(peripheral)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// skips results that may have been loaded before an invalidation
+	if generation == r.generation {
+		if len(r.targets) >= registryCacheSize {
+			r.targets = make(map[string]*tracking.Peripheral)
+		}
+
+		// nil marks an unregistered key
+		r.targets[key] = target
+	}
+
+	return target, nil
+}
+
+func (r *CachingRegistry) FindSubscribers(targetId uint64, peripheral peripherals.Peripheral, events ...string) ([]*notification.Subscriber, error) {
+	// subscribers of groups depend on the key as groups do
+	cacheKey := fmt.Sprintf("%d:%s:%s", targetId, peripheral.UniqueKey(), strings.Join(events, ","))
+
+	r.mu.RLock()
+	subscribers, found := r.subscribers[cacheKey]
+	generation := r.generation
+	r.mu.RUnlock()
+
+	if found {
+		atomic.AddUint64(&r.hits, 1)
+
+		return subscribers, nil
+	}
+
+	atomic.AddUint64(&r.misses, 1)
+
+	subscribers, err := r.source.FindSubscribers(targetId, peripheral, events...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if generation == r.generation {
+		if len(r.subscribers) >= registryCacheSize {
+			r.subscribers = make(map[string][]*notification.Subscriber)
+		}
+
+		r.subscribers[cacheKey] = subscribers
+	}
+
+	return subscribers, nil
+}
+
+func (r *CachingRegistry) FindGroups(targetId uint64, peripheral peripherals.Peripheral) ([]*tracking.Group, error) {
+	// unregistered peripherals can only match by their key
+	cacheKey := fmt.Sprintf("%d:%s", targetId, peripheral.UniqueKey())
+
+	r.mu.RLock()
+	groups, found := r.groups[cacheKey]
+	generation := r.generation
+	r.mu.RUnlock()
+
+	if found {
+		atomic.AddUint64(&r.hits, 1)
+
+		return groups, nil
+	}
+
+	atomic.AddUint64(&r.misses, 1)
+
+	groups, err := r.source.FindGroups(targetId, peripheral)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if generation == r.generation {
+		if len(r.groups) >= registryCacheSize {
+			r.groups = make(map[string][]*tracking.Group)
+		}
+
+		r.groups[cacheKey] = groups
+	}
+
+	return groups, nil
+}
+
+func (r *CachingRegistry) FindGroupSubscribers(groupId uint64, events ...string) ([]*notification.Subscriber, error) {
+	// group subscribers share the map with peripheral ones, so their keys are prefixed
+	cacheKey := fmt.Sprintf("group:%d:%s", groupId, strings.Join(events, ","))
+
+	r.mu.RLock()
+	subscribers, found := r.subscribers[cacheKey]
+	generation := r.generation
+	r.mu.RUnlock()
+
+	if found {
+		atomic.AddUint64(&r.hits, 1)
+
+		return subscribers, nil
+	}
+
+	atomic.AddUint64(&r.misses, 1)
+
+	subscribers, err := r.source.FindGroupSubscribers(groupId, events...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if generation == r.generation {
+		if len(r.subscribers) >= registryCacheSize {
+			r.subscribers = make(map[string][]*notification.Subscriber)
+		}
+
+		r.subscribers[cacheKey] = subscribers
+	}
+
+	return subscribers, nil
+}
+
+// Invalidate drops all cached lookups.
+// Mutations are rare compared to lookups, so there is no need for finer invalidation.
+func (r *CachingRegistry) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	r.targets = make(map[string]*tracking.Peripheral)
+	r.subscribers = make(map[string][]*notification.Subscriber)
+	r.groups = make(map[string][]*tracking.Group)
+
+	atomic.AddUint64(&r.invalidations, 1)
+}
+
+func (r *CachingRegistry) CacheStats() *storage.CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return &storage.CacheStats{
+		Targets:       len(r.targets),
+		Subscribers:   len(r.subscribers),
+		Groups:        len(r.groups),
+		Hits:          atomic.LoadUint64(&r.hits),
+		Misses:        atomic.LoadUint64(&r.misses),
+		Invalidations: atomic.LoadUint64(&r.invalidations),
+	}
 }
@@ -0,0 +1,558 @@
+package server_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/blent/beagle/server"
+	"github.com/blent/beagle/server/initialization/initializers"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/storage/providers/sqlite"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func createManager(t *testing.T) (*storage.Manager, func()) {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := sqlite.NewSQLiteProvider(filepath.Join(dir, "database.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := initializers.NewDatabaseInitializer(zap.NewNop(), provider).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	return storage.NewManager(zap.NewNop(), provider), func() {
+		provider.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func createTestPeripheral(key string) peripherals.Peripheral {
+	return peripherals.NewMockPeripheral(key, peripherals.PERIPHERAL_IBEACON, "", nil, 0, 0, "")
+}
+
+func TestCachingRegistry(t *testing.T) {
+	manager, cleanup := createManager(t)
+	defer cleanup()
+
+	source, err := server.NewRegistry(manager)
+
+	assert.NoError(t, err)
+
+	registry := server.NewCachingRegistry(source).Use(manager)
+
+	// unregistered keys are cached as well
+	for i := 0; i < 2; i++ {
+		target, err := registry.FindTarget(createTestPeripheral("test"))
+
+		assert.NoError(t, err)
+		assert.Nil(t, target, "unregistered")
+	}
+
+	stats := registry.CacheStats()
+
+	assert.Equal(t, uint64(1), stats.Hits, "hits")
+	assert.Equal(t, uint64(1), stats.Misses, "misses")
+	assert.Equal(t, 1, stats.Targets, "targets")
+
+	endpointId, err := manager.CreateEndpoint(&notification.Endpoint{
+		Name:   "test",
+		Url:    "http://localhost",
+		Method: "GET",
+		Kind:   notification.ENDPOINT_KIND_HTTP,
+	})
+
+	assert.NoError(t, err)
+
+	id, err := manager.CreatePeripheral(&tracking.Peripheral{
+		Key:     "test",
+		Name:    "test",
+		Kind:    "ibeacon",
+		Enabled: true,
+	}, []*notification.Subscriber{{
+		Name:     "test",
+		Event:    notification.FOUND,
+		Enabled:  true,
+		Endpoint: &notification.Endpoint{Id: endpointId},
+	}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), registry.CacheStats().Invalidations, "invalidations")
+
+	target, err := registry.FindTarget(createTestPeripheral("test"))
+
+	assert.NoError(t, err)
+	assert.NotNil(t, target, "registered")
+	assert.Equal(t, id, target.Id, "target id")
+
+	for i := 0; i < 2; i++ {
+		subscribers, err := registry.FindSubscribers(id, createTestPeripheral("test"), notification.FOUND, "*")
+
+		assert.NoError(t, err)
+		assert.Len(t, subscribers, 1, "subscribers")
+		assert.Equal(t, "http://localhost", subscribers[0].Endpoint.Url, "endpoint url")
+	}
+
+	err = manager.UpdateEndpoint(&notification.Endpoint{
+		Id:     endpointId,
+		Name:   "test",
+		Url:    "http://localhost/updated",
+		Method: "GET",
+		Kind:   notification.ENDPOINT_KIND_HTTP,
+	})
+
+	assert.NoError(t, err)
+
+	subscribers, err := registry.FindSubscribers(id, createTestPeripheral("test"), notification.FOUND, "*")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost/updated", subscribers[0].Endpoint.Url, "updated endpoint url")
+
+	assert.NoError(t, manager.DeletePeripheral(id))
+
+	target, err = registry.FindTarget(createTestPeripheral("test"))
+
+	assert.NoError(t, err)
+	assert.Nil(t, target, "deleted")
+
+	stats = registry.CacheStats()
+
+	assert.Equal(t, uint64(2), stats.Hits, "hits")
+	assert.Equal(t, uint64(5), stats.Misses, "misses")
+	assert.Equal(t, uint64(4), stats.Invalidations, "invalidations")
+}
+
+func TestRegistryGroups(t *testing.T) {
+	manager, cleanup := createManager(t)
+	defer cleanup()
+
+	source, err := server.NewRegistry(manager)
+
+	assert.NoError(t, err)
+
+	registry := server.NewCachingRegistry(source).Use(manager)
+
+	endpointId, err := manager.CreateEndpoint(&notification.Endpoint{
+		Name:   "test",
+		Url:    "http://localhost",
+		Method: "GET",
+		Kind:   notification.ENDPOINT_KIND_HTTP,
+	})
+
+	assert.NoError(t, err)
+
+	uuid := "b9407f30f5f8466eaff925556b57fe6d"
+	key := peripherals.CreateIBeaconUniqueKey(uuid, 1, 2)
+
+	targetId, err := manager.CreatePeripheral(&tracking.Peripheral{
+		Key:     key,
+		Name:    "desk",
+		Kind:    peripherals.PERIPHERAL_IBEACON,
+		Enabled: true,
+	}, []*notification.Subscriber{{
+		Name:     "desk",
+		Event:    notification.FOUND,
+		Enabled:  true,
+		Endpoint: &notification.Endpoint{Id: endpointId},
+	}})
+
+	assert.NoError(t, err)
+
+	added, err := manager.CreateGroup(
+		&tracking.Group{Name: "office", Enabled: true},
+		[]uint64{targetId},
+		[]*notification.Subscriber{{
+			Name:     "office",
+			Event:    notification.LOST,
+			Enabled:  true,
+			Mode:     notification.SUBSCRIBER_MODE_PRESENCE,
+			Endpoint: &notification.Endpoint{Id: endpointId},
+		}},
+	)
+
+	assert.NoError(t, err)
+
+	wildcard, err := manager.CreateGroup(
+		&tracking.Group{Name: "floor", Enabled: true, Uuid: uuid, Major: 1},
+		nil,
+		[]*notification.Subscriber{{
+			Name:     "floor",
+			Event:    "*",
+			Enabled:  true,
+			Endpoint: &notification.Endpoint{Id: endpointId},
+		}},
+	)
+
+	assert.NoError(t, err)
+
+	_, err = manager.CreateGroup(&tracking.Group{Name: "building", Enabled: false, Uuid: uuid}, nil, nil)
+
+	assert.NoError(t, err)
+
+	_, err = manager.CreateGroup(&tracking.Group{Name: "other", Enabled: true, Uuid: uuid, Major: 2}, nil, nil)
+
+	assert.NoError(t, err)
+
+	groups, err := registry.FindGroups(targetId, createTestPeripheral(key))
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, groups, 2, "added and matching enabled groups") {
+		assert.Equal(t, added, groups[0].Id)
+		assert.Equal(t, wildcard, groups[1].Id)
+	}
+
+	groups, err = registry.FindGroups(0, createTestPeripheral(peripherals.CreateIBeaconUniqueKey(uuid, 1, 3)))
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, groups, 1, "unregistered iBeacon matches by uuid and major") {
+		assert.Equal(t, wildcard, groups[0].Id)
+	}
+
+	subscribers, err := registry.FindGroupSubscribers(added, notification.LOST, "*")
+
+	assert.NoError(t, err)
+	assert.Len(t, subscribers, 1, "presence subscribers")
+
+	subscribers, err = registry.FindGroupSubscribers(wildcard, notification.FOUND, "*")
+
+	assert.NoError(t, err)
+	assert.Empty(t, subscribers, "member subscribers are not presence ones")
+
+	subscribers, err = registry.FindSubscribers(targetId, createTestPeripheral(key), notification.FOUND, notification.LOST, "*")
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, subscribers, 2, "peripheral and member subscribers") {
+		assert.Equal(t, "desk", subscribers[0].Name)
+		assert.Equal(t, "floor", subscribers[1].Name)
+		assert.Equal(t, notification.SUBSCRIBER_MODE_MEMBER, subscribers[1].Mode, "default mode")
+	}
+
+	unregistered, err := registry.FindSubscribers(0, createTestPeripheral(peripherals.CreateIBeaconUniqueKey(uuid, 1, 3)), notification.FOUND, "*")
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, unregistered, 1, "unregistered iBeacon matches wildcard member subscribers") {
+		assert.Equal(t, "floor", unregistered[0].Name)
+	}
+
+	// updating subscribers of the peripheral must not touch those of the group
+	subscribers = subscribers[:1]
+	subscribers[0].Name = "updated"
+
+	assert.NoError(t, manager.UpdatePeripheral(&tracking.Peripheral{
+		Id:      targetId,
+		Key:     key,
+		Name:    "desk",
+		Kind:    peripherals.PERIPHERAL_IBEACON,
+		Enabled: true,
+	}, subscribers))
+
+	subscribers, err = registry.FindGroupSubscribers(added, notification.LOST, "*")
+
+	assert.NoError(t, err)
+	assert.Len(t, subscribers, 1, "group subscribers after peripheral update")
+
+	assert.NoError(t, manager.DeletePeripheral(targetId))
+
+	_, members, _, err := manager.GetGroupWithDetails(added)
+
+	assert.NoError(t, err)
+	assert.Empty(t, members, "deleted peripheral is removed from groups")
+
+	assert.NoError(t, manager.DeleteGroup(added))
+
+	subscribers, err = registry.FindGroupSubscribers(added, notification.LOST, "*")
+
+	assert.NoError(t, err)
+	assert.Empty(t, subscribers, "subscribers of deleted group")
+}
+
+func TestManagerReplaceSubscribers(t *testing.T) {
+	manager, cleanup := createManager(t)
+	defer cleanup()
+
+	endpointId, err := manager.CreateEndpoint(&notification.Endpoint{
+		Name:   "test",
+		Url:    "http://localhost",
+		Method: "GET",
+		Kind:   notification.ENDPOINT_KIND_HTTP,
+	})
+
+	assert.NoError(t, err)
+
+	subscriber := func(name string) []*notification.Subscriber {
+		return []*notification.Subscriber{{
+			Name:     name,
+			Event:    notification.FOUND,
+			Enabled:  true,
+			Endpoint: &notification.Endpoint{Id: endpointId},
+		}}
+	}
+
+	desk := &tracking.Peripheral{
+		Key:     peripherals.CreateIBeaconUniqueKey("b9407f30f5f8466eaff925556b57fe6d", 1, 2),
+		Name:    "desk",
+		Kind:    peripherals.PERIPHERAL_IBEACON,
+		Enabled: true,
+	}
+
+	desk.Id, err = manager.CreatePeripheral(desk, subscriber("desk"))
+
+	assert.NoError(t, err)
+
+	doorId, err := manager.CreatePeripheral(&tracking.Peripheral{
+		Key:     peripherals.CreateIBeaconUniqueKey("b9407f30f5f8466eaff925556b57fe6d", 1, 3),
+		Name:    "door",
+		Kind:    peripherals.PERIPHERAL_IBEACON,
+		Enabled: true,
+	}, subscriber("door"))
+
+	assert.NoError(t, err)
+
+	group := &tracking.Group{Name: "office", Enabled: true}
+
+	group.Id, err = manager.CreateGroup(group, []uint64{desk.Id}, subscriber("office"))
+
+	assert.NoError(t, err)
+
+	// only new subscribers replace the old ones
+	assert.NoError(t, manager.UpdatePeripheral(desk, subscriber("replaced")))
+
+	_, subscribers, err := manager.GetPeripheralWithSubscribers(desk.Id)
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, subscribers, 1, "replaced subscribers") {
+		assert.Equal(t, "replaced", subscribers[0].Name)
+	}
+
+	// an empty list deletes all subscribers of the owner only
+	assert.NoError(t, manager.UpdatePeripheral(desk, []*notification.Subscriber{}))
+
+	_, subscribers, err = manager.GetPeripheralWithSubscribers(desk.Id)
+
+	assert.NoError(t, err)
+	assert.Empty(t, subscribers, "deleted subscribers")
+
+	_, subscribers, err = manager.GetPeripheralWithSubscribers(doorId)
+
+	assert.NoError(t, err)
+	assert.Len(t, subscribers, 1, "subscribers of another peripheral")
+
+	_, _, subscribers, err = manager.GetGroupWithDetails(group.Id)
+
+	assert.NoError(t, err)
+	assert.Len(t, subscribers, 1, "subscribers of a group")
+
+	assert.NoError(t, manager.UpdateGroup(group, []uint64{desk.Id}, nil))
+
+	_, _, subscribers, err = manager.GetGroupWithDetails(group.Id)
+
+	assert.NoError(t, err)
+	assert.Empty(t, subscribers, "deleted subscribers of a group")
+
+	_, subscribers, err = manager.GetPeripheralWithSubscribers(doorId)
+
+	assert.NoError(t, err)
+	assert.Len(t, subscribers, 1, "subscribers of another peripheral after a group update")
+}
+
+func TestRegistryPatterns(t *testing.T) {
+	manager, cleanup := createManager(t)
+	defer cleanup()
+
+	source, err := server.NewRegistry(manager)
+
+	assert.NoError(t, err)
+
+	registry := server.NewCachingRegistry(source).Use(manager)
+	uuid := "b9407f30f5f8466eaff925556b57fe6d"
+
+	lot, err := manager.CreatePeripheral(&tracking.Peripheral{
+		Key:     uuid + ":1:*",
+		Name:    "lot",
+		Kind:    peripherals.PERIPHERAL_IBEACON,
+		Enabled: true,
+		Pattern: true,
+	}, nil)
+
+	assert.NoError(t, err)
+
+	target, err := registry.FindTarget(createTestPeripheral(peripherals.CreateIBeaconUniqueKey(uuid, 1, 2)))
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, target, "matched by pattern") {
+		assert.Equal(t, lot, target.Id)
+		assert.True(t, target.Pattern)
+	}
+
+	target, err = registry.FindTarget(createTestPeripheral(peripherals.CreateIBeaconUniqueKey(uuid, 2, 2)))
+
+	assert.NoError(t, err)
+	assert.Nil(t, target, "other major")
+
+	exact, err := manager.CreatePeripheral(&tracking.Peripheral{
+		Key:     peripherals.CreateIBeaconUniqueKey(uuid, 1, 2),
+		Name:    "exact",
+		Kind:    peripherals.PERIPHERAL_IBEACON,
+		Enabled: true,
+	}, nil)
+
+	assert.NoError(t, err)
+
+	target, err = registry.FindTarget(createTestPeripheral(peripherals.CreateIBeaconUniqueKey(uuid, 1, 2)))
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, target, "exact registration") {
+		assert.Equal(t, exact, target.Id, "exact registration wins")
+	}
+}
+
+func TestRuleRegistrar(t *testing.T) {
+	manager, cleanup := createManager(t)
+	defer cleanup()
+
+	registrar := server.NewRuleRegistrar(manager)
+	uuid := "b9407f30f5f8466eaff925556b57fe6d"
+	badge := func(minor uint16) peripherals.Peripheral {
+		return createTestPeripheral(peripherals.CreateIBeaconUniqueKey(uuid, 1, minor))
+	}
+
+	target, err := registrar.Register(badge(7))
+
+	assert.NoError(t, err)
+	assert.Nil(t, target, "no rules")
+
+	endpointId, err := manager.CreateEndpoint(&notification.Endpoint{
+		Name:   "test",
+		Url:    "http://localhost",
+		Method: "GET",
+		Kind:   notification.ENDPOINT_KIND_HTTP,
+	})
+
+	assert.NoError(t, err)
+
+	ruleId, err := manager.CreateRule(&tracking.Rule{
+		Name:              "badges",
+		Enabled:           true,
+		Key:               uuid + ":1:*",
+		NameTemplate:      "badge-{minor}",
+		Mode:              tracking.RULE_MODE_ACCEPT,
+		PeripheralEnabled: true,
+	}, []*notification.Subscriber{{
+		Name:     "arrival",
+		Event:    notification.FOUND,
+		Enabled:  true,
+		Endpoint: &notification.Endpoint{Id: endpointId},
+	}})
+
+	assert.NoError(t, err)
+
+	target, err = registrar.Register(badge(7))
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, target, "registered by the rule") {
+		assert.Equal(t, "badge-7", target.Name)
+		assert.True(t, target.Enabled)
+
+		subscribers, err := manager.GetPeripheralSubscribersByEvent(target.Id, []string{notification.FOUND}, storage.PERIPHERAL_STATUS_ANY)
+
+		assert.NoError(t, err)
+
+		if assert.Len(t, subscribers, 1, "copied subscribers") {
+			assert.Equal(t, "arrival", subscribers[0].Name)
+			assert.Equal(t, uint64(0), subscribers[0].RuleId)
+		}
+	}
+
+	candidate, err := manager.GetCandidateByKey(badge(7).UniqueKey())
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, candidate, "audit trail") {
+		assert.Equal(t, tracking.CANDIDATE_STATUS_REGISTERED, candidate.Status)
+		assert.Equal(t, ruleId, candidate.RuleId)
+	}
+
+	// rule subscribers are not listed with subscribers of peripherals
+	_, quantity, _, err := manager.FindSubscribers(storage.NewSubscriberQuery(0, 0, 0, nil, storage.PERIPHERAL_STATUS_ANY))
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), quantity, "subscribers of peripherals")
+
+	// a peripheral named badge-8 already exists
+	_, err = manager.CreatePeripheral(&tracking.Peripheral{
+		Key:     "other",
+		Name:    "badge-8",
+		Kind:    peripherals.PERIPHERAL_IBEACON,
+		Enabled: true,
+	}, nil)
+
+	assert.NoError(t, err)
+
+	target, err = registrar.Register(badge(8))
+
+	assert.NoError(t, err)
+	assert.Nil(t, target, "name conflict")
+
+	candidate, err = manager.GetCandidateByKey(badge(8).UniqueKey())
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, candidate, "failed candidate") {
+		assert.Equal(t, tracking.CANDIDATE_STATUS_FAILED, candidate.Status)
+		assert.NotEmpty(t, candidate.Message)
+	}
+
+	err = manager.UpdateRule(&tracking.Rule{
+		Id:           ruleId,
+		Name:         "badges",
+		Enabled:      true,
+		Key:          uuid + ":1:*",
+		NameTemplate: "badge-{minor}",
+		Mode:         tracking.RULE_MODE_REVIEW,
+	}, nil)
+
+	assert.NoError(t, err)
+
+	source, err := server.NewRegistry(manager)
+
+	assert.NoError(t, err)
+
+	registry := server.NewCachingRegistry(source).Use(manager)
+	invalidations := registry.CacheStats().Invalidations
+
+	target, err = registrar.Register(badge(9))
+
+	assert.NoError(t, err)
+	assert.Nil(t, target, "queued for a review")
+	assert.Equal(t, invalidations, registry.CacheStats().Invalidations, "queued candidates keep the cache")
+
+	candidate, err = manager.GetCandidateByKey(badge(9).UniqueKey())
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, candidate, "pending candidate") {
+		assert.Equal(t, tracking.CANDIDATE_STATUS_PENDING, candidate.Status)
+		assert.Equal(t, "badge-9", candidate.Name)
+	}
+}
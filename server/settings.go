@@ -1,23 +1,43 @@
 package server
 
 import (
+	"github.com/blent/beagle/pkg/auth"
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/pkg/streaming"
 	"github.com/blent/beagle/pkg/tracking"
 	"github.com/blent/beagle/server/http"
 	"github.com/blent/beagle/server/storage"
 	"time"
 )
 
-type Settings struct {
-	Version  string
-	Name     string
-	Http     *http.Settings
-	Storage  *storage.Settings
-	Tracking *tracking.Settings
-}
+type (
+	Settings struct {
+		Version         string              `yaml:"-"`
+		Name            string              `yaml:"name"`
+		ShutdownTimeout time.Duration       `yaml:"shutdownTimeout"`
+		Log             *LogSettings        `yaml:"log"`
+		Http            *http.Settings      `yaml:"http"`
+		Auth            *auth.Settings      `yaml:"auth"`
+		Storage         *storage.Settings   `yaml:"storage"`
+		Device          *devices.Settings   `yaml:"device"`
+		Tracking        *tracking.Settings  `yaml:"tracking"`
+		Delivery        *delivery.Settings  `yaml:"delivery"`
+		Streaming       *streaming.Settings `yaml:"streaming"`
+	}
+
+	LogSettings struct {
+		Level string `yaml:"level"`
+	}
+)
 
 func NewDefaultSettings() *Settings {
 	return &Settings{
-		Name: "beagle",
+		Name:            "beagle",
+		ShutdownTimeout: time.Second * 10,
+		Log: &LogSettings{
+			Level: "info",
+		},
 		Http: &http.Settings{
 			Port:     8080,
 			Enabled:  true,
@@ -29,14 +49,63 @@ func NewDefaultSettings() *Settings {
 				Route:     "/",
 				Directory: "",
 			},
+			Tls: &http.TlsSettings{
+				Enabled:     false,
+				Certificate: "/var/lib/beagle/tls/certificate.pem",
+				Key:         "/var/lib/beagle/tls/key.pem",
+				SelfSigned:  true,
+				ClientCa:    "",
+				ClientAuth:  http.CLIENT_AUTH_REQUIRED,
+			},
+			Metrics: &http.MetricsSettings{
+				Enabled: true,
+				Route:   "/metrics",
+			},
+		},
+		Auth: &auth.Settings{
+			Enabled:    true,
+			SessionTtl: time.Hour * 24,
+			Admin: &auth.AdminSettings{
+				Username: "admin",
+				Password: "",
+			},
 		},
 		Storage: &storage.Settings{
 			ConnectionString: "/var/lib/beagle/database.db",
 			Provider:         "sqlite3",
 		},
+		Device: &devices.Settings{
+			Kind:   devices.DEVICE_KIND_BLE,
+			Hci:    0,
+			Replay: "",
+			Simulated: &devices.SimulatedSettings{
+				Peripherals: 10,
+				Interval:    time.Second,
+				Scenario:    "",
+			},
+		},
 		Tracking: &tracking.Settings{
 			Heartbeat: time.Second * 5,
 			Ttl:       time.Second * 5,
 		},
+		Delivery: &delivery.Settings{
+			Policy: &delivery.PolicySettings{
+				RateLimit:        0,
+				Burst:            1,
+				MaxInFlight:      10,
+				Timeout:          time.Second * 30,
+				BreakerThreshold: 5,
+				BreakerCooldown:  time.Second * 30,
+			},
+			Command: &delivery.CommandSettings{
+				Enabled:     false,
+				Timeout:     time.Second * 10,
+				Concurrency: 4,
+			},
+		},
+		Streaming: &streaming.Settings{
+			History: 100,
+			Buffer:  64,
+		},
 	}
 }
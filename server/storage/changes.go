@@ -0,0 +1,34 @@
+package storage
+
+import "time"
+
+const (
+	ENTITY_PERIPHERAL = "peripheral"
+	ENTITY_SUBSCRIBER = "subscriber"
+	ENTITY_ENDPOINT   = "endpoint"
+	ENTITY_GROUP      = "group"
+	ENTITY_RULE       = "rule"
+	ENTITY_CANDIDATE  = "candidate"
+)
+
+type (
+	// Change describes a successful mutation of stored entities
+	Change struct {
+		Entity string
+		Ids    []uint64
+	}
+
+	ChangeListener func(change Change)
+
+	// QueryObserver receives a name and a duration of a storage operation
+	QueryObserver func(operation string, duration time.Duration)
+
+	CacheStats struct {
+		Targets       int    `json:"targets"`
+		Subscribers   int    `json:"subscribers"`
+		Groups        int    `json:"groups"`
+		Hits          uint64 `json:"hits"`
+		Misses        uint64 `json:"misses"`
+		Invalidations uint64 `json:"invalidations"`
+	}
+)
@@ -0,0 +1,8 @@
+package storage
+
+import "github.com/pkg/errors"
+
+var (
+	ErrNotFound = errors.New("entity not found")
+	ErrConflict = errors.New("entity with the same unique value already exists")
+)
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"time"
+)
+
+func (m *Manager) FindGroups(query *GroupQuery) ([]*tracking.Group, uint64, error) {
+	defer m.observe("find_groups", time.Now())
+
+	res, err := m.groups.Find(query)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := m.groups.Count(query.GroupFilter)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return res, count, nil
+}
+
+func (m *Manager) GetGroup(id uint64) (*tracking.Group, error) {
+	defer m.observe("get_group", time.Now())
+
+	return m.groups.Get(id)
+}
+
+// GetGroupWithDetails returns a group with ids of its added members and its subscribers
+func (m *Manager) GetGroupWithDetails(id uint64) (*tracking.Group, []uint64, []*notification.Subscriber, error) {
+	defer m.observe("get_group_with_details", time.Now())
+
+	group, err := m.groups.Get(id)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if group == nil {
+		return nil, nil, nil, nil
+	}
+
+	members, err := m.groups.GetMembers(id)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	subscribers, err := m.subscribers.Find(NewGroupSubscriberQuery(
+		0,
+		0,
+		id,
+		nil,
+		PERIPHERAL_STATUS_ANY,
+	))
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return group, members, subscribers, nil
+}
+
+// GetPeripheralGroups returns groups a peripheral belongs to.
+// Unregistered iBeacons have no target id, but belong to groups matching their uuid and major.
+func (m *Manager) GetPeripheralGroups(filter *MemberFilter) ([]*tracking.Group, error) {
+	defer m.observe("get_peripheral_groups", time.Now())
+
+	return m.groups.FindByMember(filter)
+}
+
+func (m *Manager) GetGroupSubscribersByEvent(groupId uint64, eventNames []string, status string) ([]*notification.Subscriber, error) {
+	defer m.observe("get_group_subscribers_by_event", time.Now())
+
+	return m.subscribers.Find(NewGroupSubscriberQuery(
+		0,
+		0,
+		groupId,
+		eventNames,
+		status,
+	))
+}
+
+func (m *Manager) CreateGroup(group *tracking.Group, members []uint64, subscribers []*notification.Subscriber) (uint64, error) {
+	defer m.observe("create_group", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := m.groups.Create(group, tx)
+
+	if err != nil {
+		return 0, TryToRollback(tx, err, true)
+	}
+
+	err = m.groups.SetMembers(id, members, tx)
+
+	if err != nil {
+		return 0, TryToRollback(tx, err, true)
+	}
+
+	if len(subscribers) > 0 {
+		err = m.subscribers.CreateManyForGroup(subscribers, id, tx)
+
+		if err != nil {
+			return 0, TryToRollback(tx, err, true)
+		}
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return 0, err
+	}
+
+	m.changed(ENTITY_GROUP, id)
+
+	return id, nil
+}
+
+// UpdateGroup updates a group and replaces its members
+func (m *Manager) UpdateGroup(group *tracking.Group, members []uint64, subscribers []*notification.Subscriber) error {
+	defer m.observe("update_group", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	err = m.groups.Update(group, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.groups.SetMembers(group.Id, members, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.saveSubscribers(subscribers, 0, group.Id, 0, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_GROUP, group.Id)
+
+	return nil
+}
+
+// DeleteGroup deletes a group with its memberships and subscribers
+func (m *Manager) DeleteGroup(id uint64) error {
+	defer m.observe("delete_group", time.Now())
+
+	err := m.deleteGroups([]uint64{id})
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_GROUP, id)
+
+	return nil
+}
+
+func (m *Manager) DeleteGroups(ids []uint64) error {
+	defer m.observe("delete_groups", time.Now())
+
+	err := m.deleteGroups(ids)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_GROUP, ids...)
+
+	return nil
+}
+
+func (m *Manager) deleteGroups(ids []uint64) error {
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		subscribers, err := m.subscribers.Find(NewGroupSubscriberQuery(0, 0, id, nil, PERIPHERAL_STATUS_ANY))
+
+		if err != nil {
+			return TryToRollback(tx, err, true)
+		}
+
+		if len(subscribers) > 0 {
+			subscriberIds := make([]uint64, 0, len(subscribers))
+
+			for _, subscriber := range subscribers {
+				subscriberIds = append(subscriberIds, subscriber.Id)
+			}
+
+			err = m.subscribers.DeleteMany(&DeletionQuery{
+				Id:      subscriberIds,
+				InRange: true,
+				GroupId: id,
+			}, tx)
+
+			if err != nil {
+				return TryToRollback(tx, err, true)
+			}
+		}
+
+		err = m.groups.Delete(id, tx)
+
+		if err != nil {
+			return TryToRollback(tx, err, true)
+		}
+	}
+
+	return TryToCommit(tx, true)
+}
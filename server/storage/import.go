@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+)
+
+type (
+	// PeripheralEntry is a peripheral with its subscribers, a unit of imports and exports
+	PeripheralEntry struct {
+		Peripheral  *tracking.Peripheral
+		Subscribers []*notification.Subscriber
+	}
+
+	// ImportError tells which entry of an import has failed
+	ImportError struct {
+		Index int
+		Err   error
+	}
+)
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("entry %d: %s", e.Index, e.Err)
+}
+
+// Cause lets errors.Cause find the reason of a failure
+func (e *ImportError) Cause() error {
+	return e.Err
+}
+
+// ImportPeripherals creates all entries in a single transaction, so either all of them are created or none.
+// With dryRun the transaction is rolled back, which checks entries against the registry without changing it.
+func (m *Manager) ImportPeripherals(entries []*PeripheralEntry, dryRun bool) ([]uint64, error) {
+	defer m.observe("import_peripherals", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, 0, len(entries))
+
+	for idx, entry := range entries {
+		id, err := m.peripherals.Create(entry.Peripheral, tx)
+
+		if err == nil && len(entry.Subscribers) > 0 {
+			err = m.subscribers.CreateMany(entry.Subscribers, id, tx)
+		}
+
+		if err != nil {
+			return nil, TryToRollback(tx, &ImportError{idx, err}, true)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if dryRun {
+		return ids, tx.Rollback()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	m.changed(ENTITY_PERIPHERAL, ids...)
+
+	return ids, nil
+}
+
+// ExportPeripherals returns all peripherals ordered by id with their subscribers
+func (m *Manager) ExportPeripherals() ([]*PeripheralEntry, error) {
+	defer m.observe("export_peripherals", time.Now())
+
+	targets, err := m.peripherals.Find(&PeripheralQuery{
+		Pagination: NewPagination(0, 0),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	subscribers, err := m.subscribers.Find(NewSubscriberQuery(0, 0, 0, nil, PERIPHERAL_STATUS_ANY))
+
+	if err != nil {
+		return nil, err
+	}
+
+	byTarget := make(map[uint64][]*notification.Subscriber)
+
+	for _, subscriber := range subscribers {
+		if subscriber.TargetId > 0 {
+			byTarget[subscriber.TargetId] = append(byTarget[subscriber.TargetId], subscriber)
+		}
+	}
+
+	entries := make([]*PeripheralEntry, 0, len(targets))
+
+	for _, target := range targets {
+		entries = append(entries, &PeripheralEntry{target, byTarget[target.Id]})
+	}
+
+	return entries, nil
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/blent/beagle/pkg/notification"
 	"github.com/blent/beagle/pkg/tracking"
 	"go.uber.org/zap"
+	"sync"
+	"time"
 )
 
 type Manager struct {
@@ -13,6 +15,15 @@ type Manager struct {
 	peripherals PeripheralRepository
 	subscribers SubscriberRepository
 	endpoints   EndpointRepository
+	groups      GroupRepository
+	users       UserRepository
+	apiKeys     ApiKeyRepository
+	sessions    SessionRepository
+	rules       RuleRepository
+	candidates  CandidateRepository
+	mu          *sync.RWMutex
+	listeners   []ChangeListener
+	observers   []QueryObserver
 }
 
 func NewManager(logger *zap.Logger, provider Provider) *Manager {
@@ -22,34 +33,87 @@ func NewManager(logger *zap.Logger, provider Provider) *Manager {
 		peripherals: provider.GetPeripheralRepository(),
 		subscribers: provider.GetSubscriberRepository(),
 		endpoints:   provider.GetEndpointRepository(),
+		groups:      provider.GetGroupRepository(),
+		users:       provider.GetUserRepository(),
+		apiKeys:     provider.GetApiKeyRepository(),
+		sessions:    provider.GetSessionRepository(),
+		rules:       provider.GetRuleRepository(),
+		candidates:  provider.GetCandidateRepository(),
+		mu:          &sync.RWMutex{},
+		listeners:   make([]ChangeListener, 0, 5),
+		observers:   make([]QueryObserver, 0, 1),
 	}
 }
 
-func (m *Manager) FindPeripherals(query *PeripheralQuery) ([]*tracking.Peripheral, uint64, error) {
+// OnChange registers a listener which is called synchronously after every successful mutation
+func (m *Manager) OnChange(listener ChangeListener) {
+	if listener == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.listeners = append(m.listeners, listener)
+}
+
+// OnQuery registers an observer which is called with a duration of every manager operation
+func (m *Manager) OnQuery(observer QueryObserver) {
+	if observer == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.observers = append(m.observers, observer)
+}
+
+// FindPeripherals returns a page of peripherals, a total quantity of matched ones and a cursor of the next page
+func (m *Manager) FindPeripherals(query *PeripheralQuery) ([]*tracking.Peripheral, uint64, *Cursor, error) {
+	defer m.observe("find_peripherals", time.Now())
+
 	res, err := m.peripherals.Find(query)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	count, err := m.peripherals.Count(query.PeripheralFilter)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
-	return res, count, nil
+	next := nextCursor(query.Pagination, len(res), func() *Cursor {
+		return peripheralCursor(res[len(res)-1], query.Sorting.SortField())
+	})
+
+	return res, count, next, nil
 }
 
 func (m *Manager) GetPeripheral(id uint64) (*tracking.Peripheral, error) {
+	defer m.observe("get_peripheral", time.Now())
+
 	return m.peripherals.Get(id)
 }
 
 func (m *Manager) GetPeripheralByKey(key string) (*tracking.Peripheral, error) {
+	defer m.observe("get_peripheral_by_key", time.Now())
+
 	return m.peripherals.GetByKey(key)
 }
 
+// GetPeripheralPatterns returns registrations matching peripherals by patterns instead of exact keys
+func (m *Manager) GetPeripheralPatterns() ([]*tracking.Peripheral, error) {
+	defer m.observe("get_peripheral_patterns", time.Now())
+
+	return m.peripherals.FindPatterns()
+}
+
 func (m *Manager) GetPeripheralWithSubscribers(id uint64) (*tracking.Peripheral, []*notification.Subscriber, error) {
+	defer m.observe("get_peripheral_with_subscribers", time.Now())
+
 	target, err := m.peripherals.Get(id)
 
 	if err != nil {
@@ -76,6 +140,8 @@ func (m *Manager) GetPeripheralWithSubscribers(id uint64) (*tracking.Peripheral,
 }
 
 func (m *Manager) GetPeripheralSubscribersByEvent(targetId uint64, eventNames []string, status string) ([]*notification.Subscriber, error) {
+	defer m.observe("get_peripheral_subscribers_by_event", time.Now())
+
 	return m.subscribers.Find(NewSubscriberQuery(
 		0,
 		0,
@@ -86,6 +152,8 @@ func (m *Manager) GetPeripheralSubscribersByEvent(targetId uint64, eventNames []
 }
 
 func (m *Manager) CreatePeripheral(target *tracking.Peripheral, subscribers []*notification.Subscriber) (uint64, error) {
+	defer m.observe("create_peripheral", time.Now())
+
 	tx, err := m.db.Begin()
 
 	if err != nil {
@@ -112,10 +180,14 @@ func (m *Manager) CreatePeripheral(target *tracking.Peripheral, subscribers []*n
 		return 0, err
 	}
 
+	m.changed(ENTITY_PERIPHERAL, id)
+
 	return id, nil
 }
 
 func (m *Manager) UpdatePeripheral(target *tracking.Peripheral, subscribers []*notification.Subscriber) error {
+	defer m.observe("update_peripheral", time.Now())
+
 	tx, err := m.db.Begin()
 
 	if err != nil {
@@ -128,96 +200,286 @@ func (m *Manager) UpdatePeripheral(target *tracking.Peripheral, subscribers []*n
 		return TryToRollback(tx, err, true)
 	}
 
-	if subscribers != nil && len(subscribers) > 0 {
-		update := make([]*notification.Subscriber, 0, len(subscribers))
-		create := make([]*notification.Subscriber, 0, len(subscribers))
-		existingIds := make([]uint64, 0, len(subscribers))
-
-		for _, subscriber := range subscribers {
-			if subscriber.Id == 0 {
-				create = append(create, subscriber)
-			} else {
-				update = append(update, subscriber)
-				existingIds = append(existingIds, subscriber.Id)
-			}
-		}
+	err = m.saveSubscribers(subscribers, target.Id, 0, 0, tx)
 
-		if len(update) > 0 {
-			err = m.subscribers.UpdateMany(update, tx)
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
 
-			if err != nil {
-				return TryToRollback(tx, err, true)
-			}
+	err = TryToCommit(tx, true)
 
-			// delete those that are not part of the payload
-			err = m.subscribers.DeleteMany(&DeletionQuery{
-				Id:      existingIds,
-				InRange: false,
-			}, tx)
+	if err != nil {
+		return err
+	}
 
-			if err != nil {
-				return TryToRollback(tx, err, true)
-			}
-		}
+	m.changed(ENTITY_PERIPHERAL, target.Id)
 
-		if len(create) > 0 {
-			err = m.subscribers.CreateMany(create, target.Id, tx)
+	return nil
+}
 
-			if err != nil {
-				return TryToRollback(tx, err, true)
-			}
-		}
+func (m *Manager) DeletePeripheral(id uint64) error {
+	defer m.observe("delete_peripheral", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return err
 	}
 
-	return TryToCommit(tx, true)
-}
+	err = m.groups.DeleteMembers([]uint64{id}, tx)
 
-func (m *Manager) DeletePeripheral(id uint64) error {
-	return m.peripherals.Delete(id, nil)
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.subscribers.DeleteByTargets([]uint64{id}, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.peripherals.Delete(id, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_PERIPHERAL, id)
+
+	return nil
 }
 
 func (m *Manager) DeletePeripherals(ids []uint64) error {
-	return m.peripherals.DeleteMany(&DeletionQuery{
+	defer m.observe("delete_peripherals", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	err = m.groups.DeleteMembers(ids, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.subscribers.DeleteByTargets(ids, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.peripherals.DeleteMany(&DeletionQuery{
 		Id:      ids,
 		InRange: true,
-	}, nil)
+	}, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_PERIPHERAL, ids...)
+
+	return nil
 }
 
-func (m *Manager) FindEndpoints(query *EndpointQuery) ([]*notification.Endpoint, uint64, error) {
+// FindEndpoints returns a page of endpoints, a total quantity of matched ones and a cursor of the next page
+func (m *Manager) FindEndpoints(query *EndpointQuery) ([]*notification.Endpoint, uint64, *Cursor, error) {
+	defer m.observe("find_endpoints", time.Now())
+
 	res, err := m.endpoints.Find(query)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	count, err := m.endpoints.Count(query.EndpointFilter)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
-	return res, count, nil
+	next := nextCursor(query.Pagination, len(res), func() *Cursor {
+		return endpointCursor(res[len(res)-1], query.Sorting.SortField())
+	})
+
+	return res, count, next, nil
 }
 
 func (m *Manager) GetEndpoint(id uint64) (*notification.Endpoint, error) {
+	defer m.observe("get_endpoint", time.Now())
+
 	return m.endpoints.Get(id)
 }
 
 func (m *Manager) CreateEndpoint(endpoint *notification.Endpoint) (uint64, error) {
-	return m.endpoints.Create(endpoint, nil)
+	defer m.observe("create_endpoint", time.Now())
+
+	id, err := m.endpoints.Create(endpoint, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	m.changed(ENTITY_ENDPOINT, id)
+
+	return id, nil
 }
 
 func (m *Manager) UpdateEndpoint(endpoint *notification.Endpoint) error {
-	return m.endpoints.Update(endpoint, nil)
+	defer m.observe("update_endpoint", time.Now())
+
+	err := m.endpoints.Update(endpoint, nil)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_ENDPOINT, endpoint.Id)
+
+	return nil
 }
 
+// DeleteEndpoint deletes an endpoint with all subscribers pointing at it
 func (m *Manager) DeleteEndpoint(id uint64) error {
-	return m.endpoints.Delete(id, nil)
+	defer m.observe("delete_endpoint", time.Now())
+
+	return m.deleteEndpoints([]uint64{id})
 }
 
+// DeleteEndpoints deletes endpoints with all subscribers pointing at them
 func (m *Manager) DeleteEndpoints(ids []uint64) error {
-	return m.endpoints.DeleteMany(&DeletionQuery{
+	defer m.observe("delete_endpoints", time.Now())
+
+	return m.deleteEndpoints(ids)
+}
+
+func (m *Manager) deleteEndpoints(ids []uint64) error {
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	err = m.subscribers.DeleteByEndpoints(ids, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.endpoints.DeleteMany(&DeletionQuery{
 		Id:      ids,
 		InRange: true,
-	}, nil)
+	}, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_ENDPOINT, ids...)
+
+	return nil
+}
+
+// saveSubscribers updates and creates subscribers of a peripheral, a group or a rule.
+// Only subscribers of the same owner are updated, those which are not in the list are deleted.
+func (m *Manager) saveSubscribers(subscribers []*notification.Subscriber, targetId, groupId, ruleId uint64, tx *sql.Tx) error {
+	update := make([]*notification.Subscriber, 0, len(subscribers))
+	create := make([]*notification.Subscriber, 0, len(subscribers))
+	existingIds := make([]uint64, 0, len(subscribers))
+
+	for _, subscriber := range subscribers {
+		subscriber.TargetId = targetId
+		subscriber.GroupId = groupId
+		subscriber.RuleId = ruleId
+
+		if subscriber.Id == 0 {
+			create = append(create, subscriber)
+		} else {
+			update = append(update, subscriber)
+			existingIds = append(existingIds, subscriber.Id)
+		}
+	}
+
+	if len(update) > 0 {
+		if err := m.subscribers.UpdateMany(update, tx); err != nil {
+			return err
+		}
+	}
+
+	// delete those that are not part of the payload, all of them if there are no existing ones,
+	// scoped to the owner since subscribers of all peripherals, groups and rules share the storage
+	err := m.subscribers.DeleteMany(&DeletionQuery{
+		Id:       existingIds,
+		InRange:  false,
+		TargetId: targetId,
+		GroupId:  groupId,
+		RuleId:   ruleId,
+	}, tx)
+
+	if err != nil {
+		return err
+	}
+
+	if len(create) == 0 {
+		return nil
+	}
+
+	if groupId > 0 {
+		return m.subscribers.CreateManyForGroup(create, groupId, tx)
+	}
+
+	if ruleId > 0 {
+		return m.subscribers.CreateManyForRule(create, ruleId, tx)
+	}
+
+	return m.subscribers.CreateMany(create, targetId, tx)
+}
+
+func (m *Manager) changed(entity string, ids ...uint64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	change := Change{
+		Entity: entity,
+		Ids:    ids,
+	}
+
+	for _, listener := range m.listeners {
+		listener(change)
+	}
+}
+
+func (m *Manager) observe(operation string, start time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.observers) == 0 {
+		return
+	}
+
+	duration := time.Since(start)
+
+	for _, observer := range m.observers {
+		observer(operation, duration)
+	}
 }
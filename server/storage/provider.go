@@ -13,6 +13,12 @@ type (
 		GetPeripheralRepository() PeripheralRepository
 		GetSubscriberRepository() SubscriberRepository
 		GetEndpointRepository() EndpointRepository
+		GetGroupRepository() GroupRepository
+		GetUserRepository() UserRepository
+		GetApiKeyRepository() ApiKeyRepository
+		GetSessionRepository() SessionRepository
+		GetRuleRepository() RuleRepository
+		GetCandidateRepository() CandidateRepository
 		Close() error
 	}
 )
@@ -5,7 +5,37 @@ import (
 	"fmt"
 )
 
-type tableCreator func(tx *sql.Tx) error
+type (
+	tableCreator func(tx *sql.Tx) error
+
+	// columnCreator adds a column to a table created by a previous version of the schema,
+	// queries migrate existing rows once the column is added
+	columnCreator struct {
+		table      string
+		column     string
+		definition string
+		queries    []string
+	}
+)
+
+var columnCreators = []columnCreator{
+	{peripheralTableName, "pattern", "pattern INTEGER NOT NULL DEFAULT 0", nil},
+	{endpointTableName, "kind", "kind TEXT NOT NULL DEFAULT 'http'", nil},
+	{endpointTableName, "policy", "policy TEXT", nil},
+	{subscriberTableName, "batch", "batch TEXT", nil},
+	{subscriberTableName, "group_id", fmt.Sprintf("group_id INTEGER REFERENCES %s(id) ON DELETE CASCADE", groupTableName), nil},
+	{subscriberTableName, "rule_id", fmt.Sprintf("rule_id INTEGER REFERENCES %s(id) ON DELETE CASCADE", ruleTableName), nil},
+	// group subscribers used to be notified only by arrivals of first members and departures of last ones
+	{subscriberTableName, "mode", "mode TEXT NOT NULL DEFAULT ''", []string{
+		fmt.Sprintf("UPDATE %s SET mode='presence' WHERE group_id IS NOT NULL;", subscriberTableName),
+	}},
+}
+
+// indexQueries create indexes on columns added by column creators, so they run on every start
+var indexQueries = []string{
+	fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_group_idx on %s(group_id);", subscriberTableName, subscriberTableName),
+	fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_rule_idx on %s(rule_id);", subscriberTableName, subscriberTableName),
+}
 
 func initialize(tx *sql.Tx) (bool, error) {
 	tables, err := getTableCreators(tx)
@@ -14,10 +44,6 @@ func initialize(tx *sql.Tx) (bool, error) {
 		return false, err
 	}
 
-	if len(tables) == 0 {
-		return false, nil
-	}
-
 	for _, table := range tables {
 		if err = table(tx); err != nil {
 			break
@@ -28,7 +54,27 @@ func initialize(tx *sql.Tx) (bool, error) {
 		return false, err
 	}
 
-	return true, nil
+	columns, err := getColumnCreators(tx)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, column := range columns {
+		if err = column(tx); err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if err = execQueries(tx, indexQueries); err != nil {
+		return false, err
+	}
+
+	return len(tables) > 0 || len(columns) > 0, nil
 }
 
 func getTableCreators(tx *sql.Tx) (map[string]tableCreator, error) {
@@ -42,6 +88,13 @@ func getTableCreators(tx *sql.Tx) (map[string]tableCreator, error) {
 	tables[peripheralTableName] = createPeripheralsTable
 	tables[endpointTableName] = createEndpointsTable
 	tables[subscriberTableName] = createSubscribersTable
+	tables[groupTableName] = createGroupsTable
+	tables[groupMemberTableName] = createGroupMembersTable
+	tables[userTableName] = createUsersTable
+	tables[apiKeyTableName] = createApiKeysTable
+	tables[sessionTableName] = createSessionsTable
+	tables[ruleTableName] = createRulesTable
+	tables[candidateTableName] = createCandidatesTable
 
 	for rows.Next() {
 		var name string
@@ -62,6 +115,78 @@ func getTableCreators(tx *sql.Tx) (map[string]tableCreator, error) {
 	return tables, nil
 }
 
+func getColumnCreators(tx *sql.Tx) ([]tableCreator, error) {
+	result := make([]tableCreator, 0, len(columnCreators))
+	existing := make(map[string]map[string]bool)
+
+	for _, creator := range columnCreators {
+		columns, ok := existing[creator.table]
+
+		if !ok {
+			var err error
+
+			columns, err = getTableColumns(tx, creator.table)
+
+			if err != nil {
+				return nil, err
+			}
+
+			existing[creator.table] = columns
+		}
+
+		if columns[creator.column] {
+			continue
+		}
+
+		result = append(result, addColumn(creator.table, creator.definition, creator.queries))
+	}
+
+	return result, nil
+}
+
+func getTableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+
+	for rows.Next() {
+		var cid int
+		var name string
+		var kind string
+		var notNull int
+		var defaultValue sql.NullString
+		var pk int
+
+		err = rows.Scan(&cid, &name, &kind, &notNull, &defaultValue, &pk)
+
+		if err != nil {
+			break
+		}
+
+		columns[name] = true
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return columns, rows.Err()
+}
+
+func addColumn(table, definition string, queries []string) tableCreator {
+	return func(tx *sql.Tx) error {
+		return execQueries(tx, append([]string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, definition),
+		}, queries...))
+	}
+}
+
 func execQueries(tx *sql.Tx, queries []string) error {
 	var err error
 
@@ -86,7 +211,8 @@ func createPeripheralsTable(tx *sql.Tx) error {
 				"key TEXT NOT NULL,"+
 				"name TEXT NOT NULL,"+
 				"kind TEXT NOT NULL,"+
-				"enabled INTEGER NOT NULL"+
+				"enabled INTEGER NOT NULL,"+
+				"pattern INTEGER NOT NULL DEFAULT 0"+
 				");",
 			peripheralTableName,
 		),
@@ -109,9 +235,11 @@ func createEndpointsTable(tx *sql.Tx) error {
 			"CREATE TABLE %s("+
 				"id INTEGER NOT NULL PRIMARY KEY,"+
 				"name TEXT NOT NULL,"+
+				"kind TEXT NOT NULL DEFAULT 'http',"+
 				"url TEXT NOT NULL,"+
 				"method TEXT NOT NULL,"+
-				"headers TEXT"+
+				"headers TEXT,"+
+				"policy TEXT"+
 				");",
 			endpointTableName,
 		),
@@ -131,11 +259,17 @@ func createSubscribersTable(tx *sql.Tx) error {
 				"name TEXT NOT NULL,"+
 				"event TEXT NOT NULL,"+
 				"enabled INTEGER NOT NULL,"+
+				"batch TEXT,"+
+				"mode TEXT NOT NULL DEFAULT '',"+
 				"target_id INTEGER REFERENCES %s(id) ON DELETE CASCADE,"+
+				"group_id INTEGER REFERENCES %s(id) ON DELETE CASCADE,"+
+				"rule_id INTEGER REFERENCES %s(id) ON DELETE CASCADE,"+
 				"endpoint_id INTEGER REFERENCES %s(id) ON DELETE CASCADE"+
 				");",
 			subscriberTableName,
 			peripheralTableName,
+			groupTableName,
+			ruleTableName,
 			endpointTableName,
 		),
 		fmt.Sprintf(
@@ -150,3 +284,178 @@ func createSubscribersTable(tx *sql.Tx) error {
 		),
 	})
 }
+
+func createGroupsTable(tx *sql.Tx) error {
+	return execQueries(tx, []string{
+		fmt.Sprintf(
+			"CREATE TABLE %s("+
+				"id INTEGER NOT NULL PRIMARY KEY,"+
+				"name TEXT NOT NULL,"+
+				"enabled INTEGER NOT NULL,"+
+				"uuid TEXT NOT NULL DEFAULT '',"+
+				"major INTEGER NOT NULL DEFAULT 0"+
+				");",
+			groupTableName,
+		),
+		fmt.Sprintf(
+			"CREATE UNIQUE INDEX %s_name_idx on %s(name);",
+			groupTableName,
+			groupTableName,
+		),
+		fmt.Sprintf(
+			"CREATE INDEX %s_uuid_idx on %s(uuid);",
+			groupTableName,
+			groupTableName,
+		),
+	})
+}
+
+func createGroupMembersTable(tx *sql.Tx) error {
+	return execQueries(tx, []string{
+		fmt.Sprintf(
+			"CREATE TABLE %s("+
+				"group_id INTEGER NOT NULL REFERENCES %s(id) ON DELETE CASCADE,"+
+				"target_id INTEGER NOT NULL REFERENCES %s(id) ON DELETE CASCADE,"+
+				"PRIMARY KEY (group_id, target_id)"+
+				");",
+			groupMemberTableName,
+			groupTableName,
+			peripheralTableName,
+		),
+		fmt.Sprintf(
+			"CREATE INDEX %s_target_idx on %s(target_id);",
+			groupMemberTableName,
+			groupMemberTableName,
+		),
+	})
+}
+
+func createUsersTable(tx *sql.Tx) error {
+	return execQueries(tx, []string{
+		fmt.Sprintf(
+			"CREATE TABLE %s("+
+				"id INTEGER NOT NULL PRIMARY KEY,"+
+				"username TEXT NOT NULL,"+
+				"password TEXT NOT NULL,"+
+				"role TEXT NOT NULL,"+
+				"enabled INTEGER NOT NULL,"+
+				"created_at INTEGER NOT NULL"+
+				");",
+			userTableName,
+		),
+		fmt.Sprintf(
+			"CREATE UNIQUE INDEX %s_username_idx on %s(username);",
+			userTableName,
+			userTableName,
+		),
+	})
+}
+
+func createApiKeysTable(tx *sql.Tx) error {
+	return execQueries(tx, []string{
+		fmt.Sprintf(
+			"CREATE TABLE %s("+
+				"id INTEGER NOT NULL PRIMARY KEY,"+
+				"name TEXT NOT NULL,"+
+				"prefix TEXT NOT NULL,"+
+				"hash TEXT NOT NULL,"+
+				"created_at INTEGER NOT NULL,"+
+				"user_id INTEGER REFERENCES %s(id) ON DELETE CASCADE"+
+				");",
+			apiKeyTableName,
+			userTableName,
+		),
+		fmt.Sprintf(
+			"CREATE UNIQUE INDEX %s_hash_idx on %s(hash);",
+			apiKeyTableName,
+			apiKeyTableName,
+		),
+		fmt.Sprintf(
+			"CREATE INDEX %s_user_idx on %s(user_id);",
+			apiKeyTableName,
+			apiKeyTableName,
+		),
+	})
+}
+
+func createSessionsTable(tx *sql.Tx) error {
+	return execQueries(tx, []string{
+		fmt.Sprintf(
+			"CREATE TABLE %s("+
+				"id INTEGER NOT NULL PRIMARY KEY,"+
+				"hash TEXT NOT NULL,"+
+				"created_at INTEGER NOT NULL,"+
+				"expires_at INTEGER NOT NULL,"+
+				"user_id INTEGER REFERENCES %s(id) ON DELETE CASCADE"+
+				");",
+			sessionTableName,
+			userTableName,
+		),
+		fmt.Sprintf(
+			"CREATE UNIQUE INDEX %s_hash_idx on %s(hash);",
+			sessionTableName,
+			sessionTableName,
+		),
+		fmt.Sprintf(
+			"CREATE INDEX %s_user_idx on %s(user_id);",
+			sessionTableName,
+			sessionTableName,
+		),
+	})
+}
+
+func createRulesTable(tx *sql.Tx) error {
+	return execQueries(tx, []string{
+		fmt.Sprintf(
+			"CREATE TABLE %s("+
+				"id INTEGER NOT NULL PRIMARY KEY,"+
+				"name TEXT NOT NULL,"+
+				"enabled INTEGER NOT NULL,"+
+				"key TEXT NOT NULL,"+
+				"name_template TEXT NOT NULL,"+
+				"mode TEXT NOT NULL,"+
+				"peripheral_enabled INTEGER NOT NULL"+
+				");",
+			ruleTableName,
+		),
+		fmt.Sprintf(
+			"CREATE UNIQUE INDEX %s_name_idx on %s(name);",
+			ruleTableName,
+			ruleTableName,
+		),
+	})
+}
+
+// createCandidatesTable keeps candidates of deleted rules and peripherals as an audit trail,
+// so their references are not cascaded
+func createCandidatesTable(tx *sql.Tx) error {
+	return execQueries(tx, []string{
+		fmt.Sprintf(
+			"CREATE TABLE %s("+
+				"id INTEGER NOT NULL PRIMARY KEY,"+
+				"rule_id INTEGER NOT NULL,"+
+				"key TEXT NOT NULL,"+
+				"kind TEXT NOT NULL,"+
+				"address TEXT NOT NULL DEFAULT '',"+
+				"name TEXT NOT NULL,"+
+				"status TEXT NOT NULL,"+
+				"target_id INTEGER NOT NULL DEFAULT 0,"+
+				"message TEXT NOT NULL DEFAULT '',"+
+				"reviewer TEXT NOT NULL DEFAULT '',"+
+				"created_at INTEGER NOT NULL,"+
+				"decided_at INTEGER NOT NULL DEFAULT 0"+
+				");",
+			candidateTableName,
+		),
+		fmt.Sprintf(
+			"CREATE UNIQUE INDEX %s_key_idx on %s(key);",
+			candidateTableName,
+			candidateTableName,
+		),
+		fmt.Sprintf(
+			"CREATE INDEX %s_status_idx on %s(status);",
+			candidateTableName,
+			candidateTableName,
+		),
+	})
+}
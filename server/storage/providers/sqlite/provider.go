@@ -42,6 +42,7 @@ func (provider *SQLiteProvider) GetInitializer() storage.Initializer {
 func (provider *SQLiteProvider) GetPeripheralRepository() storage.PeripheralRepository {
 	return repositories.NewSQLitePeripheralRepository(
 		peripheralTableName,
+		subscriberTableName,
 		provider.db,
 	)
 }
@@ -61,6 +62,49 @@ func (provider *SQLiteProvider) GetSubscriberRepository() storage.SubscriberRepo
 	)
 }
 
+func (provider *SQLiteProvider) GetGroupRepository() storage.GroupRepository {
+	return repositories.NewSQLiteGroupRepository(
+		groupTableName,
+		groupMemberTableName,
+		provider.db,
+	)
+}
+
+func (provider *SQLiteProvider) GetUserRepository() storage.UserRepository {
+	return repositories.NewSQLiteUserRepository(
+		userTableName,
+		provider.db,
+	)
+}
+
+func (provider *SQLiteProvider) GetApiKeyRepository() storage.ApiKeyRepository {
+	return repositories.NewSQLiteApiKeyRepository(
+		apiKeyTableName,
+		provider.db,
+	)
+}
+
+func (provider *SQLiteProvider) GetSessionRepository() storage.SessionRepository {
+	return repositories.NewSQLiteSessionRepository(
+		sessionTableName,
+		provider.db,
+	)
+}
+
+func (provider *SQLiteProvider) GetRuleRepository() storage.RuleRepository {
+	return repositories.NewSQLiteRuleRepository(
+		ruleTableName,
+		provider.db,
+	)
+}
+
+func (provider *SQLiteProvider) GetCandidateRepository() storage.CandidateRepository {
+	return repositories.NewSQLiteCandidateRepository(
+		candidateTableName,
+		provider.db,
+	)
+}
+
 func (provider *SQLiteProvider) Close() error {
 	return provider.db.Close()
 }
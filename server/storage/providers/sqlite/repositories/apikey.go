@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/blent/beagle/pkg/auth"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/storage/providers/sqlite/repositories/mapping"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+const (
+	apiKeySelectQuery = "SELECT id, name, prefix, hash, created_at, user_id FROM %s"
+	apiKeyInsertQuery = "INSERT INTO %s (name, prefix, hash, created_at, user_id) VALUES (?, ?, ?, ?, ?)"
+	apiKeyDeleteQuery = "DELETE FROM %s WHERE %s=?"
+)
+
+type SQLiteApiKeyRepository struct {
+	mu        sync.Mutex
+	tableName string
+	db        *sql.DB
+}
+
+func NewSQLiteApiKeyRepository(tableName string, db *sql.DB) *SQLiteApiKeyRepository {
+	return &SQLiteApiKeyRepository{tableName: tableName, db: db}
+}
+
+func (r *SQLiteApiKeyRepository) FindByUser(userId uint64) ([]*auth.ApiKey, error) {
+	stmt, err := r.db.Prepare(
+		fmt.Sprintf(
+			"%s WHERE user_id=? ORDER BY id",
+			fmt.Sprintf(apiKeySelectQuery, r.tableName),
+		),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping.ToApiKeys(rows)
+}
+
+func (r *SQLiteApiKeyRepository) Get(id uint64) (*auth.ApiKey, error) {
+	if id == 0 {
+		return nil, errors.New("id must be greater than 0")
+	}
+
+	return r.getBy("id", id)
+}
+
+func (r *SQLiteApiKeyRepository) GetByHash(hash string) (*auth.ApiKey, error) {
+	return r.getBy("hash", hash)
+}
+
+func (r *SQLiteApiKeyRepository) Create(key *auth.ApiKey, tx *sql.Tx) (uint64, error) {
+	if key == nil {
+		return 0, errors.New("api key missed")
+	}
+
+	if key.Id > 0 {
+		return 0, errors.New("api key already created")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(
+		fmt.Sprintf(apiKeyInsertQuery, r.tableName),
+		key.Name,
+		key.Prefix,
+		key.Hash,
+		key.CreatedAt.Unix(),
+		key.UserId,
+	)
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, err, closeTx)
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, err, closeTx)
+	}
+
+	err = storage.TryToCommit(tx, closeTx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
+
+func (r *SQLiteApiKeyRepository) Delete(id uint64, tx *sql.Tx) error {
+	if id == 0 {
+		return errors.New("id must be greater than 0")
+	}
+
+	return r.deleteBy("id", id, tx)
+}
+
+func (r *SQLiteApiKeyRepository) DeleteByUser(userId uint64, tx *sql.Tx) error {
+	return r.deleteBy("user_id", userId, tx)
+}
+
+func (r *SQLiteApiKeyRepository) getBy(column string, value interface{}) (*auth.ApiKey, error) {
+	stmt, err := r.db.Prepare(
+		fmt.Sprintf(
+			"%s WHERE %s=? LIMIT 1",
+			fmt.Sprintf(apiKeySelectQuery, r.tableName),
+			column,
+		),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	return mapping.ToApiKey(stmt.QueryRow(value))
+}
+
+func (r *SQLiteApiKeyRepository) deleteBy(column string, value interface{}, tx *sql.Tx) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(apiKeyDeleteQuery, r.tableName, column), value)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
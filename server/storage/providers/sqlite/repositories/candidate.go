@@ -0,0 +1,260 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/storage/providers/sqlite/repositories/mapping"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+)
+
+const (
+	candidateSelectQuery = "SELECT id, rule_id, key, kind, address, name, status, target_id, message, reviewer, created_at, decided_at FROM %s"
+	candidateInsertQuery = "INSERT INTO %s (rule_id, key, kind, address, name, status, target_id, message, reviewer, created_at, decided_at) " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	candidateUpdateQuery = "UPDATE %s SET name=?, status=?, target_id=?, message=?, reviewer=?, decided_at=? WHERE id=?"
+	candidateDeleteQuery = "DELETE FROM %s WHERE id=?"
+	candidateCountQuery  = "SELECT COUNT(id) FROM %s"
+)
+
+type SQLiteCandidateRepository struct {
+	mu        sync.Mutex
+	tableName string
+	db        *sql.DB
+}
+
+func NewSQLiteCandidateRepository(tableName string, db *sql.DB) *SQLiteCandidateRepository {
+	return &SQLiteCandidateRepository{
+		tableName: tableName,
+		db:        db,
+	}
+}
+
+func (r *SQLiteCandidateRepository) Get(id uint64) (*tracking.Candidate, error) {
+	if id == 0 {
+		return nil, errors.New("id must be greater than 0")
+	}
+
+	return r.getBy("id", id)
+}
+
+func (r *SQLiteCandidateRepository) GetByKey(key string) (*tracking.Candidate, error) {
+	if key == "" {
+		return nil, errors.New("key missed")
+	}
+
+	return r.getBy("key", key)
+}
+
+// Find returns candidates starting from the latest one
+func (r *SQLiteCandidateRepository) Find(query *storage.CandidateQuery) ([]*tracking.Candidate, error) {
+	args := make([]interface{}, 0, 4)
+	findQuery := fmt.Sprintf(candidateSelectQuery, r.tableName)
+	size := uint64(0)
+
+	if query != nil {
+		where, whereArgs := r.createWhereStatement(query.CandidateFilter)
+		findQuery += where
+		args = append(args, whereArgs...)
+	}
+
+	findQuery += " ORDER BY id DESC"
+
+	if query != nil && query.Pagination != nil && query.Take > 0 {
+		findQuery += " LIMIT ? OFFSET ?"
+		size = query.Take
+
+		args = append(args, query.Take, query.Skip)
+	}
+
+	stmt, err := r.db.Prepare(findQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping.ToCandidates(rows, size)
+}
+
+func (r *SQLiteCandidateRepository) Count(filter *storage.CandidateFilter) (uint64, error) {
+	var count uint64
+
+	where, args := r.createWhereStatement(filter)
+
+	err := r.db.QueryRow(fmt.Sprintf(candidateCountQuery, r.tableName)+where, args...).Scan(&count)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Create inserts a candidate, a candidate with the same key is a storage.ErrConflict
+func (r *SQLiteCandidateRepository) Create(candidate *tracking.Candidate, tx *sql.Tx) (uint64, error) {
+	if candidate == nil {
+		return 0, errors.New("candidate missed")
+	}
+
+	if candidate.Id > 0 {
+		return 0, errors.New("candidate already created")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(
+		fmt.Sprintf(candidateInsertQuery, r.tableName),
+		candidate.RuleId,
+		candidate.Key,
+		candidate.Kind,
+		candidate.Address,
+		candidate.Name,
+		candidate.Status,
+		candidate.TargetId,
+		candidate.Message,
+		candidate.Reviewer,
+		candidate.CreatedAt.Unix(),
+		mapping.FromOptionalTime(candidate.DecidedAt),
+	)
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, toStorageError(err), closeTx)
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, err, closeTx)
+	}
+
+	err = storage.TryToCommit(tx, closeTx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
+
+// Update saves a decision about a candidate
+func (r *SQLiteCandidateRepository) Update(candidate *tracking.Candidate, tx *sql.Tx) error {
+	if candidate == nil {
+		return errors.New("candidate missed")
+	}
+
+	if candidate.Id == 0 {
+		return errors.New("id must be greater than 0")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(
+		fmt.Sprintf(candidateUpdateQuery, r.tableName),
+		candidate.Name,
+		candidate.Status,
+		candidate.TargetId,
+		candidate.Message,
+		candidate.Reviewer,
+		mapping.FromOptionalTime(candidate.DecidedAt),
+		candidate.Id,
+	)
+
+	if err == nil {
+		err = expectAffected(res)
+	}
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+func (r *SQLiteCandidateRepository) Delete(id uint64, tx *sql.Tx) error {
+	if id == 0 {
+		return errors.New("id must be greater than 0")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(fmt.Sprintf(candidateDeleteQuery, r.tableName), id)
+
+	if err == nil {
+		err = expectAffected(res)
+	}
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+func (r *SQLiteCandidateRepository) getBy(column string, value interface{}) (*tracking.Candidate, error) {
+	stmt, err := r.db.Prepare(fmt.Sprintf(candidateSelectQuery, r.tableName) + fmt.Sprintf(" WHERE %s=? LIMIT 1", column))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	return mapping.ToCandidate(stmt.QueryRow(value))
+}
+
+func (r *SQLiteCandidateRepository) createWhereStatement(filter *storage.CandidateFilter) (string, []interface{}) {
+	if filter == nil {
+		return "", nil
+	}
+
+	where := make([]string, 0, 2)
+	args := make([]interface{}, 0, 2)
+
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	if filter.RuleId > 0 {
+		where = append(where, "rule_id = ?")
+		args = append(args, filter.RuleId)
+	}
+
+	if len(where) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(where, " AND "), args
+}
@@ -13,14 +13,22 @@ import (
 )
 
 const (
-	endpointSelectQuery       = "SELECT id, name, url, method, headers FROM %s"
-	endpointInsertQuery       = "INSERT INTO %s (name, url, method, headers) VALUES %s"
-	endpointInsertValuesQuery = "(?, ?, ?, ?)"
-	endpointUpdateQuery       = "UPDATE %s SET name=?, url=?, method=?, headers=? WHERE id=?"
+	endpointSelectQuery       = "SELECT id, name, kind, url, method, headers, policy FROM %s"
+	endpointInsertQuery       = "INSERT INTO %s (name, kind, url, method, headers, policy) VALUES %s"
+	endpointInsertValuesQuery = "(?, ?, ?, ?, ?, ?)"
+	endpointUpdateQuery       = "UPDATE %s SET name=?, kind=?, url=?, method=?, headers=?, policy=? WHERE id=?"
 	endpointDeleteQuery       = "DELETE FROM %s"
 	endpointCountQuery        = "SELECT COUNT(id) from %s"
 )
 
+// endpointSortColumns maps sort fields to columns
+var endpointSortColumns = map[string]string{
+	storage.SORT_NAME:   "name",
+	storage.SORT_KIND:   "kind",
+	storage.SORT_URL:    "url",
+	storage.SORT_METHOD: "method",
+}
+
 type (
 	SQLiteEndpointRepository struct {
 		mu        sync.Mutex
@@ -58,29 +66,28 @@ func (r *SQLiteEndpointRepository) Get(id uint64) (*notification.Endpoint, error
 }
 
 func (r *SQLiteEndpointRepository) Find(query *storage.EndpointQuery) ([]*notification.Endpoint, error) {
-	args := make([]interface{}, 0, 5)
-	findQuery := fmt.Sprintf(endpointSelectQuery, r.tableName)
-
-	if query != nil {
-		var whereStmt string
-		whereStmt, args = r.createWhereStatement(query.EndpointFilter, args)
+	if query == nil {
+		query = &storage.EndpointQuery{}
+	}
 
-		if whereStmt != "" {
-			findQuery += whereStmt
-		}
+	page, err := newPage(query.Pagination, query.Sorting, endpointSortColumns, "id")
 
-		findQuery += " ORDER BY id"
+	if err != nil {
+		return nil, err
+	}
 
-		if query.Take > 0 {
-			findQuery += " LIMIT ? OFFSET ?"
+	conditions, args := r.createConditions(query.EndpointFilter, nil)
 
-			args = append(args, query.Take, query.Skip)
-		}
-	} else {
-		findQuery += " ORDER BY id"
+	if page.condition != "" {
+		conditions = append(conditions, page.condition)
+		args = append(args, page.conditionArgs...)
 	}
 
-	stmt, err := r.db.Prepare(findQuery)
+	args = append(args, page.limitArgs...)
+
+	stmt, err := r.db.Prepare(
+		fmt.Sprintf(endpointSelectQuery, r.tableName) + joinConditions(conditions) + page.order,
+	)
 
 	if err != nil {
 		return nil, err
@@ -94,21 +101,21 @@ func (r *SQLiteEndpointRepository) Find(query *storage.EndpointQuery) ([]*notifi
 		return nil, err
 	}
 
-	return mapping.ToEndpoints(rows, query.Take)
-}
+	var size uint64
 
-func (r *SQLiteEndpointRepository) Count(filter *storage.EndpointFilter) (uint64, error) {
-	countQuery := fmt.Sprintf(endpointCountQuery, r.tableName)
+	if query.Pagination != nil {
+		size = query.Take
+	}
 
-	var whereStmt string
-	args := make([]interface{}, 0, 1)
-	whereStmt, args = r.createWhereStatement(filter, args)
+	return mapping.ToEndpoints(rows, size)
+}
 
-	if whereStmt != "" {
-		countQuery += whereStmt
-	}
+func (r *SQLiteEndpointRepository) Count(filter *storage.EndpointFilter) (uint64, error) {
+	conditions, args := r.createConditions(filter, nil)
 
-	stmt, err := r.db.Prepare(countQuery)
+	stmt, err := r.db.Prepare(
+		fmt.Sprintf(endpointCountQuery, r.tableName) + joinConditions(conditions),
+	)
 
 	if err != nil {
 		return 0, err
@@ -158,10 +165,10 @@ func (r *SQLiteEndpointRepository) Create(endpoint *notification.Endpoint, tx *s
 		return 0, storage.TryToRollback(tx, err, closeTx)
 	}
 
-	res, err := stmt.Exec(endpoint.Name, endpoint.Url, endpoint.Method, endpoint.Headers)
+	res, err := stmt.Exec(endpoint.Name, endpointKind(endpoint), endpoint.Url, endpoint.Method, endpoint.Headers, endpoint.Policy)
 
 	if err != nil {
-		return 0, storage.TryToRollback(tx, err, closeTx)
+		return 0, storage.TryToRollback(tx, toStorageError(err), closeTx)
 	}
 
 	id, err = res.LastInsertId()
@@ -207,10 +214,14 @@ func (r *SQLiteEndpointRepository) Update(endpoint *notification.Endpoint, tx *s
 		return storage.TryToRollback(tx, err, closeTx)
 	}
 
-	_, err = stmt.Exec(endpoint.Name, endpoint.Url, endpoint.Method, endpoint.Headers, endpoint.Id)
+	res, err := stmt.Exec(endpoint.Name, endpointKind(endpoint), endpoint.Url, endpoint.Method, endpoint.Headers, endpoint.Policy, endpoint.Id)
+
+	if err == nil {
+		err = expectAffected(res)
+	}
 
 	if err != nil {
-		return storage.TryToRollback(tx, err, closeTx)
+		return storage.TryToRollback(tx, toStorageError(err), closeTx)
 	}
 
 	return storage.TryToCommit(tx, closeTx)
@@ -302,20 +313,18 @@ func (r *SQLiteEndpointRepository) DeleteMany(query *storage.DeletionQuery, tx *
 	return storage.TryToCommit(tx, closeTx)
 }
 
-func (r *SQLiteEndpointRepository) createWhereStatement(filter *storage.EndpointFilter, args []interface{}) (string, []interface{}) {
-	stmt := ""
+func (r *SQLiteEndpointRepository) createConditions(filter *storage.EndpointFilter, args []interface{}) ([]string, []interface{}) {
+	conditions := make([]string, 0, 3)
 
-	if filter == nil {
-		return stmt, args
+	if args == nil {
+		args = make([]interface{}, 0, 3)
 	}
 
-	if args == nil {
-		args = make([]interface{}, 0, 1)
+	if filter == nil {
+		return conditions, args
 	}
 
 	if filter.Name != "" {
-		stmt += " WHERE"
-
 		startsWith := strings.HasPrefix(filter.Name, "*")
 		endsWith := strings.HasSuffix(filter.Name, "*")
 		arg := filter.Name
@@ -331,13 +340,31 @@ func (r *SQLiteEndpointRepository) createWhereStatement(filter *storage.Endpoint
 				arg = "%" + arg
 			}
 
-			stmt += " name LIKE ?"
+			conditions = append(conditions, "name LIKE ?")
 		} else {
-			stmt += " name = ?"
+			conditions = append(conditions, "name = ?")
 		}
 
 		args = append(args, arg)
 	}
 
-	return stmt, args
+	if filter.Search != "" {
+		conditions = append(conditions, `(name LIKE ? ESCAPE '\' OR url LIKE ? ESCAPE '\')`)
+		args = append(args, containsPattern(filter.Search), containsPattern(filter.Search))
+	}
+
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, filter.Kind)
+	}
+
+	return conditions, args
+}
+
+func endpointKind(endpoint *notification.Endpoint) string {
+	if endpoint.Kind == "" {
+		return notification.ENDPOINT_KIND_HTTP
+	}
+
+	return endpoint.Kind
 }
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/blent/beagle/server/storage"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// toStorageError turns unique constraint violations into storage.ErrConflict with a name of the column
+func toStorageError(err error) error {
+	sqliteErr, ok := err.(sqlite3.Error)
+
+	if !ok || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+		return err
+	}
+
+	// the message looks like "UNIQUE constraint failed: table.column"
+	message := sqliteErr.Error()
+	column := message[strings.LastIndex(message, ".")+1:]
+
+	return errors.Wrap(storage.ErrConflict, column)
+}
+
+// expectAffected returns storage.ErrNotFound if a statement has not changed any rows
+func expectAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
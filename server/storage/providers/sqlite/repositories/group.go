@@ -0,0 +1,389 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/storage/providers/sqlite/repositories/mapping"
+	"github.com/blent/beagle/server/utils"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+)
+
+const (
+	groupSelectQuery        = "SELECT id, name, enabled, uuid, major FROM %s"
+	groupInsertQuery        = "INSERT INTO %s (name, enabled, uuid, major) VALUES (?, ?, ?, ?)"
+	groupUpdateQuery        = "UPDATE %s SET name=?, enabled=?, uuid=?, major=? WHERE id=?"
+	groupDeleteQuery        = "DELETE FROM %s WHERE id=?"
+	groupCountQuery         = "SELECT COUNT(id) FROM %s"
+	groupMemberSelectQuery  = "SELECT target_id FROM %s WHERE group_id=? ORDER BY target_id"
+	groupMemberInsertQuery  = "INSERT INTO %s (group_id, target_id) VALUES (?, ?)"
+	groupMemberDeleteQuery  = "DELETE FROM %s WHERE group_id=?"
+	groupMembersDeleteQuery = "DELETE FROM %s WHERE target_id IN (%s)"
+)
+
+type SQLiteGroupRepository struct {
+	mu              sync.Mutex
+	tableName       string
+	memberTableName string
+	db              *sql.DB
+}
+
+func NewSQLiteGroupRepository(tableName, memberTableName string, db *sql.DB) *SQLiteGroupRepository {
+	return &SQLiteGroupRepository{
+		tableName:       tableName,
+		memberTableName: memberTableName,
+		db:              db,
+	}
+}
+
+func (r *SQLiteGroupRepository) Get(id uint64) (*tracking.Group, error) {
+	if id == 0 {
+		return nil, errors.New("id must be greater than 0")
+	}
+
+	stmt, err := r.db.Prepare(fmt.Sprintf(groupSelectQuery, r.tableName) + " WHERE id=? LIMIT 1")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	return mapping.ToGroup(stmt.QueryRow(id))
+}
+
+func (r *SQLiteGroupRepository) Find(query *storage.GroupQuery) ([]*tracking.Group, error) {
+	args := make([]interface{}, 0, 3)
+	findQuery := fmt.Sprintf(groupSelectQuery, r.tableName)
+	size := uint64(0)
+
+	if query != nil {
+		where, whereArgs := r.createWhereStatement(query.GroupFilter)
+		findQuery += where
+		args = append(args, whereArgs...)
+	}
+
+	findQuery += " ORDER BY id"
+
+	if query != nil && query.Pagination != nil && query.Take > 0 {
+		findQuery += " LIMIT ? OFFSET ?"
+		size = query.Take
+
+		args = append(args, query.Take, query.Skip)
+	}
+
+	stmt, err := r.db.Prepare(findQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping.ToGroups(rows, size)
+}
+
+func (r *SQLiteGroupRepository) FindByMember(filter *storage.MemberFilter) ([]*tracking.Group, error) {
+	if filter == nil {
+		return nil, errors.New("filter object is missed")
+	}
+
+	where, args := r.createWhereStatement(&storage.GroupFilter{Status: filter.Status})
+	conditions := make([]string, 0, 2)
+
+	if filter.TargetId > 0 {
+		conditions = append(conditions, fmt.Sprintf("id IN (SELECT group_id FROM %s WHERE target_id = ?)", r.memberTableName))
+		args = append(args, filter.TargetId)
+	}
+
+	if filter.Uuid != "" {
+		conditions = append(conditions, "(uuid = ? AND (major = 0 OR major = ?))")
+		args = append(args, filter.Uuid, filter.Major)
+	}
+
+	if len(conditions) == 0 {
+		return []*tracking.Group{}, nil
+	}
+
+	membership := "(" + strings.Join(conditions, " OR ") + ")"
+
+	if where == "" {
+		where = " WHERE " + membership
+	} else {
+		where += " AND " + membership
+	}
+
+	stmt, err := r.db.Prepare(fmt.Sprintf(groupSelectQuery, r.tableName) + where + " ORDER BY id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping.ToGroups(rows, 0)
+}
+
+func (r *SQLiteGroupRepository) Count(filter *storage.GroupFilter) (uint64, error) {
+	var count uint64
+
+	where, args := r.createWhereStatement(filter)
+
+	err := r.db.QueryRow(fmt.Sprintf(groupCountQuery, r.tableName)+where, args...).Scan(&count)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *SQLiteGroupRepository) Create(group *tracking.Group, tx *sql.Tx) (uint64, error) {
+	if group == nil {
+		return 0, errors.New("group missed")
+	}
+
+	if group.Id > 0 {
+		return 0, errors.New("group already created")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(
+		fmt.Sprintf(groupInsertQuery, r.tableName),
+		group.Name,
+		boolToInt(group.Enabled),
+		group.Uuid,
+		group.Major,
+	)
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, toStorageError(err), closeTx)
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, err, closeTx)
+	}
+
+	err = storage.TryToCommit(tx, closeTx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
+
+func (r *SQLiteGroupRepository) Update(group *tracking.Group, tx *sql.Tx) error {
+	if group == nil {
+		return errors.New("group missed")
+	}
+
+	if group.Id == 0 {
+		return errors.New("id must be greater than 0")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(
+		fmt.Sprintf(groupUpdateQuery, r.tableName),
+		group.Name,
+		boolToInt(group.Enabled),
+		group.Uuid,
+		group.Major,
+		group.Id,
+	)
+
+	if err == nil {
+		err = expectAffected(res)
+	}
+
+	if err != nil {
+		return storage.TryToRollback(tx, toStorageError(err), closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+// Delete deletes a group with its memberships
+func (r *SQLiteGroupRepository) Delete(id uint64, tx *sql.Tx) error {
+	if id == 0 {
+		return errors.New("id must be greater than 0")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(groupMemberDeleteQuery, r.memberTableName), id)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(groupDeleteQuery, r.tableName), id)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+func (r *SQLiteGroupRepository) GetMembers(id uint64) ([]uint64, error) {
+	if id == 0 {
+		return nil, errors.New("id must be greater than 0")
+	}
+
+	rows, err := r.db.Query(fmt.Sprintf(groupMemberSelectQuery, r.memberTableName), id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	members := make([]uint64, 0, 10)
+
+	for rows.Next() {
+		var targetId uint64
+
+		if err := rows.Scan(&targetId); err != nil {
+			return nil, err
+		}
+
+		members = append(members, targetId)
+	}
+
+	return members, rows.Err()
+}
+
+// SetMembers replaces members of a group
+func (r *SQLiteGroupRepository) SetMembers(id uint64, targetIds []uint64, tx *sql.Tx) error {
+	if id == 0 {
+		return errors.New("id must be greater than 0")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(groupMemberDeleteQuery, r.memberTableName), id)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	if len(targetIds) > 0 {
+		stmt, err := tx.Prepare(fmt.Sprintf(groupMemberInsertQuery, r.memberTableName))
+
+		if err != nil {
+			return storage.TryToRollback(tx, err, closeTx)
+		}
+
+		defer stmt.Close()
+
+		added := make(map[uint64]bool, len(targetIds))
+
+		for _, targetId := range targetIds {
+			if added[targetId] {
+				continue
+			}
+
+			added[targetId] = true
+
+			if _, err = stmt.Exec(id, targetId); err != nil {
+				return storage.TryToRollback(tx, err, closeTx)
+			}
+		}
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+// DeleteMembers removes given peripherals from all groups
+func (r *SQLiteGroupRepository) DeleteMembers(targetIds []uint64, tx *sql.Tx) error {
+	if len(targetIds) == 0 {
+		return errors.New("passed empty list of ids")
+	}
+
+	return r.exec(
+		tx,
+		fmt.Sprintf(groupMembersDeleteQuery, r.memberTableName, utils.JoinUintSlice(targetIds, ", ")),
+	)
+}
+
+func (r *SQLiteGroupRepository) exec(tx *sql.Tx, query string, args ...interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, args...)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+func (r *SQLiteGroupRepository) createWhereStatement(filter *storage.GroupFilter) (string, []interface{}) {
+	if filter == nil {
+		return "", nil
+	}
+
+	switch filter.Status {
+	case storage.PERIPHERAL_STATUS_ENABLED:
+		return " WHERE enabled = ?", []interface{}{1}
+	case storage.PERIPHERAL_STATUS_DISABLED:
+		return " WHERE enabled = ?", []interface{}{0}
+	default:
+		return "", nil
+	}
+}
@@ -8,11 +8,13 @@ import (
 func ToEndpoint(row DataRow) (*notification.Endpoint, error) {
 	var id uint64
 	var name string
+	var kind string
 	var url string
 	var method string
 	headers := notification.Headers{}
+	var policy []byte
 
-	if err := row.Scan(&id, &name, &url, &method, &headers); err != nil {
+	if err := row.Scan(&id, &name, &kind, &url, &method, &headers, &policy); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -20,12 +22,20 @@ func ToEndpoint(row DataRow) (*notification.Endpoint, error) {
 		return nil, err
 	}
 
+	endpointPolicy, err := notification.ParsePolicy(policy)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &notification.Endpoint{
 		Id:      id,
 		Name:    name,
+		Kind:    kind,
 		Url:     url,
 		Method:  method,
 		Headers: headers,
+		Policy:  endpointPolicy,
 	}, nil
 }
 
@@ -0,0 +1,47 @@
+package mapping
+
+import (
+	"database/sql"
+	"github.com/blent/beagle/pkg/tracking"
+)
+
+func ToGroup(row DataRow) (*tracking.Group, error) {
+	var id uint64
+	var name string
+	var enabled int
+	var uuid string
+	var major uint16
+
+	if err := row.Scan(&id, &name, &enabled, &uuid, &major); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &tracking.Group{
+		Id:      id,
+		Name:    name,
+		Enabled: enabled == 1,
+		Uuid:    uuid,
+		Major:   major,
+	}, nil
+}
+
+func ToGroups(rows DataRows, size uint64) ([]*tracking.Group, error) {
+	results := make([]*tracking.Group, 0, size)
+	defer rows.Close()
+
+	for rows.Next() {
+		group, err := ToGroup(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, group)
+	}
+
+	return results, nil
+}
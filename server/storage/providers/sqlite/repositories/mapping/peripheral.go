@@ -23,8 +23,9 @@ func ToPeripheral(row DataRow) (*tracking.Peripheral, error) {
 	var name string
 	var kind string
 	var enabled int
+	var pattern int
 
-	if err := row.Scan(&id, &key, &name, &kind, &enabled); err != nil {
+	if err := row.Scan(&id, &key, &name, &kind, &enabled, &pattern); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -38,6 +39,7 @@ func ToPeripheral(row DataRow) (*tracking.Peripheral, error) {
 		Name:    name,
 		Kind:    kind,
 		Enabled: enabled == 1,
+		Pattern: pattern == 1,
 	}, nil
 }
 
@@ -0,0 +1,116 @@
+package mapping
+
+import (
+	"database/sql"
+	"github.com/blent/beagle/pkg/tracking"
+	"time"
+)
+
+func ToRule(row DataRow) (*tracking.Rule, error) {
+	var id uint64
+	var name string
+	var enabled int
+	var key string
+	var nameTemplate string
+	var mode string
+	var peripheralEnabled int
+
+	if err := row.Scan(&id, &name, &enabled, &key, &nameTemplate, &mode, &peripheralEnabled); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &tracking.Rule{
+		Id:                id,
+		Name:              name,
+		Enabled:           enabled == 1,
+		Key:               key,
+		NameTemplate:      nameTemplate,
+		Mode:              mode,
+		PeripheralEnabled: peripheralEnabled == 1,
+	}, nil
+}
+
+func ToRules(rows DataRows, size uint64) ([]*tracking.Rule, error) {
+	results := make([]*tracking.Rule, 0, size)
+	defer rows.Close()
+
+	for rows.Next() {
+		rule, err := ToRule(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, rule)
+	}
+
+	return results, nil
+}
+
+func ToCandidate(row DataRow) (*tracking.Candidate, error) {
+	var candidate tracking.Candidate
+	var createdAt int64
+	var decidedAt int64
+
+	err := row.Scan(
+		&candidate.Id,
+		&candidate.RuleId,
+		&candidate.Key,
+		&candidate.Kind,
+		&candidate.Address,
+		&candidate.Name,
+		&candidate.Status,
+		&candidate.TargetId,
+		&candidate.Message,
+		&candidate.Reviewer,
+		&createdAt,
+		&decidedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	candidate.CreatedAt = time.Unix(createdAt, 0)
+
+	if decidedAt > 0 {
+		decided := time.Unix(decidedAt, 0)
+		candidate.DecidedAt = &decided
+	}
+
+	return &candidate, nil
+}
+
+func ToCandidates(rows DataRows, size uint64) ([]*tracking.Candidate, error) {
+	results := make([]*tracking.Candidate, 0, size)
+	defer rows.Close()
+
+	for rows.Next() {
+		candidate, err := ToCandidate(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, candidate)
+	}
+
+	return results, nil
+}
+
+// FromOptionalTime stores a missing time as 0
+func FromOptionalTime(value *time.Time) int64 {
+	if value == nil {
+		return 0
+	}
+
+	return value.Unix()
+}
@@ -11,23 +11,37 @@ func ToSubscriber(row DataRow) (*notification.Subscriber, error) {
 	var name string
 	var event string
 	var enabled uint64
+	var batch []byte
+	var mode string
+	var targetId sql.NullInt64
+	var groupId sql.NullInt64
+	var ruleId sql.NullInt64
 
 	var endpointId uint64
 	var endpointName string
+	var endpointKind string
 	var endpointUrl string
 	var endpointMethod string
 	endpointHeaders := notification.Headers{}
+	var endpointPolicy []byte
 
 	if err := row.Scan(
 		&id,
 		&name,
 		&event,
 		&enabled,
+		&batch,
+		&mode,
+		&targetId,
+		&groupId,
+		&ruleId,
 		&endpointId,
 		&endpointName,
+		&endpointKind,
 		&endpointUrl,
 		&endpointMethod,
 		&endpointHeaders,
+		&endpointPolicy,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -36,17 +50,36 @@ func ToSubscriber(row DataRow) (*notification.Subscriber, error) {
 		return nil, err
 	}
 
+	subscriberBatch, err := notification.ParseBatch(batch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := notification.ParsePolicy(endpointPolicy)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &notification.Subscriber{
-		Id:      id,
-		Name:    name,
-		Event:   event,
-		Enabled: enabled > 0,
+		Id:       id,
+		Name:     name,
+		Event:    event,
+		Enabled:  enabled > 0,
+		Batch:    subscriberBatch,
+		Mode:     mode,
+		TargetId: uint64(targetId.Int64),
+		GroupId:  uint64(groupId.Int64),
+		RuleId:   uint64(ruleId.Int64),
 		Endpoint: &notification.Endpoint{
 			Id:      endpointId,
 			Name:    endpointName,
+			Kind:    endpointKind,
 			Url:     endpointUrl,
 			Method:  endpointMethod,
 			Headers: endpointHeaders,
+			Policy:  policy,
 		},
 	}, nil
 }
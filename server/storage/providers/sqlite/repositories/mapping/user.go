@@ -0,0 +1,129 @@
+package mapping
+
+import (
+	"database/sql"
+	"github.com/blent/beagle/pkg/auth"
+	"time"
+)
+
+func ToUser(row DataRow) (*auth.User, error) {
+	var id uint64
+	var username string
+	var password string
+	var role string
+	var enabled int
+	var createdAt int64
+
+	if err := row.Scan(&id, &username, &password, &role, &enabled, &createdAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &auth.User{
+		Id:           id,
+		Username:     username,
+		PasswordHash: password,
+		Role:         role,
+		Enabled:      enabled > 0,
+		CreatedAt:    time.Unix(createdAt, 0),
+	}, nil
+}
+
+func ToUsers(rows DataRows, size uint64) ([]*auth.User, error) {
+	results := make([]*auth.User, 0, size)
+	var err error
+	defer rows.Close()
+
+	for rows.Next() {
+		user, parseErr := ToUser(rows)
+
+		if parseErr != nil {
+			err = parseErr
+			break
+		}
+
+		results = append(results, user)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func ToApiKey(row DataRow) (*auth.ApiKey, error) {
+	var id uint64
+	var name string
+	var prefix string
+	var hash string
+	var createdAt int64
+	var userId uint64
+
+	if err := row.Scan(&id, &name, &prefix, &hash, &createdAt, &userId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &auth.ApiKey{
+		Id:        id,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		UserId:    userId,
+		CreatedAt: time.Unix(createdAt, 0),
+	}, nil
+}
+
+func ToApiKeys(rows DataRows) ([]*auth.ApiKey, error) {
+	results := make([]*auth.ApiKey, 0, 5)
+	var err error
+	defer rows.Close()
+
+	for rows.Next() {
+		key, parseErr := ToApiKey(rows)
+
+		if parseErr != nil {
+			err = parseErr
+			break
+		}
+
+		results = append(results, key)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func ToSession(row DataRow) (*auth.Session, error) {
+	var id uint64
+	var hash string
+	var createdAt int64
+	var expiresAt int64
+	var userId uint64
+
+	if err := row.Scan(&id, &hash, &createdAt, &expiresAt, &userId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &auth.Session{
+		Id:        id,
+		Hash:      hash,
+		UserId:    userId,
+		CreatedAt: time.Unix(createdAt, 0),
+		ExpiresAt: time.Unix(expiresAt, 0),
+	}, nil
+}
@@ -8,31 +8,42 @@ import (
 	"github.com/blent/beagle/server/storage/providers/sqlite/repositories/mapping"
 	"github.com/blent/beagle/server/utils"
 	"github.com/pkg/errors"
+	"strconv"
 	"strings"
 	"sync"
 )
 
 const (
-	peripheralSelectQuery       = "SELECT id, key, name, kind, enabled FROM %s"
-	peripheralInsertQuery       = "INSERT INTO %s (key, name, kind, enabled) VALUES %s"
-	peripheralInsertValuesQuery = "(?, ?, ?, ?)"
+	peripheralSelectQuery       = "SELECT id, key, name, kind, enabled, pattern FROM %s"
+	peripheralInsertQuery       = "INSERT INTO %s (key, name, kind, enabled, pattern) VALUES %s"
+	peripheralInsertValuesQuery = "(?, ?, ?, ?, ?)"
 	peripheralUpdateQuery       = "UPDATE %s SET name=?, enabled=? WHERE id=?"
 	peripheralDeleteQuery       = "DELETE FROM %s"
 	peripheralCountQuery        = "SELECT COUNT(id) from %s"
 )
 
+// peripheralSortColumns maps sort fields to columns
+var peripheralSortColumns = map[string]string{
+	storage.SORT_NAME:    "name",
+	storage.SORT_KEY:     "key",
+	storage.SORT_KIND:    "kind",
+	storage.SORT_ENABLED: "enabled",
+}
+
 type (
 	SQLitePeripheralRepository struct {
-		mu        sync.Mutex
-		tableName string
-		db        *sql.DB
+		mu                  sync.Mutex
+		tableName           string
+		subscriberTableName string
+		db                  *sql.DB
 	}
 )
 
-func NewSQLitePeripheralRepository(tableName string, db *sql.DB) *SQLitePeripheralRepository {
+func NewSQLitePeripheralRepository(tableName, subscriberTableName string, db *sql.DB) *SQLitePeripheralRepository {
 	return &SQLitePeripheralRepository{
-		tableName: tableName,
-		db:        db,
+		tableName:           tableName,
+		subscriberTableName: subscriberTableName,
+		db:                  db,
 	}
 }
 
@@ -84,33 +95,39 @@ func (r *SQLitePeripheralRepository) GetByKey(key string) (*tracking.Peripheral,
 	return mapping.ToPeripheral(stmt.QueryRow(key))
 }
 
-func (r *SQLitePeripheralRepository) Count(filter *storage.PeripheralFilter) (uint64, error) {
-	queryStmt := fmt.Sprintf(peripheralCountQuery, r.tableName)
-	whereKeys := make([]string, 0, 5)
-	whereValues := make([]interface{}, 0, 5)
+// FindPatterns returns all pattern registrations
+func (r *SQLitePeripheralRepository) FindPatterns() ([]*tracking.Peripheral, error) {
+	stmt, err := r.db.Prepare(
+		fmt.Sprintf(
+			"%s WHERE pattern=1 ORDER BY id",
+			fmt.Sprintf(
+				peripheralSelectQuery,
+				r.tableName,
+			),
+		),
+	)
 
-	if filter != nil {
-		if filter.Status != "" {
-			var enabled bool
+	if err != nil {
+		return nil, err
+	}
 
-			if filter.Status == storage.PERIPHERAL_STATUS_ENABLED {
-				enabled = true
-			}
+	defer stmt.Close()
 
-			whereKeys = append(whereKeys, "enabled = ?")
-			whereValues = append(whereValues, enabled)
-		}
+	rows, err := stmt.Query()
 
-		if len(whereKeys) > 0 {
-			queryStmt = fmt.Sprintf(
-				"%s WHERE %s",
-				queryStmt,
-				strings.Join(whereKeys, " AND "),
-			)
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	stmt, err := r.db.Prepare(queryStmt)
+	return mapping.ToPeripherals(rows, 0)
+}
+
+func (r *SQLitePeripheralRepository) Count(filter *storage.PeripheralFilter) (uint64, error) {
+	conditions, args := r.createConditions(filter, nil)
+
+	stmt, err := r.db.Prepare(
+		fmt.Sprintf(peripheralCountQuery, r.tableName) + joinConditions(conditions),
+	)
 
 	if err != nil {
 		return 0, err
@@ -118,7 +135,7 @@ func (r *SQLitePeripheralRepository) Count(filter *storage.PeripheralFilter) (ui
 
 	defer stmt.Close()
 
-	row := stmt.QueryRow(whereValues...)
+	row := stmt.QueryRow(args...)
 
 	var count uint64
 
@@ -132,28 +149,28 @@ func (r *SQLitePeripheralRepository) Count(filter *storage.PeripheralFilter) (ui
 }
 
 func (r *SQLitePeripheralRepository) Find(query *storage.PeripheralQuery) ([]*tracking.Peripheral, error) {
-	var queryStmt string
-	var takeAll bool
+	if query == nil {
+		query = &storage.PeripheralQuery{}
+	}
 
-	if query == nil || query.Take == 0 {
-		takeAll = true
+	page, err := newPage(query.Pagination, query.Sorting, peripheralSortColumns, "id")
+
+	if err != nil {
+		return nil, err
 	}
 
-	orderedSelectQuery := peripheralSelectQuery + " ORDER BY id"
+	conditions, args := r.createConditions(query.PeripheralFilter, nil)
 
-	if !takeAll {
-		queryStmt = fmt.Sprintf(
-			"%s LIMIT ? OFFSET ?",
-			fmt.Sprintf(
-				orderedSelectQuery,
-				r.tableName,
-			),
-		)
-	} else {
-		queryStmt = fmt.Sprintf(orderedSelectQuery, r.tableName)
+	if page.condition != "" {
+		conditions = append(conditions, page.condition)
+		args = append(args, page.conditionArgs...)
 	}
 
-	stmt, err := r.db.Prepare(queryStmt)
+	args = append(args, page.limitArgs...)
+
+	stmt, err := r.db.Prepare(
+		fmt.Sprintf(peripheralSelectQuery, r.tableName) + joinConditions(conditions) + page.order,
+	)
 
 	if err != nil {
 		return nil, err
@@ -161,19 +178,19 @@ func (r *SQLitePeripheralRepository) Find(query *storage.PeripheralQuery) ([]*tr
 
 	defer stmt.Close()
 
-	var rows *sql.Rows
-
-	if !takeAll {
-		rows, err = stmt.Query(query.Take, query.Skip)
-	} else {
-		rows, err = stmt.Query()
-	}
+	rows, err := stmt.Query(args...)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return mapping.ToPeripherals(rows, query.Take)
+	var size uint64
+
+	if query.Pagination != nil {
+		size = query.Take
+	}
+
+	return mapping.ToPeripherals(rows, size)
 }
 
 func (r *SQLitePeripheralRepository) Create(target *tracking.Peripheral, tx *sql.Tx) (uint64, error) {
@@ -204,10 +221,10 @@ func (r *SQLitePeripheralRepository) Create(target *tracking.Peripheral, tx *sql
 		return 0, storage.TryToRollback(tx, err, closeTx)
 	}
 
-	res, err := stmt.Exec(target.Key, target.Name, target.Kind, boolToInt(target.Enabled))
+	res, err := stmt.Exec(target.Key, target.Name, target.Kind, boolToInt(target.Enabled), boolToInt(target.Pattern))
 
 	if err != nil {
-		return 0, storage.TryToRollback(tx, err, closeTx)
+		return 0, storage.TryToRollback(tx, toStorageError(err), closeTx)
 	}
 
 	id, err = res.LastInsertId()
@@ -251,10 +268,14 @@ func (r *SQLitePeripheralRepository) Update(target *tracking.Peripheral, tx *sql
 		return storage.TryToRollback(tx, err, closeTx)
 	}
 
-	_, err = stmt.Exec(target.Name, boolToInt(target.Enabled), target.Id)
+	res, err := stmt.Exec(target.Name, boolToInt(target.Enabled), target.Id)
+
+	if err == nil {
+		err = expectAffected(res)
+	}
 
 	if err != nil {
-		return storage.TryToRollback(tx, err, closeTx)
+		return storage.TryToRollback(tx, toStorageError(err), closeTx)
 	}
 
 	return storage.TryToCommit(tx, closeTx)
@@ -346,6 +367,60 @@ func (r *SQLitePeripheralRepository) DeleteMany(query *storage.DeletionQuery, tx
 	return storage.TryToCommit(tx, closeTx)
 }
 
+func (r *SQLitePeripheralRepository) createConditions(filter *storage.PeripheralFilter, args []interface{}) ([]string, []interface{}) {
+	conditions := make([]string, 0, 6)
+
+	if args == nil {
+		args = make([]interface{}, 0, 6)
+	}
+
+	if filter == nil {
+		return conditions, args
+	}
+
+	if filter.Status == storage.PERIPHERAL_STATUS_ENABLED || filter.Status == storage.PERIPHERAL_STATUS_DISABLED {
+		conditions = append(conditions, "enabled = ?")
+		args = append(args, boolToInt(filter.Status == storage.PERIPHERAL_STATUS_ENABLED))
+	}
+
+	if filter.Search != "" {
+		conditions = append(conditions, `(name LIKE ? ESCAPE '\' OR key LIKE ? ESCAPE '\')`)
+		args = append(args, containsPattern(filter.Search), containsPattern(filter.Search))
+	}
+
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, filter.Kind)
+	}
+
+	// iBeacon keys and patterns look like "uuid:major:minor"
+	if filter.Uuid != "" || filter.Major > 0 {
+		uuid := "%"
+		major := "%"
+
+		if filter.Uuid != "" {
+			uuid = likeEscaper.Replace(strings.ToLower(filter.Uuid))
+		}
+
+		if filter.Major > 0 {
+			major = strconv.Itoa(int(filter.Major))
+		}
+
+		conditions = append(conditions, `key LIKE ? ESCAPE '\'`)
+		args = append(args, fmt.Sprintf("%s:%s:%%", uuid, major))
+	}
+
+	if filter.Event != "" {
+		conditions = append(
+			conditions,
+			fmt.Sprintf("id IN (SELECT target_id FROM %s WHERE event = ?)", r.subscriberTableName),
+		)
+		args = append(args, filter.Event)
+	}
+
+	return conditions, args
+}
+
 func boolToInt(val bool) int {
 	enabled := 0
 
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blent/beagle/server/storage"
+	"github.com/pkg/errors"
+)
+
+// likeEscaper escapes wildcards of LIKE patterns, patterns are used with ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// containsPattern returns a LIKE pattern matching values containing a substring
+func containsPattern(value string) string {
+	return "%" + likeEscaper.Replace(value) + "%"
+}
+
+// sortColumn returns a column of a sort field, columns contain all supported fields but id
+func sortColumn(sorting *storage.Sorting, columns map[string]string) (string, error) {
+	field := sorting.SortField()
+
+	if field == storage.SORT_ID {
+		return "", nil
+	}
+
+	column, ok := columns[field]
+
+	if !ok {
+		return "", errors.Errorf("unsupported sort field: '%s'", field)
+	}
+
+	return column, nil
+}
+
+// orderStatement orders items by a sort column and then by id in the same direction
+func orderStatement(sorting *storage.Sorting, column, idColumn string) string {
+	direction := "ASC"
+
+	if sorting.IsDescending() {
+		direction = "DESC"
+	}
+
+	if column == "" {
+		return fmt.Sprintf(" ORDER BY %s %s", idColumn, direction)
+	}
+
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s", column, direction, idColumn, direction)
+}
+
+// cursorCondition matches items placed after a cursor in the sort order
+func cursorCondition(cursor *storage.Cursor, sorting *storage.Sorting, column, idColumn string) (string, []interface{}, error) {
+	if cursor.Field != sorting.SortField() {
+		return "", nil, storage.ErrInvalidCursor
+	}
+
+	operator := ">"
+
+	if sorting.IsDescending() {
+		operator = "<"
+	}
+
+	if column == "" {
+		return fmt.Sprintf("%s %s ?", idColumn, operator), []interface{}{cursor.Id}, nil
+	}
+
+	return fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", column, operator, column, idColumn, operator),
+		[]interface{}{cursor.Value, cursor.Value, cursor.Id},
+		nil
+}
+
+// page contains a cursor condition with its arguments, an order and limits of a query
+type page struct {
+	condition     string
+	conditionArgs []interface{}
+	order         string
+	limitArgs     []interface{}
+}
+
+func newPage(pagination *storage.Pagination, sorting *storage.Sorting, columns map[string]string, idColumn string) (*page, error) {
+	column, err := sortColumn(sorting, columns)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &page{
+		order: orderStatement(sorting, column, idColumn),
+	}
+
+	if pagination == nil {
+		return p, nil
+	}
+
+	if pagination.After != nil {
+		p.condition, p.conditionArgs, err = cursorCondition(pagination.After, sorting, column, idColumn)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pagination.Take > 0 {
+		p.order += " LIMIT ? OFFSET ?"
+		p.limitArgs = []interface{}{pagination.Take, pagination.Skip}
+	}
+
+	return p, nil
+}
+
+// joinConditions joins conditions into a where statement
+func joinConditions(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,224 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/storage/providers/sqlite/repositories/mapping"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+const (
+	ruleSelectQuery = "SELECT id, name, enabled, key, name_template, mode, peripheral_enabled FROM %s"
+	ruleInsertQuery = "INSERT INTO %s (name, enabled, key, name_template, mode, peripheral_enabled) VALUES (?, ?, ?, ?, ?, ?)"
+	ruleUpdateQuery = "UPDATE %s SET name=?, enabled=?, key=?, name_template=?, mode=?, peripheral_enabled=? WHERE id=?"
+	ruleDeleteQuery = "DELETE FROM %s WHERE id=?"
+	ruleCountQuery  = "SELECT COUNT(id) FROM %s"
+)
+
+type SQLiteRuleRepository struct {
+	mu        sync.Mutex
+	tableName string
+	db        *sql.DB
+}
+
+func NewSQLiteRuleRepository(tableName string, db *sql.DB) *SQLiteRuleRepository {
+	return &SQLiteRuleRepository{
+		tableName: tableName,
+		db:        db,
+	}
+}
+
+func (r *SQLiteRuleRepository) Get(id uint64) (*tracking.Rule, error) {
+	if id == 0 {
+		return nil, errors.New("id must be greater than 0")
+	}
+
+	stmt, err := r.db.Prepare(fmt.Sprintf(ruleSelectQuery, r.tableName) + " WHERE id=? LIMIT 1")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	return mapping.ToRule(stmt.QueryRow(id))
+}
+
+func (r *SQLiteRuleRepository) Find(query *storage.RuleQuery) ([]*tracking.Rule, error) {
+	args := make([]interface{}, 0, 3)
+	findQuery := fmt.Sprintf(ruleSelectQuery, r.tableName)
+	size := uint64(0)
+
+	if query != nil {
+		where, whereArgs := r.createWhereStatement(query.RuleFilter)
+		findQuery += where
+		args = append(args, whereArgs...)
+	}
+
+	findQuery += " ORDER BY id"
+
+	if query != nil && query.Pagination != nil && query.Take > 0 {
+		findQuery += " LIMIT ? OFFSET ?"
+		size = query.Take
+
+		args = append(args, query.Take, query.Skip)
+	}
+
+	stmt, err := r.db.Prepare(findQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping.ToRules(rows, size)
+}
+
+func (r *SQLiteRuleRepository) Count(filter *storage.RuleFilter) (uint64, error) {
+	var count uint64
+
+	where, args := r.createWhereStatement(filter)
+
+	err := r.db.QueryRow(fmt.Sprintf(ruleCountQuery, r.tableName)+where, args...).Scan(&count)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *SQLiteRuleRepository) Create(rule *tracking.Rule, tx *sql.Tx) (uint64, error) {
+	if rule == nil {
+		return 0, errors.New("rule missed")
+	}
+
+	if rule.Id > 0 {
+		return 0, errors.New("rule already created")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(
+		fmt.Sprintf(ruleInsertQuery, r.tableName),
+		rule.Name,
+		boolToInt(rule.Enabled),
+		rule.Key,
+		rule.NameTemplate,
+		rule.Mode,
+		boolToInt(rule.PeripheralEnabled),
+	)
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, toStorageError(err), closeTx)
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, err, closeTx)
+	}
+
+	err = storage.TryToCommit(tx, closeTx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
+
+func (r *SQLiteRuleRepository) Update(rule *tracking.Rule, tx *sql.Tx) error {
+	if rule == nil {
+		return errors.New("rule missed")
+	}
+
+	if rule.Id == 0 {
+		return errors.New("id must be greater than 0")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(
+		fmt.Sprintf(ruleUpdateQuery, r.tableName),
+		rule.Name,
+		boolToInt(rule.Enabled),
+		rule.Key,
+		rule.NameTemplate,
+		rule.Mode,
+		boolToInt(rule.PeripheralEnabled),
+		rule.Id,
+	)
+
+	if err == nil {
+		err = expectAffected(res)
+	}
+
+	if err != nil {
+		return storage.TryToRollback(tx, toStorageError(err), closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+func (r *SQLiteRuleRepository) Delete(id uint64, tx *sql.Tx) error {
+	if id == 0 {
+		return errors.New("id must be greater than 0")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(ruleDeleteQuery, r.tableName), id)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+func (r *SQLiteRuleRepository) createWhereStatement(filter *storage.RuleFilter) (string, []interface{}) {
+	if filter == nil {
+		return "", nil
+	}
+
+	switch filter.Status {
+	case storage.PERIPHERAL_STATUS_ENABLED:
+		return " WHERE enabled = ?", []interface{}{1}
+	case storage.PERIPHERAL_STATUS_DISABLED:
+		return " WHERE enabled = ?", []interface{}{0}
+	default:
+		return "", nil
+	}
+}
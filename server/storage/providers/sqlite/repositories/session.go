@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/blent/beagle/pkg/auth"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/storage/providers/sqlite/repositories/mapping"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+const (
+	sessionSelectQuery = "SELECT id, hash, created_at, expires_at, user_id FROM %s WHERE hash=? LIMIT 1"
+	sessionInsertQuery = "INSERT INTO %s (hash, created_at, expires_at, user_id) VALUES (?, ?, ?, ?)"
+	sessionDeleteQuery = "DELETE FROM %s WHERE %s"
+)
+
+type SQLiteSessionRepository struct {
+	mu        sync.Mutex
+	tableName string
+	db        *sql.DB
+}
+
+func NewSQLiteSessionRepository(tableName string, db *sql.DB) *SQLiteSessionRepository {
+	return &SQLiteSessionRepository{tableName: tableName, db: db}
+}
+
+func (r *SQLiteSessionRepository) GetByHash(hash string) (*auth.Session, error) {
+	stmt, err := r.db.Prepare(fmt.Sprintf(sessionSelectQuery, r.tableName))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	return mapping.ToSession(stmt.QueryRow(hash))
+}
+
+func (r *SQLiteSessionRepository) Create(session *auth.Session, tx *sql.Tx) (uint64, error) {
+	if session == nil {
+		return 0, errors.New("session missed")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(
+		fmt.Sprintf(sessionInsertQuery, r.tableName),
+		session.Hash,
+		session.CreatedAt.Unix(),
+		session.ExpiresAt.Unix(),
+		session.UserId,
+	)
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, err, closeTx)
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, err, closeTx)
+	}
+
+	err = storage.TryToCommit(tx, closeTx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
+
+func (r *SQLiteSessionRepository) DeleteByHash(hash string, tx *sql.Tx) error {
+	return r.delete("hash=?", hash, tx)
+}
+
+func (r *SQLiteSessionRepository) DeleteExpired(now time.Time, tx *sql.Tx) error {
+	return r.delete("expires_at<=?", now.Unix(), tx)
+}
+
+func (r *SQLiteSessionRepository) DeleteByUser(userId uint64, tx *sql.Tx) error {
+	return r.delete("user_id=?", userId, tx)
+}
+
+func (r *SQLiteSessionRepository) delete(where string, value interface{}, tx *sql.Tx) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(sessionDeleteQuery, r.tableName, where), value)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
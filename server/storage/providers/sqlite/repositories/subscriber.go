@@ -18,20 +18,35 @@ const (
 		"t1.name as t1_name, " +
 		"t1.event as t1_event, " +
 		"t1.enabled as t1_enabled, " +
+		"t1.batch as t1_batch, " +
+		"t1.mode as t1_mode, " +
+		"t1.target_id as t1_target_id, " +
+		"t1.group_id as t1_group_id, " +
+		"t1.rule_id as t1_rule_id, " +
 		"t2.id AS t2_id, " +
 		"t2.name AS t2_name, " +
+		"t2.kind AS t2_kind, " +
 		"t2.url AS t2_url, " +
 		"t2.method AS t2_method, " +
-		"t2.headers AS t2_headers " +
+		"t2.headers AS t2_headers, " +
+		"t2.policy AS t2_policy " +
 		"FROM %s AS t1 " +
 		"INNER JOIN %s AS t2 ON t1.endpoint_id = t2.id "
-	subscriberInsertQuery       = "INSERT INTO %s (name, event, enabled, endpoint_id, target_id) VALUES %s"
-	subscriberInsertValuesQuery = "(?, ?, ?, ?, ?)"
-	subscriberUpdateQuery       = "UPDATE %s SET name=?, event=?, enabled=? WHERE id=?"
+	subscriberInsertQuery       = "INSERT INTO %s (name, event, enabled, batch, mode, endpoint_id, target_id, group_id, rule_id) VALUES %s"
+	subscriberInsertValuesQuery = "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	subscriberUpdateQuery       = "UPDATE %s SET name=?, event=?, enabled=?, batch=?, mode=?, endpoint_id=? WHERE id=? AND IFNULL(target_id, 0)=? AND IFNULL(group_id, 0)=? AND IFNULL(rule_id, 0)=?"
+	subscriberEnableQuery       = "UPDATE %s SET enabled=? WHERE id IN (%s)"
 	subscriberDeleteQuery       = "DELETE FROM %s"
-	subscriberCountQuery        = "SELECT COUNT(id) FROM %s"
+	subscriberCountQuery        = "SELECT COUNT(t1.id) FROM %s AS t1"
 )
 
+// subscriberSortColumns maps sort fields to columns
+var subscriberSortColumns = map[string]string{
+	storage.SORT_NAME:    "t1.name",
+	storage.SORT_EVENT:   "t1.event",
+	storage.SORT_ENABLED: "t1.enabled",
+}
+
 type SQLiteSubscriberRepository struct {
 	mu                sync.Mutex
 	tableName         string
@@ -106,31 +121,29 @@ func (r *SQLiteSubscriberRepository) Find(query *storage.SubscriberQuery) ([]*no
 		return nil, errors.New("query object is missed")
 	}
 
-	queryStmt := subscriberSelectQuery
-	whereQuery, args := r.createWhereStatement(query.SubscriberFilter, nil)
+	page, err := newPage(query.Pagination, query.Sorting, subscriberSortColumns, "t1.id")
 
-	if whereQuery != "" {
-		queryStmt += whereQuery
+	if err != nil {
+		return nil, err
 	}
 
-	queryStmt += " ORDER BY t1.id"
+	whereQuery, args := r.createWhereStatement(query.SubscriberFilter, nil)
 
-	if query != nil && query.Take > 0 {
-		args = append(args, query.Take, query.Skip)
+	if page.condition != "" {
+		if whereQuery == "" {
+			whereQuery = " WHERE " + page.condition
+		} else {
+			whereQuery += " AND " + page.condition
+		}
 
-		queryStmt = fmt.Sprintf(
-			"%s LIMIT ? OFFSET ?",
-			fmt.Sprintf(
-				queryStmt,
-				r.tableName,
-				r.endpointTableName,
-			),
-		)
-	} else {
-		queryStmt = fmt.Sprintf(queryStmt, r.tableName, r.endpointTableName)
+		args = append(args, page.conditionArgs...)
 	}
 
-	stmt, err := r.db.Prepare(queryStmt)
+	args = append(args, page.limitArgs...)
+
+	stmt, err := r.db.Prepare(
+		fmt.Sprintf(subscriberSelectQuery, r.tableName, r.endpointTableName) + whereQuery + page.order,
+	)
 
 	if err != nil {
 		return nil, err
@@ -147,7 +160,8 @@ func (r *SQLiteSubscriberRepository) Find(query *storage.SubscriberQuery) ([]*no
 	return mapping.ToSubscribers(rows, query)
 }
 
-func (r *SQLiteSubscriberRepository) Create(subscriber *notification.Subscriber, targetId uint64, tx *sql.Tx) (uint64, error) {
+// Create inserts a subscriber owned by a peripheral or a group given by TargetId or GroupId
+func (r *SQLiteSubscriberRepository) Create(subscriber *notification.Subscriber, tx *sql.Tx) (uint64, error) {
 	var id int64
 	var err error
 
@@ -155,6 +169,10 @@ func (r *SQLiteSubscriberRepository) Create(subscriber *notification.Subscriber,
 		return 0, err
 	}
 
+	if countOwners(subscriber.TargetId, subscriber.GroupId, subscriber.RuleId) != 1 {
+		return 0, errors.New("subscriber must belong either to a peripheral, to a group or to a rule")
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -176,8 +194,12 @@ func (r *SQLiteSubscriberRepository) Create(subscriber *notification.Subscriber,
 		subscriber.Name,
 		subscriber.Event,
 		boolToInt(subscriber.Enabled),
+		subscriber.Batch,
+		subscriberMode(subscriber, subscriber.GroupId > 0),
 		subscriber.Endpoint.Id,
-		targetId,
+		nullableId(subscriber.TargetId),
+		nullableId(subscriber.GroupId),
+		nullableId(subscriber.RuleId),
 	)
 
 	if err != nil {
@@ -200,13 +222,26 @@ func (r *SQLiteSubscriberRepository) Create(subscriber *notification.Subscriber,
 }
 
 func (r *SQLiteSubscriberRepository) CreateMany(subscribers []*notification.Subscriber, targetId uint64, tx *sql.Tx) error {
+	return r.createMany(subscribers, targetId, nil, nil, tx)
+}
+
+func (r *SQLiteSubscriberRepository) CreateManyForGroup(subscribers []*notification.Subscriber, groupId uint64, tx *sql.Tx) error {
+	return r.createMany(subscribers, nil, groupId, nil, tx)
+}
+
+func (r *SQLiteSubscriberRepository) CreateManyForRule(subscribers []*notification.Subscriber, ruleId uint64, tx *sql.Tx) error {
+	return r.createMany(subscribers, nil, nil, ruleId, tx)
+}
+
+// createMany inserts subscribers owned by a peripheral, a group or a rule, other owners are nil
+func (r *SQLiteSubscriberRepository) createMany(subscribers []*notification.Subscriber, targetId, groupId, ruleId interface{}, tx *sql.Tx) error {
 	if subscribers == nil {
 		return errors.New("subscribers missed")
 	}
 
 	var err error
 	valueStrings := make([]string, 0, len(subscribers))
-	valueArgs := make([]interface{}, 0, len(subscribers)*5)
+	valueArgs := make([]interface{}, 0, len(subscribers)*9)
 
 	for _, subscriber := range subscribers {
 		err := r.validate(subscriber, true)
@@ -215,15 +250,19 @@ func (r *SQLiteSubscriberRepository) CreateMany(subscribers []*notification.Subs
 			break
 		}
 
-		// name, event, enabled, endpoint_id, target_id
+		// name, event, enabled, batch, mode, endpoint_id, target_id, group_id, rule_id
 		valueStrings = append(valueStrings, subscriberInsertValuesQuery)
 		valueArgs = append(
 			valueArgs,
 			subscriber.Name,
 			subscriber.Event,
 			boolToInt(subscriber.Enabled),
+			subscriber.Batch,
+			subscriberMode(subscriber, groupId != nil),
 			subscriber.Endpoint.Id,
 			targetId,
+			groupId,
+			ruleId,
 		)
 	}
 
@@ -384,7 +423,10 @@ func (r *SQLiteSubscriberRepository) DeleteMany(query *storage.DeletionQuery, tx
 		return errors.New("missed query object")
 	}
 
-	if len(query.Id) == 0 {
+	owned := query.TargetId > 0 || query.GroupId > 0 || query.RuleId > 0
+
+	// an empty list is allowed only to delete all subscribers of an owner
+	if len(query.Id) == 0 && (query.InRange || !owned) {
 		return errors.New("passed empty list of ids")
 	}
 
@@ -399,20 +441,121 @@ func (r *SQLiteSubscriberRepository) DeleteMany(query *storage.DeletionQuery, tx
 		return err
 	}
 
-	where := "WHERE id"
+	conditions := make([]string, 0, 2)
+	args := make([]interface{}, 0, 1)
 
-	if query.InRange == false {
-		where += " NOT IN"
-	} else {
-		where += " IN"
+	if len(query.Id) > 0 {
+		if query.InRange == false {
+			conditions = append(conditions, fmt.Sprintf("id NOT IN (%s)", utils.JoinUintSlice(query.Id, ", ")))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("id IN (%s)", utils.JoinUintSlice(query.Id, ", ")))
+		}
+	}
+
+	if query.TargetId > 0 {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, query.TargetId)
+	}
+
+	if query.GroupId > 0 {
+		conditions = append(conditions, "group_id = ?")
+		args = append(args, query.GroupId)
+	}
+
+	if query.RuleId > 0 {
+		conditions = append(conditions, "rule_id = ?")
+		args = append(args, query.RuleId)
 	}
 
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
 	stmt, err := tx.Prepare(
 		fmt.Sprintf(
-			"%s %s (%s)",
+			"%s %s",
 			fmt.Sprintf(subscriberDeleteQuery, r.tableName),
 			where,
-			utils.JoinUintSlice(query.Id, ", "),
+		),
+	)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	_, err = stmt.Exec(args...)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+// SetEnabled enables or disables subscribers by ids
+func (r *SQLiteSubscriberRepository) SetEnabled(ids []uint64, enabled bool, tx *sql.Tx) error {
+	if len(ids) == 0 {
+		return errors.New("passed empty list of ids")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(
+		fmt.Sprintf(subscriberEnableQuery, r.tableName, utils.JoinUintSlice(ids, ", ")),
+	)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	res, err := stmt.Exec(boolToInt(enabled))
+
+	if err == nil {
+		err = expectAffected(res)
+	}
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+// DeleteByEndpoints deletes all subscribers pointing at given endpoints
+func (r *SQLiteSubscriberRepository) DeleteByEndpoints(ids []uint64, tx *sql.Tx) error {
+	return r.deleteByReference("endpoint_id", ids, tx)
+}
+
+// DeleteByTargets deletes all subscribers of given peripherals
+func (r *SQLiteSubscriberRepository) DeleteByTargets(ids []uint64, tx *sql.Tx) error {
+	return r.deleteByReference("target_id", ids, tx)
+}
+
+func (r *SQLiteSubscriberRepository) deleteByReference(column string, ids []uint64, tx *sql.Tx) error {
+	if len(ids) == 0 {
+		return errors.New("passed empty list of ids")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(
+		fmt.Sprintf(
+			"%s WHERE %s IN (%s)",
+			fmt.Sprintf(subscriberDeleteQuery, r.tableName),
+			column,
+			utils.JoinUintSlice(ids, ", "),
 		),
 	)
 
@@ -429,10 +572,48 @@ func (r *SQLiteSubscriberRepository) DeleteMany(query *storage.DeletionQuery, tx
 	return storage.TryToCommit(tx, closeTx)
 }
 
+// DeleteByRules deletes all subscribers of given rules
+func (r *SQLiteSubscriberRepository) DeleteByRules(ids []uint64, tx *sql.Tx) error {
+	return r.deleteByReference("rule_id", ids, tx)
+}
+
+// doUpdate updates a subscriber only if it belongs to the same peripheral, group or rule
 func (r *SQLiteSubscriberRepository) doUpdate(stmt *sql.Stmt, subscriber *notification.Subscriber) error {
-	_, err := stmt.Exec(subscriber.Name, subscriber.Event, boolToInt(subscriber.Enabled), subscriber.Id)
+	res, err := stmt.Exec(
+		subscriber.Name,
+		subscriber.Event,
+		boolToInt(subscriber.Enabled),
+		subscriber.Batch,
+		subscriberMode(subscriber, subscriber.GroupId > 0),
+		subscriber.Endpoint.Id,
+		subscriber.Id,
+		subscriber.TargetId,
+		subscriber.GroupId,
+		subscriber.RuleId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(res); err != nil {
+		return errors.Wrapf(err, "subscriber %d", subscriber.Id)
+	}
 
-	return err
+	return nil
+}
+
+// subscriberMode returns a mode of a group subscriber, member by default, other subscribers have no mode
+func subscriberMode(subscriber *notification.Subscriber, grouped bool) string {
+	if !grouped {
+		return ""
+	}
+
+	if subscriber.Mode == "" {
+		return notification.SUBSCRIBER_MODE_MEMBER
+	}
+
+	return subscriber.Mode
 }
 
 func (r *SQLiteSubscriberRepository) validate(subscriber *notification.Subscriber, isNew bool) error {
@@ -491,6 +672,29 @@ func (r *SQLiteSubscriberRepository) createWhereStatement(filter *storage.Subscr
 		where = append(where, "t1.target_id = ?")
 	}
 
+	if filter.GroupId > 0 {
+		args = append(args, filter.GroupId)
+		where = append(where, "t1.group_id = ?")
+	}
+
+	// subscribers of rules are templates, they are only listed along with their rules or endpoints
+	if filter.RuleId > 0 {
+		args = append(args, filter.RuleId)
+		where = append(where, "t1.rule_id = ?")
+	} else if filter.TargetId == 0 && filter.GroupId == 0 && filter.EndpointId == 0 {
+		where = append(where, "t1.rule_id IS NULL")
+	}
+
+	if filter.EndpointId > 0 {
+		args = append(args, filter.EndpointId)
+		where = append(where, "t1.endpoint_id = ?")
+	}
+
+	if filter.Search != "" {
+		args = append(args, containsPattern(filter.Search))
+		where = append(where, `t1.name LIKE ? ESCAPE '\'`)
+	}
+
 	if filter.Events != nil && len(filter.Events) > 0 {
 		if len(filter.Events) == 1 {
 			where = append(where, "t1.event = ?")
@@ -512,3 +716,24 @@ func (r *SQLiteSubscriberRepository) createWhereStatement(filter *storage.Subscr
 
 	return " WHERE " + strings.Join(where, " AND "), args
 }
+
+func countOwners(ids ...uint64) int {
+	count := 0
+
+	for _, id := range ids {
+		if id > 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+// nullableId stores a missing owner as NULL
+func nullableId(id uint64) interface{} {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
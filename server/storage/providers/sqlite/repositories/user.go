@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/blent/beagle/pkg/auth"
+	"github.com/blent/beagle/server/storage"
+	"github.com/blent/beagle/server/storage/providers/sqlite/repositories/mapping"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+const (
+	userSelectQuery = "SELECT id, username, password, role, enabled, created_at FROM %s"
+	userInsertQuery = "INSERT INTO %s (username, password, role, enabled, created_at) VALUES (?, ?, ?, ?, ?)"
+	userUpdateQuery = "UPDATE %s SET username=?, password=?, role=?, enabled=? WHERE id=?"
+	userDeleteQuery = "DELETE FROM %s WHERE id=?"
+	userCountQuery  = "SELECT COUNT(id) from %s"
+)
+
+type SQLiteUserRepository struct {
+	mu        sync.Mutex
+	tableName string
+	db        *sql.DB
+}
+
+func NewSQLiteUserRepository(tableName string, db *sql.DB) *SQLiteUserRepository {
+	return &SQLiteUserRepository{tableName: tableName, db: db}
+}
+
+func (r *SQLiteUserRepository) Get(id uint64) (*auth.User, error) {
+	if id == 0 {
+		return nil, errors.New("id must be greater than 0")
+	}
+
+	return r.getBy("id", id)
+}
+
+func (r *SQLiteUserRepository) GetByName(username string) (*auth.User, error) {
+	if username == "" {
+		return nil, errors.New("username must be non-empty string")
+	}
+
+	return r.getBy("username", username)
+}
+
+func (r *SQLiteUserRepository) Find(pagination *storage.Pagination) ([]*auth.User, error) {
+	args := make([]interface{}, 0, 2)
+	findQuery := fmt.Sprintf(userSelectQuery, r.tableName) + " ORDER BY id"
+	size := uint64(0)
+
+	if pagination != nil && pagination.Take > 0 {
+		findQuery += " LIMIT ? OFFSET ?"
+		size = pagination.Take
+
+		args = append(args, pagination.Take, pagination.Skip)
+	}
+
+	stmt, err := r.db.Prepare(findQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping.ToUsers(rows, size)
+}
+
+func (r *SQLiteUserRepository) Count() (uint64, error) {
+	var count uint64
+
+	err := r.db.QueryRow(fmt.Sprintf(userCountQuery, r.tableName)).Scan(&count)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *SQLiteUserRepository) Create(user *auth.User, tx *sql.Tx) (uint64, error) {
+	if user == nil {
+		return 0, errors.New("user missed")
+	}
+
+	if user.Id > 0 {
+		return 0, errors.New("user already created")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(
+		fmt.Sprintf(userInsertQuery, r.tableName),
+		user.Username,
+		user.PasswordHash,
+		user.Role,
+		user.Enabled,
+		user.CreatedAt.Unix(),
+	)
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, toStorageError(err), closeTx)
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, storage.TryToRollback(tx, err, closeTx)
+	}
+
+	err = storage.TryToCommit(tx, closeTx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
+
+func (r *SQLiteUserRepository) Update(user *auth.User, tx *sql.Tx) error {
+	if user == nil {
+		return errors.New("user missed")
+	}
+
+	if user.Id == 0 {
+		return errors.New("user not created yet")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		fmt.Sprintf(userUpdateQuery, r.tableName),
+		user.Username,
+		user.PasswordHash,
+		user.Role,
+		user.Enabled,
+		user.Id,
+	)
+
+	if err != nil {
+		return storage.TryToRollback(tx, toStorageError(err), closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+func (r *SQLiteUserRepository) Delete(id uint64, tx *sql.Tx) error {
+	if id == 0 {
+		return errors.New("id must be greater than 0")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, closeTx, err := storage.TryToBegin(r.db, tx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(userDeleteQuery, r.tableName), id)
+
+	if err != nil {
+		return storage.TryToRollback(tx, err, closeTx)
+	}
+
+	return storage.TryToCommit(tx, closeTx)
+}
+
+func (r *SQLiteUserRepository) getBy(column string, value interface{}) (*auth.User, error) {
+	stmt, err := r.db.Prepare(
+		fmt.Sprintf(
+			"%s WHERE %s=? LIMIT 1",
+			fmt.Sprintf(userSelectQuery, r.tableName),
+			column,
+		),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	return mapping.ToUser(stmt.QueryRow(value))
+}
@@ -4,6 +4,13 @@ const (
 	peripheralTableName      = "peripherals"
 	subscriberTableName      = "subscribers"
 	endpointTableName        = "endpoints"
+	groupTableName           = "peripheral_groups"
+	groupMemberTableName     = "group_members"
 	activityHistoryTableName = "activity_history"
 	deliveryHistoryTableName = "delivery_history"
+	userTableName            = "users"
+	apiKeyTableName          = "api_keys"
+	sessionTableName         = "sessions"
+	ruleTableName            = "registration_rules"
+	candidateTableName       = "registration_candidates"
 )
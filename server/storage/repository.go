@@ -2,54 +2,127 @@ package storage
 
 import (
 	"database/sql"
+	"github.com/blent/beagle/pkg/auth"
 	"github.com/blent/beagle/pkg/notification"
 	"github.com/blent/beagle/pkg/tracking"
+	"time"
 )
 
 type (
+	// DeletionQuery deletes entities by ids, or all but them unless InRange.
+	// Subscribers can be scoped to a peripheral, a group or a rule.
 	DeletionQuery struct {
-		Id      []uint64
-		InRange bool
+		Id       []uint64
+		InRange  bool
+		TargetId uint64
+		GroupId  uint64
+		RuleId   uint64
 	}
 
+	// Pagination skips a number of items, or starts after a cursor of the previous page
 	Pagination struct {
-		Take uint64
-		Skip uint64
+		Take  uint64
+		Skip  uint64
+		After *Cursor
+	}
+
+	// Sorting orders items by a field, items with equal values are ordered by id
+	Sorting struct {
+		Field      string
+		Descending bool
 	}
 
+	// PeripheralFilter matches peripherals by a substring of their name or key,
+	// Uuid and Major match parts of iBeacon keys, Event matches peripherals with subscribers of the event
 	PeripheralFilter struct {
 		Status string
+		Search string
+		Kind   string
+		Uuid   string
+		Major  uint16
+		Event  string
 	}
 
 	PeripheralQuery struct {
 		*Pagination
 		*PeripheralFilter
+		*Sorting
 	}
 
+	// EndpointFilter matches endpoints by a name with optional "*" wildcards,
+	// or by a substring of their name or url
 	EndpointFilter struct {
-		Name string
+		Name   string
+		Search string
+		Kind   string
 	}
 
 	EndpointQuery struct {
 		*Pagination
 		*EndpointFilter
+		*Sorting
 	}
 
+	// SubscriberFilter matches subscribers of peripherals and groups,
+	// subscribers of rules are only matched by RuleId or EndpointId
 	SubscriberFilter struct {
-		TargetId uint64
-		Events   []string
-		Status   string
+		TargetId   uint64
+		GroupId    uint64
+		RuleId     uint64
+		EndpointId uint64
+		Events     []string
+		Status     string
+		Search     string
 	}
 
 	SubscriberQuery struct {
 		*Pagination
 		*SubscriberFilter
+		*Sorting
+	}
+
+	GroupFilter struct {
+		Status string
+	}
+
+	GroupQuery struct {
+		*Pagination
+		*GroupFilter
+	}
+
+	RuleFilter struct {
+		Status string
+	}
+
+	RuleQuery struct {
+		*Pagination
+		*RuleFilter
+	}
+
+	// CandidateFilter matches candidates by a status and a rule, zero values match any
+	CandidateFilter struct {
+		Status string
+		RuleId uint64
+	}
+
+	CandidateQuery struct {
+		*Pagination
+		*CandidateFilter
+	}
+
+	// MemberFilter matches groups a peripheral belongs to, either as an added member or by its uuid and major
+	MemberFilter struct {
+		TargetId uint64
+		Uuid     string
+		Major    uint16
+		Status   string
 	}
 
 	PeripheralRepository interface {
 		Find(*PeripheralQuery) ([]*tracking.Peripheral, error)
 		Count(*PeripheralFilter) (uint64, error)
 		GetByKey(string) (*tracking.Peripheral, error)
+		FindPatterns() ([]*tracking.Peripheral, error)
 		Get(uint64) (*tracking.Peripheral, error)
 		Create(*tracking.Peripheral, *sql.Tx) (uint64, error)
 		Update(*tracking.Peripheral, *sql.Tx) error
@@ -61,12 +134,51 @@ type (
 		Find(*SubscriberQuery) ([]*notification.Subscriber, error)
 		Count(*SubscriberFilter) (uint64, error)
 		Get(uint64) (*notification.Subscriber, error)
-		Create(*notification.Subscriber, uint64, *sql.Tx) (uint64, error)
+		Create(*notification.Subscriber, *sql.Tx) (uint64, error)
 		CreateMany([]*notification.Subscriber, uint64, *sql.Tx) error
+		CreateManyForGroup([]*notification.Subscriber, uint64, *sql.Tx) error
+		CreateManyForRule([]*notification.Subscriber, uint64, *sql.Tx) error
 		Update(*notification.Subscriber, *sql.Tx) error
 		UpdateMany([]*notification.Subscriber, *sql.Tx) error
+		SetEnabled([]uint64, bool, *sql.Tx) error
 		Delete(uint64, *sql.Tx) error
 		DeleteMany(*DeletionQuery, *sql.Tx) error
+		DeleteByEndpoints([]uint64, *sql.Tx) error
+		DeleteByTargets([]uint64, *sql.Tx) error
+		DeleteByRules([]uint64, *sql.Tx) error
+	}
+
+	RuleRepository interface {
+		Find(*RuleQuery) ([]*tracking.Rule, error)
+		Count(*RuleFilter) (uint64, error)
+		Get(uint64) (*tracking.Rule, error)
+		Create(*tracking.Rule, *sql.Tx) (uint64, error)
+		Update(*tracking.Rule, *sql.Tx) error
+		Delete(uint64, *sql.Tx) error
+	}
+
+	// CandidateRepository stores peripherals matched by rules, there is at most one candidate per key
+	CandidateRepository interface {
+		Find(*CandidateQuery) ([]*tracking.Candidate, error)
+		Count(*CandidateFilter) (uint64, error)
+		Get(uint64) (*tracking.Candidate, error)
+		GetByKey(string) (*tracking.Candidate, error)
+		Create(*tracking.Candidate, *sql.Tx) (uint64, error)
+		Update(*tracking.Candidate, *sql.Tx) error
+		Delete(uint64, *sql.Tx) error
+	}
+
+	GroupRepository interface {
+		Find(*GroupQuery) ([]*tracking.Group, error)
+		FindByMember(*MemberFilter) ([]*tracking.Group, error)
+		Count(*GroupFilter) (uint64, error)
+		Get(uint64) (*tracking.Group, error)
+		Create(*tracking.Group, *sql.Tx) (uint64, error)
+		Update(*tracking.Group, *sql.Tx) error
+		Delete(uint64, *sql.Tx) error
+		GetMembers(uint64) ([]uint64, error)
+		SetMembers(uint64, []uint64, *sql.Tx) error
+		DeleteMembers([]uint64, *sql.Tx) error
 	}
 
 	EndpointRepository interface {
@@ -79,20 +191,51 @@ type (
 		DeleteMany(*DeletionQuery, *sql.Tx) error
 	}
 
+	UserRepository interface {
+		Find(*Pagination) ([]*auth.User, error)
+		Count() (uint64, error)
+		Get(uint64) (*auth.User, error)
+		GetByName(string) (*auth.User, error)
+		Create(*auth.User, *sql.Tx) (uint64, error)
+		Update(*auth.User, *sql.Tx) error
+		Delete(uint64, *sql.Tx) error
+	}
+
+	ApiKeyRepository interface {
+		FindByUser(uint64) ([]*auth.ApiKey, error)
+		Get(uint64) (*auth.ApiKey, error)
+		GetByHash(string) (*auth.ApiKey, error)
+		Create(*auth.ApiKey, *sql.Tx) (uint64, error)
+		Delete(uint64, *sql.Tx) error
+		DeleteByUser(uint64, *sql.Tx) error
+	}
+
+	SessionRepository interface {
+		GetByHash(string) (*auth.Session, error)
+		Create(*auth.Session, *sql.Tx) (uint64, error)
+		DeleteByHash(string, *sql.Tx) error
+		DeleteExpired(time.Time, *sql.Tx) error
+		DeleteByUser(uint64, *sql.Tx) error
+	}
+
 	ActivityHistoryRepository interface{}
 
 	DeliveryHistoryRepository interface{}
 )
 
 func NewPagination(take, skip uint64) *Pagination {
-	return &Pagination{take, skip}
+	return &Pagination{Take: take, Skip: skip}
+}
+
+func NewSorting(field string, descending bool) *Sorting {
+	return &Sorting{field, descending}
 }
 
 func NewTargetQuery(take, skip uint64, status string) *PeripheralQuery {
 	return &PeripheralQuery{
 		Pagination: NewPagination(take, skip),
 		PeripheralFilter: &PeripheralFilter{
-			status,
+			Status: status,
 		},
 	}
 }
@@ -110,9 +253,39 @@ func NewSubscriberQuery(take, skip, targetId uint64, events []string, status str
 	return &SubscriberQuery{
 		Pagination: NewPagination(take, skip),
 		SubscriberFilter: &SubscriberFilter{
-			targetId,
-			events,
-			status,
+			TargetId: targetId,
+			Events:   events,
+			Status:   status,
+		},
+	}
+}
+
+func NewGroupSubscriberQuery(take, skip, groupId uint64, events []string, status string) *SubscriberQuery {
+	return &SubscriberQuery{
+		Pagination: NewPagination(take, skip),
+		SubscriberFilter: &SubscriberFilter{
+			GroupId: groupId,
+			Events:  events,
+			Status:  status,
+		},
+	}
+}
+
+func NewRuleSubscriberQuery(ruleId uint64) *SubscriberQuery {
+	return &SubscriberQuery{
+		Pagination: NewPagination(0, 0),
+		SubscriberFilter: &SubscriberFilter{
+			RuleId: ruleId,
+			Status: PERIPHERAL_STATUS_ANY,
+		},
+	}
+}
+
+func NewGroupQuery(take, skip uint64, status string) *GroupQuery {
+	return &GroupQuery{
+		Pagination: NewPagination(take, skip),
+		GroupFilter: &GroupFilter{
+			Status: status,
 		},
 	}
 }
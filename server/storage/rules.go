@@ -0,0 +1,445 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrRuleNotFound      = errors.Wrap(ErrNotFound, "rule")
+	ErrCandidateNotFound = errors.Wrap(ErrNotFound, "candidate")
+	ErrCandidateDecided  = errors.Wrap(ErrConflict, "candidate is already decided")
+)
+
+func (m *Manager) FindRules(query *RuleQuery) ([]*tracking.Rule, uint64, error) {
+	defer m.observe("find_rules", time.Now())
+
+	res, err := m.rules.Find(query)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := m.rules.Count(query.RuleFilter)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return res, count, nil
+}
+
+// GetEnabledRules returns all rules applied to unregistered peripherals
+func (m *Manager) GetEnabledRules() ([]*tracking.Rule, error) {
+	defer m.observe("get_enabled_rules", time.Now())
+
+	return m.rules.Find(&RuleQuery{
+		Pagination: NewPagination(0, 0),
+		RuleFilter: &RuleFilter{Status: PERIPHERAL_STATUS_ENABLED},
+	})
+}
+
+// GetRuleWithSubscribers returns a rule with subscribers copied to peripherals registered by it
+func (m *Manager) GetRuleWithSubscribers(id uint64) (*tracking.Rule, []*notification.Subscriber, error) {
+	defer m.observe("get_rule_with_subscribers", time.Now())
+
+	rule, err := m.rules.Get(id)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if rule == nil {
+		return nil, nil, nil
+	}
+
+	subscribers, err := m.subscribers.Find(NewRuleSubscriberQuery(id))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rule, subscribers, nil
+}
+
+func (m *Manager) CreateRule(rule *tracking.Rule, subscribers []*notification.Subscriber) (uint64, error) {
+	defer m.observe("create_rule", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := m.rules.Create(rule, tx)
+
+	if err != nil {
+		return 0, TryToRollback(tx, err, true)
+	}
+
+	if len(subscribers) > 0 {
+		err = m.subscribers.CreateManyForRule(subscribers, id, tx)
+
+		if err != nil {
+			return 0, TryToRollback(tx, err, true)
+		}
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return 0, err
+	}
+
+	m.changed(ENTITY_RULE, id)
+
+	return id, nil
+}
+
+func (m *Manager) UpdateRule(rule *tracking.Rule, subscribers []*notification.Subscriber) error {
+	defer m.observe("update_rule", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	err = m.rules.Update(rule, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.saveSubscribers(subscribers, 0, 0, rule.Id, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_RULE, rule.Id)
+
+	return nil
+}
+
+// DeleteRules deletes rules with their subscribers, candidates are kept as an audit trail
+func (m *Manager) DeleteRules(ids []uint64) error {
+	defer m.observe("delete_rules", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	err = m.subscribers.DeleteByRules(ids, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	for _, id := range ids {
+		if err := m.rules.Delete(id, tx); err != nil {
+			return TryToRollback(tx, err, true)
+		}
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_RULE, ids...)
+
+	return nil
+}
+
+// FindCandidates returns a page of candidates starting from the latest one and a total quantity of matched ones
+func (m *Manager) FindCandidates(query *CandidateQuery) ([]*tracking.Candidate, uint64, error) {
+	defer m.observe("find_candidates", time.Now())
+
+	res, err := m.candidates.Find(query)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := m.candidates.Count(query.CandidateFilter)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return res, count, nil
+}
+
+func (m *Manager) GetCandidate(id uint64) (*tracking.Candidate, error) {
+	defer m.observe("get_candidate", time.Now())
+
+	return m.candidates.Get(id)
+}
+
+// GetCandidateByKey returns a candidate of a peripheral, peripherals with candidates are not matched by rules again
+func (m *Manager) GetCandidateByKey(key string) (*tracking.Candidate, error) {
+	defer m.observe("get_candidate_by_key", time.Now())
+
+	return m.candidates.GetByKey(key)
+}
+
+// ApplyRule adds a peripheral to the review queue of a rule or, if the rule accepts peripherals, registers it.
+// It returns a registration or nil if the peripheral is queued, already has a candidate or fails to register.
+// Failures, e.g. names taken by other peripherals, are recorded by candidates.
+func (m *Manager) ApplyRule(rule *tracking.Rule, peripheral peripherals.Peripheral) (*tracking.Peripheral, error) {
+	defer m.observe("apply_rule", time.Now())
+
+	candidate := &tracking.Candidate{
+		RuleId:    rule.Id,
+		Key:       peripheral.UniqueKey(),
+		Kind:      peripheral.Kind(),
+		Address:   peripheral.Address(),
+		Name:      rule.RenderName(peripheral),
+		Status:    tracking.CANDIDATE_STATUS_PENDING,
+		CreatedAt: time.Now(),
+	}
+
+	if rule.Mode != tracking.RULE_MODE_ACCEPT {
+		return nil, m.createCandidate(candidate, nil)
+	}
+
+	templates, err := m.subscribers.Find(NewRuleSubscriberQuery(rule.Id))
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := m.register(candidate, rule, templates, tx)
+
+	if err == nil {
+		err = m.createCandidate(candidate, tx)
+	}
+
+	if err != nil {
+		if errors.Cause(err) != ErrConflict {
+			return nil, TryToRollback(tx, err, true)
+		}
+
+		if err := tx.Rollback(); err != nil {
+			return nil, err
+		}
+
+		candidate.Status = tracking.CANDIDATE_STATUS_FAILED
+		candidate.TargetId = 0
+		candidate.Message = err.Error()
+
+		return nil, m.createCandidate(candidate, nil)
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.changed(ENTITY_PERIPHERAL, target.Id)
+
+	return target, nil
+}
+
+// ApproveCandidate registers a pending or failed candidate, a non-empty name replaces the rendered one
+func (m *Manager) ApproveCandidate(id uint64, name, reviewer string) (*tracking.Candidate, error) {
+	defer m.observe("approve_candidate", time.Now())
+
+	candidate, err := m.undecidedCandidate(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// rules can be deleted while their candidates are pending
+	rule, templates, err := m.GetRuleWithSubscribers(candidate.RuleId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rule == nil {
+		rule = &tracking.Rule{PeripheralEnabled: true}
+	}
+
+	if name != "" {
+		candidate.Name = name
+	}
+
+	candidate.Reviewer = reviewer
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := m.register(candidate, rule, templates, tx)
+
+	if err == nil {
+		err = m.candidates.Update(candidate, tx)
+	}
+
+	if err != nil {
+		return nil, TryToRollback(tx, err, true)
+	}
+
+	err = TryToCommit(tx, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.changed(ENTITY_PERIPHERAL, target.Id)
+	m.changed(ENTITY_CANDIDATE, candidate.Id)
+
+	return candidate, nil
+}
+
+// RejectCandidate keeps a pending or failed candidate from being registered
+func (m *Manager) RejectCandidate(id uint64, reviewer string) (*tracking.Candidate, error) {
+	defer m.observe("reject_candidate", time.Now())
+
+	candidate, err := m.undecidedCandidate(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	decidedAt := time.Now()
+
+	candidate.Status = tracking.CANDIDATE_STATUS_REJECTED
+	candidate.Reviewer = reviewer
+	candidate.DecidedAt = &decidedAt
+
+	if err := m.candidates.Update(candidate, nil); err != nil {
+		return nil, err
+	}
+
+	m.changed(ENTITY_CANDIDATE, candidate.Id)
+
+	return candidate, nil
+}
+
+// DeleteCandidate deletes a candidate, so that rules match its peripheral again
+func (m *Manager) DeleteCandidate(id uint64) error {
+	defer m.observe("delete_candidate", time.Now())
+
+	err := m.candidates.Delete(id, nil)
+
+	if err == ErrNotFound {
+		return ErrCandidateNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_CANDIDATE, id)
+
+	return nil
+}
+
+func (m *Manager) undecidedCandidate(id uint64) (*tracking.Candidate, error) {
+	candidate, err := m.candidates.Get(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate == nil {
+		return nil, ErrCandidateNotFound
+	}
+
+	if candidate.Status != tracking.CANDIDATE_STATUS_PENDING && candidate.Status != tracking.CANDIDATE_STATUS_FAILED {
+		return nil, ErrCandidateDecided
+	}
+
+	return candidate, nil
+}
+
+// createCandidate skips peripherals which already have candidates, e.g. created by a concurrent event.
+// New candidates are not announced as changes, since they do not change any lookups
+// and every unregistered peripheral in the review mode would flush caches otherwise.
+func (m *Manager) createCandidate(candidate *tracking.Candidate, tx *sql.Tx) error {
+	id, err := m.candidates.Create(candidate, tx)
+
+	if errors.Cause(err) == ErrConflict {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	candidate.Id = id
+
+	return nil
+}
+
+// register creates a peripheral of a candidate with copies of subscribers of a rule
+func (m *Manager) register(candidate *tracking.Candidate, rule *tracking.Rule, templates []*notification.Subscriber, tx *sql.Tx) (*tracking.Peripheral, error) {
+	target := &tracking.Peripheral{
+		Key:     candidate.Key,
+		Name:    candidate.Name,
+		Kind:    candidate.Kind,
+		Enabled: rule.PeripheralEnabled,
+	}
+
+	id, err := m.peripherals.Create(target, tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	target.Id = id
+
+	if len(templates) > 0 {
+		subscribers := make([]*notification.Subscriber, 0, len(templates))
+
+		for _, template := range templates {
+			subscribers = append(subscribers, &notification.Subscriber{
+				Name:     template.Name,
+				Event:    template.Event,
+				Enabled:  template.Enabled,
+				Batch:    template.Batch,
+				Endpoint: template.Endpoint,
+			})
+		}
+
+		if err := m.subscribers.CreateMany(subscribers, id, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	decidedAt := time.Now()
+
+	candidate.Status = tracking.CANDIDATE_STATUS_REGISTERED
+	candidate.TargetId = id
+	candidate.Message = ""
+	candidate.DecidedAt = &decidedAt
+
+	return target, nil
+}
@@ -2,7 +2,7 @@ package storage
 
 type (
 	Settings struct {
-		Provider         string
-		ConnectionString string
+		Provider         string `yaml:"provider"`
+		ConnectionString string `yaml:"connection"`
 	}
 )
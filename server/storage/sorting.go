@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/pkg/errors"
+)
+
+const (
+	SORT_ID      = "id"
+	SORT_NAME    = "name"
+	SORT_KEY     = "key"
+	SORT_KIND    = "kind"
+	SORT_ENABLED = "enabled"
+	SORT_URL     = "url"
+	SORT_METHOD  = "method"
+	SORT_EVENT   = "event"
+)
+
+var (
+	PeripheralSortFields = []string{SORT_ID, SORT_NAME, SORT_KEY, SORT_KIND, SORT_ENABLED}
+	EndpointSortFields   = []string{SORT_ID, SORT_NAME, SORT_KIND, SORT_URL, SORT_METHOD}
+	SubscriberSortFields = []string{SORT_ID, SORT_NAME, SORT_EVENT, SORT_ENABLED}
+
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Cursor points to the last item of a page by a value of the sort field and the id
+type Cursor struct {
+	Field string      `json:"f"`
+	Value interface{} `json:"v,omitempty"`
+	Id    uint64      `json:"i"`
+}
+
+func NewCursor(field string, value interface{}, id uint64) *Cursor {
+	return &Cursor{field, value, id}
+}
+
+// ParseCursor decodes a cursor returned with a previous page
+func ParseCursor(str string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(str)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &Cursor{}
+
+	if err := json.Unmarshal(data, cursor); err != nil || cursor.Id == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	switch cursor.Value.(type) {
+	case nil, string, float64:
+		return cursor, nil
+	default:
+		return nil, ErrInvalidCursor
+	}
+}
+
+func (c *Cursor) String() string {
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// SortField returns a field items are sorted by, id by default
+func (s *Sorting) SortField() string {
+	if s == nil || s.Field == "" {
+		return SORT_ID
+	}
+
+	return s.Field
+}
+
+func (s *Sorting) IsDescending() bool {
+	return s != nil && s.Descending
+}
+
+// nextCursor returns a cursor of the last item if a page is full, so there may be more items
+func nextCursor(pagination *Pagination, size int, next func() *Cursor) *Cursor {
+	if pagination == nil || pagination.Take == 0 || uint64(size) < pagination.Take {
+		return nil
+	}
+
+	return next()
+}
+
+func peripheralCursor(peripheral *tracking.Peripheral, field string) *Cursor {
+	var value interface{}
+
+	switch field {
+	case SORT_NAME:
+		value = peripheral.Name
+	case SORT_KEY:
+		value = peripheral.Key
+	case SORT_KIND:
+		value = peripheral.Kind
+	case SORT_ENABLED:
+		value = boolToNumber(peripheral.Enabled)
+	}
+
+	return NewCursor(field, value, peripheral.Id)
+}
+
+func endpointCursor(endpoint *notification.Endpoint, field string) *Cursor {
+	var value interface{}
+
+	switch field {
+	case SORT_NAME:
+		value = endpoint.Name
+	case SORT_KIND:
+		value = endpoint.Kind
+	case SORT_URL:
+		value = endpoint.Url
+	case SORT_METHOD:
+		value = endpoint.Method
+	}
+
+	return NewCursor(field, value, endpoint.Id)
+}
+
+func subscriberCursor(subscriber *notification.Subscriber, field string) *Cursor {
+	var value interface{}
+
+	switch field {
+	case SORT_NAME:
+		value = subscriber.Name
+	case SORT_EVENT:
+		value = subscriber.Event
+	case SORT_ENABLED:
+		value = boolToNumber(subscriber.Enabled)
+	}
+
+	return NewCursor(field, value, subscriber.Id)
+}
+
+// boolToNumber returns a number as it is stored and as it is decoded from a cursor
+func boolToNumber(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
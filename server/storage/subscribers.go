@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+)
+
+var ErrSubscriberNotFound = errors.Wrap(ErrNotFound, "subscriber")
+
+// FindSubscribers returns a page of subscribers of peripherals and groups,
+// a total quantity of matched ones and a cursor of the next page
+func (m *Manager) FindSubscribers(query *SubscriberQuery) ([]*notification.Subscriber, uint64, *Cursor, error) {
+	defer m.observe("find_subscribers", time.Now())
+
+	res, err := m.subscribers.Find(query)
+
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	count, err := m.subscribers.Count(query.SubscriberFilter)
+
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	next := nextCursor(query.Pagination, len(res), func() *Cursor {
+		return subscriberCursor(res[len(res)-1], query.Sorting.SortField())
+	})
+
+	return res, count, next, nil
+}
+
+// GetEndpointSubscribers returns all subscribers pointing at an endpoint
+func (m *Manager) GetEndpointSubscribers(endpointId uint64) ([]*notification.Subscriber, error) {
+	defer m.observe("get_endpoint_subscribers", time.Now())
+
+	return m.subscribers.Find(&SubscriberQuery{
+		Pagination: NewPagination(0, 0),
+		SubscriberFilter: &SubscriberFilter{
+			EndpointId: endpointId,
+			Status:     PERIPHERAL_STATUS_ANY,
+		},
+	})
+}
+
+func (m *Manager) GetSubscriber(id uint64) (*notification.Subscriber, error) {
+	defer m.observe("get_subscriber", time.Now())
+
+	return m.subscribers.Get(id)
+}
+
+// CreateSubscriber creates a subscriber of a peripheral or a group given by TargetId or GroupId
+func (m *Manager) CreateSubscriber(subscriber *notification.Subscriber) (uint64, error) {
+	defer m.observe("create_subscriber", time.Now())
+
+	id, err := m.subscribers.Create(subscriber, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	m.changed(ENTITY_SUBSCRIBER, id)
+
+	return id, nil
+}
+
+// UpdateSubscriber updates a subscriber, it stays with its peripheral or group
+func (m *Manager) UpdateSubscriber(subscriber *notification.Subscriber) error {
+	defer m.observe("update_subscriber", time.Now())
+
+	existing, err := m.subscribers.Get(subscriber.Id)
+
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		return ErrSubscriberNotFound
+	}
+
+	subscriber.TargetId = existing.TargetId
+	subscriber.GroupId = existing.GroupId
+
+	err = m.subscribers.Update(subscriber, nil)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_SUBSCRIBER, subscriber.Id)
+
+	return nil
+}
+
+// SetSubscribersEnabled enables or disables many subscribers at once
+func (m *Manager) SetSubscribersEnabled(ids []uint64, enabled bool) error {
+	defer m.observe("set_subscribers_enabled", time.Now())
+
+	err := m.subscribers.SetEnabled(ids, enabled, nil)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_SUBSCRIBER, ids...)
+
+	return nil
+}
+
+func (m *Manager) DeleteSubscriber(id uint64) error {
+	defer m.observe("delete_subscriber", time.Now())
+
+	err := m.subscribers.Delete(id, nil)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_SUBSCRIBER, id)
+
+	return nil
+}
+
+func (m *Manager) DeleteSubscribers(ids []uint64) error {
+	defer m.observe("delete_subscribers", time.Now())
+
+	err := m.subscribers.DeleteMany(&DeletionQuery{
+		Id:      ids,
+		InRange: true,
+	}, nil)
+
+	if err != nil {
+		return err
+	}
+
+	m.changed(ENTITY_SUBSCRIBER, ids...)
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"github.com/blent/beagle/pkg/auth"
+	"time"
+)
+
+func (m *Manager) FindUsers(pagination *Pagination) ([]*auth.User, uint64, error) {
+	defer m.observe("find_users", time.Now())
+
+	res, err := m.users.Find(pagination)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := m.users.Count()
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return res, count, nil
+}
+
+func (m *Manager) GetUser(id uint64) (*auth.User, error) {
+	defer m.observe("get_user", time.Now())
+
+	return m.users.Get(id)
+}
+
+func (m *Manager) GetUserByName(username string) (*auth.User, error) {
+	defer m.observe("get_user_by_name", time.Now())
+
+	return m.users.GetByName(username)
+}
+
+func (m *Manager) CountUsers() (uint64, error) {
+	defer m.observe("count_users", time.Now())
+
+	return m.users.Count()
+}
+
+func (m *Manager) CreateUser(user *auth.User) (uint64, error) {
+	defer m.observe("create_user", time.Now())
+
+	return m.users.Create(user, nil)
+}
+
+// UpdateUser updates a user and optionally ends all its sessions
+func (m *Manager) UpdateUser(user *auth.User, endSessions bool) error {
+	defer m.observe("update_user", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	err = m.users.Update(user, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	if endSessions {
+		err = m.sessions.DeleteByUser(user.Id, tx)
+
+		if err != nil {
+			return TryToRollback(tx, err, true)
+		}
+	}
+
+	return TryToCommit(tx, true)
+}
+
+// DeleteUser deletes a user with all its sessions and api keys
+func (m *Manager) DeleteUser(id uint64) error {
+	defer m.observe("delete_user", time.Now())
+
+	tx, err := m.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	err = m.sessions.DeleteByUser(id, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.apiKeys.DeleteByUser(id, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	err = m.users.Delete(id, tx)
+
+	if err != nil {
+		return TryToRollback(tx, err, true)
+	}
+
+	return TryToCommit(tx, true)
+}
+
+func (m *Manager) FindApiKeys(userId uint64) ([]*auth.ApiKey, error) {
+	defer m.observe("find_api_keys", time.Now())
+
+	return m.apiKeys.FindByUser(userId)
+}
+
+func (m *Manager) GetApiKey(id uint64) (*auth.ApiKey, error) {
+	defer m.observe("get_api_key", time.Now())
+
+	return m.apiKeys.Get(id)
+}
+
+func (m *Manager) GetApiKeyByHash(hash string) (*auth.ApiKey, error) {
+	defer m.observe("get_api_key_by_hash", time.Now())
+
+	return m.apiKeys.GetByHash(hash)
+}
+
+func (m *Manager) CreateApiKey(key *auth.ApiKey) (uint64, error) {
+	defer m.observe("create_api_key", time.Now())
+
+	return m.apiKeys.Create(key, nil)
+}
+
+func (m *Manager) DeleteApiKey(id uint64) error {
+	defer m.observe("delete_api_key", time.Now())
+
+	return m.apiKeys.Delete(id, nil)
+}
+
+func (m *Manager) CreateSession(session *auth.Session) (uint64, error) {
+	defer m.observe("create_session", time.Now())
+
+	return m.sessions.Create(session, nil)
+}
+
+func (m *Manager) GetSessionByHash(hash string) (*auth.Session, error) {
+	defer m.observe("get_session_by_hash", time.Now())
+
+	return m.sessions.GetByHash(hash)
+}
+
+func (m *Manager) DeleteSessionByHash(hash string) error {
+	defer m.observe("delete_session_by_hash", time.Now())
+
+	return m.sessions.DeleteByHash(hash, nil)
+}
+
+func (m *Manager) DeleteExpiredSessions(now time.Time) error {
+	defer m.observe("delete_expired_sessions", time.Now())
+
+	return m.sessions.DeleteExpired(now, nil)
+}